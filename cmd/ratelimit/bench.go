@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/benchmarks"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/check"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/headers"
+)
+
+// runBench runs the benchmarks of the ratelimiter/benchmarks package and prints
+// their results in the format of go test -bench, or drives a limiter at a
+// target rate for a duration and prints its throughput, latency and
+// allocations.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	var limiter limiterFlags
+	limiter.register(fs)
+	pattern := fs.String("bench", ".", "regular expression selecting the benchmarks to run")
+	duration := fs.Duration("duration", 0, "duration of the load driven through the limiter instead of running the benchmarks, none if zero")
+	qps := fs.Float64("qps", 0, "target number of decisions per second of the load, as many as possible if zero")
+	concurrency := fs.Int("concurrency", runtime.GOMAXPROCS(0), "number of goroutines making the decisions of the load")
+	keys := fs.Int("keys", 1000, "number of keys the decisions of the load are spread over")
+	url := fs.String("url", "", "URL of a server to send the requests of the load to instead of the limiter, a 429 response being a denial")
+	checkURL := fs.String("check", "", "URL of a decision server to check the keys of the load with instead of the limiter, e.g. http://localhost:8080/check")
+	fs.Parse(args)
+
+	if *duration > 0 {
+		var rateLimiter ratelimiter.Limiter
+		policy := limiter.String()
+		// Every goroutine of the load keeps its connection to the server, so
+		// the load does not measure new connections.
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = max(transport.MaxIdleConns, *concurrency)
+		transport.MaxIdleConnsPerHost = *concurrency
+		client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+		switch {
+		case *checkURL != "":
+			rateLimiter = check.NewClient(*checkURL, client)
+			policy = *checkURL
+		case *url != "":
+			rateLimiter = &remoteLimiter{client: client, url: *url}
+			policy = *url
+		default:
+			var err error
+			if rateLimiter, err = limiter.newLimiter(); err != nil {
+				return err
+			}
+		}
+		load := benchmarks.Load{Rate: *qps, Concurrency: *concurrency, Duration: *duration, Keys: *keys}
+		return printLoad(policy, benchmarks.RunLoad(rateLimiter, load))
+	}
+
+	re, err := regexp.Compile(*pattern)
+	if err != nil {
+		return fmt.Errorf("invalid benchmark pattern %q: %w", *pattern, err)
+	}
+
+	for _, b := range benchmarks.All() {
+		if !re.MatchString(b.Name) {
+			continue
+		}
+		result := testing.Benchmark(b.F)
+		fmt.Printf("%-40s %s %s\n", b.Name, result, result.MemString())
+	}
+	return nil
+}
+
+// printLoad prints the result of the load driven through the limiter described
+// by policy.
+func printLoad(policy string, result *benchmarks.LoadResult) error {
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "limiter\t%s\n", policy)
+	fmt.Fprintf(table, "decisions\t%d in %s\n", result.Decisions, result.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(table, "decisions/sec\t%.0f\n", result.Throughput())
+	fmt.Fprintf(table, "allowed\t%d\n", result.Allowed)
+	fmt.Fprintf(table, "denied\t%d\n", result.Decisions-result.Allowed)
+	if result.Failures > 0 {
+		fmt.Fprintf(table, "failures\t%d\n", result.Failures)
+	}
+	for _, p := range []float64{50, 90, 99, 99.9, 100} {
+		fmt.Fprintf(table, "latency p%g\t%s\n", p, result.Latency(p))
+	}
+	fmt.Fprintf(table, "allocs/decision\t%.1f\n", result.AllocsPerDecision())
+	fmt.Fprintf(table, "bytes/decision\t%.0f\n", result.BytesPerDecision())
+	return table.Flush()
+}
+
+// remoteLimiter is a Limiter sending a request to a server for every decision,
+// the server keying them itself, e.g. by client IP address.
+type remoteLimiter struct {
+	client *http.Client // The client sending the requests.
+	url    string       // The URL the requests are sent to.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (l *remoteLimiter) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return l.AllowN(key, requestTime, 1)
+}
+
+// AllowN sends a request to the server, denied if it answers 429 Too Many
+// Requests, or with ratelimiter.ReasonBackendFailure if it fails otherwise.
+func (l *remoteLimiter) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	resp, err := l.client.Get(l.url)
+	if err != nil {
+		return ratelimiter.Decision{Reason: ratelimiter.ReasonBackendFailure}
+	}
+	// The body is read to its end so the connection is reused.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	status := headers.Parse(resp.Header, time.Now())
+	decision := ratelimiter.Decision{
+		Allowed:    resp.StatusCode < 400,
+		Limit:      status.Limit,
+		Remaining:  status.Remaining,
+		ResetAfter: status.Reset,
+		RetryAfter: status.RetryAfter,
+		Window:     status.Window,
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		decision.Reason = ratelimiter.ReasonRateLimit
+	case !decision.Allowed:
+		decision.Reason = ratelimiter.ReasonBackendFailure
+	}
+	return decision
+}
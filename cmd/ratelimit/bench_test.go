@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// loadRow returns the fields of the row of the load results named name.
+func loadRow(out, name string) []string {
+	for line := range strings.SplitSeq(out, "\n") {
+		value, ok := strings.CutPrefix(line, name+" ")
+		if ok {
+			return strings.Fields(value)
+		}
+	}
+	return nil
+}
+
+func TestBenchLoad(t *testing.T) {
+	out, err := run(t, runBench, "", "-duration", "200ms", "-qps", "500", "-concurrency", "2", "-keys", "1", "-rate", "10", "-window", "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := loadRow(out, "limiter"); len(row) != 1 || row[0] != "sliding-window:10/1h0m0s" {
+		t.Errorf("limiter %q, want the flags", row)
+	}
+	// A single key allows the rate, and denies the rest of the load.
+	if row := loadRow(out, "allowed"); len(row) != 1 || row[0] != "10" {
+		t.Errorf("allowed %q, want 10 in\n%s", row, out)
+	}
+	for _, name := range []string{"decisions", "decisions/sec", "denied", "latency p99", "allocs/decision"} {
+		if loadRow(out, name) == nil {
+			t.Errorf("no %s row in\n%s", name, out)
+		}
+	}
+}
+
+func TestRemoteLimiter(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	server := httptest.NewServer(ratelimiter.Middleware(limiter)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	defer server.Close()
+
+	remote := &remoteLimiter{client: server.Client(), url: server.URL}
+	if decision := remote.Allow("ignored", time.Now()); !decision.Allowed || decision.Limit != 1 || decision.Remaining != 0 {
+		t.Errorf("first decision %+v, want allowed with the headers of the server", decision)
+	}
+	if decision := remote.Allow("ignored", time.Now()); decision.Allowed || decision.Reason != ratelimiter.ReasonRateLimit || decision.RetryAfter <= 0 {
+		t.Errorf("second decision %+v, want denied by the server", decision)
+	}
+	server.Close()
+	if decision := remote.Allow("ignored", time.Now()); decision.Allowed || decision.Reason != ratelimiter.ReasonBackendFailure {
+		t.Errorf("decision %+v with the server down, want a backend failure", decision)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/config"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// runCheck validates a configuration file, builds its limiters and rules
+// without starting any server, and prints the effective policy of every rule,
+// then optionally replays sample traffic through the rules.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	path := fs.String("c", "", "configuration file to check, in YAML or JSON")
+	var input inputFlags
+	input.register(fs, replay.JSONL)
+	fs.Parse(args)
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "ratelimit check: missing -c")
+		fs.Usage()
+		os.Exit(2)
+	}
+	replaying := false
+	fs.Visit(func(fl *flag.Flag) { replaying = replaying || fl.Name == "input" })
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		var invalid config.ValidationError
+		if !errors.As(err, &invalid) {
+			return err
+		}
+		for _, fieldErr := range invalid {
+			fmt.Fprintln(os.Stderr, fieldErr)
+		}
+		return fmt.Errorf("%s: %d invalid fields", *path, len(invalid))
+	}
+
+	// The backends are not connected to: their limiters keep their state in
+	// memory, as the limiters without backend.
+	var opts []config.Option
+	for _, backend := range cfg.Backends {
+		opts = append(opts, config.WithBackend(backend.Type, func(_ config.Backend, newAlgorithm func() ratelimiter.Algorithm) (ratelimiter.Limiter, error) {
+			return ratelimiter.NewKeyed(newAlgorithm), nil
+		}))
+	}
+	graph, err := cfg.Build(opts...)
+	if err != nil {
+		return err
+	}
+	defer graph.Close()
+	policies := graph.Policies()
+	fmt.Printf("%s: valid, %d limiters, %d rules in %s mode\n\n", *path, len(cfg.Limiters), len(policies), orDefault(string(cfg.Mode), string(config.FirstMatch)))
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "rule\tpriority\tmethod\tpath\tmatch\tkey\tcost\tlimiter\tpolicy")
+	for _, p := range policies {
+		rule := p.Rule
+		priority := fmt.Sprint(rule.Priority)
+		if p.Default {
+			priority = "default"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rule.Name, priority, orDefault(rule.Method, "*"), rule.Path+"*", orDefault(rule.Match, "-"), orDefault(rule.Key, "ip"), orDefault(rule.Cost, "1"), rule.Limiter, formatPolicy(p))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if !replaying {
+		return nil
+	}
+
+	reader, closeInput, err := input.open()
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	fmt.Println()
+	return checkTraffic(os.Stdout, graph, policies, reader)
+}
+
+// checkTraffic replays the events of source through the rules of graph, as its
+// handler would at the time of the events, and prints the statistics of the
+// decisions of every rule to w. The requests are keyed by the key of their
+// rule, so the key of the events is only their client address, and cost what
+// the cost expression of their rule computes, if it has one. The requests the
+// configuration skips are counted apart.
+func checkTraffic(w io.Writer, graph *config.Graph, policies []config.Policy, source replay.Source) error {
+	stats := make(map[string]*replay.Stats, len(policies))
+	rules := make(map[string]config.Policy, len(policies))
+	for _, p := range policies {
+		stats[p.Rule.Name] = new(replay.Stats)
+		rules[p.Rule.Name] = p
+	}
+	var keyed []*ratelimiter.Keyed
+	for _, limiter := range graph.Limiters() {
+		if k, ok := limiter.(*ratelimiter.Keyed); ok {
+			keyed = append(keyed, k)
+		}
+	}
+	var pruner pruner
+	unmatched, skipped, invalid := 0, 0, 0
+
+	err := forEach(source, func(event replay.Event) error {
+		pruner.observe(event.Time, keyed...)
+		r, err := eventRequest(event)
+		if err != nil {
+			invalid++
+			return nil
+		}
+		explanation := graph.Explain(r)
+		if len(explanation.Applied) == 0 {
+			unmatched++
+			return nil
+		}
+		if graph.Skipped(r) {
+			skipped++
+			return nil
+		}
+		for _, rule := range explanation.Rules {
+			if !rule.Applied {
+				continue
+			}
+			p := rules[rule.Name]
+			cost := event.Cost
+			if p.CostFunc != nil {
+				cost = p.CostFunc(r)
+			}
+			limiter, _ := graph.Limiter(rule.Limiter)
+			decision := limiter.AllowN(p.KeyFunc(r), event.Time, cost)
+			stats[rule.Name].Observe(event, decision)
+			if !decision.Allowed {
+				// The rules after a denial never see the request.
+				break
+			}
+		}
+		return nil
+	}, func(err *replay.LineError) error {
+		fmt.Fprintf(os.Stderr, "ratelimit: skipping %v\n", err)
+		invalid++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "rule\trequests\tallowed\tdenied\tdenied %\t")
+	for _, p := range policies {
+		s := stats[p.Rule.Name]
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%.1f\t\n", p.Rule.Name, s.Total, s.Allowed, s.Denied, 100*s.DenialRatio())
+	}
+	fmt.Fprintf(table, "skipped\t%d\t%d\t\t\t\n", skipped, skipped)
+	fmt.Fprintf(table, "no rule\t%d\t%d\t\t\t\n", unmatched, unmatched)
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if invalid > 0 {
+		fmt.Fprintf(w, "%d invalid requests skipped\n", invalid)
+	}
+	return nil
+}
+
+// eventRequest returns the HTTP request of event, a GET of / by default, from
+// the client whose address is the key of the event.
+func eventRequest(event replay.Event) (*http.Request, error) {
+	r, err := http.NewRequest(orDefault(event.Method, http.MethodGet), orDefault(event.Path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.RemoteAddr = event.Key
+	return r, nil
+}
+
+// formatPolicy formats the limiter of p, e.g. "token-bucket 600/1m0s, burst
+// 100 (preset api-default)".
+func formatPolicy(p config.Policy) string {
+	l := p.Limiter
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d/%s", l.Algorithm, l.Rate, l.Window)
+	if l.Algorithm == ratelimiter.TokenBucketAlgorithm && l.Burst > 0 {
+		fmt.Fprintf(&b, ", burst %d", l.Burst)
+	}
+	if l.Algorithm == ratelimiter.CalendarWindowAlgorithm {
+		period := ratelimiter.Period(l.Period)
+		if period == "" {
+			period, _ = ratelimiter.PeriodOf(time.Duration(l.Window))
+		}
+		fmt.Fprintf(&b, ", per %s in %s", period, orDefault(l.Timezone, "UTC"))
+	}
+	if l.Backend != "" {
+		fmt.Fprintf(&b, ", backend %s of type %s", p.Backend.Name, p.Backend.Type)
+	}
+	if l.Shadow {
+		b.WriteString(", shadow")
+	}
+	if l.Preset != "" {
+		fmt.Fprintf(&b, " (preset %s)", l.Preset)
+	}
+	return b.String()
+}
+
+// orDefault returns s, or def if s is empty.
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/config"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+const checkConfig = `
+limiters:
+  - name: login
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+  - name: global
+    algorithm: sliding-window
+    rate: 3
+    window: 1h
+rules:
+  - name: login
+    path: /login
+    limiter: login
+    key: ip
+default:
+  limiter: global
+  key: global
+`
+
+// Two clients log in twice and browse, the login rule limits each client and
+// the default rule every client together.
+const checkTrafficInput = `{"ts": "2026-03-10T12:00:00Z", "key": "10.0.0.1", "path": "/login"}
+{"ts": "2026-03-10T12:00:01Z", "key": "10.0.0.1", "path": "/login"}
+{"ts": "2026-03-10T12:00:02Z", "key": "10.0.0.2", "path": "/login"}
+{"ts": "2026-03-10T12:00:03Z", "key": "10.0.0.2", "path": "/login"}
+{"ts": "2026-03-10T12:00:04Z", "key": "10.0.0.1", "path": "/"}
+{"ts": "2026-03-10T12:00:05Z", "key": "10.0.0.2", "path": "/"}
+{"ts": "2026-03-10T12:00:06Z", "key": "10.0.0.3", "path": "/"}
+{"ts": "2026-03-10T12:00:07Z", "key": "10.0.0.4", "path": "/"}
+`
+
+func TestCheckTraffic(t *testing.T) {
+	cfg, err := config.Parse([]byte(checkConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer graph.Close()
+	reader, err := replay.NewReader(strings.NewReader(checkTrafficInput), replay.JSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := checkTraffic(&out, graph, graph.Policies(), reader); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range [][]string{
+		{"login", "4", "2", "2", "50.0"},
+		{"default", "4", "3", "1", "25.0"},
+	} {
+		if !hasRow(out.String(), want) {
+			t.Errorf("no row %v in\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCheckTrafficSkipAndCost(t *testing.T) {
+	cfg, err := config.Parse([]byte(`
+skip:
+  paths: [/healthz]
+limiters:
+  - name: api
+    algorithm: sliding-window
+    rate: 10
+    window: 1h
+rules:
+  - name: uploads
+    path: /upload
+    cost: "5"
+    limiter: api
+    key: global
+default:
+  limiter: api
+  key: global
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer graph.Close()
+	reader, err := replay.NewReader(strings.NewReader(`{"ts": "2026-03-10T12:00:00Z", "key": "10.0.0.1", "path": "/healthz"}
+{"ts": "2026-03-10T12:00:01Z", "key": "10.0.0.1", "path": "/healthz"}
+{"ts": "2026-03-10T12:00:02Z", "key": "10.0.0.1", "path": "/upload"}
+{"ts": "2026-03-10T12:00:03Z", "key": "10.0.0.1", "path": "/upload"}
+{"ts": "2026-03-10T12:00:04Z", "key": "10.0.0.1", "path": "/upload"}
+{"ts": "2026-03-10T12:00:05Z", "key": "10.0.0.1", "path": "/"}
+`), replay.JSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := checkTraffic(&out, graph, graph.Policies(), reader); err != nil {
+		t.Fatal(err)
+	}
+	// The health checks consume nothing, and two uploads the whole budget.
+	for _, want := range [][]string{
+		{"uploads", "3", "2", "1", "33.3"},
+		{"default", "1", "0", "1", "100.0"},
+		{"skipped", "2", "2"},
+	} {
+		if !hasRow(out.String(), want) {
+			t.Errorf("no row %v in\n%s", want, out.String())
+		}
+	}
+}
+
+// hasRow reports whether a line of table holds exactly the fields of row.
+func hasRow(table string, row []string) bool {
+	for line := range strings.SplitSeq(table, "\n") {
+		if strings.Join(strings.Fields(line), " ") == strings.Join(row, " ") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheck(t *testing.T) {
+	path := writeInput(t, `
+backends:
+  - name: shared
+    type: redis
+limiters:
+  - name: login
+    preset: login-strict
+  - name: api
+    algorithm: token-bucket
+    rate: 600
+    window: 1m
+    burst: 100
+    backend: shared
+  - name: daily
+    algorithm: calendar-window
+    rate: 1000
+    window: 24h
+    timezone: Europe/Paris
+    shadow: true
+rules:
+  - name: login
+    path: /login
+    method: POST
+    limiter: login
+  - name: api
+    path: /api/
+    key: header:X-API-Key
+    limiter: api
+  - name: exports
+    path: /exports/
+    priority: 10
+    cost: "2"
+    limiter: daily
+`)
+	out, err := run(t, runCheck, "", "-c", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := path + ": valid, 3 limiters, 3 rules in first-match mode\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("output\n%s\nwant it to start with %q", out, want)
+	}
+	for _, row := range [][]string{
+		{"exports", "10", "*", "/exports/*", "-", "ip", "2", "daily", "calendar-window", "1000/24h0m0s,", "per", "day", "in", "Europe/Paris,", "shadow"},
+		{"login", "0", "POST", "/login*", "-", "ip", "1", "login", "sliding-window", "5/15m0s", "(preset", "login-strict)"},
+		{"api", "0", "*", "/api/*", "-", "header:X-API-Key", "1", "api", "token-bucket", "600/1m0s,", "burst", "100,", "backend", "shared", "of", "type", "redis"},
+	} {
+		if !hasRow(out, row) {
+			t.Errorf("no row %v in\n%s", row, out)
+		}
+	}
+
+	// The requests matching no rule are counted apart.
+	out, err = run(t, runCheck, "", "-c", path, "-input", writeInput(t, checkTrafficInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasRow(out, []string{"login", "0", "0", "0", "0.0"}) || !hasRow(out, []string{"no", "rule", "8", "8"}) {
+		t.Errorf("traffic of GET requests outside of the rules reported as\n%s", out)
+	}
+}
+
+func TestCheckInvalid(t *testing.T) {
+	path := writeInput(t, "limiters: [{name: api, algorithm: sliding-window, rate: 0, window: 1m}]\nrules: [{path: /, limiter: missing}]\n")
+	if _, err := run(t, runCheck, "", "-c", path); err == nil || err.Error() != path+": 2 invalid fields" {
+		t.Errorf("error %v, want the number of invalid fields", err)
+	}
+	if _, err := run(t, runCheck, "", "-c", path+".missing"); err == nil {
+		t.Error("missing file checked")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// runCompare runs the same requests through several limiters at once, and
+// prints a table comparing their decisions.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var defaults limiterFlags
+	defaults.register(fs)
+	var input inputFlags
+	input.register(fs, replay.Lines)
+	specs := fs.String("limiters", strings.Join(ratelimiter.Algorithms, ","), "comma-separated limiters to compare, as <algorithm>[:<rate>/<window>[:<burst>]], the omitted parts defaulting to the flags")
+	viz := fs.String("viz", "", "HTML file charting the decisions of every limiter over time, none if empty")
+	var summary summaryFlags
+	summary.register(fs)
+	fs.Parse(args)
+
+	type contender struct {
+		limiterFlags
+		limiter  *ratelimiter.Keyed
+		stats    replay.Stats
+		timeline *replay.Timeline
+	}
+	var contenders []*contender
+	for _, spec := range strings.Split(*specs, ",") {
+		f, err := parseLimiter(strings.TrimSpace(spec), defaults)
+		if err != nil {
+			return err
+		}
+		limiter, err := f.newLimiter()
+		if err != nil {
+			return fmt.Errorf("limiter %s: %w", spec, err)
+		}
+		contenders = append(contenders, &contender{limiterFlags: f, limiter: limiter, timeline: replay.NewTimeline(f.String())})
+	}
+
+	reader, closeInput, err := input.open()
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	limiters := make([]*ratelimiter.Keyed, len(contenders))
+	for i, c := range contenders {
+		limiters[i] = c.limiter
+	}
+	var pruner pruner
+	invalid := 0
+
+	// Every request of the input is decided by every limiter.
+	err = forEach(reader, func(event replay.Event) error {
+		pruner.observe(event.Time, limiters...)
+		for _, c := range contenders {
+			decision := c.limiter.AllowN(event.Key, event.Time, event.Cost)
+			c.stats.Observe(event, decision)
+			if *viz != "" {
+				c.timeline.Observe(event, decision)
+			}
+		}
+		return nil
+	}, func(err *replay.LineError) error {
+		if invalid++; invalid <= 10 {
+			fmt.Fprintf(os.Stderr, "Skipping %v\n", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "limiter\trequests\tallowed\tdenied\tdenied %\tdenial bursts\tmax burst/s\t")
+	for _, c := range contenders {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%.1f\t%d\t%d\t\n", c.String(), c.stats.Total, c.stats.Allowed, c.stats.Denied, 100*c.stats.DenialRatio(), c.stats.DenialBursts, c.stats.MaxBurst)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if invalid > 0 {
+		fmt.Printf("%d invalid lines skipped\n", invalid)
+	}
+	var timelines []*replay.Timeline
+	summaries := []replay.Summary{}
+	for _, c := range contenders {
+		timelines = append(timelines, c.timeline)
+		summaries = append(summaries, c.stats.Summarize(c.String()))
+	}
+	if *viz != "" {
+		if err := writeViz(*viz, timelines...); err != nil {
+			return err
+		}
+	}
+	return summary.finish(summaries, summaries...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// compareInput is a burst of 4 requests then one every 10s.
+const compareInput = `2022-01-20T00:13:00Z
+2022-01-20T00:13:00Z
+2022-01-20T00:13:00Z
+2022-01-20T00:13:00Z
+2022-01-20T00:13:10Z
+2022-01-20T00:13:20Z
+2022-01-20T00:13:30Z
+`
+
+func TestCompare(t *testing.T) {
+	out, err := run(t, runCompare, compareInput, "-limiters", "sliding-window, token-bucket:6/1m:4", "-rate", "3", "-window", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]string{
+		{"sliding-window:3/1m0s", "7", "3", "4", "57.1", "1", "3"},
+		{"token-bucket:6/1m0s:4", "7", "7", "0", "0.0", "0", "4"},
+	} {
+		if !hasRow(out, row) {
+			t.Errorf("no row %v in\n%s", row, out)
+		}
+	}
+	if _, err := run(t, runCompare, "", "-limiters", "token-bucket:0/1m"); err == nil {
+		t.Error("limiter of rate 0 compared")
+	}
+}
+
+func TestCompareInvalidLines(t *testing.T) {
+	input := "not a time\n" + compareInput + "2022-13-45T00:00:00Z\n"
+	out, err := run(t, runCompare, input, "-limiters", "sliding-window:3/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasRow(out, []string{"sliding-window:3/1m0s", "7", "3", "4", "57.1", "1", "3"}) {
+		t.Errorf("no row of the valid requests in\n%s", out)
+	}
+	if !strings.Contains(out, "2 invalid lines skipped") {
+		t.Errorf("output\n%s\nwithout the count of the invalid lines", out)
+	}
+}
+
+func TestCompareViz(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeline.html")
+	if _, err := run(t, runCompare, compareInput, "-limiters", "sliding-window,leaky-bucket", "-viz", path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"sliding-window:3/1h0m0s", "leaky-bucket:3/1h0m0s"} {
+		if !strings.Contains(string(data), title) {
+			t.Errorf("chart without timeline of %s", title)
+		}
+	}
+}
+
+func TestCompareSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+	_, err := run(t, runCompare, compareInput, "-limiters", "token-bucket:6/1m:4,sliding-window:3/1m", "-summary", path, "-fail-over", "0.5")
+	// The sliding window denies 4 of the 7 requests.
+	var exit *exitError
+	if !errors.As(err, &exit) || exit.code != 3 || !strings.Contains(err.Error(), "sliding-window:3/1m0s denied 57.1% of the requests, over 50.0%") {
+		t.Errorf("error %v, want exiting with code 3 for the sliding window", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []replay.Summary
+	if err := json.Unmarshal(data, &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].Limiter != "token-bucket:6/1m0s:4" || summaries[0].Denied != 0 || summaries[1].Denied != 4 || summaries[1].DenialRatio != 4.0/7 {
+		t.Errorf("summaries %+v, want both limiters", summaries)
+	}
+
+	if _, err := run(t, runCompare, compareInput, "-limiters", "token-bucket:6/1m:4", "-fail-over", "0"); err != nil {
+		t.Errorf("error %v without denials, want none", err)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// limiterFlags are the flags selecting the limiter of the subcommands.
+type limiterFlags struct {
+	algorithm string        // The name of the algorithm.
+	rate      int           // The maximum number of requests allowed in the window.
+	window    time.Duration // The duration of the window.
+	burst     int           // The size of the bursts of token buckets, zero for the rate.
+	timezone  string        // The time zone of the calendar of calendar windows, empty for UTC.
+}
+
+// register defines the flags in fs.
+func (f *limiterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.algorithm, "algorithm", ratelimiter.SlidingWindowAlgorithm, "algorithm limiting the requests, one of "+strings.Join(ratelimiter.Algorithms, ", "))
+	fs.IntVar(&f.rate, "rate", 3, "maximum number of requests allowed in the window")
+	fs.DurationVar(&f.window, "window", time.Hour, "duration of the window")
+	fs.IntVar(&f.burst, "burst", 0, "size of the bursts of the token-bucket algorithm, defaulting to the rate")
+	fs.StringVar(&f.timezone, "timezone", "", "IANA time zone of the calendar of the calendar-window algorithm, whose window is a minute, hour, day or week of its clock, e.g. Europe/Paris, defaulting to UTC")
+	fs.Func("preset", "named policy giving the algorithm, rate, window and burst not set by earlier flags, one of "+strings.Join(presetNames(), ", "), func(name string) error {
+		preset, ok := ratelimiter.LookupPreset(name)
+		if !ok {
+			return fmt.Errorf("unknown preset %q", name)
+		}
+		set := make(map[string]bool)
+		fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+		if !set["algorithm"] {
+			f.algorithm = preset.Algorithm
+		}
+		if !set["rate"] {
+			f.rate = preset.Rate
+		}
+		if !set["window"] {
+			f.window = preset.Window
+		}
+		if !set["burst"] {
+			f.burst = preset.Burst
+		}
+		return nil
+	})
+}
+
+// presetNames returns the names of the presets.
+func presetNames() []string {
+	var names []string
+	for _, preset := range ratelimiter.Presets {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+// String formats the limiter as a specification parsed by parseLimiter.
+func (f *limiterFlags) String() string {
+	s := fmt.Sprintf("%s:%d/%s", f.algorithm, f.rate, f.window)
+	if f.burst > 0 {
+		s += fmt.Sprintf(":%d", f.burst)
+	}
+	if f.timezone != "" {
+		s += "@" + f.timezone
+	}
+	return s
+}
+
+// parseLimiter parses the specification "<algorithm>[:<rate>/<window>[:<burst>]][@<timezone>]"
+// of a limiter, e.g. "token-bucket:100/1m:20" or
+// "calendar-window:1000/24h@Europe/Paris", the omitted parts defaulting to
+// those of defaults. The algorithm may be a preset instead, e.g.
+// "api-default", the omitted parts defaulting to those of the preset.
+func parseLimiter(spec string, defaults limiterFlags) (limiterFlags, error) {
+	f := defaults
+	spec, timezone, ok := strings.Cut(spec, "@")
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return f, fmt.Errorf("invalid limiter %q, want <algorithm>[:<rate>/<window>[:<burst>]]", spec)
+	}
+	f.algorithm = parts[0]
+	if preset, ok := ratelimiter.LookupPreset(parts[0]); ok {
+		f = limiterFlags{algorithm: preset.Algorithm, rate: preset.Rate, window: preset.Window, burst: preset.Burst}
+	}
+	if ok {
+		f.timezone = timezone
+	}
+	if len(parts) > 1 {
+		rate, window, ok := strings.Cut(parts[1], "/")
+		var err error
+		if f.rate, err = strconv.Atoi(rate); err != nil || !ok {
+			return f, fmt.Errorf("invalid limiter %q, want <rate>/<window> after the algorithm", spec)
+		}
+		if f.window, err = time.ParseDuration(window); err != nil {
+			return f, fmt.Errorf("invalid limiter %q: %w", spec, err)
+		}
+	}
+	if len(parts) > 2 {
+		var err error
+		if f.burst, err = strconv.Atoi(parts[2]); err != nil {
+			return f, fmt.Errorf("invalid limiter %q: invalid burst %q", spec, parts[2])
+		}
+	}
+	return f, nil
+}
+
+// newAlgorithm returns the function creating the algorithm instances selected
+// by the flags.
+func (f *limiterFlags) newAlgorithm() (func() ratelimiter.Algorithm, error) {
+	if f.rate < 1 || f.window <= 0 {
+		return nil, fmt.Errorf("invalid rate %d per %s", f.rate, f.window)
+	}
+	if f.algorithm == ratelimiter.CalendarWindowAlgorithm {
+		period, ok := ratelimiter.PeriodOf(f.window)
+		if !ok {
+			return nil, fmt.Errorf("no calendar period lasts %s, want 1m, 1h, 24h or 168h", f.window)
+		}
+		location, err := time.LoadLocation(f.timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", f.timezone)
+		}
+		return ratelimiter.CalendarWindowFactory(f.rate, period, location)
+	}
+	if f.algorithm == ratelimiter.TokenBucketAlgorithm && f.burst > 0 {
+		rate, window, burst := f.rate, f.window, f.burst
+		return func() ratelimiter.Algorithm { return ratelimiter.NewTokenBucket(rate, window, burst) }, nil
+	}
+	return ratelimiter.AlgorithmFactory(f.algorithm, f.rate, f.window)
+}
+
+// newLimiter returns a limiter of every key with the algorithm selected by the
+// flags.
+func (f *limiterFlags) newLimiter() (*ratelimiter.Keyed, error) {
+	newAlgorithm, err := f.newAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+	return ratelimiter.NewKeyed(newAlgorithm), nil
+}
+
+// inputFlags are the flags selecting the input of the subcommands.
+type inputFlags struct {
+	path       string        // The path of the input, - for the standard input.
+	format     string        // The format of the input.
+	timeFormat string        // The format of the timestamps, empty for the default of the format.
+	timeField  string        // The name of the field of the timestamps, empty for the default of the format.
+	keyField   string        // The name of the field of the keys, empty for the default of the format.
+	costField  string        // The name of the field of the costs, empty for the default of the format.
+	bufferSize int           // The size of the buffer reading the input.
+	maxLine    int           // The size of the longest line of the input.
+	progress   time.Duration // The interval of the progress reports, zero for none.
+}
+
+// register defines the flags in fs, the format defaulting to format.
+func (f *inputFlags) register(fs *flag.FlagSet, format replay.Format) {
+	fs.StringVar(&f.path, "input", "-", "file of the requests, - for the standard input")
+	fs.StringVar(&f.format, "format", string(format), "format of the input: lines of timestamps, csv rows of timestamp,key,cost, jsonl objects, or clf access logs")
+	fs.StringVar(&f.timeFormat, "time-format", "", "format of the timestamps: a layout of time.Parse, unix for epoch seconds or unixms for epoch milliseconds, RFC 3339 by default")
+	fs.StringVar(&f.timeField, "time-field", "", "name of the field of the timestamps in csv headers and jsonl objects")
+	fs.StringVar(&f.keyField, "key-field", "", "name of the field of the keys in csv headers, jsonl objects, or clf lines, e.g. user")
+	fs.StringVar(&f.costField, "cost-field", "", "name of the field of the costs in csv headers, jsonl objects, or clf lines, e.g. bytes")
+	fs.IntVar(&f.bufferSize, "buffer-size", replay.DefaultBufferSize, "size in bytes of the buffer reading the input")
+	fs.IntVar(&f.maxLine, "max-line-size", replay.DefaultMaxLineSize, "size in bytes of the longest line of the input, longer lines being skipped")
+	fs.DurationVar(&f.progress, "progress", 0, "interval of the progress reports printed to the standard error, none if zero")
+}
+
+// open returns a reader of the input selected by the flags, reporting its
+// progress if selected, and the function closing it.
+func (f *inputFlags) open() (replay.Source, func() error, error) {
+	in := os.Stdin
+	if f.path != "-" {
+		var err error
+		if in, err = os.Open(f.path); err != nil {
+			return nil, nil, err
+		}
+	}
+	opts := []replay.Option{
+		replay.WithFields(f.timeField, f.keyField, f.costField),
+		replay.WithBufferSize(f.bufferSize),
+		replay.WithMaxLineSize(f.maxLine),
+	}
+	if f.timeFormat != "" {
+		opts = append(opts, replay.WithTimeFormat(f.timeFormat))
+	}
+	reader, err := replay.NewReader(in, replay.Format(f.format), opts...)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	if f.progress <= 0 {
+		return reader, in.Close, nil
+	}
+
+	p := &progress{Reader: reader, interval: f.progress, start: time.Now()}
+	p.next = p.start.Add(p.interval)
+	if info, err := in.Stat(); err == nil && info.Mode().IsRegular() {
+		p.size = info.Size()
+	}
+	return p, func() error {
+		p.report()
+		return in.Close()
+	}, nil
+}
+
+// progress is a reader of an input periodically reporting how much of it was
+// read to the standard error.
+type progress struct {
+	*replay.Reader
+	size     int64         // The size of the input, zero if unknown.
+	interval time.Duration // The interval of the reports.
+	start    time.Time     // The time the reading started.
+	next     time.Time     // The time of the next report.
+	events   int           // The number of events read.
+}
+
+// Read returns the next event of the input, reporting the progress once the
+// interval is over.
+func (p *progress) Read() (replay.Event, error) {
+	event, err := p.Reader.Read()
+	if err == nil {
+		p.events++
+	}
+	if now := time.Now(); !now.Before(p.next) {
+		p.report()
+		p.next = now.Add(p.interval)
+	}
+	return event, err
+}
+
+// report prints the progress of the reading.
+func (p *progress) report() {
+	elapsed := time.Since(p.start)
+	read := formatBytes(p.Offset())
+	if p.size > 0 {
+		read += fmt.Sprintf(" of %s (%.1f%%)", formatBytes(p.size), 100*float64(p.Offset())/float64(p.size))
+	}
+	fmt.Fprintf(os.Stderr, "ratelimit: read %s, %d events in %s, %.0f events/s\n", read, p.events, elapsed.Round(time.Second), float64(p.events)/elapsed.Seconds())
+}
+
+// formatBytes formats a number of bytes in binary units, e.g. 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for n/div >= unit && exp < 5 {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// summaryFlags are the flags selecting the machine-readable summary of the
+// subcommands and when they fail.
+type summaryFlags struct {
+	path     string  // The file of the JSON summary, - for the standard output, empty for none.
+	failOver float64 // The denial ratio over which the subcommand fails.
+}
+
+// register defines the flags in fs.
+func (f *summaryFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "summary", "", "file of the final JSON summary of the decisions, - for the standard output, none if empty")
+	fs.Float64Var(&f.failOver, "fail-over", 1, "denial ratio, between 0 and 1, over which to exit with code 3")
+}
+
+// finish writes v as the JSON summary selected by the flags, and returns an
+// error exiting with code 3 if the denial ratio of any of summaries exceeds the
+// threshold.
+func (f *summaryFlags) finish(v any, summaries ...replay.Summary) error {
+	if f.path != "" {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if f.path == "-" {
+			_, err = os.Stdout.Write(data)
+		} else {
+			err = os.WriteFile(f.path, data, 0o644)
+		}
+		if err != nil {
+			return fmt.Errorf("writing summary: %w", err)
+		}
+	}
+	for _, summary := range summaries {
+		if summary.DenialRatio > f.failOver {
+			return &exitError{code: 3, err: fmt.Errorf("%s denied %.1f%% of the requests, over %.1f%%", summary.Limiter, 100*summary.DenialRatio, 100*f.failOver)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// parseFlags returns the limiter flags parsed from args.
+func parseFlags(t *testing.T, args ...string) limiterFlags {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var f limiterFlags
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLimiterFlags(t *testing.T) {
+	f := parseFlags(t, "-algorithm", "leaky-bucket", "-rate", "2", "-window", "1s")
+	limiter, err := f.newLimiter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if decision := limiter.Allow("a", now); decision.Allowed != want || decision.Algorithm != "leaky-bucket" {
+			t.Errorf("request %d: %+v, want allowed %v by the leaky bucket", i+1, decision, want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-rate", "0"},
+		{"-window", "0s"},
+		{"-algorithm", "random"},
+	} {
+		f := parseFlags(t, args...)
+		if _, err := f.newLimiter(); err == nil {
+			t.Errorf("%q: limiter created, want an error", args)
+		}
+	}
+}
+
+func TestParseLimiter(t *testing.T) {
+	defaults := limiterFlags{algorithm: "sliding-window", rate: 3, window: time.Hour}
+	for _, test := range []struct {
+		spec string
+		want limiterFlags
+	}{
+		{"leaky-bucket", limiterFlags{algorithm: "leaky-bucket", rate: 3, window: time.Hour}},
+		{"token-bucket:100/1m", limiterFlags{algorithm: "token-bucket", rate: 100, window: time.Minute}},
+		{"token-bucket:100/1m:20", limiterFlags{algorithm: "token-bucket", rate: 100, window: time.Minute, burst: 20}},
+	} {
+		got, err := parseLimiter(test.spec, defaults)
+		if err != nil || got != test.want {
+			t.Errorf("%s: %+v, error %v, want %+v", test.spec, got, err, test.want)
+		}
+		// The specification of the limiter parses back to it.
+		if again, err := parseLimiter(got.String(), limiterFlags{}); err != nil || again != got {
+			t.Errorf("%s parsed back to %+v, error %v, want %+v", got.String(), again, err, got)
+		}
+	}
+
+	for _, spec := range []string{"token-bucket:100", "token-bucket:x/1m", "token-bucket:100/soon", "token-bucket:100/1m:x", "a:1/1m:2:3"} {
+		if _, err := parseLimiter(spec, defaults); err == nil {
+			t.Errorf("%s: parsed, want an error", spec)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:             "512 B",
+		1536:            "1.5 KiB",
+		5 << 20:         "5.0 MiB",
+		3 << 30:         "3.0 GiB",
+		1<<62 + 1<<61:   "6.0 EiB",
+		1024*1024 - 512: "1023.5 KiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestInputProgress(t *testing.T) {
+	input := inputFlags{path: writeInput(t, simulateInput), format: "lines", bufferSize: 16, maxLine: 64, progress: time.Nanosecond}
+	source, closeInput, err := input.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := filepath.Join(t.TempDir(), "stderr")
+	f, err := os.Create(stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stderrFile := os.Stderr
+	os.Stderr = f
+	defer func() { os.Stderr = stderrFile }()
+
+	events := 0
+	forEach(source, func(replay.Event) error { events++; return nil }, func(*replay.LineError) error { return nil })
+	closeInput()
+	data, err := os.ReadFile(stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every read is reported, and the input once closed.
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := fmt.Sprintf("ratelimit: read %d B of %d B (100.0%%), %d events in", len(simulateInput), len(simulateInput), events)
+	if events != 5 || len(lines) != 8 || !strings.HasPrefix(lines[len(lines)-1], want) {
+		t.Errorf("%d events, progress reports %q, want 5 ending with %q", events, lines, want)
+	}
+}
+
+func TestPruner(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Minute) })
+	var p pruner
+	start := time.Date(2022, 1, 20, 0, 13, 5, 0, time.UTC)
+	for i := range 3 {
+		now := start.Add(time.Duration(i) * 40 * time.Second)
+		p.observe(now, limiter)
+		limiter.Allow(fmt.Sprint(i), now)
+	}
+	// Once the events pass the first minute, the replenished first key is
+	// forgotten.
+	if keys := limiter.Keys(); len(keys) != 2 {
+		t.Errorf("keys %q left, want the first one pruned", keys)
+	}
+}
+
+func TestPresetFlag(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want limiterFlags
+	}{
+		{[]string{"-preset", "api-default"}, limiterFlags{algorithm: "token-bucket", rate: 600, window: time.Minute, burst: 100}},
+		// The flags set before the preset win over it.
+		{[]string{"-rate", "1200", "-preset", "api-default"}, limiterFlags{algorithm: "token-bucket", rate: 1200, window: time.Minute, burst: 100}},
+	} {
+		if got := parseFlags(t, test.args...); got != test.want {
+			t.Errorf("%q: %+v, want %+v", test.args, got, test.want)
+		}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var f limiterFlags
+	f.register(fs)
+	if err := fs.Parse([]string{"-preset", "missing"}); err == nil {
+		t.Error("unknown preset accepted")
+	}
+
+	// Limiter specifications may name a preset.
+	got, err := parseLimiter("login-strict:10/1h", limiterFlags{})
+	if want := (limiterFlags{algorithm: "sliding-window", rate: 10, window: time.Hour}); err != nil || got != want {
+		t.Errorf("login-strict:10/1h: %+v, error %v, want %+v", got, err, want)
+	}
+}
+
+func TestCalendarFlags(t *testing.T) {
+	f := parseFlags(t, "-algorithm", "calendar-window", "-rate", "1", "-window", "24h", "-timezone", "Asia/Tokyo")
+	limiter, err := f.newLimiter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Midnight in Tokyo is 15:00 UTC.
+	before := time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC)
+	limiter.Allow("a", before)
+	if decision := limiter.Allow("a", before); decision.Allowed || decision.RetryAfter != 30*time.Minute {
+		t.Errorf("second decision %+v, want denied until midnight in Tokyo", decision)
+	}
+
+	for _, args := range [][]string{
+		{"-algorithm", "calendar-window", "-window", "90m"},
+		{"-algorithm", "calendar-window", "-window", "1h", "-timezone", "Mars/Olympus"},
+	} {
+		f := parseFlags(t, args...)
+		if _, err := f.newLimiter(); err == nil {
+			t.Errorf("%q: limiter created, want an error", args)
+		}
+	}
+
+	spec := "calendar-window:1000/24h0m0s@Europe/Paris"
+	f, err = parseLimiter(spec, limiterFlags{})
+	if want := (limiterFlags{algorithm: "calendar-window", rate: 1000, window: 24 * time.Hour, timezone: "Europe/Paris"}); err != nil || f != want || f.String() != spec {
+		t.Errorf("%s: %+v, error %v, want %+v", spec, f, err, want)
+	}
+}
@@ -0,0 +1,95 @@
+// Command ratelimit evaluates the algorithms of the ratelimiter package, with
+// one subcommand per use:
+//
+//	ratelimit simulate -algorithm sliding-window -rate 3 -window 1h -input testcase-sample.txt
+//	ratelimit replay -rate 100 -window 1m -input access.log
+//	ratelimit compare -limiters sliding-window,token-bucket:100/1m:20 -input requests.txt
+//	ratelimit repl -algorithm leaky-bucket -rate 10 -window 1m
+//	ratelimit serve -listen :8080 -algorithm token-bucket -rate 100 -window 1m
+//	ratelimit bench -bench 'leaky-bucket/.*'
+//	ratelimit bench -duration 10s -qps 50000 -concurrency 8 -algorithm token-bucket
+//	ratelimit check -c ratelimit.yaml -input sample.jsonl
+//
+// The simulate, replay, compare, repl, serve and bench subcommands take the
+// -algorithm, -rate, -window and -burst flags selecting the limiter, see
+// ratelimit <subcommand> -h. The check subcommand takes the limiters of a
+// configuration file instead.
+//
+// Ratelimit exits with code 1 on errors and 2 on usage errors. With -fail-over,
+// the simulate, replay and compare subcommands exit with code 3 when a limiter
+// denies a larger share of the requests, for automated capacity checks.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// command is a subcommand of ratelimit.
+type command struct {
+	name    string                    // The name of the subcommand.
+	summary string                    // What the subcommand does.
+	run     func(args []string) error // The function running the subcommand with its arguments.
+}
+
+var commands = []command{
+	{"simulate", "replay the requests of an input through a limiter and print the decisions", runSimulate},
+	{"replay", "report what a limiter would have allowed and denied of an access log", runReplay},
+	{"compare", "run the same requests through several limiters and compare their decisions", runCompare},
+	{"repl", "explore a limiter interactively, deciding on requests and moving a virtual clock", runRepl},
+	{"serve", "serve HTTP requests limited per client IP address", runServe},
+	{"bench", "run the benchmarks of the algorithms, or drive a limiter at a target rate", runBench},
+	{"check", "validate a configuration file and print the effective policy of every rule", runCheck},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ratelimit: ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				var exit *exitError
+				if errors.As(err, &exit) {
+					log.Print(exit.err)
+					os.Exit(exit.code)
+				}
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	if os.Args[1] != "-h" && os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "ratelimit: unknown subcommand %q\n", os.Args[1])
+	}
+	usage()
+	os.Exit(2)
+}
+
+// exitError is an error exiting ratelimit with a specific code.
+type exitError struct {
+	code int   // The exit code.
+	err  error // The error.
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: ratelimit <subcommand> [flags]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// run runs a subcommand with args, the standard input reading stdin, and
+// returns what it printed to the standard output. What it prints to the
+// standard error is discarded.
+func run(t *testing.T, subcommand func(args []string) error, stdin string, args ...string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	in, err := os.Create(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := in.WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	in.Seek(0, 0)
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	errOut, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errOut.Close()
+
+	stdinFile, stdoutFile, stderrFile := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = in, out, errOut
+	defer func() { os.Stdin, os.Stdout, os.Stderr = stdinFile, stdoutFile, stderrFile }()
+	err = subcommand(args)
+
+	data, readErr := os.ReadFile(out.Name())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(data), err
+}
+
+// writeInput writes data to a new input file, and returns its path.
+func writeInput(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
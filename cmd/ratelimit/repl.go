@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// replHelp describes the commands of the repl subcommand.
+const replHelp = `Commands:
+  allow [key=<key>] [cost=<n>]  decide on a request of key, the empty key by default, costing n, 1 by default
+  advance <duration>            move the clock forward, e.g. advance 30s
+  state [key=<key>]             print the internal state of key, or of every key
+  setrate <rate> [<window>]     change the rate, and the window, starting every key over
+  setalgorithm <algorithm>      change the algorithm, starting every key over
+  reset [key=<key>]             start key over, or every key
+  help                          print this help
+  quit                          exit
+`
+
+// repl is the state of an interactive session.
+type repl struct {
+	flags   limiterFlags       // The flags of the limiter.
+	limiter *ratelimiter.Keyed // The limiter explored.
+	start   time.Time          // The time of the start of the session.
+	now     time.Time          // The time of the virtual clock.
+	out     io.Writer          // Where the results are printed.
+}
+
+// runRepl reads commands deciding on requests and moving a virtual clock
+// forward from the standard input, to explore a limiter step by step.
+func runRepl(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	var limiter limiterFlags
+	limiter.register(fs)
+	start := fs.String("start", "", "RFC 3339 time the virtual clock starts at, now by default")
+	fs.Parse(args)
+
+	r := &repl{flags: limiter, out: os.Stdout, start: time.Now().Truncate(time.Second)}
+	if *start != "" {
+		var err error
+		if r.start, err = time.Parse(time.RFC3339, *start); err != nil {
+			return fmt.Errorf("invalid start: %w", err)
+		}
+	}
+	r.now = r.start
+	if err := r.rebuild(); err != nil {
+		return err
+	}
+
+	// Prompts are only shown to a terminal, so scripts can be piped in.
+	prompt := ""
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		prompt = "> "
+		fmt.Fprintf(r.out, "%s at %s, type help for the commands\n", r.flags.String(), r.now.Format(time.RFC3339))
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Fprint(r.out, prompt); scanner.Scan(); fmt.Fprint(r.out, prompt) {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return nil
+		}
+		if err := r.exec(fields[0], fields[1:]); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// exec runs the command name with its arguments.
+func (r *repl) exec(name string, args []string) error {
+	switch name {
+	case "allow":
+		params, err := parseParams(args, "key", "cost")
+		if err != nil {
+			return err
+		}
+		cost := 1
+		if value, ok := params["cost"]; ok {
+			if cost, err = strconv.Atoi(value); err != nil || cost < 0 {
+				return fmt.Errorf("invalid cost %q", value)
+			}
+		}
+		decision := r.limiter.AllowN(params["key"], r.now, cost)
+		verdict := "allowed"
+		if !decision.Allowed {
+			verdict = fmt.Sprintf("denied (%s), retry after %s", decision.Reason, decision.RetryAfter.Round(time.Millisecond))
+		}
+		fmt.Fprintf(r.out, "%s %s: %d/%d remaining, reset after %s\n", r.clock(), verdict, decision.Remaining, decision.Limit, decision.ResetAfter.Round(time.Millisecond))
+	case "advance":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: advance <duration>")
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration %q", args[0])
+		}
+		r.now = r.now.Add(d)
+		fmt.Fprintln(r.out, r.clock())
+	case "state":
+		params, err := parseParams(args, "key")
+		if err != nil {
+			return err
+		}
+		keys := r.limiter.Keys()
+		if key, ok := params["key"]; ok {
+			keys = []string{key}
+		}
+		slices.Sort(keys)
+		fmt.Fprintf(r.out, "%s %s, %d keys\n", r.clock(), r.flags.String(), r.limiter.Len())
+		for _, key := range keys {
+			r.printState(key)
+		}
+	case "setrate":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: setrate <rate> [<window>]")
+		}
+		flags := r.flags
+		var err error
+		if flags.rate, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("invalid rate %q", args[0])
+		}
+		if len(args) == 2 {
+			if flags.window, err = time.ParseDuration(args[1]); err != nil {
+				return fmt.Errorf("invalid window %q", args[1])
+			}
+		}
+		return r.set(flags)
+	case "setalgorithm":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: setalgorithm <algorithm>")
+		}
+		flags := r.flags
+		flags.algorithm = args[0]
+		return r.set(flags)
+	case "reset":
+		params, err := parseParams(args, "key")
+		if err != nil {
+			return err
+		}
+		if key, ok := params["key"]; ok {
+			r.limiter.Reset(key)
+		} else if err := r.rebuild(); err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, "reset")
+	case "help":
+		fmt.Fprint(r.out, replHelp)
+	default:
+		return fmt.Errorf("unknown command %q, type help for the commands", name)
+	}
+	return nil
+}
+
+// set replaces the limiter with the one selected by flags.
+func (r *repl) set(flags limiterFlags) error {
+	previous := r.flags
+	r.flags = flags
+	if err := r.rebuild(); err != nil {
+		r.flags = previous
+		return err
+	}
+	fmt.Fprintf(r.out, "%s, every key starting over\n", r.flags.String())
+	return nil
+}
+
+// rebuild replaces the limiter with a new one selected by the flags.
+func (r *repl) rebuild() error {
+	limiter, err := r.flags.newLimiter()
+	if err != nil {
+		return err
+	}
+	r.limiter = limiter
+	return nil
+}
+
+// printState prints the internal state of the algorithm of key.
+func (r *repl) printState(key string) {
+	decision, ok := r.limiter.Peek(key, r.now)
+	if !ok {
+		fmt.Fprintf(r.out, "  %q: not tracked\n", key)
+		return
+	}
+	fmt.Fprintf(r.out, "  %q: %d/%d remaining, reset after %s", key, decision.Remaining, decision.Limit, decision.ResetAfter.Round(time.Millisecond))
+	if state, ok := r.limiter.Inspect(key); ok {
+		switch state.Algorithm {
+		case ratelimiter.SlidingWindowAlgorithm:
+			seconds := slices.Sorted(maps.Keys(state.Counts))
+			fmt.Fprint(r.out, ", counted")
+			for _, second := range seconds {
+				fmt.Fprintf(r.out, " %s=%d", r.elapsed(time.Unix(second, 0)), state.Counts[second])
+			}
+		case ratelimiter.LeakyBucketAlgorithm:
+			fmt.Fprintf(r.out, ", level %.2f at %s", state.Level, r.elapsed(state.LastUpdate))
+		case ratelimiter.TokenBucketAlgorithm:
+			fmt.Fprintf(r.out, ", %.2f/%d tokens at %s", state.Tokens, state.Burst, r.elapsed(state.LastUpdate))
+		}
+	}
+	fmt.Fprintln(r.out)
+}
+
+// clock formats the virtual clock.
+func (r *repl) clock() string {
+	return fmt.Sprintf("[%s]", r.elapsed(r.now))
+}
+
+// elapsed formats t relative to the start of the session.
+func (r *repl) elapsed(t time.Time) string {
+	return "+" + t.Sub(r.start).String()
+}
+
+// parseParams parses args of the form name=value, the first being allowed
+// without its name, into the values of the names accepted.
+func parseParams(args []string, names ...string) (map[string]string, error) {
+	params := make(map[string]string)
+	for i, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			if i > 0 {
+				return nil, fmt.Errorf("invalid argument %q, want <name>=<value>", arg)
+			}
+			name, value = names[0], arg
+		}
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("unknown argument %q, want one of %s", name, strings.Join(names, ", "))
+		}
+		params[name] = value
+	}
+	return params, nil
+}
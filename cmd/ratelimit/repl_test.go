@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestRepl(t *testing.T) {
+	script := `# A limit of 2 per minute.
+allow alice
+allow key=alice cost=1
+allow alice
+state
+advance 30s
+allow bob
+setrate 5 1m
+allow alice cost=5
+reset
+state
+allow alice bob
+jump
+quit
+allow alice
+`
+	out, err := run(t, runRepl, script, "-rate", "2", "-window", "1m", "-start", "2022-01-20T00:13:05Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[+0s] allowed: 1/2 remaining, reset after 1m1s
+[+0s] allowed: 0/2 remaining, reset after 1m1s
+[+0s] denied (rate_limit), retry after 1m1s: 0/2 remaining, reset after 1m1s
+[+0s] sliding-window:2/1m0s, 1 keys
+  "alice": 0/2 remaining, reset after 1m1s, counted +0s=2
+[+30s]
+[+30s] allowed: 1/2 remaining, reset after 1m1s
+sliding-window:5/1m0s, every key starting over
+[+30s] allowed: 0/5 remaining, reset after 1m1s
+reset
+[+30s] sliding-window:5/1m0s, 0 keys
+error: invalid argument "bob", want <name>=<value>
+error: unknown command "jump", type help for the commands
+`
+	if out != want {
+		t.Errorf("output\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestReplAlgorithm(t *testing.T) {
+	script := "setalgorithm token-bucket\nallow alice cost=3\nadvance 12s\nstate alice\nstate bob\nsetalgorithm random\nallow alice\n"
+	out, err := run(t, runRepl, script, "-rate", "5", "-window", "1m", "-start", "2022-01-20T00:13:05Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failed change keeps the token bucket and its state.
+	want := `token-bucket:5/1m0s, every key starting over
+[+0s] allowed: 2/5 remaining, reset after 36s
+[+12s]
+[+12s] token-bucket:5/1m0s, 1 keys
+  "alice": 3/5 remaining, reset after 24s, 3.00/5 tokens at +12s
+[+12s] token-bucket:5/1m0s, 1 keys
+  "bob": not tracked
+error: ratelimiter: unknown algorithm "random"
+[+12s] allowed: 2/5 remaining, reset after 36s
+`
+	if out != want {
+		t.Errorf("output\n%s\nwant\n%s", out, want)
+	}
+}
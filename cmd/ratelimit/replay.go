@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// runReplay replays an access log through a limiter, and reports what it would
+// have allowed and denied, overall and for the most denied keys. The most
+// denied keys are found in memory bounded by the number of tracked keys, so
+// any number of keys replays in constant memory.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var limiter limiterFlags
+	limiter.register(fs)
+	var input inputFlags
+	input.register(fs, replay.CLF)
+	top := fs.Int("top", 10, "number of the most denied keys to report")
+	tracked := fs.Int("track", 1000, "number of keys tracked to find the most denied ones, the keys denied more than 1/track of the denials being always found")
+	var summary summaryFlags
+	summary.register(fs)
+	fs.Parse(args)
+
+	rateLimiter, err := limiter.newLimiter()
+	if err != nil {
+		return err
+	}
+	reader, closeInput, err := input.open()
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	var total replay.Stats
+	keys := ratelimiter.NewTopKeys(max(*tracked, *top), 0)
+	invalid := 0
+	var pruner pruner
+	err = forEach(reader, func(event replay.Event) error {
+		pruner.observe(event.Time, rateLimiter)
+		decision := rateLimiter.AllowN(event.Key, event.Time, event.Cost)
+		total.Observe(event, decision)
+		keys.Observe(event.Key, event.Time, decision)
+		return nil
+	}, func(err *replay.LineError) error {
+		if invalid++; invalid <= 10 {
+			fmt.Fprintf(os.Stderr, "Skipping %v\n", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	denied := keys.Current(time.Now()).Denied
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "policy\t%d per %s, %s\n", limiter.rate, limiter.window, limiter.algorithm)
+	fmt.Fprintf(table, "requests\t%d\n", total.Total)
+	fmt.Fprintf(table, "allowed\t%d\n", total.Allowed)
+	fmt.Fprintf(table, "denied\t%d (%.1f%%)\n", total.Denied, 100*total.DenialRatio())
+	if invalid > 0 {
+		fmt.Fprintf(table, "invalid lines\t%d\n", invalid)
+	}
+	if len(denied) > 0 {
+		fmt.Fprintf(table, "\nkey\tdenied\terror\n")
+		for _, key := range denied[:min(*top, len(denied))] {
+			fmt.Fprintf(table, "%s\t%d\t%d\n", key.Key, key.Count, key.Error)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	s := total.Summarize(limiter.String())
+	return summary.finish(s, s)
+}
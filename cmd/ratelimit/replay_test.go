@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const accessLog = `203.0.113.7 - - [20/Jan/2022:00:13:05 +0000] "GET / HTTP/1.1" 200 512
+203.0.113.7 - - [20/Jan/2022:00:13:06 +0000] "GET / HTTP/1.1" 200 512
+203.0.113.7 - - [20/Jan/2022:00:13:07 +0000] "GET / HTTP/1.1" 200 512
+198.51.100.1 - - [20/Jan/2022:00:13:07 +0000] "GET / HTTP/1.1" 200 512
+203.0.113.7 - - [20/Jan/2022:00:13:08 +0000] "GET / HTTP/1.1" 200 512
+garbage
+198.51.100.1 - - [20/Jan/2022:00:13:08 +0000] "GET / HTTP/1.1" 200 512
+198.51.100.1 - - [20/Jan/2022:00:13:09 +0000] "GET / HTTP/1.1" 200 512
+`
+
+func TestReplay(t *testing.T) {
+	out, err := run(t, runReplay, accessLog, "-rate", "2", "-window", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]string{
+		{"policy", "2", "per", "1m0s,", "sliding-window"},
+		{"requests", "7"},
+		{"allowed", "4"},
+		{"denied", "3", "(42.9%)"},
+		{"invalid", "lines", "1"},
+		{"203.0.113.7", "2", "0"},
+		{"198.51.100.1", "1", "0"},
+	} {
+		if !hasRow(out, row) {
+			t.Errorf("no row %v in\n%s", row, out)
+		}
+	}
+	// The most denied keys come first.
+	if strings.Index(out, "203.0.113.7") > strings.Index(out, "198.51.100.1") {
+		t.Errorf("keys out of order in\n%s", out)
+	}
+}
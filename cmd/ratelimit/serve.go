@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/check"
+)
+
+// runServe serves HTTP requests limited per client IP address, to try a
+// limiter with curl or a load generator, and answers the POST /check requests
+// of other services with the decisions of the same limiter, see package check.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var limiter limiterFlags
+	limiter.register(fs)
+	listen := fs.String("listen", ":8080", "address to listen on")
+	fs.Parse(args)
+
+	rateLimiter, err := limiter.newLimiter()
+	if err != nil {
+		return err
+	}
+	// Forget the clients whose budget is replenished while serving.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ratelimiter.PruneEvery(ctx, ratelimiter.DefaultPruneInterval, rateLimiter)
+
+	log.Printf("Serving %s, allowing %d requests per %s per client with the %s algorithm", *listen, limiter.rate, limiter.window, limiter.algorithm)
+	return http.ListenAndServe(*listen, newServeMux(rateLimiter))
+}
+
+// newServeMux returns the handler of the serve subcommand, answering the
+// requests limited by limiter and the POST /check requests.
+func newServeMux(limiter ratelimiter.Limiter) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/check", check.NewHandler(limiter))
+	mux.Handle("/", ratelimiter.Middleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	})))
+	return mux
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/check"
+)
+
+func TestServeMux(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	server := httptest.NewServer(newServeMux(limiter))
+	defer server.Close()
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		resp, err := server.Client().Get(server.URL + "/orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("request %d: status %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+
+	// The decision API shares the limiter of the requests.
+	client := check.NewClient(server.URL+"/check", server.Client())
+	if decision, err := client.Check("127.0.0.1", 1); err != nil || decision.Allowed {
+		t.Errorf("check of the client: decision %+v, error %v, want denied", decision, err)
+	}
+	if decision, err := client.Check("203.0.113.7", 1); err != nil || !decision.Allowed {
+		t.Errorf("check of another client: decision %+v, error %v, want allowed", decision, err)
+	}
+}
+
+func TestBenchCheck(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(5, time.Hour) })
+	server := httptest.NewServer(newServeMux(limiter))
+	defer server.Close()
+
+	out, err := run(t, runBench, "", "-duration", "100ms", "-qps", "200", "-concurrency", "2", "-keys", "1", "-check", server.URL+"/check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := loadRow(out, "allowed"); len(row) != 1 || row[0] != "5" {
+		t.Errorf("allowed %q, want the 5 allowed by the server in\n%s", row, out)
+	}
+	if row := loadRow(out, "failures"); row != nil {
+		t.Errorf("failures %q, want none", row)
+	}
+}
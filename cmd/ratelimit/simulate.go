@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/replay"
+)
+
+// runSimulate replays the requests of an input through a limiter, printing the
+// decision of every one of them, or generates synthetic traffic and prints the
+// statistics of the decisions of every pattern.
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	var limiter limiterFlags
+	limiter.register(fs)
+	var input inputFlags
+	input.register(fs, replay.Lines)
+	patterns := fs.String("pattern", "", "comma-separated patterns of synthetic traffic replacing the input: constant, poisson or bursty")
+	qps := fs.Float64("qps", 10, "mean number of requests per second of the synthetic traffic")
+	duration := fs.Duration("duration", time.Hour, "duration of the synthetic traffic")
+	keys := fs.Int("keys", 1, "number of keys the synthetic requests are spread over")
+	burstSize := fs.Int("burst-size", 10, "number of requests of the bursts of the bursty pattern")
+	jitter := fs.Duration("jitter", 0, "maximum random change of the intervals between the synthetic requests")
+	seed := fs.Uint64("seed", 0, "seed of the randomness of the synthetic traffic, making it reproducible, a random one printed to the standard error if zero")
+	viz := fs.String("viz", "", "HTML file charting the decisions over time, none if empty")
+	var summary summaryFlags
+	summary.register(fs)
+	fs.Parse(args)
+
+	if *patterns != "" {
+		if *seed == 0 {
+			*seed = rand.Uint64()
+			fmt.Fprintf(os.Stderr, "ratelimit: seed %d, rerun with -seed %d for the same traffic\n", *seed, *seed)
+		}
+		traffic := replay.Traffic{Rate: *qps, Duration: *duration, Keys: *keys, BurstSize: *burstSize, Jitter: *jitter, Seed: *seed}
+		return simulatePatterns(&limiter, strings.Split(*patterns, ","), traffic, *viz, &summary)
+	}
+
+	rateLimiter, err := limiter.newLimiter()
+	if err != nil {
+		return err
+	}
+
+	reader, closeInput, err := input.open()
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	out := bufio.NewWriterSize(os.Stdout, 64<<10)
+	writer := replay.NewWriter(out, replay.Format(input.format))
+	timeline := replay.NewTimeline(limiter.String())
+	var stats replay.Stats
+	var pruner pruner
+
+	err = forEach(reader, func(event replay.Event) error {
+		pruner.observe(event.Time, rateLimiter)
+		decision := rateLimiter.AllowN(event.Key, event.Time, event.Cost)
+		stats.Observe(event, decision)
+		if *viz != "" {
+			timeline.Observe(event, decision)
+		}
+		return writer.Write(event, decision)
+	}, func(err *replay.LineError) error {
+		return writer.WriteError(err)
+	})
+	if err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	if *viz != "" {
+		if err := writeViz(*viz, timeline); err != nil {
+			return err
+		}
+	}
+	s := stats.Summarize(limiter.String())
+	return summary.finish(s, s)
+}
+
+// writeViz writes the HTML file at path charting timelines.
+func writeViz(path string, timelines ...*replay.Timeline) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := replay.WriteHTML(f, timelines...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// simulatePatterns runs synthetic traffic of every pattern through a new
+// limiter, and prints the statistics of their decisions, charted in the HTML
+// file at viz unless it is empty, and summarized as selected by summary.
+func simulatePatterns(limiter *limiterFlags, patterns []string, traffic replay.Traffic, viz string, summary *summaryFlags) error {
+	var timelines []*replay.Timeline
+	summaries := []replay.Summary{}
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "pattern\trequests\tallowed\tdenied\tdenied %\t")
+	for _, pattern := range patterns {
+		rateLimiter, err := limiter.newLimiter()
+		if err != nil {
+			return err
+		}
+		traffic.Pattern = replay.Pattern(strings.TrimSpace(pattern))
+		generator, err := replay.NewGenerator(traffic)
+		if err != nil {
+			return err
+		}
+
+		var stats replay.Stats
+		timeline := replay.NewTimeline(fmt.Sprintf("%s traffic, %s", traffic.Pattern, limiter))
+		err = forEach(generator, func(event replay.Event) error {
+			decision := rateLimiter.AllowN(event.Key, event.Time, event.Cost)
+			stats.Observe(event, decision)
+			if viz != "" {
+				timeline.Observe(event, decision)
+			}
+			return nil
+		}, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%.1f\t\n", traffic.Pattern, stats.Total, stats.Allowed, stats.Denied, 100*stats.DenialRatio())
+		timelines = append(timelines, timeline)
+		summaries = append(summaries, stats.Summarize(fmt.Sprintf("%s traffic, %s", traffic.Pattern, limiter)))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if viz != "" {
+		if err := writeViz(viz, timelines...); err != nil {
+			return err
+		}
+	}
+	return summary.finish(summaries, summaries...)
+}
+
+// forEach passes the events of source to handle until its end, and the invalid
+// ones to invalid, if not nil.
+func forEach(source replay.Source, handle func(replay.Event) error, invalid func(*replay.LineError) error) error {
+	for {
+		event, err := source.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var lineErr *replay.LineError
+		if errors.As(err, &lineErr) && invalid != nil {
+			if err := invalid(lineErr); err != nil {
+				return fmt.Errorf("writing output: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+
+		if err := handle(event); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+}
+
+// pruneInterval is the interval of the time of the events after which the
+// limiters forget the keys whose budget is replenished, so their memory does
+// not grow with the length of the input.
+const pruneInterval = time.Minute
+
+// pruner prunes limiters as the time of the events goes by.
+type pruner struct {
+	next time.Time // The time of the events after which to prune next.
+}
+
+// observe prunes limiters if the time t of an event is past the next pruning.
+func (p *pruner) observe(t time.Time, limiters ...*ratelimiter.Keyed) {
+	if t.Before(p.next) {
+		return
+	}
+	if !p.next.IsZero() {
+		for _, limiter := range limiters {
+			limiter.Prune(t)
+		}
+	}
+	p.next = t.Add(pruneInterval)
+}
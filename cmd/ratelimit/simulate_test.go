@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const simulateInput = `2022-01-20T00:13:05Z
+2022-01-20T00:13:06Z
+2022-01-20T00:13:07Z
+soon
+2022-01-20T00:13:08Z
+2022-01-20T01:13:08Z
+`
+
+func TestSimulate(t *testing.T) {
+	want := "true\ntrue\ntrue\nError parsing line 4: parsing time \"soon\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"soon\" as \"2006\"\nfalse\ntrue\n"
+	// The input is read from a file, or from the standard input.
+	for _, args := range [][]string{{"-input", writeInput(t, simulateInput)}, nil} {
+		out, err := run(t, runSimulate, simulateInput, append(args, "-rate", "3", "-window", "1h")...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != want {
+			t.Errorf("%q: output\n%s\nwant\n%s", args, out, want)
+		}
+	}
+	if _, err := run(t, runSimulate, "", "-rate", "0"); err == nil || !strings.Contains(err.Error(), "invalid rate") {
+		t.Errorf("rate of 0: error %v, want invalid rate", err)
+	}
+}
+
+func TestSimulateCSV(t *testing.T) {
+	input := `timestamp,key,cost
+2022-01-20T00:13:05Z,alice,2
+2022-01-20T00:13:06Z,bob,3
+2022-01-20T00:13:07Z,alice,2
+2022-01-20T00:13:08Z,bob,x
+`
+	out, err := run(t, runSimulate, input, "-format", "csv", "-rate", "3", "-window", "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every key has its own budget, consumed by the cost of its requests.
+	if want := "true\ntrue\nfalse\nError parsing line 5: invalid cost \"x\"\n"; out != want {
+		t.Errorf("output\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestSimulateJSONL(t *testing.T) {
+	input := `{"ts": "2022-01-20T00:13:05Z", "key": "alice"}
+{"ts": "2022-01-20T00:13:06Z", "key": "alice"}
+{"ts": "yesterday"}
+`
+	out, err := run(t, runSimulate, input, "-format", "jsonl", "-rate", "1", "-window", "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ts":"2022-01-20T00:13:05Z","key":"alice","cost":1,"allowed":true,"remaining":0,"retry_after":0}
+{"ts":"2022-01-20T00:13:06Z","key":"alice","cost":1,"allowed":false,"remaining":0,"retry_after":3600,"reason":"rate_limit"}
+{"line":3,"error":"parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\""}
+`
+	if out != want {
+		t.Errorf("output\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestSimulateTimeFormat(t *testing.T) {
+	for _, test := range []struct {
+		format, input string
+	}{
+		{"unix", "1642637585\n1642637585.5\n1642637586\n"},
+		{"unixms", "1642637585000\n1642637585500\n1642637586000\n"},
+		{"2006-01-02 15:04:05", "2022-01-20 00:13:05\n2022-01-20 00:13:05\n2022-01-20 00:13:06\n"},
+	} {
+		out, err := run(t, runSimulate, test.input, "-time-format", test.format, "-rate", "2", "-window", "1h")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "true\ntrue\nfalse\n"; out != want {
+			t.Errorf("%s: output %q, want %q", test.format, out, want)
+		}
+	}
+}
+
+func TestSimulatePatterns(t *testing.T) {
+	out, err := run(t, runSimulate, "", "-pattern", "constant, poisson", "-qps", "2", "-duration", "1m", "-rate", "60", "-window", "1m", "-seed", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Constant traffic at twice the rate has about half its requests denied.
+	if !hasRow(out, []string{"constant", "119", "60", "59", "49.6"}) {
+		t.Errorf("no constant row in\n%s", out)
+	}
+	if !strings.Contains(out, "poisson") {
+		t.Errorf("no poisson row in\n%s", out)
+	}
+	if _, err := run(t, runSimulate, "", "-pattern", "tidal", "-seed", "1"); err == nil {
+		t.Error("unknown pattern simulated")
+	}
+}
+
+func TestSimulateSeed(t *testing.T) {
+	args := []string{"-pattern", "poisson,bursty", "-qps", "5", "-duration", "1m", "-keys", "3", "-jitter", "100ms", "-rate", "100", "-window", "1m"}
+	first, err := run(t, runSimulate, "", append(args, "-seed", "7")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := run(t, runSimulate, "", append(args, "-seed", "7")...); second != first {
+		t.Errorf("output of the same seed\n%s\ndiffers from\n%s", second, first)
+	}
+	if other, _ := run(t, runSimulate, "", append(args, "-seed", "8")...); other == first {
+		t.Errorf("output of another seed is the same\n%s", other)
+	}
+}
@@ -0,0 +1,56 @@
+// Command rlproxy is an HTTP reverse proxy applying the rate limits of a rules
+// file to the requests it forwards to an upstream server.
+//
+//	rlproxy -upstream http://localhost:3000 -rules rules.json -metrics :9090
+//
+// Decisions are counted per rule and published on /debug/vars of the metrics
+// address.
+package main
+
+import (
+	"context"
+	"expvar"
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	upstream := flag.String("upstream", "", "URL of the upstream server")
+	rulesPath := flag.String("rules", "rules.json", "path of the rules file")
+	metricsListen := flag.String("metrics", "", "address serving the metrics on /debug/vars, disabled if empty")
+	flag.Parse()
+
+	target, err := url.Parse(*upstream)
+	if err != nil || target.Host == "" {
+		log.Fatalf("Invalid upstream URL %q", *upstream)
+	}
+	rules, err := loadRules(*rulesPath)
+	if err != nil {
+		log.Fatalf("Error loading rules: %v", err)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	rt, err := newRouter(rules, proxy, expvar.NewMap("rlproxy"))
+	if err != nil {
+		log.Fatalf("Error loading rules: %v", err)
+	}
+
+	// Forget the clients whose budget is replenished, for as long as the
+	// proxy runs.
+	go ratelimiter.PruneEvery(context.Background(), ratelimiter.DefaultPruneInterval, rt.limiters...)
+
+	if *metricsListen != "" {
+		go func() {
+			log.Fatal(http.ListenAndServe(*metricsListen, expvar.Handler()))
+		}()
+	}
+
+	log.Printf("Proxying %s to %s", *listen, target)
+	log.Fatal(http.ListenAndServe(*listen, rt))
+}
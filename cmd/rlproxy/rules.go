@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// duration is a time.Duration read from a JSON string such as "1m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// Rule is a rate limit applied to the requests matching its path and method.
+type Rule struct {
+	Name      string   `json:"name"`      // Name of the rule in the metrics.
+	Path      string   `json:"path"`      // Path prefix of the matching requests, empty matches every path.
+	Method    string   `json:"method"`    // Method of the matching requests, empty matches every method.
+	Algorithm string   `json:"algorithm"` // Name of the algorithm, see ratelimiter.Algorithms.
+	Rate      int      `json:"rate"`      // Maximum number of requests allowed in the window.
+	Window    duration `json:"window"`    // Duration of the window.
+	Key       string   `json:"key"`       // Key of the requests: "ip", "global" or "header:<name>".
+}
+
+// Skip lists the requests that are never limited.
+type Skip struct {
+	Methods []string `json:"methods"` // Methods of the skipped requests, e.g. "OPTIONS".
+	Paths   []string `json:"paths"`   // Paths of the skipped requests, those ending with a slash match every path below them.
+	Subnets []string `json:"subnets"` // CIDRs of the clients whose requests are skipped.
+}
+
+// matchers returns the matchers of the skipped requests, the clients of the
+// subnets being those clientIP returns as the ip key does.
+func (skip *Skip) matchers(clientIP ratelimiter.KeyFunc) ([]ratelimiter.Matcher, error) {
+	var matchers []ratelimiter.Matcher
+	if len(skip.Methods) > 0 {
+		matchers = append(matchers, ratelimiter.MatchMethods(skip.Methods...))
+	}
+	if len(skip.Paths) > 0 {
+		matchers = append(matchers, ratelimiter.MatchPaths(skip.Paths...))
+	}
+	if len(skip.Subnets) > 0 {
+		matcher, err := ratelimiter.MatchClientSubnets(clientIP, skip.Subnets...)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// Rules is the content of a rules file.
+type Rules struct {
+	TrustedProxies   []string `json:"trustedProxies"`   // CIDRs of the proxies whose forwarding headers are believed by the "ip" key.
+	ForwardingHeader string   `json:"forwardingHeader"` // Header the trusted proxies write the client address to, the only one believed if set.
+	Skip             Skip     `json:"skip"`             // Requests never limited by any rule.
+	Default          *Rule    `json:"default"`          // Rule applied to the requests matching no other rule, if any.
+	Rules            []Rule   `json:"rules"`            // Rules tried in order, the first matching one applies.
+}
+
+// loadRules reads the rules file at path.
+func loadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &rules, nil
+}
+
+// matches reports whether the rule applies to r.
+func (rule *Rule) matches(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, rule.Path) && (rule.Method == "" || rule.Method == r.Method)
+}
+
+// keyFunc returns the function extracting the key of a request for the rule.
+func (rule *Rule) keyFunc(rules *Rules) (ratelimiter.KeyFunc, error) {
+	switch {
+	case rule.Key == "" || rule.Key == "ip":
+		return ratelimiter.KeyByClientIP(rules.TrustedProxies, ratelimiter.WithForwardingHeader(rules.ForwardingHeader))
+	case rule.Key == "global":
+		return func(r *http.Request) string { return "" }, nil
+	case strings.HasPrefix(rule.Key, "header:"):
+		return ratelimiter.KeyByHeader(strings.TrimPrefix(rule.Key, "header:")), nil
+	default:
+		return nil, fmt.Errorf("rule %s: unknown key %q", rule.Name, rule.Key)
+	}
+}
+
+// handler returns next limited by the rule, counting its decisions in metrics,
+// and the limiter of the rule.
+func (rule *Rule) handler(next http.Handler, rules *Rules, metrics *expvar.Map) (http.Handler, *ratelimiter.Keyed, error) {
+	newAlgorithm, err := ratelimiter.AlgorithmFactory(rule.Algorithm, rule.Rate, time.Duration(rule.Window))
+	if err != nil {
+		return nil, nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+	}
+	keyFunc, err := rule.keyFunc(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientIP, err := ratelimiter.KeyByClientIP(rules.TrustedProxies, ratelimiter.WithForwardingHeader(rules.ForwardingHeader))
+	if err != nil {
+		return nil, nil, err
+	}
+	skip, err := rules.Skip.matchers(clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyed := ratelimiter.NewKeyed(newAlgorithm)
+	limiter := &countingLimiter{
+		Limiter: keyed,
+		allowed: new(expvar.Int),
+		denied:  new(expvar.Int),
+	}
+	metrics.Set(rule.Name+".allowed", limiter.allowed)
+	metrics.Set(rule.Name+".denied", limiter.denied)
+	skipped := new(expvar.Int)
+	metrics.Set(rule.Name+".skipped", skipped)
+
+	return ratelimiter.Middleware(limiter,
+		ratelimiter.WithKeyFunc(keyFunc),
+		ratelimiter.WithSkip(skip...),
+		ratelimiter.WithOnSkipped(func(r *http.Request) { skipped.Add(1) }),
+	)(next), keyed, nil
+}
+
+// countingLimiter is a ratelimiter.Limiter counting the decisions it makes.
+type countingLimiter struct {
+	ratelimiter.Limiter
+	allowed *expvar.Int // Number of allowed requests.
+	denied  *expvar.Int // Number of denied requests.
+}
+
+func (l *countingLimiter) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return l.AllowN(key, requestTime, 1)
+}
+
+func (l *countingLimiter) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	decision := l.Limiter.AllowN(key, requestTime, n)
+	if decision.Allowed {
+		l.allowed.Add(1)
+	} else {
+		l.denied.Add(1)
+	}
+	return decision
+}
+
+// router dispatches requests to the handler of the first matching rule.
+type router struct {
+	rules    []Rule               // The rules tried in order.
+	handlers []http.Handler       // The handler of each rule.
+	fallback http.Handler         // The handler of the requests matching no rule.
+	limiters []*ratelimiter.Keyed // The limiters of the rules, pruned periodically.
+}
+
+// newRouter creates a router limiting the requests proxied to next with rules.
+func newRouter(rules *Rules, next http.Handler, metrics *expvar.Map) (*router, error) {
+	rt := &router{rules: rules.Rules, fallback: next}
+	for i := range rt.rules {
+		if rt.rules[i].Name == "" {
+			rt.rules[i].Name = fmt.Sprintf("rule-%d", i)
+		}
+		h, limiter, err := rt.rules[i].handler(next, rules, metrics)
+		if err != nil {
+			return nil, err
+		}
+		rt.handlers = append(rt.handlers, h)
+		rt.limiters = append(rt.limiters, limiter)
+	}
+
+	if rules.Default != nil {
+		if rules.Default.Name == "" {
+			rules.Default.Name = "default"
+		}
+		h, limiter, err := rules.Default.handler(next, rules, metrics)
+		if err != nil {
+			return nil, err
+		}
+		rt.fallback = h
+		rt.limiters = append(rt.limiters, limiter)
+	}
+	return rt, nil
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for i := range rt.rules {
+		if rt.rules[i].matches(r) {
+			rt.handlers[i].ServeHTTP(w, r)
+			return
+		}
+	}
+	rt.fallback.ServeHTTP(w, r)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRules = `{
+	"trustedProxies": ["10.0.0.0/8"],
+	"forwardingHeader": "X-Forwarded-For",
+	"rules": [
+		{"name": "login", "path": "/login", "method": "POST", "algorithm": "sliding-window", "rate": 1, "window": "1h"}
+	],
+	"default": {"algorithm": "sliding-window", "rate": 2, "window": "1h"}
+}`
+
+// newTestRouter creates a router of testRules in front of a handler answering
+// 200.
+func newTestRouter(t *testing.T) *router {
+	t.Helper()
+	var rules Rules
+	if err := json.Unmarshal([]byte(testRules), &rules); err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rt, err := newRouter(&rules, next, new(expvar.Map).Init())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt
+}
+
+// status returns the status code of the response of rt to a request.
+func status(rt http.Handler, method, path, forwardedFor, forwarded string) int {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", forwardedFor)
+	if forwarded != "" {
+		r.Header.Set("Forwarded", forwarded)
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRouter(t *testing.T) {
+	rt := newTestRouter(t)
+	if got := status(rt, http.MethodPost, "/login", "1.2.3.4", ""); got != http.StatusOK {
+		t.Errorf("first login: status %d, want %d", got, http.StatusOK)
+	}
+	if got := status(rt, http.MethodPost, "/login", "1.2.3.4", ""); got != http.StatusTooManyRequests {
+		t.Errorf("second login: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+	// A forged Forwarded header does not give the client a new key.
+	if got := status(rt, http.MethodPost, "/login", "1.2.3.4", "for=6.6.6.6"); got != http.StatusTooManyRequests {
+		t.Errorf("login with a forged Forwarded: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if got := status(rt, http.MethodGet, "/login", "1.2.3.4", ""); got != http.StatusOK {
+		t.Errorf("default rule: status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestRouterLimitersPruned(t *testing.T) {
+	rt := newTestRouter(t)
+	if len(rt.limiters) != 2 {
+		t.Fatalf("%d limiters, want one per rule and the default", len(rt.limiters))
+	}
+	status(rt, http.MethodGet, "/", "1.2.3.4", "")
+	if pruned := rt.limiters[1].Prune(time.Now().Add(2 * time.Hour)); pruned != 1 {
+		t.Errorf("pruned %d keys, want the replenished one", pruned)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := loadRules("rules.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Rules) != 2 || rules.Rules[1].Key != "header:X-API-Key" || time.Duration(rules.Rules[1].Window) != time.Hour || rules.Default == nil {
+		t.Errorf("rules %+v, want those of the example", rules)
+	}
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"rules": [{"window": "soon"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRules(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("invalid window: error %v, want the path of the file", err)
+	}
+}
+
+func TestRouterKeysAndMetrics(t *testing.T) {
+	rules := &Rules{
+		Rules: []Rule{
+			{Name: "api", Path: "/api/", Algorithm: "sliding-window", Rate: 1, Window: duration(time.Hour), Key: "header:X-API-Key"},
+			{Path: "/exports/", Algorithm: "sliding-window", Rate: 1, Window: duration(time.Hour), Key: "global"},
+		},
+	}
+	metrics := new(expvar.Map).Init()
+	rt, err := newRouter(rules, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(path, apiKey, addr string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = addr
+		r.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		return w.Code
+	}
+	for _, test := range []struct {
+		path, apiKey, addr string
+		want               int
+	}{
+		{"/api/orders", "a", "192.0.2.1:1", http.StatusOK},
+		{"/api/orders", "a", "192.0.2.2:1", http.StatusTooManyRequests},
+		{"/api/orders", "b", "192.0.2.1:1", http.StatusOK},
+		{"/exports/1", "a", "192.0.2.1:1", http.StatusOK},
+		{"/exports/2", "b", "192.0.2.2:1", http.StatusTooManyRequests},
+		// The requests matching no rule are passed through without default.
+		{"/", "", "192.0.2.1:1", http.StatusOK},
+		{"/", "", "192.0.2.1:1", http.StatusOK},
+	} {
+		if got := send(test.path, test.apiKey, test.addr); got != test.want {
+			t.Errorf("%s with key %q from %s: status %d, want %d", test.path, test.apiKey, test.addr, got, test.want)
+		}
+	}
+	for name, want := range map[string]string{"api.allowed": "2", "api.denied": "1", "rule-1.allowed": "1", "rule-1.denied": "1"} {
+		if got := metrics.Get(name); got == nil || got.String() != want {
+			t.Errorf("metric %s = %v, want %s", name, got, want)
+		}
+	}
+
+	rules.Rules[0].Key = "cookie:session"
+	if _, err := newRouter(rules, http.NotFoundHandler(), new(expvar.Map).Init()); err == nil {
+		t.Error("unknown key accepted")
+	}
+}
+
+func TestRouterSkipSubnetsBehindProxies(t *testing.T) {
+	rules := &Rules{
+		TrustedProxies:   []string{"10.0.0.0/8"},
+		ForwardingHeader: "X-Forwarded-For",
+		Skip:             Skip{Subnets: []string{"192.0.2.0/24"}},
+		Default:          &Rule{Algorithm: "sliding-window", Rate: 1, Window: duration(time.Hour)},
+	}
+	rt, err := newRouter(rules, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), new(expvar.Map).Init())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The subnets match the clients behind the proxy, not the proxy itself.
+	for i, test := range []struct {
+		forwardedFor string
+		want         int
+	}{
+		{"192.0.2.7", http.StatusOK},
+		{"192.0.2.7", http.StatusOK},
+		{"198.51.100.1", http.StatusOK},
+		{"198.51.100.1", http.StatusTooManyRequests},
+	} {
+		if got := status(rt, http.MethodGet, "/", test.forwardedFor, ""); got != test.want {
+			t.Errorf("request %d from %s: status %d, want %d", i, test.forwardedFor, got, test.want)
+		}
+	}
+}
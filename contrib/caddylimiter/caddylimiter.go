@@ -0,0 +1,228 @@
+// Package caddylimiter exposes the ratelimiter package as a Caddy HTTP handler
+// module, http.handlers.rate_limit, and its rate_limit Caddyfile directive:
+//
+//	rate_limit [<matcher>] {
+//		zone      <name>
+//		algorithm sliding-window|leaky-bucket|token-bucket
+//		rate      <requests>
+//		window    <duration>
+//		key       <placeholder>
+//	}
+//
+// Handlers sharing a zone name and limit share their counters, which are also
+// kept across config reloads leaving the limit of the zone unchanged; changing
+// it starts the zone over with the new limit. The key defaults to {remote_host}. The counters of the keys
+// whose budget is replenished are pruned every minute.
+package caddylimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func init() {
+	caddy.RegisterModule(Handler{})
+	httpcaddyfile.RegisterHandlerDirective("rate_limit", parseCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("rate_limit", httpcaddyfile.Before, "basic_auth")
+}
+
+// zones holds the limiters shared by the handlers using the same zone name and
+// limit, by zoneKey.
+var zones = caddy.NewUsagePool()
+
+// zone is a limiter shared between handlers.
+type zone struct {
+	*ratelimiter.Keyed
+
+	// The function stopping the pruning of the limiter.
+	stopPruning context.CancelFunc
+}
+
+// newZone returns a zone pruning its limiter until it is destructed.
+func newZone(limiter *ratelimiter.Keyed) zone {
+	ctx, cancel := context.WithCancel(context.Background())
+	go ratelimiter.PruneEvery(ctx, ratelimiter.DefaultPruneInterval, limiter)
+	return zone{Keyed: limiter, stopPruning: cancel}
+}
+
+// Destruct implements caddy.Destructor, it stops the pruning of the limiter.
+func (z zone) Destruct() error {
+	z.stopPruning()
+	return nil
+}
+
+// Handler limits the requests it handles, answering 429 Too Many Requests to
+// the denied ones through the Caddy error handling.
+type Handler struct {
+	// Name of the zone whose counters the handler shares with the handlers of
+	// the same limit. Handlers without a zone get their own counters.
+	Zone string `json:"zone,omitempty"`
+
+	// Name of the algorithm, "sliding-window", "leaky-bucket" or
+	// "token-bucket". Defaults to "leaky-bucket".
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Maximum number of requests allowed in the window.
+	Rate int `json:"rate"`
+
+	// Duration of the window.
+	Window caddy.Duration `json:"window"`
+
+	// Key of the requests, placeholders are replaced. Defaults to
+	// {http.request.remote.host}.
+	Key string `json:"key,omitempty"`
+
+	limiter ratelimiter.Limiter
+
+	// The zone of the limiter, pruned until the handler is cleaned up.
+	zone zone
+
+	// The key of the zone of the handler in zones.
+	zoneKey string
+}
+
+// CaddyModule returns the Caddy module information.
+func (Handler) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.rate_limit",
+		New: func() caddy.Module { return new(Handler) },
+	}
+}
+
+// Provision sets up the limiter of the handler, or joins its zone.
+func (h *Handler) Provision(ctx caddy.Context) error {
+	if h.Algorithm == "" {
+		h.Algorithm = ratelimiter.LeakyBucketAlgorithm
+	}
+	if h.Key == "" {
+		h.Key = "{http.request.remote.host}"
+	}
+
+	newAlgorithm, err := ratelimiter.AlgorithmFactory(h.Algorithm, h.Rate, time.Duration(h.Window))
+	if err != nil {
+		return err
+	}
+	if h.Zone == "" {
+		h.zone = newZone(ratelimiter.NewKeyed(newAlgorithm))
+		h.limiter = h.zone.Keyed
+		return nil
+	}
+
+	// The limit is part of the key so a reload changing it creates the zone
+	// anew instead of keeping the limiter of the previous one.
+	h.zoneKey = fmt.Sprintf("%s %s %d %s", h.Zone, h.Algorithm, h.Rate, time.Duration(h.Window))
+	value, _, err := zones.LoadOrNew(h.zoneKey, func() (caddy.Destructor, error) {
+		return newZone(ratelimiter.NewKeyed(newAlgorithm)), nil
+	})
+	if err != nil {
+		return err
+	}
+	h.limiter = value.(zone).Keyed
+	return nil
+}
+
+// Validate ensures the handler configuration is usable.
+func (h *Handler) Validate() error {
+	if h.Rate < 1 {
+		return errors.New("rate must be at least 1")
+	}
+	if h.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+	return nil
+}
+
+// Cleanup releases the zone of the handler, the last handler of a zone stops
+// its pruning.
+func (h *Handler) Cleanup() error {
+	if h.Zone == "" {
+		if h.zone.stopPruning != nil {
+			return h.zone.Destruct()
+		}
+		return nil
+	}
+	_, err := zones.Delete(h.zoneKey)
+	return err
+}
+
+// ServeHTTP limits the request before passing it to next.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	decision := h.limiter.Allow(repl.ReplaceAll(h.Key, ""), time.Now())
+	ratelimiter.SetHeaders(w.Header(), decision)
+
+	if !decision.Allowed {
+		return caddyhttp.Error(http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// UnmarshalCaddyfile sets up the handler from Caddyfile tokens.
+func (h *Handler) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	d.Next() // Consume the directive name.
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+
+	for d.NextBlock(0) {
+		option := d.Val()
+		if !d.NextArg() {
+			return d.ArgErr()
+		}
+		value := d.Val()
+
+		switch option {
+		case "zone":
+			h.Zone = value
+		case "algorithm":
+			h.Algorithm = value
+		case "rate":
+			rate, err := strconv.Atoi(value)
+			if err != nil {
+				return d.Errf("invalid rate %q: %v", value, err)
+			}
+			h.Rate = rate
+		case "window":
+			window, err := caddy.ParseDuration(value)
+			if err != nil {
+				return d.Errf("invalid window %q: %v", value, err)
+			}
+			h.Window = caddy.Duration(window)
+		case "key":
+			h.Key = value
+		default:
+			return d.Errf("unknown option %q", option)
+		}
+
+		if d.NextArg() {
+			return d.ArgErr()
+		}
+	}
+	return nil
+}
+
+// parseCaddyfile sets up a handler from the rate_limit directive.
+func parseCaddyfile(helper httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	h := new(Handler)
+	err := h.UnmarshalCaddyfile(helper.Dispenser)
+	return h, err
+}
+
+var (
+	_ caddy.Provisioner           = (*Handler)(nil)
+	_ caddy.Validator             = (*Handler)(nil)
+	_ caddy.CleanerUpper          = (*Handler)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Handler)(nil)
+	_ caddyfile.Unmarshaler       = (*Handler)(nil)
+)
@@ -0,0 +1,101 @@
+package caddylimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func provision(t *testing.T, h *Handler) {
+	t.Helper()
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	if err := h.Provision(ctx); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+}
+
+func TestUnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`rate_limit {
+		zone      api
+		algorithm token-bucket
+		rate      10
+		window    1m
+		key       {http.request.header.X-API-Key}
+	}`)
+
+	var h Handler
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("UnmarshalCaddyfile: %v", err)
+	}
+	want := Handler{
+		Zone:      "api",
+		Algorithm: "token-bucket",
+		Rate:      10,
+		Window:    caddy.Duration(time.Minute),
+		Key:       "{http.request.header.X-API-Key}",
+	}
+	if h.Zone != want.Zone || h.Algorithm != want.Algorithm || h.Rate != want.Rate || h.Window != want.Window || h.Key != want.Key {
+		t.Errorf("handler = %+v, want %+v", h, want)
+	}
+}
+
+func TestZoneShared(t *testing.T) {
+	first := &Handler{Zone: "shared", Rate: 1, Window: caddy.Duration(time.Hour)}
+	second := &Handler{Zone: "shared", Rate: 1, Window: caddy.Duration(time.Hour)}
+	provision(t, first)
+	provision(t, second)
+
+	now := time.Now()
+	if !first.limiter.Allow("client", now).Allowed {
+		t.Fatal("first request denied")
+	}
+	if second.limiter.Allow("client", now).Allowed {
+		t.Error("second handler does not share the counters of the zone")
+	}
+
+	for _, h := range []*Handler{first, second} {
+		if err := h.Cleanup(); err != nil {
+			t.Fatalf("Cleanup: %v", err)
+		}
+	}
+	if references, ok := zones.References(first.zoneKey); ok {
+		t.Errorf("zone still has %d references after Cleanup", references)
+	}
+}
+
+func TestZoneReloadedWithNewLimit(t *testing.T) {
+	old := &Handler{Zone: "reloaded", Rate: 1, Window: caddy.Duration(time.Hour)}
+	provision(t, old)
+	now := time.Now()
+	if !old.limiter.Allow("client", now).Allowed {
+		t.Fatal("first request denied")
+	}
+
+	// Caddy provisions the new config before cleaning up the old one.
+	reloaded := &Handler{Zone: "reloaded", Rate: 3, Window: caddy.Duration(time.Hour)}
+	provision(t, reloaded)
+	if err := old.Cleanup(); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	defer reloaded.Cleanup()
+	for i := range 3 {
+		if !reloaded.limiter.Allow("client", now).Allowed {
+			t.Fatalf("request %d denied, want the new rate of 3 enforced", i)
+		}
+	}
+	if reloaded.limiter.Allow("client", now).Allowed {
+		t.Error("request over the new rate allowed")
+	}
+
+	// An unchanged limit keeps the counters of the zone.
+	kept := &Handler{Zone: "reloaded", Rate: 3, Window: caddy.Duration(time.Hour)}
+	provision(t, kept)
+	defer kept.Cleanup()
+	if kept.limiter.Allow("client", now).Allowed {
+		t.Error("reload with the same limit reset the counters of the zone")
+	}
+}
@@ -0,0 +1,54 @@
+// Package chilimiter adapts the ratelimiter package to the chi router.
+//
+// chi middlewares are plain net/http middlewares, so the helpers in this
+// package only make it convenient to give route groups their own limits:
+//
+//	chilimiter.Route(router, "/auth", strict, func(r chi.Router) {
+//		r.Post("/login", login)
+//	})
+//	chilimiter.Route(router, "/public", lenient, func(r chi.Router) {
+//		r.Get("/articles", articles)
+//	})
+package chilimiter
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// KeyByRoutePattern keys requests by the client IP address and the route pattern
+// matched by chi, so a limiter shared between routes keeps a separate budget
+// for each of them. Middlewares mounted on a sub-router only see the pattern
+// matched so far, e.g. "/auth/*", which gives the whole group one budget.
+func KeyByRoutePattern(r *http.Request) string {
+	pattern := ""
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		pattern = rctx.RoutePattern()
+	}
+	return pattern + "|" + ratelimiter.KeyByIP(r)
+}
+
+// Limit returns a chi middleware limiting requests with limiter, for use with
+// chi.Router.Use or chi.Router.With.
+func Limit(limiter ratelimiter.Limiter, opts ...ratelimiter.Option) func(http.Handler) http.Handler {
+	return ratelimiter.Middleware(limiter, opts...)
+}
+
+// Group adds an inline group to r whose routes are limited with limiter.
+func Group(r chi.Router, limiter ratelimiter.Limiter, fn func(r chi.Router), opts ...ratelimiter.Option) chi.Router {
+	return r.Group(func(group chi.Router) {
+		group.Use(Limit(limiter, opts...))
+		fn(group)
+	})
+}
+
+// Route mounts a sub-router on pattern whose routes are limited with limiter.
+func Route(r chi.Router, pattern string, limiter ratelimiter.Limiter, fn func(r chi.Router), opts ...ratelimiter.Option) chi.Router {
+	return r.Route(pattern, func(sub chi.Router) {
+		sub.Use(Limit(limiter, opts...))
+		fn(sub)
+	})
+}
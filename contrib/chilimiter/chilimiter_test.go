@@ -0,0 +1,65 @@
+package chilimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func get(t *testing.T, handler http.Handler, path string) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestRouteLimitsGroup(t *testing.T) {
+	router := chi.NewRouter()
+	ok := func(http.ResponseWriter, *http.Request) {}
+	strict := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	Route(router, "/auth", strict, func(r chi.Router) {
+		r.Get("/login", ok)
+		r.Get("/logout", ok)
+	})
+	router.Get("/public", ok)
+
+	if code := get(t, router, "/auth/login"); code != http.StatusOK {
+		t.Errorf("first request: status %d, want %d", code, http.StatusOK)
+	}
+	// The whole group shares the budget of the client.
+	if code := get(t, router, "/auth/logout"); code != http.StatusTooManyRequests {
+		t.Errorf("second request in group: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := get(t, router, "/public"); code != http.StatusOK {
+		t.Errorf("request outside group: status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestKeyByRoutePattern(t *testing.T) {
+	router := chi.NewRouter()
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	router.With(Limit(limiter, ratelimiter.WithKeyFunc(KeyByRoutePattern))).Get("/items/{id}", func(http.ResponseWriter, *http.Request) {})
+	router.With(Limit(limiter, ratelimiter.WithKeyFunc(KeyByRoutePattern))).Get("/users/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	// Requests to one pattern share a budget whatever the parameters.
+	for i, test := range []struct {
+		path string
+		want int
+	}{
+		{"/items/1", http.StatusOK},
+		{"/items/2", http.StatusTooManyRequests},
+		{"/users/1", http.StatusOK},
+	} {
+		if code := get(t, router, test.path); code != test.want {
+			t.Errorf("request %d to %s: status %d, want %d", i, test.path, code, test.want)
+		}
+	}
+	if keys := limiter.Keys(); len(keys) != 2 {
+		t.Errorf("keys %v, want one per pattern", keys)
+	}
+}
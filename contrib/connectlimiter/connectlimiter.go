@@ -0,0 +1,130 @@
+// Package connectlimiter adapts the ratelimiter package to connect-go servers,
+// covering the Connect, gRPC and gRPC-Web protocols with a single interceptor.
+package connectlimiter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// KeyFunc extracts the key identifying the client of a call to procedure.
+type KeyFunc func(procedure string, peer connect.Peer, header http.Header) string
+
+// KeyByPeer keys calls by procedure name and the IP address of the remote peer.
+func KeyByPeer(procedure string, peer connect.Peer, header http.Header) string {
+	address := peer.Addr
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return procedure + "|" + address
+}
+
+// KeyByHeader keys calls by procedure name and the value of the given request
+// header, e.g. an API key.
+func KeyByHeader(name string) KeyFunc {
+	return func(procedure string, peer connect.Peer, header http.Header) string {
+		return procedure + "|" + header.Get(name)
+	}
+}
+
+// Option configures the interceptor.
+type Option func(*Interceptor)
+
+// WithKeyFunc sets the function used to extract the key of a call. Calls are
+// keyed by procedure name and peer address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(i *Interceptor) {
+		i.keyFunc = keyFunc
+	}
+}
+
+// WithProcedureLimiter limits calls to procedure, e.g. "/package.Service/Method",
+// with limiter instead of the default limiter.
+func WithProcedureLimiter(procedure string, limiter ratelimiter.Limiter) Option {
+	return func(i *Interceptor) {
+		i.procedureLimiters[procedure] = limiter
+	}
+}
+
+// Interceptor is a connect.Interceptor limiting the calls handled by a server.
+type Interceptor struct {
+	limiter           ratelimiter.Limiter            // The default limiter making the decisions.
+	procedureLimiters map[string]ratelimiter.Limiter // Limiters overriding the default one per procedure.
+	keyFunc           KeyFunc                        // The function extracting the key of a call.
+}
+
+// NewInterceptor creates a new interceptor limiting calls with limiter. A nil
+// limiter only limits the procedures given a WithProcedureLimiter override.
+func NewInterceptor(limiter ratelimiter.Limiter, opts ...Option) *Interceptor {
+	i := &Interceptor{
+		limiter:           limiter,
+		procedureLimiters: make(map[string]ratelimiter.Limiter),
+		keyFunc:           KeyByPeer,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// WrapUnary limits unary calls handled by the server.
+func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		if err := i.allow(req.Spec().Procedure, req.Peer(), req.Header()); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient leaves client streams untouched.
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler limits the creation of streams handled by the server.
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if err := i.allow(conn.Spec().Procedure, conn.Peer(), conn.RequestHeader()); err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// allow decides whether a call to procedure should be allowed, returning a
+// CodeResourceExhausted error when it is not.
+func (i *Interceptor) allow(procedure string, peer connect.Peer, header http.Header) error {
+	limiter, ok := i.procedureLimiters[procedure]
+	if !ok {
+		limiter = i.limiter
+	}
+	if limiter == nil {
+		return nil
+	}
+
+	decision := limiter.Allow(i.keyFunc(procedure, peer, header), time.Now())
+	if decision.Allowed {
+		return nil
+	}
+
+	err := connect.NewError(connect.CodeResourceExhausted, errors.New("rate limit exceeded for "+procedure))
+	if detail, detailErr := connect.NewErrorDetail(&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.RetryAfter)}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	// The metadata carries the rate limit headers, including Retry-After.
+	ratelimiter.SetHeaders(err.Meta(), decision)
+	return err
+}
@@ -0,0 +1,124 @@
+package connectlimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// newClient serves procedure behind interceptor, and returns a client calling it.
+func newClient(t *testing.T, procedure string, interceptor *Interceptor) *connect.Client[emptypb.Empty, emptypb.Empty] {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(procedure,
+		func(ctx context.Context, req *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+procedure)
+}
+
+func TestWrapUnary(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	client := newClient(t, "/test.Service/Method", NewInterceptor(limiter))
+
+	if _, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{})); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeResourceExhausted {
+		t.Fatalf("second call: error %v, want %v", err, connect.CodeResourceExhausted)
+	}
+	if connectErr.Meta().Get("Retry-After") == "" {
+		t.Error("error metadata without Retry-After")
+	}
+	var retryInfo bool
+	for _, detail := range connectErr.Details() {
+		if value, err := detail.Value(); err == nil {
+			_, retryInfo = value.(*errdetails.RetryInfo)
+		}
+	}
+	if !retryInfo {
+		t.Error("error without RetryInfo detail")
+	}
+}
+
+func TestWithProcedureLimiter(t *testing.T) {
+	strict := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	interceptor := NewInterceptor(nil, WithProcedureLimiter("/test.Service/Strict", strict))
+	lenient := newClient(t, "/test.Service/Lenient", interceptor)
+	limited := newClient(t, "/test.Service/Strict", interceptor)
+
+	// Without a default limiter, only the overridden procedure is limited.
+	for range 3 {
+		if _, err := lenient.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{})); err != nil {
+			t.Fatalf("call without limiter: %v", err)
+		}
+	}
+	limited.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	if _, err := limited.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{})); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("second limited call: error %v, want %v", err, connect.CodeResourceExhausted)
+	}
+}
+
+func TestWrapStreamingHandler(t *testing.T) {
+	const procedure = "/test.Service/Stream"
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewServerStreamHandler(procedure,
+		func(ctx context.Context, req *connect.Request[emptypb.Empty], stream *connect.ServerStream[emptypb.Empty]) error {
+			return stream.Send(&emptypb.Empty{})
+		},
+		connect.WithInterceptors(NewInterceptor(limiter, WithKeyFunc(KeyByHeader("X-Tenant")))),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+procedure)
+
+	// receive opens a stream for tenant, and returns the error ending it.
+	receive := func(tenant string) error {
+		req := connect.NewRequest(&emptypb.Empty{})
+		req.Header().Set("X-Tenant", tenant)
+		stream, err := client.CallServerStream(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		for stream.Receive() {
+		}
+		return stream.Err()
+	}
+	for i, test := range []struct {
+		tenant  string
+		limited bool
+	}{
+		{"acme", false},
+		{"acme", true},
+		{"globex", false},
+	} {
+		err := receive(test.tenant)
+		if limited := connect.CodeOf(err) == connect.CodeResourceExhausted; limited != test.limited || !limited && err != nil {
+			t.Errorf("stream %d of %s: error %v, limited %t, want %t", i, test.tenant, err, limited, test.limited)
+		}
+	}
+}
+
+func TestKeyByPeer(t *testing.T) {
+	key := KeyByPeer("/test.Service/Method", connect.Peer{Addr: "192.0.2.1:1234"}, nil)
+	if want := "/test.Service/Method|192.0.2.1"; key != want {
+		t.Errorf("key %q, want %q", key, want)
+	}
+}
@@ -0,0 +1,85 @@
+// Package echolimiter adapts the ratelimiter package to the Echo web framework.
+//
+// Denied requests are reported as an *echo.HTTPError with status 429, so they
+// are rendered by the Echo error handler like any other error.
+package echolimiter
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// DecisionKey is the context key under which the decision of the current request is stored.
+const DecisionKey = "ratelimiter.decision"
+
+// KeyFunc extracts the key identifying the client of a request.
+type KeyFunc func(c echo.Context) string
+
+// KeyByRealIP keys requests by the client IP address resolved by Echo.
+func KeyByRealIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// Option configures the Echo middleware.
+type Option func(*limiterMiddleware)
+
+// WithKeyFunc sets the function used to extract the key of a request. Requests
+// are keyed by client IP address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(m *limiterMiddleware) {
+		m.keyFunc = keyFunc
+	}
+}
+
+// WithSkipper sets the function deciding which requests bypass the limiter.
+func WithSkipper(skipper middleware.Skipper) Option {
+	return func(m *limiterMiddleware) {
+		m.skipper = skipper
+	}
+}
+
+type limiterMiddleware struct {
+	limiter ratelimiter.Limiter // The limiter making the decisions.
+	keyFunc KeyFunc             // The function extracting the key of a request.
+	skipper middleware.Skipper  // The function deciding which requests bypass the limiter.
+}
+
+// New returns an Echo middleware limiting requests with limiter.
+func New(limiter ratelimiter.Limiter, opts ...Option) echo.MiddlewareFunc {
+	m := &limiterMiddleware{
+		limiter: limiter,
+		keyFunc: KeyByRealIP,
+		skipper: middleware.DefaultSkipper,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if m.skipper(c) {
+				return next(c)
+			}
+
+			decision := m.limiter.Allow(m.keyFunc(c), time.Now())
+			c.Set(DecisionKey, decision)
+			ratelimiter.SetHeaders(c.Response().Header(), decision)
+
+			if !decision.Allowed {
+				return echo.NewHTTPError(http.StatusTooManyRequests)
+			}
+			return next(c)
+		}
+	}
+}
+
+// DecisionFromContext returns the decision made for the current request, if any.
+func DecisionFromContext(c echo.Context) (ratelimiter.Decision, bool) {
+	decision, ok := c.Get(DecisionKey).(ratelimiter.Decision)
+	return decision, ok
+}
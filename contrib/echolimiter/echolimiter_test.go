@@ -0,0 +1,67 @@
+package echolimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func get(t *testing.T, e *echo.Echo, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestNew(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	e := echo.New()
+	var seen ratelimiter.Decision
+	e.GET("/", func(c echo.Context) error {
+		seen, _ = DecisionFromContext(c)
+		return nil
+	}, New(limiter))
+
+	if w := get(t, e, "/"); w.Code != http.StatusOK {
+		t.Errorf("first request: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if !seen.Allowed || seen.Limit != 1 {
+		t.Errorf("decision in context %+v, want the allowed one", seen)
+	}
+	// Denials are rendered by the Echo error handler.
+	w := get(t, e, "/")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("denied response without Retry-After")
+	}
+}
+
+func TestWithSkipper(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	e := echo.New()
+	e.Use(New(limiter, WithSkipper(func(c echo.Context) bool { return c.Path() == "/health" })))
+	e.GET("/", func(echo.Context) error { return nil })
+	e.GET("/health", func(echo.Context) error { return nil })
+
+	for i, test := range []struct {
+		path string
+		want int
+	}{
+		{"/health", http.StatusOK},
+		{"/health", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/", http.StatusTooManyRequests},
+		{"/health", http.StatusOK},
+	} {
+		if w := get(t, e, test.path); w.Code != test.want {
+			t.Errorf("request %d to %s: status %d, want %d", i, test.path, w.Code, test.want)
+		}
+	}
+}
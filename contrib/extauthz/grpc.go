@@ -0,0 +1,109 @@
+// Package extauthz serves rate limit decisions over the Envoy external
+// authorization API, for service meshes enforcing limits with the ext_authz
+// filter. Both the gRPC and the HTTP flavors of the API are supported.
+//
+// Allowed requests are forwarded upstream and their responses get the
+// x-ratelimit-* headers added. Denied requests are answered by Envoy with
+// 429 Too Many Requests, the x-ratelimit-* headers and retry-after.
+package extauthz
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// KeyFunc extracts the key identifying the client of a checked request.
+type KeyFunc func(req *authv3.CheckRequest) string
+
+// KeyBySourceAddress keys requests by the address of the downstream client, as
+// seen by Envoy.
+func KeyBySourceAddress(req *authv3.CheckRequest) string {
+	return req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress()
+}
+
+// KeyByHeader keys requests by the value of the given request header, e.g. an API key.
+func KeyByHeader(name string) KeyFunc {
+	name = strings.ToLower(name)
+	return func(req *authv3.CheckRequest) string {
+		return req.GetAttributes().GetRequest().GetHttp().GetHeaders()[name]
+	}
+}
+
+// Option configures the authorization server.
+type Option func(*Server)
+
+// WithKeyFunc sets the function used to extract the key of a request. Requests
+// are keyed by source address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(s *Server) {
+		s.keyFunc = keyFunc
+	}
+}
+
+// Server is an Envoy authorization server allowing the requests allowed by its limiter.
+type Server struct {
+	limiter ratelimiter.Limiter // The limiter making the decisions.
+	keyFunc KeyFunc             // The function extracting the key of a request.
+}
+
+var _ authv3.AuthorizationServer = (*Server)(nil)
+
+// NewServer creates a new authorization server making decisions with limiter.
+// Register it with authv3.RegisterAuthorizationServer.
+func NewServer(limiter ratelimiter.Limiter, opts ...Option) *Server {
+	s := &Server{
+		limiter: limiter,
+		keyFunc: KeyBySourceAddress,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Check decides whether the request described by req should be allowed.
+func (s *Server) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	decision := s.limiter.Allow(s.keyFunc(req), time.Now())
+	headers := headerOptions(decision)
+
+	if decision.Allowed {
+		return &authv3.CheckResponse{
+			Status: &status.Status{Code: int32(codes.OK)},
+			HttpResponse: &authv3.CheckResponse_OkResponse{
+				OkResponse: &authv3.OkHttpResponse{ResponseHeadersToAdd: headers},
+			},
+		}, nil
+	}
+
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.ResourceExhausted), Message: "rate limit exceeded"},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode_TooManyRequests},
+				Headers: headers,
+				Body:    "Too Many Requests\n",
+			},
+		},
+	}, nil
+}
+
+// headerOptions returns the rate limit headers describing decision.
+func headerOptions(decision ratelimiter.Decision) []*corev3.HeaderValueOption {
+	var options []*corev3.HeaderValueOption
+	ratelimiter.WriteHeaders(func(key, value string) {
+		options = append(options, &corev3.HeaderValueOption{
+			Header:       &corev3.HeaderValue{Key: strings.ToLower(key), Value: value},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}, decision)
+	return options
+}
@@ -0,0 +1,75 @@
+package extauthz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc/codes"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// checkRequest returns a request from address carrying the given headers.
+func checkRequest(address string, headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Source: &authv3.AttributeContext_Peer{Address: &corev3.Address{
+			Address: &corev3.Address_SocketAddress{SocketAddress: &corev3.SocketAddress{Address: address}},
+		}},
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{Headers: headers}},
+	}}
+}
+
+// header returns the value of the header named key in options.
+func header(options []*corev3.HeaderValueOption, key string) string {
+	for _, option := range options {
+		if option.GetHeader().GetKey() == key {
+			return option.GetHeader().GetValue()
+		}
+	}
+	return ""
+}
+
+func TestCheck(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	server := NewServer(limiter)
+
+	resp, err := server.Check(context.Background(), checkRequest("192.0.2.1", nil))
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if codes.Code(resp.GetStatus().GetCode()) != codes.OK {
+		t.Fatalf("first request: status %v, want %v", resp.GetStatus(), codes.OK)
+	}
+	if value := header(resp.GetOkResponse().GetResponseHeadersToAdd(), "x-ratelimit-remaining"); value != "0" {
+		t.Errorf("x-ratelimit-remaining %q on allowed request, want %q", value, "0")
+	}
+
+	resp, _ = server.Check(context.Background(), checkRequest("192.0.2.1", nil))
+	if codes.Code(resp.GetStatus().GetCode()) != codes.ResourceExhausted {
+		t.Fatalf("second request: status %v, want %v", resp.GetStatus(), codes.ResourceExhausted)
+	}
+	denied := resp.GetDeniedResponse()
+	if denied.GetStatus().GetCode() != typev3.StatusCode_TooManyRequests {
+		t.Errorf("denied status %v, want %v", denied.GetStatus().GetCode(), typev3.StatusCode_TooManyRequests)
+	}
+	if header(denied.GetHeaders(), "retry-after") == "" {
+		t.Error("denied response without retry-after")
+	}
+
+	resp, _ = server.Check(context.Background(), checkRequest("192.0.2.2", nil))
+	if codes.Code(resp.GetStatus().GetCode()) != codes.OK {
+		t.Errorf("request from another client: status %v, want %v", resp.GetStatus(), codes.OK)
+	}
+}
+
+func TestKeyByHeader(t *testing.T) {
+	// Envoy passes header names in lower case.
+	req := checkRequest("192.0.2.1", map[string]string{"x-api-key": "client"})
+	if key := KeyByHeader("X-API-Key")(req); key != "client" {
+		t.Errorf("key %q, want %q", key, "client")
+	}
+}
@@ -0,0 +1,35 @@
+package extauthz
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// KeyByForwardedFor keys requests checked over HTTP by the client address Envoy
+// appended to X-Forwarded-For, the last entry of the header. Envoy must be
+// configured with use_remote_address for that entry to be trustworthy.
+func KeyByForwardedFor(r *http.Request) string {
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		return ratelimiter.KeyByIP(r)
+	}
+	last := forwarded[len(forwarded)-1]
+	return strings.TrimSpace(last[strings.LastIndex(last, ",")+1:])
+}
+
+// HTTPHandler returns a handler serving the HTTP flavor of the authorization
+// API: allowed requests are answered with 200 OK and denied ones with 429 Too
+// Many Requests, both carrying the rate limit headers. Requests are keyed with
+// KeyByForwardedFor unless another key function is given.
+//
+// Envoy must be told to pass the headers along, with allowed_upstream_headers
+// or allowed_client_headers_on_success for allowed requests and
+// allowed_client_headers for denied ones.
+func HTTPHandler(limiter ratelimiter.Limiter, opts ...ratelimiter.Option) http.Handler {
+	opts = append([]ratelimiter.Option{ratelimiter.WithKeyFunc(KeyByForwardedFor)}, opts...)
+	return ratelimiter.Middleware(limiter, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
@@ -0,0 +1,49 @@
+package extauthz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestKeyByForwardedFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded []string
+		want      string
+	}{
+		{"none", nil, "192.0.2.1"},
+		{"single", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"appended by Envoy", []string{"203.0.113.9, 198.51.100.1"}, "198.51.100.1"},
+		{"several headers", []string{"203.0.113.9", "198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header["X-Forwarded-For"] = test.forwarded
+		if key := KeyByForwardedFor(r); key != test.want {
+			t.Errorf("%s: key %q, want %q", test.name, key, test.want)
+		}
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	handler := HTTPHandler(limiter)
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("request %d: status %d, want %d", i, w.Code, want)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "1" {
+			t.Errorf("request %d: X-RateLimit-Limit %q, want %q", i, w.Header().Get("X-RateLimit-Limit"), "1")
+		}
+	}
+}
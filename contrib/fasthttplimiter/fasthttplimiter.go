@@ -0,0 +1,76 @@
+// Package fasthttplimiter adapts the ratelimiter package to fasthttp servers.
+//
+// The default key functions do not allocate: they return strings pointing into
+// the buffers of the fasthttp.RequestCtx, which are only valid until the
+// handler returns. Limiters copy a key only when they start tracking it.
+package fasthttplimiter
+
+import (
+	"net/http"
+	"unsafe"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// KeyFunc extracts the key identifying the client of a request. The returned
+// string may reference the buffers of ctx.
+type KeyFunc func(ctx *fasthttp.RequestCtx) string
+
+// KeyByIP keys requests by the IP address of the remote peer, in its binary form.
+func KeyByIP(ctx *fasthttp.RequestCtx) string {
+	return bytesToString(ctx.RemoteIP())
+}
+
+// KeyByHeader keys requests by the value of the given header, e.g. an API key.
+func KeyByHeader(name string) KeyFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		return bytesToString(ctx.Request.Header.Peek(name))
+	}
+}
+
+// Option configures the handler.
+type Option func(*handler)
+
+// WithKeyFunc sets the function used to extract the key of a request. Requests
+// are keyed by IP address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(h *handler) {
+		h.keyFunc = keyFunc
+	}
+}
+
+type handler struct {
+	limiter ratelimiter.Limiter // The limiter making the decisions.
+	keyFunc KeyFunc             // The function extracting the key of a request.
+}
+
+// New returns a handler limiting the requests passed to next with limiter.
+// Denied requests are answered with 429 Too Many Requests.
+func New(limiter ratelimiter.Limiter, next fasthttp.RequestHandler, opts ...Option) fasthttp.RequestHandler {
+	h := &handler{
+		limiter: limiter,
+		keyFunc: KeyByIP,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		decision := h.limiter.Allow(h.keyFunc(ctx), ctx.Time())
+		if !decision.Allowed {
+			// Error resets the response headers, so they are written after it.
+			ctx.Error(http.StatusText(http.StatusTooManyRequests), fasthttp.StatusTooManyRequests)
+			ratelimiter.WriteHeaders(ctx.Response.Header.Set, decision)
+			return
+		}
+		ratelimiter.WriteHeaders(ctx.Response.Header.Set, decision)
+		next(ctx)
+	}
+}
+
+// bytesToString returns a string sharing the memory of b.
+func bytesToString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
@@ -0,0 +1,56 @@
+package fasthttplimiter
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// serve passes a request with the given API key to handler through a reused
+// context, and returns the status code of the response.
+func serve(ctx *fasthttp.RequestCtx, handler fasthttp.RequestHandler, apiKey string) int {
+	var req fasthttp.Request
+	req.Header.Set("X-API-Key", apiKey)
+	ctx.Init(&req, &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234}, nil)
+	handler(ctx)
+	return ctx.Response.StatusCode()
+}
+
+func TestNew(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	handler := New(limiter, func(*fasthttp.RequestCtx) {})
+	var ctx fasthttp.RequestCtx
+
+	if code := serve(&ctx, handler, ""); code != fasthttp.StatusOK {
+		t.Errorf("first request: status %d, want %d", code, fasthttp.StatusOK)
+	}
+	if code := serve(&ctx, handler, ""); code != fasthttp.StatusTooManyRequests {
+		t.Errorf("second request: status %d, want %d", code, fasthttp.StatusTooManyRequests)
+	}
+	if len(ctx.Response.Header.Peek("Retry-After")) == 0 {
+		t.Error("denied response without Retry-After")
+	}
+}
+
+func TestKeysOutliveRequests(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	handler := New(limiter, func(*fasthttp.RequestCtx) {}, WithKeyFunc(KeyByHeader("X-API-Key")))
+	var ctx fasthttp.RequestCtx
+
+	// The keys point into the buffers of the context, the ones tracked by the
+	// limiter must not change when the context is reused.
+	want := []string{"client-a", "client-b", "client-c"}
+	for _, key := range want {
+		serve(&ctx, handler, key)
+	}
+	keys := limiter.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, want) {
+		t.Errorf("keys %q, want %q", keys, want)
+	}
+}
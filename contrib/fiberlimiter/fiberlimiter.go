@@ -0,0 +1,90 @@
+// Package fiberlimiter adapts the ratelimiter package to the Fiber web framework.
+//
+// Fiber reuses its request buffers, so the strings returned by the default key
+// functions are only valid for the duration of the handler. They are passed to
+// the limiter without copying, the limiter copies a key only when it starts
+// tracking it.
+package fiberlimiter
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// DecisionKey is the locals key under which the decision of the current request is stored.
+const DecisionKey = "ratelimiter.decision"
+
+// KeyFunc extracts the key identifying the client of a request.
+type KeyFunc func(c *fiber.Ctx) string
+
+// KeyByIP keys requests by the client IP address resolved by Fiber.
+func KeyByIP(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+// KeyByHeader keys requests by the value of the given header, e.g. an API key.
+func KeyByHeader(name string) KeyFunc {
+	return func(c *fiber.Ctx) string {
+		return c.Get(name)
+	}
+}
+
+// Option configures the Fiber handler.
+type Option func(*handler)
+
+// WithKeyFunc sets the function used to extract the key of a request. Requests
+// are keyed by client IP address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(h *handler) {
+		h.keyFunc = keyFunc
+	}
+}
+
+// WithNext sets the function deciding which requests bypass the limiter.
+func WithNext(next func(c *fiber.Ctx) bool) Option {
+	return func(h *handler) {
+		h.next = next
+	}
+}
+
+type handler struct {
+	limiter ratelimiter.Limiter     // The limiter making the decisions.
+	keyFunc KeyFunc                 // The function extracting the key of a request.
+	next    func(c *fiber.Ctx) bool // The function deciding which requests bypass the limiter.
+}
+
+// New returns a Fiber handler limiting requests with limiter. Denied requests
+// are answered with 429 Too Many Requests.
+func New(limiter ratelimiter.Limiter, opts ...Option) fiber.Handler {
+	h := &handler{
+		limiter: limiter,
+		keyFunc: KeyByIP,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if h.next != nil && h.next(c) {
+			return c.Next()
+		}
+
+		decision := h.limiter.Allow(h.keyFunc(c), time.Now())
+		c.Locals(DecisionKey, decision)
+		ratelimiter.WriteHeaders(c.Set, decision)
+
+		if !decision.Allowed {
+			return c.SendStatus(fiber.StatusTooManyRequests)
+		}
+		return c.Next()
+	}
+}
+
+// DecisionFromContext returns the decision made for the current request, if any.
+func DecisionFromContext(c *fiber.Ctx) (ratelimiter.Decision, bool) {
+	decision, ok := c.Locals(DecisionKey).(ratelimiter.Decision)
+	return decision, ok
+}
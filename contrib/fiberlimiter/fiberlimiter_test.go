@@ -0,0 +1,95 @@
+package fiberlimiter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func get(t *testing.T, app *fiber.App, path string, header http.Header) *http.Response {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	resp, err := app.Test(r)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestNew(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	app := fiber.New()
+	var seen ratelimiter.Decision
+	app.Get("/", New(limiter), func(c *fiber.Ctx) error {
+		seen, _ = DecisionFromContext(c)
+		return nil
+	})
+
+	if resp := get(t, app, "/", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("first request: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if !seen.Allowed || seen.Limit != 1 {
+		t.Errorf("decision in context %+v, want the allowed one", seen)
+	}
+	resp := get(t, app, "/", nil)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second request: status %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("denied response without Retry-After")
+	}
+}
+
+func TestKeysOutliveRequests(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	app := fiber.New()
+	app.Use(New(limiter, WithKeyFunc(KeyByHeader("X-API-Key"))))
+	app.Get("/", func(*fiber.Ctx) error { return nil })
+
+	// Fiber reuses the buffers the keys point to, the keys tracked by the
+	// limiter must not change with later requests.
+	var want []string
+	for i := range 5 {
+		key := fmt.Sprintf("client-%d", i)
+		want = append(want, key)
+		get(t, app, "/", http.Header{"X-Api-Key": {key}})
+	}
+	keys := limiter.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, want) {
+		t.Errorf("keys %v, want %v", keys, want)
+	}
+}
+
+func TestWithNext(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	app := fiber.New()
+	app.Use(New(limiter, WithNext(func(c *fiber.Ctx) bool { return c.Path() == "/health" })))
+	app.Get("/", func(*fiber.Ctx) error { return nil })
+	app.Get("/health", func(*fiber.Ctx) error { return nil })
+
+	for i, test := range []struct {
+		path string
+		want int
+	}{
+		{"/health", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/", http.StatusTooManyRequests},
+		{"/health", http.StatusOK},
+	} {
+		if resp := get(t, app, test.path, nil); resp.StatusCode != test.want {
+			t.Errorf("request %d to %s: status %d, want %d", i, test.path, resp.StatusCode, test.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package ginlimiter adapts the ratelimiter package to the Gin web framework.
+//
+// The handler can be attached to the whole engine, to a route group or to a
+// single route, so every route can be given its own limiter:
+//
+//	router.POST("/login", ginlimiter.New(strict), login)
+//	api := router.Group("/api", ginlimiter.New(lenient))
+package ginlimiter
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// DecisionKey is the context key under which the decision of the current request is stored.
+const DecisionKey = "ratelimiter.decision"
+
+// KeyFunc extracts the key identifying the client of a request.
+type KeyFunc func(c *gin.Context) string
+
+// KeyByClientIP keys requests by the client IP address resolved by Gin.
+func KeyByClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// KeyByRoute keys requests by the client IP address and the matched route, so a
+// limiter shared between routes keeps a separate budget for each of them.
+func KeyByRoute(c *gin.Context) string {
+	return c.FullPath() + "|" + c.ClientIP()
+}
+
+// Option configures the Gin handler.
+type Option func(*handler)
+
+// WithKeyFunc sets the function used to extract the key of a request. Requests
+// are keyed by client IP address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(h *handler) {
+		h.keyFunc = keyFunc
+	}
+}
+
+type handler struct {
+	limiter ratelimiter.Limiter // The limiter making the decisions.
+	keyFunc KeyFunc             // The function extracting the key of a request.
+}
+
+// New returns a Gin handler limiting requests with limiter. Denied requests are
+// aborted with 429 Too Many Requests.
+func New(limiter ratelimiter.Limiter, opts ...Option) gin.HandlerFunc {
+	h := &handler{
+		limiter: limiter,
+		keyFunc: KeyByClientIP,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return func(c *gin.Context) {
+		decision := h.limiter.Allow(h.keyFunc(c), time.Now())
+		c.Set(DecisionKey, decision)
+		ratelimiter.SetHeaders(c.Writer.Header(), decision)
+
+		if !decision.Allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": http.StatusText(http.StatusTooManyRequests)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// DecisionFromContext returns the decision made for the current request, if any.
+func DecisionFromContext(c *gin.Context) (ratelimiter.Decision, bool) {
+	value, ok := c.Get(DecisionKey)
+	if !ok {
+		return ratelimiter.Decision{}, false
+	}
+	decision, ok := value.(ratelimiter.Decision)
+	return decision, ok
+}
@@ -0,0 +1,67 @@
+package ginlimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func get(t *testing.T, engine *gin.Engine, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestNew(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	engine := gin.New()
+	var seen ratelimiter.Decision
+	engine.GET("/", New(limiter), func(c *gin.Context) {
+		seen, _ = DecisionFromContext(c)
+	})
+
+	if w := get(t, engine, "/"); w.Code != http.StatusOK {
+		t.Errorf("first request: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if !seen.Allowed || seen.Limit != 1 {
+		t.Errorf("decision in context %+v, want the allowed one", seen)
+	}
+	w := get(t, engine, "/")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("denied response without Retry-After")
+	}
+}
+
+func TestKeyByRoute(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	engine := gin.New()
+	engine.Use(New(limiter, WithKeyFunc(KeyByRoute)))
+	engine.GET("/items/:id", func(*gin.Context) {})
+	engine.GET("/users/:id", func(*gin.Context) {})
+
+	for i, test := range []struct {
+		path string
+		want int
+	}{
+		{"/items/1", http.StatusOK},
+		{"/items/2", http.StatusTooManyRequests},
+		{"/users/1", http.StatusOK},
+	} {
+		if w := get(t, engine, test.path); w.Code != test.want {
+			t.Errorf("request %d to %s: status %d, want %d", i, test.path, w.Code, test.want)
+		}
+	}
+}
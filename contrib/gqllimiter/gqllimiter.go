@@ -0,0 +1,112 @@
+// Package gqllimiter adapts the ratelimiter package to gqlgen servers, charging
+// every operation its query complexity instead of a single request.
+//
+//	srv.Use(gqllimiter.New(limiter, func(ctx context.Context) string {
+//		return auth.UserID(ctx)
+//	}))
+package gqllimiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// ErrorCode is the extensions code of the error returned for a denied operation.
+const ErrorCode = "RATE_LIMITED"
+
+// KeyFunc extracts the key identifying the caller of an operation.
+type KeyFunc func(ctx context.Context) string
+
+// CostFunc computes the cost of an operation given its computed complexity.
+type CostFunc func(ctx context.Context, opCtx *graphql.OperationContext, complexity int) int
+
+// Option configures the extension.
+type Option func(*Extension)
+
+// WithCostFunc sets the function computing the cost of an operation. The cost
+// of an operation is its complexity by default.
+func WithCostFunc(costFunc CostFunc) Option {
+	return func(e *Extension) {
+		e.costFunc = costFunc
+	}
+}
+
+// WithComplexityOptions sets the options used to compute the complexity of operations.
+func WithComplexityOptions(opts ...complexity.Option) Option {
+	return func(e *Extension) {
+		e.complexityOpts = opts
+	}
+}
+
+// Extension is a gqlgen handler extension consuming the complexity of every
+// operation from the budget of its caller.
+type Extension struct {
+	limiter        ratelimiter.Limiter      // The limiter holding the budgets.
+	keyFunc        KeyFunc                  // The function extracting the key of an operation.
+	costFunc       CostFunc                 // The function computing the cost of an operation.
+	complexityOpts []complexity.Option      // The options used to compute complexities.
+	schema         graphql.ExecutableSchema // The schema the extension was added to.
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &Extension{}
+
+// New creates a new extension consuming operation costs from limiter, under the
+// key returned by keyFunc.
+func New(limiter ratelimiter.Limiter, keyFunc KeyFunc, opts ...Option) *Extension {
+	e := &Extension{
+		limiter: limiter,
+		keyFunc: keyFunc,
+		costFunc: func(ctx context.Context, opCtx *graphql.OperationContext, complexity int) int {
+			return complexity
+		},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ExtensionName returns the name of the extension.
+func (e *Extension) ExtensionName() string {
+	return "RateLimiter"
+}
+
+// Validate records the schema used to compute complexities.
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	e.schema = schema
+	return nil
+}
+
+// MutateOperationContext consumes the cost of the operation, rejecting it when
+// the caller's budget cannot cover it.
+func (e *Extension) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+	cost := e.costFunc(ctx, opCtx, complexity.Calculate(ctx, e.schema, op, opCtx.Variables, e.complexityOpts...))
+
+	decision := e.limiter.AllowN(e.keyFunc(ctx), time.Now(), max(cost, 1))
+	if decision.Allowed {
+		return nil
+	}
+
+	err := gqlerror.Errorf("operation has a cost of %d, which exceeds the remaining budget of %d", cost, decision.Remaining)
+	err.Extensions = map[string]any{
+		"code":       ErrorCode,
+		"cost":       cost,
+		"limit":      decision.Limit,
+		"remaining":  decision.Remaining,
+		"retryAfter": decision.RetryAfter.Seconds(),
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package gqllimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+var schema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+	type Item { id: ID! name: String! }
+	type Query { items: [Item!]! }
+`})
+
+// newExtension returns an extension over a limiter with a budget of limit per hour.
+func newExtension(t *testing.T, limit int, opts ...Option) *Extension {
+	t.Helper()
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(limit, time.Hour) })
+	e := New(limiter, func(ctx context.Context) string { return "caller" }, opts...)
+	e.Validate(&graphql.ExecutableSchemaMock{
+		SchemaFunc: func() *ast.Schema { return schema },
+		ComplexityFunc: func(ctx context.Context, typeName, fieldName string, childComplexity int, args map[string]any) (int, bool) {
+			return 0, false
+		},
+	})
+	return e
+}
+
+func operation(query string) *graphql.OperationContext {
+	return &graphql.OperationContext{Doc: gqlparser.MustLoadQuery(schema, query)}
+}
+
+func TestComplexityCost(t *testing.T) {
+	// The query has a complexity of 3: one for each field.
+	e := newExtension(t, 5)
+	op := operation(`{ items { id name } }`)
+
+	if err := e.MutateOperationContext(context.Background(), op); err != nil {
+		t.Fatalf("first operation: %v", err)
+	}
+	err := e.MutateOperationContext(context.Background(), op)
+	if err == nil {
+		t.Fatal("second operation allowed over the budget")
+	}
+	if err.Extensions["code"] != ErrorCode || err.Extensions["cost"] != 3 || err.Extensions["remaining"] != 2 {
+		t.Errorf("extensions %v, want the cost and remaining budget", err.Extensions)
+	}
+	// A cheaper operation still fits in the remaining budget.
+	if err := e.MutateOperationContext(context.Background(), operation(`{ items { id } }`)); err != nil {
+		t.Errorf("cheaper operation: %v", err)
+	}
+}
+
+func TestWithCostFunc(t *testing.T) {
+	e := newExtension(t, 10, WithCostFunc(func(ctx context.Context, opCtx *graphql.OperationContext, complexity int) int {
+		return complexity * 3
+	}))
+	op := operation(`{ items { id name } }`)
+
+	if err := e.MutateOperationContext(context.Background(), op); err != nil {
+		t.Fatalf("first operation: %v", err)
+	}
+	if err := e.MutateOperationContext(context.Background(), op); err == nil {
+		t.Error("second operation allowed over the budget")
+	}
+}
+
+func TestWithComplexityOptions(t *testing.T) {
+	// Without the scalar fields, the query has a complexity of 1.
+	e := newExtension(t, 2, WithComplexityOptions(complexity.WithFixedScalarValue(0)))
+	op := operation(`{ items { id name } }`)
+
+	for i := range 2 {
+		if err := e.MutateOperationContext(context.Background(), op); err != nil {
+			t.Fatalf("operation %d: %v", i, err)
+		}
+	}
+	if err := e.MutateOperationContext(context.Background(), op); err == nil || err.Extensions["cost"] != 1 {
+		t.Errorf("third operation: error %v, want a denial at a cost of 1", err)
+	}
+}
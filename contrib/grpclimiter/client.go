@@ -0,0 +1,186 @@
+package grpclimiter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// ClientKeyFunc extracts the key under which a call to method on the target of
+// a client connection is paced.
+type ClientKeyFunc func(ctx context.Context, target, method string) string
+
+// KeyByTarget paces every call to the same target together.
+func KeyByTarget(ctx context.Context, target, method string) string {
+	return target
+}
+
+// KeyByTargetMethod paces the calls to every method of a target separately.
+func KeyByTargetMethod(ctx context.Context, target, method string) string {
+	return target + method
+}
+
+// ClientOption configures the client interceptors.
+type ClientOption func(*clientInterceptor)
+
+// WithClientKeyFunc sets the function used to extract the key of a call. Calls
+// are keyed by target by default.
+func WithClientKeyFunc(keyFunc ClientKeyFunc) ClientOption {
+	return func(i *clientInterceptor) {
+		i.keyFunc = keyFunc
+	}
+}
+
+// WithReserve paces calls by reserving their slot up front instead of waiting
+// for the limiter to allow them. The limiter must implement ratelimiter.Reserver.
+func WithReserve() ClientOption {
+	return func(i *clientInterceptor) {
+		i.reserve = true
+	}
+}
+
+type clientInterceptor struct {
+	limiter  ratelimiter.Limiter  // The limiter pacing the calls.
+	keyFunc  ClientKeyFunc        // The function extracting the key of a call.
+	reserve  bool                 // Whether to pace calls with Reserve instead of Wait.
+	mu       sync.Mutex           // Protects pushback.
+	pushback map[string]time.Time // Time until which the server asked each key to back off.
+}
+
+func newClientInterceptor(limiter ratelimiter.Limiter, opts []ClientOption) *clientInterceptor {
+	i := &clientInterceptor{
+		limiter:  limiter,
+		keyFunc:  KeyByTarget,
+		pushback: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// wait blocks until a call for key may be sent, honoring the server pushback first.
+func (i *clientInterceptor) wait(ctx context.Context, key string) error {
+	i.mu.Lock()
+	until, ok := i.pushback[key]
+	if ok && !time.Now().Before(until) {
+		delete(i.pushback, key)
+	}
+	i.mu.Unlock()
+
+	if ok {
+		if err := sleepUntil(ctx, until); err != nil {
+			return err
+		}
+	}
+
+	if reserver, ok := i.limiter.(ratelimiter.Reserver); ok && i.reserve {
+		return ratelimiter.WaitReservation(ctx, reserver, key)
+	}
+	return ratelimiter.Wait(ctx, i.limiter, key)
+}
+
+// observe records the retry delay the server asked for when a call was rejected.
+func (i *clientInterceptor) observe(key string, err error, trailer metadata.MD) {
+	if status.Code(err) != codes.ResourceExhausted {
+		return
+	}
+	delay, ok := retryDelay(err, trailer)
+	if !ok {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	until := time.Now().Add(delay)
+	if until.After(i.pushback[key]) {
+		i.pushback[key] = until
+	}
+}
+
+// retryDelay extracts the retry delay from the RetryInfo detail of err, or from
+// the retry-after trailer.
+func retryDelay(err error, trailer metadata.MD) (time.Duration, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	if values := trailer.Get("retry-after"); len(values) > 0 {
+		if seconds, err := strconv.Atoi(values[0]); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// UnaryClientInterceptor returns a unary client interceptor pacing outgoing
+// calls with limiter. Calls rejected with ResourceExhausted make the following
+// calls for the same key wait for the retry delay given by the server.
+func UnaryClientInterceptor(limiter ratelimiter.Limiter, opts ...ClientOption) grpc.UnaryClientInterceptor {
+	i := newClientInterceptor(limiter, opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		key := i.keyFunc(ctx, cc.Target(), method)
+		if err := i.wait(ctx, key); err != nil {
+			return waitError(err)
+		}
+
+		var trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(callOpts, grpc.Trailer(&trailer))...)
+		i.observe(key, err, trailer)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a stream client interceptor pacing the
+// creation of outgoing streams with limiter.
+func StreamClientInterceptor(limiter ratelimiter.Limiter, opts ...ClientOption) grpc.StreamClientInterceptor {
+	i := newClientInterceptor(limiter, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		key := i.keyFunc(ctx, cc.Target(), method)
+		if err := i.wait(ctx, key); err != nil {
+			return nil, waitError(err)
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, callOpts...)
+		i.observe(key, err, nil)
+		return stream, err
+	}
+}
+
+// waitError converts an error returned while pacing a call to a gRPC status error.
+func waitError(err error) error {
+	if errors.Is(err, ratelimiter.ErrWaitExceedsDeadline) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.FromContextError(err).Err()
+}
+
+// sleepUntil waits until t, or until ctx is done.
+func sleepUntil(ctx context.Context, t time.Time) error {
+	delay := time.Until(t)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,147 @@
+package grpclimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func newClientConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	cc, err := grpc.NewClient("passthrough:///test", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestUnaryClientInterceptorPushback(t *testing.T) {
+	interceptor := UnaryClientInterceptor(newLimiter(100))
+	cc := newClientConn(t)
+	pushback := resourceExhausted("/test.Service/Method", 200*time.Millisecond)
+	invoke := func(err error) error {
+		return interceptor(context.Background(), "/test.Service/Method", nil, nil, cc,
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return err
+			})
+	}
+
+	if err := invoke(pushback); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("rejected call: error %v, want %v", err, codes.ResourceExhausted)
+	}
+	// The next call waits for the retry delay given by the server.
+	start := time.Now()
+	if err := invoke(nil); err != nil {
+		t.Fatalf("next call: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("next call sent after %s, want the retry delay honored", elapsed)
+	}
+}
+
+func TestUnaryClientInterceptorDeadline(t *testing.T) {
+	interceptor := UnaryClientInterceptor(newLimiter(1))
+	cc := newClientConn(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	interceptor(ctx, "/test.Service/Method", nil, nil, cc, invoker)
+	// The limit is only available again in an hour, past the deadline.
+	err := interceptor(ctx, "/test.Service/Method", nil, nil, cc, invoker)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("paced call: error %v, want %v", err, codes.DeadlineExceeded)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		trailer metadata.MD
+		want    time.Duration
+		ok      bool
+	}{
+		{"retry info", resourceExhausted("/m", 3*time.Second), nil, 3 * time.Second, true},
+		{"trailer", status.Error(codes.ResourceExhausted, "slow down"), metadata.Pairs("retry-after", "5"), 5 * time.Second, true},
+		{"none", status.Error(codes.ResourceExhausted, "slow down"), nil, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := retryDelay(test.err, test.trailer)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: delay %s, %t, want %s, %t", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestStreamClientInterceptorPushback(t *testing.T) {
+	interceptor := StreamClientInterceptor(newLimiter(100))
+	cc := newClientConn(t)
+	pushback := resourceExhausted("/test.Service/Stream", 200*time.Millisecond)
+	open := func(err error) error {
+		_, err = interceptor(context.Background(), &grpc.StreamDesc{}, cc, "/test.Service/Stream",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return nil, err
+			})
+		return err
+	}
+
+	if err := open(pushback); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("rejected stream: error %v, want %v", err, codes.ResourceExhausted)
+	}
+	start := time.Now()
+	if err := open(nil); err != nil {
+		t.Fatalf("next stream: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("next stream opened after %s, want the retry delay honored", elapsed)
+	}
+}
+
+// reservingLimiter counts the reservations made through it.
+type reservingLimiter struct {
+	*ratelimiter.Keyed
+	reserved int
+}
+
+func (l *reservingLimiter) Reserve(key string, requestTime time.Time) time.Duration {
+	l.reserved++
+	return l.Keyed.Reserve(key, requestTime)
+}
+
+func TestWithReserve(t *testing.T) {
+	cc := newClientConn(t)
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	for _, test := range []struct {
+		name string
+		opts []ClientOption
+		want int
+	}{
+		{"wait", nil, 0},
+		{"reserve", []ClientOption{WithReserve()}, 2},
+	} {
+		limiter := &reservingLimiter{Keyed: newLimiter(100)}
+		interceptor := UnaryClientInterceptor(limiter, test.opts...)
+		for range 2 {
+			if err := interceptor(context.Background(), "/test.Service/Method", nil, nil, cc, invoker); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+		if limiter.reserved != test.want {
+			t.Errorf("%s: %d reservations, want %d", test.name, limiter.reserved, test.want)
+		}
+	}
+}
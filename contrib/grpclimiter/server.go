@@ -0,0 +1,137 @@
+// Package grpclimiter adapts the ratelimiter package to gRPC servers and clients.
+package grpclimiter
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// KeyFunc extracts the key identifying the client of a call to fullMethod.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// KeyByPeer keys calls by method name and the IP address of the remote peer.
+func KeyByPeer(ctx context.Context, fullMethod string) string {
+	address := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		address = p.Addr.String()
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			address = host
+		}
+	}
+	return fullMethod + "|" + address
+}
+
+// KeyByMetadata keys calls by method name and the first value of the given
+// incoming metadata entry, e.g. an API key.
+func KeyByMetadata(name string) KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		value := ""
+		if values := metadata.ValueFromIncomingContext(ctx, name); len(values) > 0 {
+			value = values[0]
+		}
+		return fullMethod + "|" + value
+	}
+}
+
+// Option configures the server interceptors.
+type Option func(*interceptor)
+
+// WithKeyFunc sets the function used to extract the key of a call. Calls are
+// keyed by method name and peer address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(i *interceptor) {
+		i.keyFunc = keyFunc
+	}
+}
+
+// WithMethodLimiter limits calls to fullMethod, e.g. "/package.Service/Method",
+// with limiter instead of the default limiter.
+func WithMethodLimiter(fullMethod string, limiter ratelimiter.Limiter) Option {
+	return func(i *interceptor) {
+		i.methodLimiters[fullMethod] = limiter
+	}
+}
+
+type interceptor struct {
+	limiter          ratelimiter.Limiter            // The default limiter making the decisions.
+	methodLimiters   map[string]ratelimiter.Limiter // Limiters overriding the default one per method.
+	keyFunc          KeyFunc                        // The function extracting the key of a call.
+	newRecvAlgorithm func() ratelimiter.Algorithm   // Factory of the per stream received messages algorithm.
+	newSendAlgorithm func() ratelimiter.Algorithm   // Factory of the per stream sent messages algorithm.
+}
+
+func newInterceptor(limiter ratelimiter.Limiter, opts []Option) *interceptor {
+	i := &interceptor{
+		limiter:        limiter,
+		methodLimiters: make(map[string]ratelimiter.Limiter),
+		keyFunc:        KeyByPeer,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// allow decides whether a call to fullMethod should be allowed, returning a
+// ResourceExhausted error when it is not.
+func (i *interceptor) allow(ctx context.Context, fullMethod string) error {
+	limiter, ok := i.methodLimiters[fullMethod]
+	if !ok {
+		limiter = i.limiter
+	}
+	if limiter == nil {
+		return nil
+	}
+
+	decision := limiter.Allow(i.keyFunc(ctx, fullMethod), time.Now())
+	if decision.Allowed {
+		return nil
+	}
+
+	grpc.SetTrailer(ctx, metadata.Pairs(
+		"x-ratelimit-limit", strconv.Itoa(decision.Limit),
+		"x-ratelimit-remaining", strconv.Itoa(decision.Remaining),
+		"retry-after", strconv.Itoa(ceilSeconds(decision.RetryAfter)),
+	))
+	return resourceExhausted(fullMethod, decision.RetryAfter)
+}
+
+// resourceExhausted builds the error returned for a denied call, carrying the
+// retry delay as a RetryInfo detail.
+func resourceExhausted(fullMethod string, retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded for "+fullMethod)
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// UnaryServerInterceptor returns a unary server interceptor limiting calls with
+// limiter. A nil limiter only limits the methods given a WithMethodLimiter override.
+func UnaryServerInterceptor(limiter ratelimiter.Limiter, opts ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(limiter, opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := i.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ceilSeconds rounds d up to the next whole second.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
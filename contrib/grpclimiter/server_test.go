@@ -0,0 +1,88 @@
+package grpclimiter
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// newLimiter returns a limiter allowing limit calls per hour and key.
+func newLimiter(limit int) *ratelimiter.Keyed {
+	return ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(limit, time.Hour) })
+}
+
+// peerContext returns a context of a call from the given address.
+func peerContext(address string) context.Context {
+	addr, _ := net.ResolveTCPAddr("tcp", address)
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+}
+
+func callUnary(interceptor grpc.UnaryServerInterceptor, ctx context.Context, fullMethod string) error {
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	return err
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(newLimiter(1))
+	ctx := peerContext("192.0.2.1:1234")
+
+	if err := callUnary(interceptor, ctx, "/test.Service/Method"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	err := callUnary(interceptor, ctx, "/test.Service/Method")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second call: error %v, want %v", err, codes.ResourceExhausted)
+	}
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		retryInfo, _ = detail.(*errdetails.RetryInfo)
+	}
+	if retryInfo == nil || retryInfo.GetRetryDelay().AsDuration() <= 0 {
+		t.Errorf("retry info %v, want a positive delay", retryInfo)
+	}
+
+	// Other methods and other peers have their own budget.
+	if err := callUnary(interceptor, ctx, "/test.Service/Other"); err != nil {
+		t.Errorf("call to another method: %v", err)
+	}
+	if err := callUnary(interceptor, peerContext("192.0.2.2:1234"), "/test.Service/Method"); err != nil {
+		t.Errorf("call from another peer: %v", err)
+	}
+}
+
+func TestWithMethodLimiter(t *testing.T) {
+	interceptor := UnaryServerInterceptor(nil, WithMethodLimiter("/test.Service/Strict", newLimiter(1)))
+	ctx := peerContext("192.0.2.1:1234")
+
+	for range 3 {
+		if err := callUnary(interceptor, ctx, "/test.Service/Lenient"); err != nil {
+			t.Fatalf("call without limiter: %v", err)
+		}
+	}
+	callUnary(interceptor, ctx, "/test.Service/Strict")
+	if err := callUnary(interceptor, ctx, "/test.Service/Strict"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second limited call: error %v, want %v", err, codes.ResourceExhausted)
+	}
+}
+
+func TestKeyByMetadata(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "client"))
+	if key, want := KeyByMetadata("x-api-key")(ctx, "/test.Service/Method"), "/test.Service/Method|client"; key != want {
+		t.Errorf("key %q, want %q", key, want)
+	}
+	if key, want := KeyByPeer(peerContext("192.0.2.1:1234"), "/test.Service/Method"), "/test.Service/Method|192.0.2.1"; key != want {
+		t.Errorf("key %q, want %q", key, want)
+	}
+}
@@ -0,0 +1,83 @@
+package grpclimiter
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// WithRecvMessageLimit limits the rate of messages received on every stream,
+// using a new algorithm instance from newAlgorithm for each stream.
+func WithRecvMessageLimit(newAlgorithm func() ratelimiter.Algorithm) Option {
+	return func(i *interceptor) {
+		i.newRecvAlgorithm = newAlgorithm
+	}
+}
+
+// WithSendMessageLimit limits the rate of messages sent on every stream, using
+// a new algorithm instance from newAlgorithm for each stream.
+func WithSendMessageLimit(newAlgorithm func() ratelimiter.Algorithm) Option {
+	return func(i *interceptor) {
+		i.newSendAlgorithm = newAlgorithm
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor limiting the
+// creation of streams with limiter and, when configured, the messages sent and
+// received within each stream. A nil limiter only limits the creation of streams
+// for the methods given a WithMethodLimiter override.
+func StreamServerInterceptor(limiter ratelimiter.Limiter, opts ...Option) grpc.StreamServerInterceptor {
+	i := newInterceptor(limiter, opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		if i.newRecvAlgorithm == nil && i.newSendAlgorithm == nil {
+			return handler(srv, ss)
+		}
+
+		// Each direction gets its own algorithm, gRPC allows at most one goroutine
+		// to send and one to receive on a stream at a time.
+		stream := &limitedStream{ServerStream: ss, fullMethod: info.FullMethod}
+		if i.newRecvAlgorithm != nil {
+			stream.recv = i.newRecvAlgorithm()
+		}
+		if i.newSendAlgorithm != nil {
+			stream.send = i.newSendAlgorithm()
+		}
+		return handler(srv, stream)
+	}
+}
+
+// limitedStream is a grpc.ServerStream limiting the rate of its messages.
+type limitedStream struct {
+	grpc.ServerStream
+	fullMethod string                // The method the stream belongs to.
+	recv       ratelimiter.Algorithm // The algorithm limiting received messages, if any.
+	send       ratelimiter.Algorithm // The algorithm limiting sent messages, if any.
+}
+
+// RecvMsg receives a message, failing the stream when messages arrive too fast.
+func (s *limitedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.recv != nil {
+		if decision := s.recv.Allow(time.Now()); !decision.Allowed {
+			return resourceExhausted(s.fullMethod, decision.RetryAfter)
+		}
+	}
+	return nil
+}
+
+// SendMsg sends a message, failing the stream when messages are sent too fast.
+func (s *limitedStream) SendMsg(m any) error {
+	if s.send != nil {
+		if decision := s.send.Allow(time.Now()); !decision.Allowed {
+			return resourceExhausted(s.fullMethod, decision.RetryAfter)
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
@@ -0,0 +1,61 @@
+package grpclimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// fakeStream is a grpc.ServerStream whose messages always go through.
+type fakeStream struct {
+	grpc.ServerStream
+}
+
+func (fakeStream) Context() context.Context { return peerContext("192.0.2.1:1234") }
+func (fakeStream) RecvMsg(m any) error      { return nil }
+func (fakeStream) SendMsg(m any) error      { return nil }
+
+func TestStreamServerInterceptorMessages(t *testing.T) {
+	perStream := func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(2, time.Hour) }
+	interceptor := StreamServerInterceptor(nil, WithRecvMessageLimit(perStream), WithSendMessageLimit(perStream))
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+
+	for range 2 {
+		// Every stream gets its own budget of messages in each direction.
+		var recvErrs, sendErrs []error
+		err := interceptor(nil, fakeStream{}, info, func(srv any, ss grpc.ServerStream) error {
+			for range 3 {
+				recvErrs = append(recvErrs, ss.RecvMsg(nil))
+				sendErrs = append(sendErrs, ss.SendMsg(nil))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		for i, errs := range [][]error{recvErrs, sendErrs} {
+			if errs[0] != nil || errs[1] != nil || status.Code(errs[2]) != codes.ResourceExhausted {
+				t.Errorf("direction %d: errors %v, want the third message denied", i, errs)
+			}
+		}
+	}
+}
+
+func TestStreamServerInterceptorCreation(t *testing.T) {
+	interceptor := StreamServerInterceptor(newLimiter(1))
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+	handler := func(srv any, ss grpc.ServerStream) error { return nil }
+
+	if err := interceptor(nil, fakeStream{}, info, handler); err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+	if err := interceptor(nil, fakeStream{}, info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second stream: error %v, want %v", err, codes.ResourceExhausted)
+	}
+}
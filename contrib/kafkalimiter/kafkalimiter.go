@@ -0,0 +1,119 @@
+// Package kafkalimiter paces the messages fetched from Kafka with a limiter, so
+// the systems fed by a consumer are not overwhelmed while it catches up on a
+// backlog.
+//
+//	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm {
+//		return ratelimiter.NewLeakyBucket(500, time.Second)
+//	})
+//	consumer := kafkalimiter.NewConsumer(kafka.NewReader(config), limiter, kafkalimiter.WithKeyFunc(kafkalimiter.KeyByPartition))
+package kafkalimiter
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Reader is the part of *kafka.Reader used by the consumer.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KeyFunc extracts the key under which a message is paced.
+type KeyFunc func(msg kafka.Message) string
+
+// KeyByTopic paces the messages of each topic separately.
+func KeyByTopic(msg kafka.Message) string {
+	return msg.Topic
+}
+
+// KeyByPartition paces the messages of each partition separately.
+func KeyByPartition(msg kafka.Message) string {
+	return msg.Topic + "/" + strconv.Itoa(msg.Partition)
+}
+
+// Option configures a Consumer.
+type Option func(*Consumer)
+
+// WithKeyFunc sets the function used to extract the key of a message. Messages
+// are keyed by topic by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(c *Consumer) {
+		c.keyFunc = keyFunc
+	}
+}
+
+// WithReserve paces messages by reserving their slot up front instead of waiting
+// for the limiter to allow them. It is ignored unless the limiter implements
+// ratelimiter.Reserver.
+func WithReserve() Option {
+	return func(c *Consumer) {
+		c.reserve = true
+	}
+}
+
+// Consumer fetches messages from a Reader no faster than its limiter allows.
+type Consumer struct {
+	reader  Reader              // The reader fetching the messages.
+	limiter ratelimiter.Limiter // The limiter pacing the messages.
+	keyFunc KeyFunc             // The function extracting the key of a message.
+	reserve bool                // Whether to pace messages with Reserve instead of Wait.
+}
+
+// NewConsumer creates a new consumer fetching messages from reader once limiter
+// allows them.
+func NewConsumer(reader Reader, limiter ratelimiter.Limiter, opts ...Option) *Consumer {
+	c := &Consumer{
+		reader:  reader,
+		limiter: limiter,
+		keyFunc: KeyByTopic,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// FetchMessage fetches the next message and returns it once it may be processed.
+// A message fetched while ctx is done is returned along with the error, it has
+// not been committed and is delivered again after a restart.
+func (c *Consumer) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return msg, err
+	}
+
+	key := c.keyFunc(msg)
+	if reserver, ok := c.limiter.(ratelimiter.Reserver); ok && c.reserve {
+		err = ratelimiter.WaitReservation(ctx, reserver, key)
+	} else {
+		err = ratelimiter.Wait(ctx, c.limiter, key)
+	}
+	return msg, err
+}
+
+// CommitMessages commits the given messages with the underlying reader.
+func (c *Consumer) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return c.reader.CommitMessages(ctx, msgs...)
+}
+
+// Consume fetches messages until ctx is done or an error occurs, passing each
+// one to handle and committing it once handle returns without error.
+func (c *Consumer) Consume(ctx context.Context, handle func(ctx context.Context, msg kafka.Message) error) error {
+	for {
+		msg, err := c.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := handle(ctx, msg); err != nil {
+			return err
+		}
+		if err := c.CommitMessages(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package kafkalimiter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// fakeReader is a Reader serving a fixed list of messages.
+type fakeReader struct {
+	messages  []kafka.Message // The messages left to fetch.
+	committed []kafka.Message // The messages committed so far.
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.messages) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func TestConsume(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{{Offset: 1}, {Offset: 2}, {Offset: 3}}}
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(10, time.Hour) })
+	failure := errors.New("handler failed")
+
+	err := NewConsumer(reader, limiter).Consume(context.Background(), func(ctx context.Context, msg kafka.Message) error {
+		if msg.Offset == 3 {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("error %v, want %v", err, failure)
+	}
+	// The message the handler failed is not committed.
+	if len(reader.committed) != 2 {
+		t.Errorf("committed %v, want the first two messages", reader.committed)
+	}
+}
+
+func TestFetchMessagePacedByPartition(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Topic: "events", Partition: 0},
+		{Topic: "events", Partition: 1},
+		{Topic: "events", Partition: 0},
+	}}
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	consumer := NewConsumer(reader, limiter, WithKeyFunc(KeyByPartition))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i, want := range []error{nil, nil, ratelimiter.ErrWaitExceedsDeadline} {
+		if _, err := consumer.FetchMessage(ctx); !errors.Is(err, want) {
+			t.Errorf("message %d: error %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestFetchMessageWaits(t *testing.T) {
+	reader := &fakeReader{messages: make([]kafka.Message, 3)}
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewLeakyBucket(1, 100*time.Millisecond) })
+	consumer := NewConsumer(reader, limiter, WithReserve())
+
+	start := time.Now()
+	for range 3 {
+		if _, err := consumer.FetchMessage(context.Background()); err != nil {
+			t.Fatalf("FetchMessage: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("three messages fetched in %s, want them paced 100ms apart", elapsed)
+	}
+}
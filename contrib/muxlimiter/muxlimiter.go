@@ -0,0 +1,46 @@
+// Package muxlimiter adapts the ratelimiter package to the gorilla/mux router,
+// keying requests on the template of the matched route rather than the raw
+// path, so /users/123 and /users/456 share the budget of /users/{id}.
+//
+//	router.Use(muxlimiter.Middleware(limiter))
+package muxlimiter
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// RouteTemplate returns the path template of the route matched for r, or the
+// raw path when no route matched.
+func RouteTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// KeyByRouteTemplate keys requests by route template and client IP address.
+func KeyByRouteTemplate(r *http.Request) string {
+	return RouteTemplate(r) + "|" + ratelimiter.KeyByIP(r)
+}
+
+// KeyByRouteTemplateWith keys requests by route template and the key returned
+// by keyFunc, e.g. ratelimiter.KeyByHeader("X-API-Key").
+func KeyByRouteTemplateWith(keyFunc ratelimiter.KeyFunc) ratelimiter.KeyFunc {
+	return func(r *http.Request) string {
+		return RouteTemplate(r) + "|" + keyFunc(r)
+	}
+}
+
+// Middleware returns a mux middleware limiting requests with limiter, keyed by
+// route template and client IP address unless another key function is given.
+// It must be added with mux.Router.Use so routes are matched before it runs.
+func Middleware(limiter ratelimiter.Limiter, opts ...ratelimiter.Option) mux.MiddlewareFunc {
+	opts = append([]ratelimiter.Option{ratelimiter.WithKeyFunc(KeyByRouteTemplate)}, opts...)
+	return ratelimiter.Middleware(limiter, opts...)
+}
@@ -0,0 +1,57 @@
+package muxlimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestMiddlewareKeysByTemplate(t *testing.T) {
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })
+	router := mux.NewRouter()
+	router.Use(Middleware(limiter))
+	router.HandleFunc("/users/{id}", func(http.ResponseWriter, *http.Request) {})
+	router.HandleFunc("/orders/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	for i, test := range []struct {
+		path string
+		want int
+	}{
+		{"/users/123", http.StatusOK},
+		{"/users/456", http.StatusTooManyRequests},
+		{"/orders/123", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.want {
+			t.Errorf("request %d to %s: status %d, want %d", i, test.path, w.Code, test.want)
+		}
+	}
+}
+
+func TestKeyByRouteTemplateWith(t *testing.T) {
+	var key string
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		key = KeyByRouteTemplateWith(ratelimiter.KeyByHeader("X-API-Key"))(r)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	r.Header.Set("X-API-Key", "client")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if want := "/users/{id}|client"; key != want {
+		t.Errorf("key %q, want %q", key, want)
+	}
+}
+
+func TestRouteTemplateUnmatched(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/raw/path", nil)
+	if template := RouteTemplate(r); template != "/raw/path" {
+		t.Errorf("template %q, want the raw path", template)
+	}
+}
@@ -0,0 +1,310 @@
+// Package otellimiter instruments limiters with OpenTelemetry, as an
+// alternative to the Prometheus metrics of the ratelimiter/metrics package for
+// services exporting their telemetry to an OpenTelemetry collector.
+//
+//	m, err := otellimiter.NewMetrics()
+//	limiter = m.Instrument("api", limiter)
+//
+// Decisions also show up in distributed traces with a Tracer:
+//
+//	t := otellimiter.NewTracer()
+//	handler = ratelimiter.Middleware(limiter, ratelimiter.WithOnDecision(t.OnDecision("api")))(handler)
+//
+// When the instrumented limiter is used by ratelimiter.Middleware, measurements
+// are recorded with the context of the request, so the SDK attaches the trace
+// of sampled requests to them as exemplars, linking denials to their traces.
+//
+// The meter and tracer providers default to the global ones.
+package otellimiter
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// scope is the instrumentation scope of the meters and tracers.
+const scope = "github.com/minhpq331/ratelimiter-example/contrib/otellimiter"
+
+// Attribute keys recorded with the metrics and spans, following the
+// OpenTelemetry naming conventions.
+const (
+	LimiterKey   = attribute.Key("ratelimit.limiter") // Name of the instrumented limiter.
+	ResultKey    = attribute.Key("ratelimit.result")  // Outcome of the decision, "allowed" or "denied".
+	ErrorTypeKey = attribute.Key("error.type")        // Type of the backend error.
+	PhaseKey     = attribute.Key("ratelimit.phase")   // Phase of the decision duration, "local" or "backend".
+)
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+// WithMeterProvider sets the provider of the meter recording the metrics.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// WithAttributes adds attributes to every measurement, span and event, e.g. the
+// region the limiters run in.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *config) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+type config struct {
+	meterProvider  metric.MeterProvider // The provider of the meter.
+	tracerProvider trace.TracerProvider // The provider of the tracer.
+	spans          bool                 // Whether to record decisions as spans rather than events.
+	attrs          []attribute.KeyValue // The attributes added to every measurement, span and event.
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		meterProvider:  otel.GetMeterProvider(),
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// keyCounter is implemented by the limiters able to report the number of keys
+// they track, such as ratelimiter.Keyed.
+type keyCounter interface {
+	Len() int
+}
+
+// memoryReporter is implemented by the limiters able to estimate the memory
+// held by their state, such as ratelimiter.Keyed.
+type memoryReporter interface {
+	MemoryUsage() int
+}
+
+// evictor is implemented by the limiters forgetting idle keys, such as
+// ratelimiter.Keyed with Prune.
+type evictor interface {
+	Evictions() uint64
+}
+
+// Metrics records the decisions of the limiters it instruments.
+type Metrics struct {
+	decisions     metric.Int64Counter     // Counts the decisions by limiter and result.
+	duration      metric.Float64Histogram // Records the duration of the decisions.
+	backendErrors metric.Int64Counter     // Counts the backend errors by limiter.
+	attrs         []attribute.KeyValue    // The attributes added to every measurement.
+
+	mu       sync.Mutex
+	counters map[string]keyCounter     // Map to hold the limiters reporting their keys, by name.
+	memories map[string]memoryReporter // Map to hold the limiters reporting their memory, by name.
+	evictors map[string]evictor        // Map to hold the limiters reporting their evictions, by name.
+}
+
+// NewMetrics creates the instruments recording the decisions of limiters:
+//
+//	ratelimit.decisions         counter of the decisions, by limiter and result
+//	ratelimit.decision.duration histogram of the duration of the decisions, in seconds, by phase
+//	ratelimit.keys              gauge of the number of keys tracked by a limiter
+//	ratelimit.memory            gauge of the estimated memory held by the state of the keys, in bytes
+//	ratelimit.evictions         counter of the idle keys evicted by a limiter
+//	ratelimit.backend.errors    counter of the errors of the backends, by limiter and error type
+func NewMetrics(opts ...Option) (*Metrics, error) {
+	c := newConfig(opts)
+	meter := c.meterProvider.Meter(scope)
+	m := &Metrics{
+		attrs:    c.attrs,
+		counters: make(map[string]keyCounter),
+		memories: make(map[string]memoryReporter),
+		evictors: make(map[string]evictor),
+	}
+
+	var err error
+	if m.decisions, err = meter.Int64Counter("ratelimit.decisions",
+		metric.WithDescription("Number of rate limit decisions."),
+		metric.WithUnit("{decision}")); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.Float64Histogram("ratelimit.decision.duration",
+		metric.WithDescription("Duration of the rate limit decisions."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if m.backendErrors, err = meter.Int64Counter("ratelimit.backend.errors",
+		metric.WithDescription("Number of errors of the backends storing the rate limit state."),
+		metric.WithUnit("{error}")); err != nil {
+		return nil, err
+	}
+	if _, err = meter.Int64ObservableGauge("ratelimit.keys",
+		metric.WithDescription("Number of keys tracked by the limiter."),
+		metric.WithUnit("{key}"),
+		metric.WithInt64Callback(m.observeKeys)); err != nil {
+		return nil, err
+	}
+	if _, err = meter.Int64ObservableGauge("ratelimit.memory",
+		metric.WithDescription("Estimated memory held by the state of the tracked keys."),
+		metric.WithUnit("By"),
+		metric.WithInt64Callback(m.observeMemory)); err != nil {
+		return nil, err
+	}
+	if _, err = meter.Int64ObservableCounter("ratelimit.evictions",
+		metric.WithDescription("Number of idle keys evicted by the limiter."),
+		metric.WithUnit("{key}"),
+		metric.WithInt64Callback(m.observeEvictions)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Instrument returns limiter recording its decisions under name. The number of
+// tracked keys, their memory and evictions are reported if limiter can report
+// them, as ratelimiter.Keyed does.
+func (m *Metrics) Instrument(name string, limiter ratelimiter.Limiter) ratelimiter.Limiter {
+	m.mu.Lock()
+	if counter, ok := limiter.(keyCounter); ok {
+		m.counters[name] = counter
+	}
+	if memory, ok := limiter.(memoryReporter); ok {
+		m.memories[name] = memory
+	}
+	if evicting, ok := limiter.(evictor); ok {
+		m.evictors[name] = evicting
+	}
+	m.mu.Unlock()
+
+	// Every option gets its own copy of the attributes, so their appends never
+	// share a backing array.
+	attrs := append([]attribute.KeyValue{LimiterKey.String(name)}, m.attrs...)
+	return &instrumented{
+		limiter: limiter,
+		metrics: m,
+		local:   metric.WithAttributes(append(slices.Clone(attrs), PhaseKey.String("local"))...),
+		backend: metric.WithAttributes(append(slices.Clone(attrs), PhaseKey.String("backend"))...),
+		allowed: metric.WithAttributes(append(slices.Clone(attrs), ResultKey.String("allowed"))...),
+		denied:  metric.WithAttributes(append(slices.Clone(attrs), ResultKey.String("denied"))...),
+	}
+}
+
+// BackendError records an error of type errorType returned by the backend of
+// the limiter instrumented under name, for limiters storing their state out of
+// process.
+func (m *Metrics) BackendError(ctx context.Context, name, errorType string) {
+	attrs := append([]attribute.KeyValue{LimiterKey.String(name), ErrorTypeKey.String(errorType)}, m.attrs...)
+	m.backendErrors.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// observeKeys reports the number of keys tracked by each limiter.
+func (m *Metrics) observeKeys(_ context.Context, observer metric.Int64Observer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, counter := range m.counters {
+		attrs := append([]attribute.KeyValue{LimiterKey.String(name)}, m.attrs...)
+		observer.Observe(int64(counter.Len()), metric.WithAttributes(attrs...))
+	}
+	return nil
+}
+
+// observeMemory reports the estimated memory held by each limiter.
+func (m *Metrics) observeMemory(_ context.Context, observer metric.Int64Observer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, memory := range m.memories {
+		attrs := append([]attribute.KeyValue{LimiterKey.String(name)}, m.attrs...)
+		observer.Observe(int64(memory.MemoryUsage()), metric.WithAttributes(attrs...))
+	}
+	return nil
+}
+
+// observeEvictions reports the number of keys evicted by each limiter.
+func (m *Metrics) observeEvictions(_ context.Context, observer metric.Int64Observer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, evicting := range m.evictors {
+		attrs := append([]attribute.KeyValue{LimiterKey.String(name)}, m.attrs...)
+		observer.Observe(int64(evicting.Evictions()), metric.WithAttributes(attrs...))
+	}
+	return nil
+}
+
+// instrumented is a Limiter recording the decisions of another.
+type instrumented struct {
+	limiter ratelimiter.Limiter      // The limiter making the decisions.
+	metrics *Metrics                 // The instruments recording the decisions.
+	local   metric.MeasurementOption // The attributes of the time spent in process.
+	backend metric.MeasurementOption // The attributes of the time spent waiting for the backend.
+	allowed metric.MeasurementOption // The attributes of the allowed decisions.
+	denied  metric.MeasurementOption // The attributes of the denied decisions.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (i *instrumented) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return i.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed. Decisions of zero cost only report the state
+// of the key and are not recorded.
+func (i *instrumented) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	return i.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx. The measurements are
+// recorded with ctx, so the SDK attaches the trace of the request to them as
+// exemplars.
+func (i *instrumented) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) ratelimiter.Decision {
+	if n == 0 {
+		return ratelimiter.AllowNContext(ctx, i.limiter, key, requestTime, n)
+	}
+
+	start := time.Now()
+	decision, backend, timed := ratelimiter.AllowNTimed(ctx, i.limiter, key, requestTime, n)
+	if timed {
+		i.metrics.duration.Record(ctx, backend.Seconds(), i.backend)
+	}
+	i.metrics.duration.Record(ctx, (time.Since(start) - backend).Seconds(), i.local)
+	if decision.Allowed {
+		i.metrics.decisions.Add(ctx, 1, i.allowed)
+	} else {
+		i.metrics.decisions.Add(ctx, 1, i.denied)
+	}
+	return decision
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (i *instrumented) Policies() []ratelimiter.Policy {
+	if reporter, ok := i.limiter.(ratelimiter.PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (i *instrumented) Healthy() error {
+	if checker, ok := i.limiter.(ratelimiter.HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (i *instrumented) Peek(key string, requestTime time.Time) (ratelimiter.Decision, bool) {
+	if peeker, ok := i.limiter.(ratelimiter.Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return ratelimiter.Decision{}, false
+}
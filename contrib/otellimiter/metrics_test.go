@@ -0,0 +1,54 @@
+package otellimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestInstrumentAttributes(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	m, err := NewMetrics(
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithAttributes(attribute.String("region", "eu"), attribute.String("zone", "a"), attribute.String("tier", "api")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := m.Instrument("api", ratelimiter.NewKeyed(func() ratelimiter.Algorithm {
+		return ratelimiter.NewSlidingWindow(1, time.Minute)
+	}))
+	now := time.Now()
+	limiter.Allow("client", now)
+	limiter.Allow("client", now)
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]int64)
+	for _, scope := range data.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			if metric.Name != "ratelimit.decisions" {
+				continue
+			}
+			for _, point := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+				result, _ := point.Attributes.Value(ResultKey)
+				region, _ := point.Attributes.Value("region")
+				if point.Attributes.Len() != 5 || region.AsString() != "eu" {
+					t.Errorf("decision attributes = %v, want the limiter, the result and the 3 options", point.Attributes.ToSlice())
+				}
+				results[result.AsString()] += point.Value
+			}
+		}
+	}
+	if results["allowed"] != 1 || results["denied"] != 1 {
+		t.Errorf("decisions by result = %v, want one allowed and one denied", results)
+	}
+}
@@ -0,0 +1,93 @@
+package otellimiter
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Attribute keys recorded with the decision spans and events.
+const (
+	AlgorithmKey  = attribute.Key("ratelimit.algorithm")   // Name of the algorithm that made the decision.
+	ReasonKey     = attribute.Key("ratelimit.reason")      // Why the request was denied, denials only.
+	KeyHashKey    = attribute.Key("ratelimit.key_hash")    // Digest of the key of the decision, see ratelimiter.HashKey.
+	RemainingKey  = attribute.Key("ratelimit.remaining")   // Number of requests left in the window.
+	RetryAfterKey = attribute.Key("ratelimit.retry_after") // Seconds until the request would be allowed, denials only.
+)
+
+// WithTracerProvider sets the provider of the tracer recording the decisions.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithSpans records each decision as a child span of the current span rather
+// than as an event on it, e.g. for traces viewed as a span tree only.
+func WithSpans() Option {
+	return func(c *config) {
+		c.spans = true
+	}
+}
+
+// Tracer records rate limit decisions in the current trace.
+type Tracer struct {
+	tracer trace.Tracer         // The tracer creating the decision spans.
+	spans  bool                 // Whether to record decisions as spans rather than events.
+	attrs  []attribute.KeyValue // The attributes added to every span and event.
+}
+
+// NewTracer creates a new tracer of rate limit decisions. Decisions are
+// recorded as "ratelimit.decision" events on the current span unless
+// WithSpans is given.
+func NewTracer(opts ...Option) *Tracer {
+	c := newConfig(opts)
+	return &Tracer{
+		tracer: c.tracerProvider.Tracer(scope),
+		spans:  c.spans,
+		attrs:  c.attrs,
+	}
+}
+
+// Record records the decision made for key by the limiter named name in the
+// trace of ctx. The key is recorded as a digest only.
+func (t *Tracer) Record(ctx context.Context, name, key string, decision ratelimiter.Decision) {
+	result := "allowed"
+	if !decision.Allowed {
+		result = "denied"
+	}
+	attrs := append([]attribute.KeyValue{
+		LimiterKey.String(name),
+		ResultKey.String(result),
+		AlgorithmKey.String(decision.Algorithm),
+		KeyHashKey.String(ratelimiter.HashKey(key)),
+		RemainingKey.Int(decision.Remaining),
+	}, t.attrs...)
+	if !decision.Allowed {
+		attrs = append(attrs,
+			ReasonKey.String(string(decision.Reason)),
+			RetryAfterKey.Float64(decision.RetryAfter.Seconds()),
+		)
+	}
+
+	if t.spans {
+		_, span := t.tracer.Start(ctx, "ratelimit.decision", trace.WithAttributes(attrs...))
+		span.End()
+		return
+	}
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.AddEvent("ratelimit.decision", trace.WithAttributes(attrs...))
+	}
+}
+
+// OnDecision returns a function recording the decisions of the limiter named
+// name, for use with ratelimiter.WithOnDecision.
+func (t *Tracer) OnDecision(name string) ratelimiter.DecisionFunc {
+	return func(r *http.Request, key string, decision ratelimiter.Decision) {
+		t.Record(r.Context(), name, key, decision)
+	}
+}
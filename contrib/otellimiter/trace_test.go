@@ -0,0 +1,74 @@
+package otellimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// newRecorder returns a tracer provider recording its spans in memory.
+func newRecorder() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+// attributes returns the attributes of attrs by key.
+func attributes(attrs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, attr := range attrs {
+		values[attr.Key] = attr.Value
+	}
+	return values
+}
+
+var denial = ratelimiter.Decision{
+	Algorithm:  "sliding-window",
+	RetryAfter: 1500 * time.Millisecond,
+	Reason:     ratelimiter.ReasonRateLimit,
+}
+
+func TestTracerEvents(t *testing.T) {
+	provider, recorder := newRecorder()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+	tracer := NewTracer(WithTracerProvider(provider))
+	tracer.Record(ctx, "api", "203.0.113.7", ratelimiter.Decision{Allowed: true, Algorithm: "sliding-window", Remaining: 4})
+	tracer.Record(ctx, "api", "203.0.113.7", denial)
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || len(spans[0].Events()) != 2 {
+		t.Fatalf("%d spans, want the request span with two events", len(spans))
+	}
+	allowed := attributes(spans[0].Events()[0].Attributes)
+	if allowed[ResultKey].AsString() != "allowed" || allowed[RemainingKey].AsInt64() != 4 || allowed[KeyHashKey].AsString() != ratelimiter.HashKey("203.0.113.7") {
+		t.Errorf("allowed event %v, want the decision with the hashed key", allowed)
+	}
+	if _, ok := allowed[ReasonKey]; ok {
+		t.Error("allowed event carries a reason")
+	}
+	denied := attributes(spans[0].Events()[1].Attributes)
+	if denied[ReasonKey].AsString() != "rate_limit" || denied[RetryAfterKey].AsFloat64() != 1.5 {
+		t.Errorf("denied event %v, want the reason and retry delay", denied)
+	}
+}
+
+func TestTracerSpans(t *testing.T) {
+	provider, recorder := newRecorder()
+	tracer := NewTracer(WithTracerProvider(provider), WithSpans(), WithAttributes(attribute.String("region", "eu")))
+	tracer.Record(context.Background(), "api", "203.0.113.7", denial)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "ratelimit.decision" {
+		t.Fatalf("%d spans, want the decision span", len(spans))
+	}
+	attrs := attributes(spans[0].Attributes())
+	if attrs[LimiterKey].AsString() != "api" || attrs[ResultKey].AsString() != "denied" || attrs["region"].AsString() != "eu" {
+		t.Errorf("decision span %v, want the limiter, result and extra attributes", attrs)
+	}
+}
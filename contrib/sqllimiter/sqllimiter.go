@@ -0,0 +1,250 @@
+// Package sqllimiter rate limits the queries and statements a database/sql
+// pool sends to a database, protecting a shared database from the runaway query
+// loop of a single service.
+//
+//	db := sqllimiter.OpenDB(connector, limiter, sqllimiter.WithKeyFunc(sqllimiter.KeyByStatementClass))
+package sqllimiter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// ErrRateLimited is returned for the queries denied when WithFailFast is set.
+var ErrRateLimited = errors.New("sqllimiter: query rate limit exceeded")
+
+// KeyFunc extracts the key under which a query is limited.
+type KeyFunc func(query string) string
+
+// KeyByName limits every query of the pool under the same name, e.g. the DSN
+// of the database.
+func KeyByName(name string) KeyFunc {
+	return func(query string) string {
+		return name
+	}
+}
+
+// KeyByStatementClass limits queries by their first keyword, so that SELECT,
+// INSERT, UPDATE and DELETE statements each get their own budget.
+func KeyByStatementClass(query string) string {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToUpper(words[0])
+}
+
+// Option configures the connector.
+type Option func(*limits)
+
+// WithKeyFunc sets the function used to extract the key of a query. Every query
+// shares the same key by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(l *limits) {
+		l.keyFunc = keyFunc
+	}
+}
+
+// WithFailFast fails the queries over the limit with ErrRateLimited instead of
+// delaying them until the limiter allows them.
+func WithFailFast() Option {
+	return func(l *limits) {
+		l.failFast = true
+	}
+}
+
+// limits holds what the connections of a connector need to limit queries.
+type limits struct {
+	limiter  ratelimiter.Limiter // The limiter making the decisions.
+	keyFunc  KeyFunc             // The function extracting the key of a query.
+	failFast bool                // Whether to fail queries over the limit instead of delaying them.
+}
+
+// wait blocks until query may be sent, or fails if the limits say it may not.
+func (l *limits) wait(ctx context.Context, query string) error {
+	key := l.keyFunc(query)
+	if !l.failFast {
+		return ratelimiter.Wait(ctx, l.limiter, key)
+	}
+	if !l.limiter.Allow(key, time.Now()).Allowed {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// NewConnector wraps c so that the queries sent over its connections are
+// limited with limiter.
+func NewConnector(c driver.Connector, limiter ratelimiter.Limiter, opts ...Option) driver.Connector {
+	l := &limits{
+		limiter: limiter,
+		keyFunc: KeyByName(""),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return &connector{Connector: c, limits: l}
+}
+
+// OpenDB opens a database whose queries are limited with limiter.
+func OpenDB(c driver.Connector, limiter ratelimiter.Limiter, opts ...Option) *sql.DB {
+	return sql.OpenDB(NewConnector(c, limiter, opts...))
+}
+
+type connector struct {
+	driver.Connector
+	limits *limits
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: dc, limits: c.limits}, nil
+}
+
+// conn is a driver.Conn limiting its queries. It forwards the optional
+// interfaces of the wrapped connection, returning driver.ErrSkip or the
+// database/sql default behavior when they are not implemented.
+type conn struct {
+	driver.Conn
+	limits *limits
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var ds driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		ds, err = preparer.PrepareContext(ctx, query)
+	} else {
+		ds, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: ds, limits: c.limits, query: query}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.limits.wait(ctx, query); err != nil {
+		return nil, err
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.limits.wait(ctx, query); err != nil {
+		return nil, err
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("sqllimiter: driver does not support non-default transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// stmt is a driver.Stmt limiting its executions.
+type stmt struct {
+	driver.Stmt
+	limits *limits
+	query  string // The query the statement was prepared from.
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.limits.wait(ctx, s.query); err != nil {
+		return nil, err
+	}
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.limits.wait(ctx, s.query); err != nil {
+		return nil, err
+	}
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+func (s *stmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// namedValuesToValues converts arguments for drivers predating named values.
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sqllimiter: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
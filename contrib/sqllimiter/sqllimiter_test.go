@@ -0,0 +1,126 @@
+package sqllimiter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// fakeConnector connects to a database accepting every statement.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(ctx context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                            { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                                    { return nil }
+func (fakeStmt) NumInput() int                                   { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func newLimiter(limit int) *ratelimiter.Keyed {
+	return ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(limit, time.Hour) })
+}
+
+func TestFailFastByStatementClass(t *testing.T) {
+	db := OpenDB(fakeConnector{}, newLimiter(1), WithFailFast(), WithKeyFunc(KeyByStatementClass))
+	defer db.Close()
+
+	for i, test := range []struct {
+		query string
+		want  error
+	}{
+		{"INSERT INTO t VALUES (1)", nil},
+		{"insert into t values (2)", ErrRateLimited},
+		{"  DELETE FROM t", nil},
+		{"delete\n  FROM t", ErrRateLimited},
+		{"SELECT\t*\nFROM t", nil},
+	} {
+		if _, err := db.Exec(test.query); !errors.Is(err, test.want) {
+			t.Errorf("statement %d: error %v, want %v", i, err, test.want)
+		}
+	}
+}
+
+func TestPreparedStatements(t *testing.T) {
+	db := OpenDB(fakeConnector{}, newLimiter(1), WithFailFast())
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE t SET a = 1")
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	defer stmt.Close()
+	// Preparing is free, every execution of the statement is limited.
+	if _, err := stmt.Exec(); err != nil {
+		t.Fatalf("first execution: %v", err)
+	}
+	if _, err := stmt.Exec(); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("second execution: error %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestWaitHonorsDeadline(t *testing.T) {
+	db := OpenDB(fakeConnector{}, newLimiter(1))
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatalf("first statement: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (2)"); !errors.Is(err, ratelimiter.ErrWaitExceedsDeadline) {
+		t.Errorf("second statement: error %v, want %v", err, ratelimiter.ErrWaitExceedsDeadline)
+	}
+}
+
+func TestQueriesWithoutQueryer(t *testing.T) {
+	db := OpenDB(fakeConnector{}, newLimiter(1), WithFailFast())
+	defer db.Close()
+
+	// Queries fall back to prepared statements, still limited.
+	if _, err := db.Query("SELECT 1"); err == nil || errors.Is(err, ErrRateLimited) {
+		t.Fatalf("first query: error %v, want the error of the driver", err)
+	}
+	if _, err := db.Query("SELECT 1"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("second query: error %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestUnsupportedDriverFeatures(t *testing.T) {
+	db := OpenDB(fakeConnector{}, newLimiter(10))
+	defer db.Close()
+
+	if _, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); err == nil {
+		t.Error("read-only transaction begun without driver support")
+	}
+	stmt, err := db.Prepare("UPDATE t SET a = :a")
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(sql.Named("a", 1)); err == nil {
+		t.Error("named parameter sent to a driver without support")
+	}
+	if _, err := stmt.Exec(1); err != nil {
+		t.Errorf("positional parameter: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package traefiklimiter is a Traefik middleware plugin limiting requests with
+// the leaky bucket algorithm, keyed by client IP address or header value.
+//
+// Traefik interprets plugins with Yaegi, which only provides the standard
+// library, so this package carries its own copy of the keyed leaky bucket of
+// the ratelimiter package instead of importing it. Keep both in sync.
+//
+// Declared as a local plugin, from plugins-local/src/<import path>:
+//
+//	experimental:
+//	  localPlugins:
+//	    ratelimiter:
+//	      moduleName: github.com/minhpq331/ratelimiter-example/contrib/traefiklimiter
+package traefiklimiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the configuration of the plugin.
+type Config struct {
+	Rate   int    `json:"rate,omitempty"`   // Maximum number of requests allowed in the window.
+	Window string `json:"window,omitempty"` // Duration of the window, e.g. "1m".
+	Key    string `json:"key,omitempty"`    // Key of the requests: "ip" or "header:<name>".
+}
+
+// CreateConfig creates the default plugin configuration.
+func CreateConfig() *Config {
+	return &Config{
+		Rate:   100,
+		Window: "1m",
+		Key:    "ip",
+	}
+}
+
+// RateLimiter is the middleware limiting the requests passed to the next handler.
+type RateLimiter struct {
+	next    http.Handler
+	name    string
+	header  string                  // Header keying the requests, empty to key by IP address.
+	mu      sync.Mutex              // Protects buckets.
+	rate    float64                 // Maximum number of requests allowed in the window.
+	window  time.Duration           // Duration of the window.
+	buckets map[string]*leakyBucket // Map to hold the bucket of each key.
+	pruned  time.Time               // The last time the drained buckets were removed.
+}
+
+// New creates a new RateLimiter plugin.
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	if config.Rate < 1 {
+		return nil, fmt.Errorf("%s: rate must be at least 1", name)
+	}
+	window, err := time.ParseDuration(config.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("%s: invalid window %q", name, config.Window)
+	}
+
+	rl := &RateLimiter{
+		next:    next,
+		name:    name,
+		rate:    float64(config.Rate),
+		window:  window,
+		buckets: make(map[string]*leakyBucket),
+	}
+	switch {
+	case config.Key == "" || config.Key == "ip":
+	case strings.HasPrefix(config.Key, "header:"):
+		rl.header = strings.TrimPrefix(config.Key, "header:")
+	default:
+		return nil, fmt.Errorf("%s: unknown key %q", name, config.Key)
+	}
+	return rl, nil
+}
+
+func (rl *RateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	allowed, remaining, retryAfter := rl.allow(rl.key(r), time.Now())
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(int(rl.rate)))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	rl.next.ServeHTTP(w, r)
+}
+
+// key extracts the key identifying the client of a request.
+func (rl *RateLimiter) key(r *http.Request) string {
+	if rl.header != "" {
+		return r.Header.Get(rl.header)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allow determines whether a new request for key at requestTime should be allowed.
+func (rl *RateLimiter) allow(key string, requestTime time.Time) (bool, int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if requestTime.Sub(rl.pruned) >= rl.window {
+		rl.prune(requestTime)
+	}
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &leakyBucket{}
+		rl.buckets[key] = bucket
+	}
+
+	// Leak the bucket based on the time elapsed since the last request.
+	elapsed := requestTime.Sub(bucket.lastUpdate).Seconds() / rl.window.Seconds()
+	bucket.current -= elapsed * rl.rate
+	if bucket.current < 0 {
+		bucket.current = 0
+	}
+	bucket.lastUpdate = requestTime
+
+	// If the bucket is not full, allow the request and update the bucket current amount.
+	if math.Ceil(bucket.current) < rl.rate {
+		bucket.current++
+		return true, int(rl.rate - math.Ceil(bucket.current)), 0
+	}
+
+	// The request would be allowed once the bucket has leaked enough to hold one more request.
+	leakRate := rl.rate / rl.window.Seconds()
+	retryAfter := time.Duration((bucket.current - (rl.rate - 1)) / leakRate * float64(time.Second))
+	return false, 0, retryAfter
+}
+
+// prune removes the buckets drained at now, which behave as new ones, so the
+// memory of the plugin stays bounded by the clients seen within a window. It
+// runs at most once a window, from allow, and must be called with mu held.
+func (rl *RateLimiter) prune(now time.Time) {
+	for key, bucket := range rl.buckets {
+		elapsed := now.Sub(bucket.lastUpdate).Seconds() / rl.window.Seconds()
+		if bucket.current-elapsed*rl.rate <= 0 {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.pruned = now
+}
+
+// leakyBucket is the state of the leaky bucket of a key.
+type leakyBucket struct {
+	lastUpdate time.Time // The last time the bucket was updated.
+	current    float64   // The current amount of requests in the bucket.
+}
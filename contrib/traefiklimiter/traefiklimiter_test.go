@@ -0,0 +1,51 @@
+package traefiklimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newRateLimiter(t *testing.T, config *Config) *RateLimiter {
+	t.Helper()
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	handler, err := New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return handler.(*RateLimiter)
+}
+
+func TestServeHTTP(t *testing.T) {
+	rl := newRateLimiter(t, &Config{Rate: 2, Window: "1h", Key: "header:X-API-Key"})
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-API-Key", "client")
+		w := httptest.NewRecorder()
+		rl.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func TestDrainedBucketsPruned(t *testing.T) {
+	rl := newRateLimiter(t, &Config{Rate: 2, Window: "1m"})
+	start := time.Now()
+
+	rl.allow("idle", start)
+	rl.allow("busy", start.Add(50*time.Second))
+	rl.allow("busy", start.Add(50*time.Second))
+
+	// A minute later, the bucket of idle is drained and the one of busy is not.
+	rl.allow("other", start.Add(time.Minute))
+	if _, ok := rl.buckets["idle"]; ok {
+		t.Error("drained bucket not pruned")
+	}
+	if _, ok := rl.buckets["busy"]; !ok {
+		t.Error("bucket still holding requests pruned")
+	}
+}
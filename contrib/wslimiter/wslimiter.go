@@ -0,0 +1,138 @@
+// Package wslimiter limits the rate of messages received on gorilla/websocket
+// connections, per connection and per user.
+package wslimiter
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// ErrRateLimited is returned by a connection closed because its client sent
+// messages too fast.
+var ErrRateLimited = errors.New("wslimiter: message rate limit exceeded")
+
+// Policy decides what happens to a message received over the limit.
+type Policy int
+
+const (
+	// Drop discards the message and keeps reading.
+	Drop Policy = iota
+	// Close closes the connection with the configured close code.
+	Close
+)
+
+// Option configures a Conn.
+type Option func(*Conn)
+
+// WithConnLimit limits the rate of messages received on the connection, using
+// a new algorithm instance from newAlgorithm for each connection, so the
+// options can be shared by every upgraded connection.
+func WithConnLimit(newAlgorithm func() ratelimiter.Algorithm) Option {
+	return func(c *Conn) {
+		c.newConnAlgorithm = newAlgorithm
+	}
+}
+
+// WithUserLimit limits the rate of messages received from user, across all the
+// connections sharing limiter.
+func WithUserLimit(limiter ratelimiter.Limiter, user string) Option {
+	return func(c *Conn) {
+		c.userLimiter = limiter
+		c.user = user
+	}
+}
+
+// WithPolicy sets what happens to a message received over the limit. Messages
+// are dropped by default.
+func WithPolicy(policy Policy) Option {
+	return func(c *Conn) {
+		c.policy = policy
+	}
+}
+
+// WithCloseCode sets the close code sent when the Close policy closes a
+// connection. It defaults to websocket.ClosePolicyViolation.
+func WithCloseCode(code int) Option {
+	return func(c *Conn) {
+		c.closeCode = code
+	}
+}
+
+// Conn is a websocket connection limiting the rate of the messages it receives.
+type Conn struct {
+	*websocket.Conn
+	newConnAlgorithm func() ratelimiter.Algorithm // The function creating the algorithm of the connection, if any.
+	connAlgorithm    ratelimiter.Algorithm        // The algorithm limiting the messages of the connection, if any.
+	userLimiter      ratelimiter.Limiter          // The limiter limiting the messages of the user, if any.
+	user             string                       // The user the connection belongs to.
+	policy           Policy                       // What happens to a message received over the limit.
+	closeCode        int                          // The close code sent by the Close policy.
+	dropped          int                          // Number of messages dropped so far.
+}
+
+// NewConn wraps conn to limit the rate of the messages it receives.
+func NewConn(conn *websocket.Conn, opts ...Option) *Conn {
+	c := &Conn{
+		Conn:      conn,
+		closeCode: websocket.ClosePolicyViolation,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.newConnAlgorithm != nil {
+		c.connAlgorithm = c.newConnAlgorithm()
+	}
+	return c
+}
+
+// ReadMessage reads the next message allowed by the limits, applying the policy
+// to the messages received over them.
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	for {
+		messageType, p, err = c.Conn.ReadMessage()
+		if err != nil {
+			return messageType, p, err
+		}
+		if c.allow(time.Now()) {
+			return messageType, p, nil
+		}
+
+		if c.policy == Close {
+			deadline := time.Now().Add(time.Second)
+			c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, "rate limit exceeded"), deadline)
+			c.Conn.Close()
+			return 0, nil, ErrRateLimited
+		}
+		c.dropped++
+	}
+}
+
+// ReadJSON reads the next message allowed by the limits and decodes it into v.
+func (c *Conn) ReadJSON(v any) error {
+	_, p, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(p, v)
+}
+
+// Dropped returns the number of messages dropped by the Drop policy so far.
+func (c *Conn) Dropped() int {
+	return c.dropped
+}
+
+// allow determines whether a message received at requestTime is within the limits.
+func (c *Conn) allow(requestTime time.Time) bool {
+	if c.connAlgorithm != nil && !c.connAlgorithm.Allow(requestTime).Allowed {
+		return false
+	}
+	if c.userLimiter != nil && !c.userLimiter.Allow(c.user, requestTime).Allowed {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package wslimiter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// dial serves websocket connections with handle, and returns a client connected to it.
+func dial(t *testing.T, handle func(conn *websocket.Conn)) *websocket.Conn {
+	t.Helper()
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func send(t *testing.T, client *websocket.Conn, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if err := client.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+}
+
+func TestDrop(t *testing.T) {
+	type result struct {
+		messages []string
+		dropped  int
+	}
+	done := make(chan result)
+	client := dial(t, func(ws *websocket.Conn) {
+		conn := NewConn(ws, WithConnLimit(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(2, time.Hour) }))
+		var messages []string
+		for {
+			_, p, err := conn.ReadMessage()
+			if err != nil {
+				break
+			}
+			messages = append(messages, string(p))
+		}
+		done <- result{messages, conn.Dropped()}
+	})
+
+	send(t, client, "a", "b", "c", "d")
+	client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	got := <-done
+	if strings.Join(got.messages, "") != "ab" || got.dropped != 2 {
+		t.Errorf("read %q with %d dropped, want the first two and 2 dropped", got.messages, got.dropped)
+	}
+}
+
+func TestClose(t *testing.T) {
+	done := make(chan error)
+	client := dial(t, func(ws *websocket.Conn) {
+		conn := NewConn(ws, WithConnLimit(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) }), WithPolicy(Close))
+		conn.ReadMessage()
+		_, _, err := conn.ReadMessage()
+		done <- err
+	})
+
+	send(t, client, "a", "b")
+	if err := <-done; !errors.Is(err, ErrRateLimited) {
+		t.Errorf("server error %v, want %v", err, ErrRateLimited)
+	}
+	_, _, err := client.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("client error %v, want a policy violation close", err)
+	}
+}
+
+func TestReadJSONCloseCode(t *testing.T) {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type result struct {
+		first message
+		err   error
+	}
+	done := make(chan result)
+	client := dial(t, func(ws *websocket.Conn) {
+		conn := NewConn(ws, WithConnLimit(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) }), WithPolicy(Close), WithCloseCode(4429))
+		var first, second message
+		if err := conn.ReadJSON(&first); err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{first, conn.ReadJSON(&second)}
+	})
+
+	send(t, client, `{"text": "hello"}`, `{"text": "again"}`)
+	got := <-done
+	if got.first.Text != "hello" || !errors.Is(got.err, ErrRateLimited) {
+		t.Errorf("read %+v then error %v, want hello then %v", got.first, got.err, ErrRateLimited)
+	}
+	if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, 4429) {
+		t.Errorf("client error %v, want a close with code 4429", err)
+	}
+}
+
+func TestConnLimitPerConnection(t *testing.T) {
+	// The options are shared by every connection, not their budgets.
+	opts := []Option{WithConnLimit(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) })}
+	dropped := make(chan int)
+	handle := func(ws *websocket.Conn) {
+		conn := NewConn(ws, opts...)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+		}
+		dropped <- conn.Dropped()
+	}
+
+	for i := range 2 {
+		client := dial(t, handle)
+		send(t, client, "a", "b")
+		client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		if n := <-dropped; n != 1 {
+			t.Errorf("connection %d: %d messages dropped, want 1", i, n)
+		}
+	}
+}
+
+func TestUserLimitShared(t *testing.T) {
+	users := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(3, time.Hour) })
+	dropped := make(chan int)
+	handle := func(ws *websocket.Conn) {
+		conn := NewConn(ws, WithUserLimit(users, "alice"))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+		}
+		dropped <- conn.Dropped()
+	}
+
+	// Both connections of the user draw from the same budget.
+	total := 0
+	for range 2 {
+		client := dial(t, handle)
+		send(t, client, "a", "b")
+		client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		total += <-dropped
+	}
+	if total != 1 {
+		t.Errorf("%d messages dropped, want 1", total)
+	}
+}
@@ -0,0 +1,142 @@
+// Package notify throttles the notifications sent to each recipient, such as
+// at most 3 emails per day per user, with deduplication of identical
+// notifications and reporting of the quota left to each recipient.
+//
+// Windows of notification policies are long, so the throttler uses the sliding
+// window algorithm: it is exact, and its memory only grows with the number of
+// notifications actually sent in the window.
+package notify
+
+import (
+	"sync"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Notification describes a notification about to be sent.
+type Notification struct {
+	Channel   string // Channel the notification is sent on, e.g. "email", "sms" or "push".
+	Recipient string // Recipient of the notification, e.g. a user ID.
+	DedupKey  string // Key identifying identical notifications, empty to disable deduplication.
+}
+
+// Result is the outcome of a throttling check.
+type Result struct {
+	Allowed   bool                 // Whether the notification may be sent.
+	Duplicate bool                 // Whether the notification was suppressed as a duplicate.
+	Decision  ratelimiter.Decision // Decision of the channel limiter, zero for duplicates and unthrottled channels.
+}
+
+// Quota describes the budget of a recipient on a channel.
+type Quota struct {
+	Limit      int           // Maximum number of notifications in the window.
+	Remaining  int           // Number of notifications that can still be sent in the window.
+	ResetAfter time.Duration // Time until the whole budget is available again.
+}
+
+// Option configures a Throttler.
+type Option func(*Throttler)
+
+// WithDedupWindow sets how long a deduplication key suppresses identical
+// notifications. It defaults to 24 hours.
+func WithDedupWindow(window time.Duration) Option {
+	return func(t *Throttler) {
+		t.dedupWindow = window
+	}
+}
+
+// Throttler limits the notifications sent to each recipient on each channel.
+type Throttler struct {
+	mu          sync.Mutex
+	limiters    map[string]*ratelimiter.Keyed // Map to hold the limiter of each channel.
+	dedupWindow time.Duration                 // How long a deduplication key suppresses identical notifications.
+	dedup       map[string]time.Time          // Map to hold the expiry time of each deduplication key.
+	lastPrune   time.Time                     // The last time expired deduplication keys and replenished recipients were removed.
+}
+
+// NewThrottler creates a new throttler. Channels without a policy are not throttled.
+func NewThrottler(opts ...Option) *Throttler {
+	t := &Throttler{
+		limiters:    make(map[string]*ratelimiter.Keyed),
+		dedupWindow: 24 * time.Hour,
+		dedup:       make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// SetPolicy allows at most limit notifications per window to each recipient on
+// channel. Setting the policy of a channel resets the budgets of its recipients.
+func (t *Throttler) SetPolicy(channel string, limit int, window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.limiters[channel] = ratelimiter.NewKeyed(func() ratelimiter.Algorithm {
+		return ratelimiter.NewSlidingWindow(limit, window)
+	})
+}
+
+// Allow determines whether n may be sent at now. Allowed notifications consume
+// the budget of their recipient and record their deduplication key, duplicates
+// consume nothing.
+func (t *Throttler) Allow(n Notification, now time.Time) Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	dedupKey := n.Channel + "|" + n.Recipient + "|" + n.DedupKey
+	if n.DedupKey != "" {
+		if expiry, ok := t.dedup[dedupKey]; ok && now.Before(expiry) {
+			return Result{Duplicate: true}
+		}
+	}
+
+	result := Result{Allowed: true}
+	if limiter, ok := t.limiters[n.Channel]; ok {
+		result.Decision = limiter.Allow(n.Recipient, now)
+		result.Allowed = result.Decision.Allowed
+	}
+	if result.Allowed && n.DedupKey != "" {
+		t.dedup[dedupKey] = now.Add(t.dedupWindow)
+	}
+	return result
+}
+
+// Quota reports the budget left to recipient on channel at now, without
+// consuming it. It reports false for channels without a policy.
+func (t *Throttler) Quota(channel, recipient string, now time.Time) (Quota, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limiter, ok := t.limiters[channel]
+	if !ok {
+		return Quota{}, false
+	}
+	decision := limiter.AllowN(recipient, now, 0)
+	return Quota{
+		Limit:      decision.Limit,
+		Remaining:  decision.Remaining,
+		ResetAfter: decision.ResetAfter,
+	}, true
+}
+
+// prune removes the expired deduplication keys and the recipients whose budget
+// is replenished, at most once a minute.
+func (t *Throttler) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+
+	for key, expiry := range t.dedup {
+		if !now.Before(expiry) {
+			delete(t.dedup, key)
+		}
+	}
+	for _, limiter := range t.limiters {
+		limiter.Prune(now)
+	}
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottlerAllow(t *testing.T) {
+	throttler := NewThrottler(WithDedupWindow(time.Hour))
+	throttler.SetPolicy("email", 2, 24*time.Hour)
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		notification Notification
+		allowed      bool
+		duplicate    bool
+	}{
+		{"first", Notification{Channel: "email", Recipient: "alice", DedupKey: "welcome"}, true, false},
+		{"duplicate", Notification{Channel: "email", Recipient: "alice", DedupKey: "welcome"}, false, true},
+		{"second", Notification{Channel: "email", Recipient: "alice"}, true, false},
+		{"over quota", Notification{Channel: "email", Recipient: "alice"}, false, false},
+		{"other recipient", Notification{Channel: "email", Recipient: "bob"}, true, false},
+		{"unthrottled channel", Notification{Channel: "push", Recipient: "alice"}, true, false},
+	}
+	for _, tt := range tests {
+		result := throttler.Allow(tt.notification, now)
+		if result.Allowed != tt.allowed || result.Duplicate != tt.duplicate {
+			t.Errorf("%s: Allow = %+v, want allowed %t, duplicate %t", tt.name, result, tt.allowed, tt.duplicate)
+		}
+	}
+
+	quota, ok := throttler.Quota("email", "alice", now)
+	if !ok || quota.Limit != 2 || quota.Remaining != 0 {
+		t.Errorf("Quota = %+v, %t, want limit 2 and nothing remaining", quota, ok)
+	}
+}
+
+func TestThrottlerPrunesRecipients(t *testing.T) {
+	throttler := NewThrottler()
+	throttler.SetPolicy("sms", 1, time.Hour)
+	start := time.Now()
+
+	throttler.Allow(Notification{Channel: "sms", Recipient: "alice"}, start)
+	throttler.Allow(Notification{Channel: "sms", Recipient: "bob"}, start.Add(30*time.Minute))
+
+	// Past the hour, the budget of alice is replenished, the one of bob is not.
+	throttler.Allow(Notification{Channel: "email", Recipient: "carol"}, start.Add(time.Hour+time.Minute))
+	keys := throttler.limiters["sms"].Keys()
+	if len(keys) != 1 || keys[0] != "bob" {
+		t.Errorf("tracked recipients = %v, want [bob]", keys)
+	}
+}
@@ -0,0 +1,297 @@
+// Package admin provides an HTTP API to operate a running limiter: inspect and
+// reset keys, change the limit of a key, toggle shadow mode and reload rules.
+// The handler is protected by a bearer token and mounted wherever the
+// application chooses:
+//
+//	mux.Handle("/admin/ratelimit/", http.StripPrefix("/admin/ratelimit", admin.NewHandler(limiter, token)))
+//
+// It serves the following routes:
+//
+//	GET    /keys?top=N       the N keys having consumed the most of their budget
+//	GET    /keys/{key}       the state of a key
+//	GET    /keys/{key}/state the internal state of the algorithm of a key
+//	DELETE /keys/{key}       reset a key
+//	PUT    /limits/{key}     change the limit of a key, e.g. {"algorithm": "sliding-window", "rate": 10, "window": "1m"}
+//	GET    /shadow           whether shadow mode is on
+//	PUT    /shadow           turn shadow mode on or off, e.g. {"enabled": true}
+//	POST   /reload           reload the rules
+//	GET    /top              the keys with the most allowed and denied requests of the current and previous intervals
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Option configures the admin handler.
+type Option func(*handler)
+
+// WithShadow enables the shadow mode routes, toggling shadow.
+func WithShadow(shadow *ratelimiter.Shadow) Option {
+	return func(h *handler) {
+		h.shadow = shadow
+	}
+}
+
+// WithReload enables the reload route, calling reload.
+func WithReload(reload func() error) Option {
+	return func(h *handler) {
+		h.reload = reload
+	}
+}
+
+// WithTopKeys enables the top route, reporting the heavy hitters tracked by top.
+func WithTopKeys(top *ratelimiter.TopKeys) Option {
+	return func(h *handler) {
+		h.top = top
+	}
+}
+
+// WithLogger logs the limit changes made through the API with logger.
+func WithLogger(logger *ratelimiter.Logger) Option {
+	return func(h *handler) {
+		h.logger = logger
+	}
+}
+
+// KeyState is the state of a key reported by the admin API.
+type KeyState struct {
+	Key       string `json:"key"`       // The key.
+	Limit     int    `json:"limit"`     // Maximum number of requests allowed in the window.
+	Remaining int    `json:"remaining"` // Number of requests that can still be made in the current window.
+	Used      int    `json:"used"`      // Number of requests counted in the current window.
+	Reset     int    `json:"reset"`     // Number of seconds until the budget is fully replenished.
+}
+
+// Internals is the internal state of the algorithm of a key reported by the
+// admin API, see ratelimiter.State.
+type Internals struct {
+	Key        string        `json:"key"`                   // The key.
+	Algorithm  string        `json:"algorithm"`             // Name of the algorithm.
+	Limit      int           `json:"limit"`                 // Maximum number of requests allowed in the window.
+	Window     string        `json:"window"`                // Duration of the window, e.g. "1m0s".
+	Counts     map[int64]int `json:"counts,omitempty"`      // Sliding window: requests counted in each second, by Unix time.
+	Level      float64       `json:"level,omitempty"`       // Leaky bucket: amount of requests in the bucket.
+	Tokens     float64       `json:"tokens,omitempty"`      // Token bucket: number of tokens in the bucket.
+	Burst      int           `json:"burst,omitempty"`       // Token bucket: maximum number of tokens.
+	LastUpdate *time.Time    `json:"last_update,omitempty"` // Buckets: last time the bucket was updated.
+	Start      *time.Time    `json:"start,omitempty"`       // Calendar window: start of the current window.
+	Count      int           `json:"count,omitempty"`       // Calendar window: requests counted in the current window.
+	Reserved   []int         `json:"reserved,omitempty"`    // Calendar window: requests reserved in each of the next windows.
+}
+
+// Limit is the body of the requests changing the limit of a key.
+type Limit struct {
+	Algorithm string `json:"algorithm"` // Name of the algorithm, see ratelimiter.Algorithms.
+	Rate      int    `json:"rate"`      // Maximum number of requests allowed in the window.
+	Window    string `json:"window"`    // Duration of the window, e.g. "1m".
+}
+
+type handler struct {
+	limiter *ratelimiter.Keyed   // The limiter operated by the API.
+	token   string               // The bearer token of the API.
+	shadow  *ratelimiter.Shadow  // The shadow limiter toggled by the API, if any.
+	reload  func() error         // The function reloading the rules, if any.
+	logger  *ratelimiter.Logger  // The logger of the limit changes, if any.
+	top     *ratelimiter.TopKeys // The heavy hitters reported by the API, if any.
+	mux     *http.ServeMux       // The mux dispatching the routes.
+}
+
+// NewHandler returns the admin API of limiter, accepting the requests carrying
+// token as a bearer token. An empty token rejects every request.
+func NewHandler(limiter *ratelimiter.Keyed, token string, opts ...Option) http.Handler {
+	h := &handler{
+		limiter: limiter,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mux.HandleFunc("GET /keys", h.topKeys)
+	h.mux.HandleFunc("GET /keys/{key}", h.getKey)
+	h.mux.HandleFunc("GET /keys/{key}/state", h.inspectKey)
+	h.mux.HandleFunc("DELETE /keys/{key}", h.resetKey)
+	h.mux.HandleFunc("PUT /limits/{key}", h.setLimit)
+	if h.shadow != nil {
+		h.mux.HandleFunc("GET /shadow", h.getShadow)
+		h.mux.HandleFunc("PUT /shadow", h.setShadow)
+	}
+	if h.reload != nil {
+		h.mux.HandleFunc("POST /reload", h.doReload)
+	}
+	if h.top != nil {
+		h.mux.Handle("GET /top", h.top)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler) topKeys(w http.ResponseWriter, r *http.Request) {
+	top := 10
+	if value := r.URL.Query().Get("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "top must be a positive integer")
+			return
+		}
+		top = n
+	}
+
+	now := time.Now()
+	states := []KeyState{}
+	for _, key := range h.limiter.Keys() {
+		if decision, ok := h.limiter.Peek(key, now); ok {
+			states = append(states, keyState(key, decision))
+		}
+	}
+	slices.SortFunc(states, func(a, b KeyState) int {
+		return b.Used - a.Used
+	})
+	writeJSON(w, http.StatusOK, states[:min(top, len(states))])
+}
+
+func (h *handler) getKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	decision, ok := h.limiter.Peek(key, time.Now())
+	if !ok {
+		writeError(w, http.StatusNotFound, "key not tracked")
+		return
+	}
+	writeJSON(w, http.StatusOK, keyState(key, decision))
+}
+
+func (h *handler) inspectKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	state, ok := h.limiter.Inspect(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, "key not tracked or not inspectable")
+		return
+	}
+
+	internals := Internals{
+		Key:       key,
+		Algorithm: state.Algorithm,
+		Limit:     state.Limit,
+		Window:    state.Window.String(),
+		Counts:    state.Counts,
+		Level:     state.Level,
+		Tokens:    state.Tokens,
+		Burst:     state.Burst,
+		Count:     state.Count,
+		Reserved:  state.Reserved,
+	}
+	if !state.LastUpdate.IsZero() {
+		internals.LastUpdate = &state.LastUpdate
+	}
+	if !state.Start.IsZero() {
+		internals.Start = &state.Start
+	}
+	writeJSON(w, http.StatusOK, internals)
+}
+
+func (h *handler) resetKey(w http.ResponseWriter, r *http.Request) {
+	h.limiter.Reset(r.PathValue("key"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) setLimit(w http.ResponseWriter, r *http.Request) {
+	var limit Limit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	window, err := time.ParseDuration(limit.Window)
+	if err != nil || window <= 0 || limit.Rate <= 0 {
+		writeError(w, http.StatusBadRequest, "rate and window must be positive")
+		return
+	}
+	newAlgorithm, err := ratelimiter.AlgorithmFactory(limit.Algorithm, limit.Rate, window)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key := r.PathValue("key")
+	h.limiter.SetAlgorithm(key, newAlgorithm())
+	if h.logger != nil {
+		policy := ratelimiter.Policy{Limit: limit.Rate, Window: window}
+		h.logger.PolicyChange(r.Context(), key, policy, slog.String("algorithm", limit.Algorithm))
+	}
+	decision, _ := h.limiter.Peek(key, time.Now())
+	writeJSON(w, http.StatusOK, keyState(key, decision))
+}
+
+func (h *handler) getShadow(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": h.shadow.Enabled()})
+}
+
+func (h *handler) setShadow(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.shadow.SetEnabled(body.Enabled)
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": h.shadow.Enabled()})
+}
+
+func (h *handler) doReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keyState converts the decision peeked for key to its reported state.
+func keyState(key string, decision ratelimiter.Decision) KeyState {
+	return KeyState{
+		Key:       key,
+		Limit:     decision.Limit,
+		Remaining: decision.Remaining,
+		Used:      decision.Limit - decision.Remaining,
+		Reset:     int((decision.ResetAfter + time.Second - 1) / time.Second),
+	}
+}
+
+// bearerToken returns the bearer token of r.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return token, true
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response with the given status.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
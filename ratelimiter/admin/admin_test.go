@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+const token = "secret"
+
+// do sends a request to handler with the admin token, and decodes the JSON
+// response into v when it is not nil.
+func do(t *testing.T, handler http.Handler, method, path, body string, v any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func newLimiter() *ratelimiter.Keyed {
+	return ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(10, time.Hour) })
+}
+
+func TestUnauthorized(t *testing.T) {
+	for _, handler := range []http.Handler{NewHandler(newLimiter(), token), NewHandler(newLimiter(), "")} {
+		for _, authorization := range []string{"", "Bearer wrong", "Basic " + token} {
+			r := httptest.NewRequest(http.MethodGet, "/keys", nil)
+			r.Header.Set("Authorization", authorization)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("authorization %q: status %d, want %d", authorization, w.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	limiter := newLimiter()
+	now := time.Now()
+	limiter.AllowN("light", now, 2)
+	limiter.AllowN("heavy", now, 7)
+	handler := NewHandler(limiter, token)
+
+	var top []KeyState
+	if code := do(t, handler, http.MethodGet, "/keys?top=1", "", &top); code != http.StatusOK {
+		t.Fatalf("GET /keys: status %d", code)
+	}
+	if len(top) != 1 || top[0].Key != "heavy" || top[0].Used != 7 || top[0].Remaining != 3 {
+		t.Errorf("top keys %+v, want heavy with 7 used", top)
+	}
+	if code := do(t, handler, http.MethodGet, "/keys?top=0", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET /keys?top=0: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var state KeyState
+	if code := do(t, handler, http.MethodGet, "/keys/light", "", &state); code != http.StatusOK || state.Used != 2 {
+		t.Errorf("GET /keys/light: status %d, state %+v, want 2 used", code, state)
+	}
+	var internals Internals
+	if code := do(t, handler, http.MethodGet, "/keys/light/state", "", &internals); code != http.StatusOK || internals.Algorithm != "sliding-window" {
+		t.Errorf("GET /keys/light/state: status %d, internals %+v", code, internals)
+	}
+
+	if code := do(t, handler, http.MethodDelete, "/keys/light", "", nil); code != http.StatusNoContent {
+		t.Errorf("DELETE /keys/light: status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := do(t, handler, http.MethodGet, "/keys/light", "", nil); code != http.StatusNotFound {
+		t.Errorf("GET /keys/light after reset: status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	limiter := newLimiter()
+	handler := NewHandler(limiter, token)
+
+	var state KeyState
+	code := do(t, handler, http.MethodPut, "/limits/vip", `{"algorithm": "sliding-window", "rate": 100, "window": "1m"}`, &state)
+	if code != http.StatusOK || state.Limit != 100 {
+		t.Fatalf("PUT /limits/vip: status %d, state %+v, want a limit of 100", code, state)
+	}
+	if decision := limiter.Allow("vip", time.Now()); decision.Limit != 100 {
+		t.Errorf("limit of vip %d, want 100", decision.Limit)
+	}
+
+	for _, body := range []string{`{"algorithm": "sliding-window", "rate": 0, "window": "1m"}`, `{"algorithm": "unknown", "rate": 1, "window": "1m"}`, `{`} {
+		if code := do(t, handler, http.MethodPut, "/limits/vip", body, nil); code != http.StatusBadRequest {
+			t.Errorf("PUT /limits/vip %s: status %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestShadowAndReload(t *testing.T) {
+	shadow := ratelimiter.NewShadow(newLimiter(), false, nil)
+	reloads := 0
+	reload := func() error {
+		reloads++
+		if reloads > 1 {
+			return errors.New("invalid rules")
+		}
+		return nil
+	}
+	handler := NewHandler(newLimiter(), token, WithShadow(shadow), WithReload(reload))
+
+	var body map[string]bool
+	if code := do(t, handler, http.MethodPut, "/shadow", `{"enabled": true}`, &body); code != http.StatusOK || !body["enabled"] || !shadow.Enabled() {
+		t.Errorf("PUT /shadow: status %d, body %v, want shadow mode on", code, body)
+	}
+	if code := do(t, handler, http.MethodPost, "/reload", "", nil); code != http.StatusNoContent {
+		t.Errorf("first reload: status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := do(t, handler, http.MethodPost, "/reload", "", nil); code != http.StatusInternalServerError {
+		t.Errorf("failed reload: status %d, want %d", code, http.StatusInternalServerError)
+	}
+
+	// The routes are only served when enabled.
+	if code := do(t, NewHandler(newLimiter(), token), http.MethodPost, "/reload", "", nil); code != http.StatusNotFound {
+		t.Errorf("reload without option: status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestTopKeys(t *testing.T) {
+	if code := do(t, NewHandler(newLimiter(), token), http.MethodGet, "/top", "", nil); code != http.StatusNotFound {
+		t.Errorf("top without WithTopKeys: status %d, want %d", code, http.StatusNotFound)
+	}
+
+	top := ratelimiter.NewTopKeys(5, 0)
+	top.Observe("heavy", time.Now(), ratelimiter.Decision{})
+	var report struct {
+		Current ratelimiter.TopReport
+	}
+	if code := do(t, NewHandler(newLimiter(), token, WithTopKeys(top)), http.MethodGet, "/top", "", &report); code != http.StatusOK {
+		t.Fatalf("top: status %d, want %d", code, http.StatusOK)
+	}
+	if denied := report.Current.Denied; len(denied) != 1 || denied[0].Key != "heavy" {
+		t.Errorf("denied %+v, want heavy", denied)
+	}
+}
@@ -0,0 +1,40 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Names of the algorithms accepted by AlgorithmFactory.
+const (
+	SlidingWindowAlgorithm  = "sliding-window"
+	LeakyBucketAlgorithm    = "leaky-bucket"
+	TokenBucketAlgorithm    = "token-bucket"
+	CalendarWindowAlgorithm = "calendar-window"
+)
+
+// Algorithms lists the names of the algorithms accepted by AlgorithmFactory.
+var Algorithms = []string{SlidingWindowAlgorithm, LeakyBucketAlgorithm, TokenBucketAlgorithm, CalendarWindowAlgorithm}
+
+// AlgorithmFactory returns a function creating instances of the named algorithm
+// allowing rate requests per windowDuration, for use with NewKeyed. Token buckets
+// get a burst of rate requests, and calendar windows the period of
+// windowDuration, see PeriodOf, in UTC.
+func AlgorithmFactory(name string, rate int, windowDuration time.Duration) (func() Algorithm, error) {
+	switch name {
+	case SlidingWindowAlgorithm:
+		return func() Algorithm { return NewSlidingWindow(rate, windowDuration) }, nil
+	case LeakyBucketAlgorithm:
+		return func() Algorithm { return NewLeakyBucket(rate, windowDuration) }, nil
+	case TokenBucketAlgorithm:
+		return func() Algorithm { return NewTokenBucket(rate, windowDuration, rate) }, nil
+	case CalendarWindowAlgorithm:
+		period, ok := PeriodOf(windowDuration)
+		if !ok {
+			return nil, fmt.Errorf("ratelimiter: no calendar period lasts %s, want 1m, 1h, 24h or 168h", windowDuration)
+		}
+		return CalendarWindowFactory(rate, period, time.UTC)
+	default:
+		return nil, fmt.Errorf("ratelimiter: unknown algorithm %q", name)
+	}
+}
@@ -0,0 +1,29 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlgorithmFactory(t *testing.T) {
+	for _, name := range Algorithms {
+		newAlgorithm, err := AlgorithmFactory(name, 5, time.Minute)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		decision := newAlgorithm().Allow(epoch)
+		if decision.Algorithm != name || decision.Limit != 5 || decision.Window != time.Minute {
+			t.Errorf("%s: decision %+v, want a limit of 5 per minute", name, decision)
+		}
+	}
+}
+
+func TestAlgorithmFactoryErrors(t *testing.T) {
+	if _, err := AlgorithmFactory("fixed-window", 5, time.Minute); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+	if _, err := AlgorithmFactory(CalendarWindowAlgorithm, 5, 90*time.Second); err == nil {
+		t.Error("calendar window without a matching period accepted")
+	}
+}
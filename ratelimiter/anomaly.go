@@ -0,0 +1,340 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Anomaly reports an interval whose traffic surged compared to the baseline.
+type Anomaly struct {
+	Time     time.Time // End of the anomalous interval.
+	Requests int       // Number of requests of the interval.
+	Mean     float64   // Baseline number of requests per interval.
+	StdDev   float64   // Baseline standard deviation of the requests per interval.
+	Score    float64   // Number of standard deviations above the baseline.
+	Change   float64   // Ratio of the requests to those of the previous interval, zero if it had none.
+}
+
+// AnomalyOption configures an AnomalyDetector.
+type AnomalyOption func(*AnomalyDetector)
+
+// WithAnomalyWarmup sets the number of intervals learning the baseline before
+// anything is flagged. It defaults to 10.
+func WithAnomalyWarmup(intervals int) AnomalyOption {
+	return func(d *AnomalyDetector) {
+		d.warmup = intervals
+	}
+}
+
+// WithAnomalySmoothing sets the weight of every interval in the baseline, an
+// exponentially weighted moving average, between 0 and 1. Higher values adapt
+// faster to changes of the traffic. It defaults to 0.1.
+func WithAnomalySmoothing(alpha float64) AnomalyOption {
+	return func(d *AnomalyDetector) {
+		d.alpha = alpha
+	}
+}
+
+// WithAnomalyChange also flags the intervals with more than ratio times the
+// requests of the previous one, e.g. 3, whatever their score.
+func WithAnomalyChange(ratio float64) AnomalyOption {
+	return func(d *AnomalyDetector) {
+		d.change = ratio
+	}
+}
+
+// WithAnomalyMaxIntervals sets the number of consecutive anomalous intervals
+// after which the next ones are learnt in the baseline anyway, so a lasting
+// change of the traffic becomes its new normal instead of being flagged for
+// as long as it lasts. It defaults to 10.
+func WithAnomalyMaxIntervals(intervals int) AnomalyOption {
+	return func(d *AnomalyDetector) {
+		d.maxAnomalous = intervals
+	}
+}
+
+// AnomalyDetector flags surges of the aggregate traffic, so defenses can
+// tighten automatically during attacks. It learns the baseline number of
+// requests per interval and flags the intervals more than a threshold of
+// standard deviations above it, their z-score. Anomalous intervals do not
+// update the baseline, so a sustained attack is not learnt as normal traffic,
+// until they last more than WithAnomalyMaxIntervals.
+type AnomalyDetector struct {
+	mu           sync.Mutex
+	interval     time.Duration // The duration of the intervals.
+	threshold    float64       // The score over which an interval is anomalous.
+	onAnomaly    func(Anomaly) // The function notified of the anomalies, nil for none.
+	warmup       int           // The number of intervals learning the baseline.
+	alpha        float64       // The weight of every interval in the baseline.
+	change       float64       // The ratio to the previous interval over which an interval is anomalous, zero to disable.
+	maxAnomalous int           // The number of consecutive anomalous intervals after which they are learnt.
+	start        time.Time     // The start of the current interval.
+	requests     int           // The number of requests of the current interval.
+	previous     int           // The number of requests of the previous interval.
+	learnt       int           // The number of intervals learnt in the baseline.
+	mean         float64       // The baseline number of requests per interval.
+	variance     float64       // The baseline variance of the requests per interval.
+	anomalous    bool          // Whether the last complete interval was anomalous.
+	streak       int           // The number of consecutive anomalous intervals up to the last complete one.
+}
+
+// maxGapIntervals bounds the number of intervals without requests learnt at
+// once after a quiet period.
+const maxGapIntervals = 100
+
+// NewAnomalyDetector creates a new detector flagging the intervals whose number
+// of requests is more than threshold standard deviations above the baseline,
+// e.g. 4. onAnomaly, if not nil, is called with every anomalous interval from
+// the goroutine observing the first request of the next interval, so it
+// should not block.
+func NewAnomalyDetector(interval time.Duration, threshold float64, onAnomaly func(Anomaly), opts ...AnomalyOption) *AnomalyDetector {
+	d := &AnomalyDetector{
+		interval:     interval,
+		threshold:    threshold,
+		onAnomaly:    onAnomaly,
+		warmup:       10,
+		alpha:        0.1,
+		maxAnomalous: 10,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Observe counts a request made at requestTime.
+func (d *AnomalyDetector) Observe(requestTime time.Time) {
+	anomaly, ok := d.observe(requestTime)
+	if ok && d.onAnomaly != nil {
+		d.onAnomaly(anomaly)
+	}
+}
+
+// OnDecision returns a function counting the requests of the middleware, for
+// use with WithOnDecision.
+func (d *AnomalyDetector) OnDecision() DecisionFunc {
+	return func(r *http.Request, key string, decision Decision) {
+		d.Observe(time.Now())
+	}
+}
+
+// Anomalous reports whether the last complete interval was anomalous, for
+// adaptive limiters and alert hooks polling the signal.
+func (d *AnomalyDetector) Anomalous() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.anomalous
+}
+
+// Tighten returns a limiter deciding with normal, and with strict while the
+// traffic is anomalous. Its requests are counted by the detector, so it needs
+// no other source of observations. The limiter is a RateChangeNotifier,
+// notifying its switches between normal and strict.
+func (d *AnomalyDetector) Tighten(normal, strict Limiter) Limiter {
+	return &tightened{detector: d, normal: normal, strict: strict}
+}
+
+// observe counts the request, and returns the anomaly of the interval it ends,
+// if any.
+func (d *AnomalyDetector) observe(requestTime time.Time) (Anomaly, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	anomaly, ok := d.advance(requestTime)
+	d.requests++
+	return anomaly, ok
+}
+
+// advance evaluates the current interval if it is over at now, and starts a
+// new one. It must be called with mu held.
+func (d *AnomalyDetector) advance(now time.Time) (Anomaly, bool) {
+	if d.start.IsZero() {
+		d.start = now.Truncate(d.interval)
+		return Anomaly{}, false
+	}
+	if now.Sub(d.start) < d.interval {
+		return Anomaly{}, false
+	}
+
+	end := d.start.Add(d.interval)
+	anomaly, ok := d.evaluate(end)
+	// The intervals without requests are part of the baseline too.
+	gap := min(int(now.Sub(end)/d.interval), maxGapIntervals)
+	for range gap {
+		d.learn(0)
+		d.previous = 0
+		d.anomalous = false
+		d.streak = 0
+	}
+	d.start = now.Truncate(d.interval)
+	d.requests = 0
+	return anomaly, ok
+}
+
+// evaluate scores the interval ending at end, and learns it in the baseline
+// unless it is anomalous, or follows maxAnomalous anomalous ones.
+func (d *AnomalyDetector) evaluate(end time.Time) (Anomaly, bool) {
+	requests := float64(d.requests)
+	// Counts of requests vary at least as much as a Poisson process.
+	stdDev := math.Max(math.Sqrt(d.variance), math.Sqrt(math.Max(d.mean, 1)))
+	anomaly := Anomaly{
+		Time:     end,
+		Requests: d.requests,
+		Mean:     d.mean,
+		StdDev:   stdDev,
+		Score:    (requests - d.mean) / stdDev,
+	}
+	if d.previous > 0 {
+		anomaly.Change = requests / float64(d.previous)
+	}
+	d.previous = d.requests
+
+	d.anomalous = d.learnt >= d.warmup &&
+		(anomaly.Score > d.threshold || (d.change > 0 && anomaly.Change > d.change))
+	if !d.anomalous {
+		d.streak = 0
+		d.learn(requests)
+	} else {
+		d.streak++
+		if d.streak > d.maxAnomalous {
+			// The change lasts, the baseline catches up with it.
+			d.learn(requests)
+		}
+	}
+	return anomaly, d.anomalous
+}
+
+// learn updates the baseline with an interval of x requests.
+func (d *AnomalyDetector) learn(x float64) {
+	if d.learnt == 0 {
+		d.mean = x
+	} else {
+		delta := x - d.mean
+		d.mean += d.alpha * delta
+		d.variance = (1 - d.alpha) * (d.variance + d.alpha*delta*delta)
+	}
+	d.learnt++
+}
+
+// tightened is a Limiter switching to a stricter one during anomalies.
+type tightened struct {
+	detector *AnomalyDetector // The detector flagging the anomalies.
+	normal   Limiter          // The limiter deciding the normal traffic.
+	strict   Limiter          // The limiter deciding during anomalies.
+
+	mu        sync.Mutex
+	tightened bool             // Whether strict decided the last request.
+	onChange  []RateChangeFunc // The functions notified of the switches.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (t *tightened) Allow(key string, requestTime time.Time) Decision {
+	return t.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed. Decisions of zero cost are not counted by the
+// detector.
+func (t *tightened) AllowN(key string, requestTime time.Time, n int) Decision {
+	return t.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, passed to the limiter
+// deciding.
+func (t *tightened) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision {
+	if n != 0 {
+		t.detector.Observe(requestTime)
+	}
+	anomalous := t.detector.Anomalous()
+	t.switchTo(anomalous, requestTime)
+	if anomalous {
+		return AllowNContext(ctx, t.strict, key, requestTime, n)
+	}
+	return AllowNContext(ctx, t.normal, key, requestTime, n)
+}
+
+// NotifyRateChanges adds a function notified with the trigger "anomaly" every
+// time the limiter switches between the normal and strict limiters.
+func (t *tightened) NotifyRateChanges(f RateChangeFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onChange = append(t.onChange, f)
+}
+
+// switchTo records whether strict decides the request at requestTime, and
+// notifies the switch if it did not decide the previous one.
+func (t *tightened) switchTo(strict bool, requestTime time.Time) {
+	t.mu.Lock()
+	switched := strict != t.tightened
+	t.tightened = strict
+	onChange := t.onChange
+	t.mu.Unlock()
+	if !switched || len(onChange) == 0 {
+		return
+	}
+
+	change := RateChange{Time: requestTime, Old: firstPolicy(t.normal), New: firstPolicy(t.strict), Trigger: "anomaly"}
+	if !strict {
+		change.Old, change.New = change.New, change.Old
+	}
+	for _, f := range onChange {
+		f(change)
+	}
+}
+
+// firstPolicy returns the first policy enforced by limiter, the zero Policy if
+// it cannot describe them.
+func firstPolicy(limiter Limiter) Policy {
+	if reporter, ok := limiter.(PolicyReporter); ok {
+		if policies := reporter.Policies(); len(policies) > 0 {
+			return policies[0]
+		}
+	}
+	return Policy{}
+}
+
+// Policies returns the policies enforced by the limiter currently deciding, if
+// it can describe them.
+func (t *tightened) Policies() []Policy {
+	limiter := t.normal
+	if t.detector.Anomalous() {
+		limiter = t.strict
+	}
+	if reporter, ok := limiter.(PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the limiter currently deciding, if it can
+// peek at it, without consuming anything.
+func (t *tightened) Peek(key string, requestTime time.Time) (Decision, bool) {
+	limiter := t.normal
+	if t.detector.Anomalous() {
+		limiter = t.strict
+	}
+	if peeker, ok := limiter.(Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return Decision{}, false
+}
+
+// Healthy reports the health of the backends of the normal and strict
+// limiters, either deciding as soon as the traffic changes.
+func (t *tightened) Healthy() error {
+	var errs []error
+	for _, limiter := range []Limiter{t.normal, t.strict} {
+		if checker, ok := limiter.(HealthChecker); ok {
+			if err := checker.Healthy(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
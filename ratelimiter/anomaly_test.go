@@ -0,0 +1,145 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+// surge observes the given numbers of requests in consecutive seconds after
+// the epoch, starting with the i-th.
+func surge(d *AnomalyDetector, i int, counts ...int) {
+	for j, n := range counts {
+		at := epoch.Add(time.Duration(i+j) * time.Second)
+		for range n {
+			d.Observe(at)
+		}
+	}
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	var anomalies []Anomaly
+	d := NewAnomalyDetector(time.Second, 4, func(a Anomaly) { anomalies = append(anomalies, a) }, WithAnomalyWarmup(5))
+	surge(d, 0, 10, 10, 10, 10, 10, 10, 100, 1)
+	if len(anomalies) != 1 || !d.Anomalous() {
+		t.Fatalf("anomalies %+v, want the surge", anomalies)
+	}
+	if a := anomalies[0]; a.Requests != 100 || a.Mean != 10 || a.Score < 20 || a.Change != 10 || !a.Time.Equal(epoch.Add(7*time.Second)) {
+		t.Errorf("anomaly %+v, want 100 requests against 10", a)
+	}
+
+	// A sustained surge is not learnt as normal traffic.
+	surge(d, 8, 99, 10, 1)
+	if len(anomalies) != 2 {
+		t.Errorf("%d anomalies, want the sustained surge flagged again", len(anomalies))
+	}
+	if d.Anomalous() {
+		t.Error("anomalous after the traffic went back to normal")
+	}
+}
+
+func TestAnomalyDetectorWarmup(t *testing.T) {
+	var anomalies []Anomaly
+	d := NewAnomalyDetector(time.Second, 4, func(a Anomaly) { anomalies = append(anomalies, a) })
+	surge(d, 0, 10, 10, 100, 1)
+	if len(anomalies) != 0 || d.Anomalous() {
+		t.Errorf("anomalies %+v while learning the baseline, want none", anomalies)
+	}
+}
+
+func TestAnomalyDetectorChange(t *testing.T) {
+	d := NewAnomalyDetector(time.Second, 100, nil, WithAnomalyWarmup(1), WithAnomalyChange(3))
+	surge(d, 0, 10, 10, 40, 1)
+	if !d.Anomalous() {
+		t.Error("interval with four times the requests of the previous one not flagged")
+	}
+}
+
+func TestAnomalyDetectorSmoothing(t *testing.T) {
+	for _, test := range []struct {
+		opts []AnomalyOption
+		want float64
+	}{
+		{nil, 11},
+		{[]AnomalyOption{WithAnomalySmoothing(0.5)}, 15},
+	} {
+		d := NewAnomalyDetector(time.Second, 100, nil, test.opts...)
+		surge(d, 0, 10, 20, 1)
+		if d.mean != test.want {
+			t.Errorf("%d options: baseline of %v requests, want %v", len(test.opts), d.mean, test.want)
+		}
+	}
+}
+
+func TestTighten(t *testing.T) {
+	d := NewAnomalyDetector(time.Second, 4, nil, WithAnomalyWarmup(2))
+	normal := NewKeyed(func() Algorithm { return NewSlidingWindow(1000, time.Minute) })
+	strict := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	limiter := d.Tighten(normal, strict)
+	for i, n := range []int{10, 10, 10, 100} {
+		for range n {
+			limiter.Allow("a", epoch.Add(time.Duration(i)*time.Second))
+		}
+	}
+	if d.Anomalous() || strict.Len() != 0 {
+		t.Fatal("strict limiter used before the surge ended")
+	}
+
+	// The requests of the surge fed the detector, the next ones are strict.
+	at := epoch.Add(4 * time.Second)
+	if !limiter.Allow("a", at).Allowed || limiter.Allow("a", at).Allowed {
+		t.Error("strict limit not enforced during the anomaly")
+	}
+	if policies := limiter.(PolicyReporter).Policies(); len(policies) != 1 || policies[0].Limit != 1 {
+		t.Errorf("policies %+v, want the strict ones", policies)
+	}
+	if decision, ok := limiter.(Peeker).Peek("a", at); !ok || decision.Remaining != 0 || decision.Limit != 1 {
+		t.Errorf("peeked %+v, %t, want the state in the strict limiter", decision, ok)
+	}
+}
+
+func TestAnomalyDetectorStepChange(t *testing.T) {
+	d := NewAnomalyDetector(time.Second, 4, nil, WithAnomalyWarmup(5), WithAnomalyMaxIntervals(3))
+	surge(d, 0, 10, 10, 10, 10, 10, 10)
+	anomalous := 0
+	for i := range 10 {
+		surge(d, 6+i, 100)
+		if d.Anomalous() {
+			anomalous++
+		}
+	}
+	// The new level is flagged for the 3 intervals allowed, and learnt from
+	// the next one on, which ends the anomaly.
+	if d.Anomalous() || anomalous != 4 {
+		t.Errorf("%d anomalous intervals of the new level, then anomalous %t, want 4 then false", anomalous, d.Anomalous())
+	}
+}
+
+func TestTightenRateChanges(t *testing.T) {
+	d := NewAnomalyDetector(time.Second, 4, nil, WithAnomalyWarmup(2))
+	normal := NewKeyed(func() Algorithm { return NewSlidingWindow(1000, time.Minute) })
+	strict := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	limiter := d.Tighten(normal, strict)
+	var changes []RateChange
+	limiter.(RateChangeNotifier).NotifyRateChanges(func(change RateChange) { changes = append(changes, change) })
+
+	for i, n := range []int{10, 10, 10, 100, 10, 1} {
+		for range n {
+			limiter.Allow("a", epoch.Add(time.Duration(i)*time.Second))
+		}
+	}
+	if len(changes) != 2 {
+		t.Fatalf("rate changes %+v, want the switches to strict and back", changes)
+	}
+	for i, want := range []struct {
+		old, new int
+		at       time.Duration
+	}{
+		{1000, 1, 4 * time.Second},
+		{1, 1000, 5 * time.Second},
+	} {
+		change := changes[i]
+		if change.Trigger != "anomaly" || change.Old.Limit != want.old || change.New.Limit != want.new || !change.Time.Equal(epoch.Add(want.at)) {
+			t.Errorf("change %d = %+v, want %d to %d at %v", i, change, want.old, want.new, want.at)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package audit records every rate limit denial, with the state of the limiter
+// at that time, as JSON lines written to any io.Writer, such as a RotatingFile,
+// for abuse investigations and compliance.
+//
+//	file, err := audit.NewRotatingFile("/var/log/ratelimit/audit.jsonl", 100<<20, 10)
+//	sink := audit.NewSink(file)
+//	defer sink.Close()
+//	handler = ratelimiter.Middleware(limiter, ratelimiter.WithOnDecision(sink.OnDecision("api")))(handler)
+//
+// With WithAllowed and WithSampling, the sink also exports the allowed
+// decisions, every one of them or a sample, for offline analytics pipelines
+// tuning the limits.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Record is an audited decision.
+type Record struct {
+	Time       time.Time `json:"time"`                // Time of the request.
+	Allowed    bool      `json:"allowed"`             // Whether the request was allowed, see WithAllowed.
+	Rule       string    `json:"rule,omitempty"`      // Name of the rule or limiter that decided the request.
+	Key        string    `json:"key"`                 // Key of the request.
+	Method     string    `json:"method,omitempty"`    // Method of the HTTP request.
+	Path       string    `json:"path,omitempty"`      // Path of the HTTP request.
+	Reason     string    `json:"reason,omitempty"`    // Why the request was denied, see ratelimiter.Reason.
+	Algorithm  string    `json:"algorithm,omitempty"` // Algorithm that decided the request.
+	Limit      int       `json:"limit"`               // Maximum number of requests allowed in the window.
+	Remaining  int       `json:"remaining"`           // Number of requests left in the window.
+	Window     float64   `json:"window"`              // Duration of the window, in seconds.
+	ResetAfter float64   `json:"reset_after"`         // Seconds until the budget is fully replenished.
+	RetryAfter float64   `json:"retry_after"`         // Seconds until the request would be allowed.
+}
+
+// Option configures a Sink.
+type Option func(*Sink)
+
+// WithBufferSize sets the number of records held while the writer is busy. It
+// defaults to 4096.
+func WithBufferSize(size int) Option {
+	return func(s *Sink) {
+		s.bufferSize = size
+	}
+}
+
+// WithFlushInterval sets how often the buffered records are flushed to the
+// writer. It defaults to one second.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.flushInterval = interval
+	}
+}
+
+// WithKeyRedaction sets the function applied to keys before they are recorded,
+// e.g. ratelimiter.HashKey. Investigations usually need the raw keys, so they
+// are recorded as is by default.
+func WithKeyRedaction(redact func(key string) string) Option {
+	return func(s *Sink) {
+		s.redact = redact
+	}
+}
+
+// WithAllowed also records the allowed decisions, not only the denials.
+func WithAllowed() Option {
+	return func(s *Sink) {
+		s.allowed = true
+	}
+}
+
+// WithSampling records one decision in n, for the volume of exported decisions
+// to stay manageable. Decisions are recorded whether allowed or denied, so the
+// sample is not biased. It defaults to 1, recording every decision, as audits
+// usually require.
+func WithSampling(n int) Option {
+	return func(s *Sink) {
+		s.sampling = uint64(max(n, 1))
+	}
+}
+
+// WithOnError sets a function notified of the errors of the writer. Records
+// failing to be written are lost.
+func WithOnError(onError func(err error)) Option {
+	return func(s *Sink) {
+		s.onError = onError
+	}
+}
+
+// Sink writes the audited decisions as JSON lines on its own goroutine. No
+// selected decision is dropped: when the writer falls behind by more than the
+// buffer, recording blocks until it catches up.
+type Sink struct {
+	w             io.Writer               // The writer receiving the records.
+	bufferSize    int                     // The number of records held while the writer is busy.
+	flushInterval time.Duration           // How often the buffered records are flushed.
+	redact        func(key string) string // The function applied to keys, nil to record them as is.
+	onError       func(err error)         // The function notified of the errors of the writer.
+	allowed       bool                    // Whether to record the allowed decisions.
+	sampling      uint64                  // The number of decisions per recorded one.
+	decisions     atomic.Uint64           // The number of decisions seen, for sampling.
+	records       chan Record             // The records waiting to be written.
+	closeOnce     sync.Once               // Closes records once.
+	done          chan struct{}           // Closed once every record was written.
+	err           error                   // The error closing the writer, if any.
+}
+
+// NewSink creates a new sink writing its records to w. Close must be called to
+// flush the records and stop the goroutine writing them.
+func NewSink(w io.Writer, opts ...Option) *Sink {
+	s := &Sink{
+		w:             w,
+		bufferSize:    4096,
+		flushInterval: time.Second,
+		onError:       func(error) {},
+		sampling:      1,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.records = make(chan Record, s.bufferSize)
+	go s.run()
+	return s
+}
+
+// Record records the decision of a request for key made at t by the rule or
+// limiter named rule. Allowed decisions are ignored unless WithAllowed.
+func (s *Sink) Record(rule, key string, t time.Time, decision ratelimiter.Decision) {
+	s.record(rule, key, t, decision, nil)
+}
+
+// OnDecision returns a function recording the decisions of the middleware made
+// under rule, with the method and path of the requests, for use with
+// ratelimiter.WithOnDecision.
+func (s *Sink) OnDecision(rule string) ratelimiter.DecisionFunc {
+	return func(r *http.Request, key string, decision ratelimiter.Decision) {
+		s.record(rule, key, time.Now(), decision, r)
+	}
+}
+
+// OnDeny returns a hook recording the denials of a ratelimiter.Hooked limiter
+// under rule, for use with ratelimiter.OnDeny.
+func (s *Sink) OnDeny(rule string) func(ratelimiter.Event) {
+	return func(e ratelimiter.Event) {
+		s.Record(rule, e.Key, e.Time, e.Decision)
+	}
+}
+
+// Close writes the pending records and flushes them. It also closes the writer
+// if it is an io.Closer, returning its error. The sink must not be used
+// afterwards.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.records)
+	})
+	<-s.done
+	return s.err
+}
+
+// record queues the record of a decision, with the details of r if not nil.
+func (s *Sink) record(rule, key string, t time.Time, decision ratelimiter.Decision, r *http.Request) {
+	if decision.Allowed && !s.allowed {
+		return
+	}
+	if s.sampling > 1 && (s.decisions.Add(1)-1)%s.sampling != 0 {
+		return
+	}
+	if s.redact != nil {
+		key = s.redact(key)
+	}
+	record := Record{
+		Time:       t,
+		Allowed:    decision.Allowed,
+		Rule:       rule,
+		Key:        key,
+		Reason:     string(decision.Reason),
+		Algorithm:  decision.Algorithm,
+		Limit:      decision.Limit,
+		Remaining:  decision.Remaining,
+		Window:     decision.Window.Seconds(),
+		ResetAfter: decision.ResetAfter.Seconds(),
+		RetryAfter: decision.RetryAfter.Seconds(),
+	}
+	if r != nil {
+		record.Method = r.Method
+		record.Path = r.URL.Path
+	}
+	s.records <- record
+}
+
+// run writes the records until Close, flushing them periodically.
+func (s *Sink) run() {
+	defer close(s.done)
+
+	// Records are encoded aside and flushed whole, so a rotating writer never
+	// splits a line across files.
+	buffered := bufio.NewWriter(s.w)
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record, ok := <-s.records:
+			if !ok {
+				if err := buffered.Flush(); err != nil {
+					s.onError(err)
+				}
+				if closer, ok := s.w.(io.Closer); ok {
+					s.err = closer.Close()
+				}
+				return
+			}
+			line.Reset()
+			if err := encoder.Encode(record); err != nil {
+				s.onError(err)
+				continue
+			}
+			if buffered.Available() < line.Len() {
+				if err := buffered.Flush(); err != nil {
+					s.onError(err)
+				}
+			}
+			if _, err := buffered.Write(line.Bytes()); err != nil {
+				s.onError(err)
+			}
+		case <-ticker.C:
+			if err := buffered.Flush(); err != nil {
+				s.onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+var (
+	allowed = ratelimiter.Decision{Allowed: true, Limit: 2, Remaining: 1, Window: time.Minute}
+	denied  = ratelimiter.Decision{Limit: 2, Window: time.Minute, RetryAfter: 30 * time.Second, ResetAfter: time.Minute, Reason: ratelimiter.ReasonRateLimit, Algorithm: "sliding-window"}
+)
+
+// closedRecords closes the sink, and returns the records written to out.
+func closedRecords(t *testing.T, sink *Sink, out *bytes.Buffer) []Record {
+	t.Helper()
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var records []Record
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewSink(&out, WithFlushInterval(time.Hour), WithKeyRedaction(func(key string) string { return "redacted-" + key }))
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sink.Record("login", "alice", now, allowed)
+	sink.Record("login", "alice", now, denied)
+	sink.OnDecision("api")(httptest.NewRequest(http.MethodPost, "/api/orders?page=2", nil), "bob", denied)
+
+	// Only the denials are recorded, by default.
+	records := closedRecords(t, sink, &out)
+	if len(records) != 2 {
+		t.Fatalf("records %+v, want the two denials", records)
+	}
+	want := Record{Time: now, Rule: "login", Key: "redacted-alice", Reason: "rate_limit", Algorithm: "sliding-window", Limit: 2, Window: 60, ResetAfter: 60, RetryAfter: 30}
+	if records[0] != want {
+		t.Errorf("record %+v, want %+v", records[0], want)
+	}
+	if r := records[1]; r.Rule != "api" || r.Key != "redacted-bob" || r.Method != "POST" || r.Path != "/api/orders" {
+		t.Errorf("middleware record %+v, want the request of bob", r)
+	}
+}
+
+func TestSinkOnDeny(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewSink(&out, WithBufferSize(1), WithFlushInterval(time.Hour))
+	limiter := ratelimiter.NewHooked(ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) }), ratelimiter.OnDeny(sink.OnDeny("jobs")))
+	now := time.Now()
+	for range 3 {
+		limiter.Allow("export", now)
+	}
+	// The hooks are done with the denials once the limiter is closed.
+	limiter.Close()
+	if records := closedRecords(t, sink, &out); len(records) != 2 || records[0].Rule != "jobs" || records[0].Key != "export" {
+		t.Errorf("records %+v, want the two denials of export", records)
+	}
+}
+
+func TestSinkAllowed(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewSink(&out, WithFlushInterval(time.Hour), WithAllowed())
+	now := time.Now()
+	sink.Record("api", "alice", now, allowed)
+	sink.Record("api", "alice", now, denied)
+	if records := closedRecords(t, sink, &out); len(records) != 2 || !records[0].Allowed || records[1].Allowed {
+		t.Errorf("records %+v, want the allowed decision then the denial", records)
+	}
+}
+
+func TestSinkSampling(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewSink(&out, WithFlushInterval(time.Hour), WithAllowed(), WithSampling(3))
+	now := time.Now()
+	for i := range 9 {
+		decision := allowed
+		if i%2 == 1 {
+			decision = denied
+		}
+		sink.Record("api", "alice", now.Add(time.Duration(i)*time.Second), decision)
+	}
+	// One decision in three is recorded, whatever its outcome.
+	records := closedRecords(t, sink, &out)
+	var outcomes []bool
+	for _, record := range records {
+		outcomes = append(outcomes, record.Allowed)
+	}
+	if len(records) != 3 || !records[1].Time.Equal(now.Add(3*time.Second)) || !slices.Equal(outcomes, []bool{true, false, true}) {
+		t.Errorf("records %+v, want the decisions 0, 3 and 6", records)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestSinkOnError(t *testing.T) {
+	errWrite := errors.New("disk full")
+	var errs []error
+	sink := NewSink(failingWriter{errWrite}, WithFlushInterval(time.Hour), WithOnError(func(err error) { errs = append(errs, err) }))
+	sink.Record("login", "alice", time.Now(), denied)
+
+	// The record is written, and fails, when Close flushes it.
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], errWrite) {
+		t.Errorf("errors %v, want the write error", errs)
+	}
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser appending to a file, and rotating it once
+// it reaches its maximum size: path is renamed path.1, path.1 is renamed
+// path.2 and so on, the oldest backup being removed. When a rotation fails, the
+// records keep going to the current file and the next write retries it, so no
+// record is lost to a transient error.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string   // The path of the file written to.
+	maxSize    int64    // The size over which the file is rotated.
+	maxBackups int      // The number of rotated files kept.
+	file       *os.File // The file written to.
+	size       int64    // The size of the file written to.
+	reopen     bool     // Whether the file was rotated but no new file could be opened yet.
+}
+
+// NewRotatingFile opens path for appending, rotating it once it holds maxSize
+// bytes and keeping maxBackups rotated files.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	file, size, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	f.file, f.size = file, size
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if p would not fit. If the
+// rotation fails, p is appended to the current file and the error of the
+// rotation is returned with the number of bytes written.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	switch {
+	case f.reopen:
+		rotateErr = f.swap()
+	case f.size > 0 && f.size+int64(len(p)) > f.maxSize:
+		rotateErr = f.rotate()
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil && rotateErr != nil {
+		// p was written to the current file all the same.
+		err = fmt.Errorf("audit: rotating %s: %w", f.path, rotateErr)
+	}
+	return n, err
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens path for appending, and returns the file and its size.
+func open(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// rotate shifts the backups, renames the file to the first backup and opens a
+// new file. The current file stays open until the new one is, so the records
+// still have a file to go to if the rotation fails.
+func (f *RotatingFile) rotate() error {
+	if f.maxBackups <= 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.swap()
+	}
+	for i := f.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(backup(f.path, i), backup(f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, backup(f.path, 1)); err != nil {
+		return err
+	}
+	return f.swap()
+}
+
+// swap opens a new file at path and closes the current one. If the new file
+// cannot be opened, the current one is kept and the next write tries again.
+func (f *RotatingFile) swap() error {
+	file, size, err := open(f.path)
+	if err != nil {
+		f.reopen = true
+		return err
+	}
+	f.reopen = false
+	previous := f.file
+	f.file, f.size = file, size
+	return previous.Close()
+}
+
+// backup returns the path of the i-th backup of path.
+func backup(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFile returns the content of path, failing the test on errors.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, record := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(record)); err != nil {
+			t.Fatalf("Write(%q): %v", record, err)
+		}
+	}
+	for path, want := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		if got := readFile(t, path); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRotatingFileKeepsWritingOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	f, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first backup cannot be replaced while it is a non-empty directory.
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.Write([]byte("second\n"))
+	if err == nil || n != len("second\n") {
+		t.Fatalf("Write = %d, %v, want the record written and the rotation error", n, err)
+	}
+	if got := readFile(t, path); got != "first\nsecond\n" {
+		t.Errorf("file = %q, want both records", got)
+	}
+
+	// The next write retries the rotation.
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("third\n")); err != nil {
+		t.Fatalf("Write after the failure: %v", err)
+	}
+	if got := readFile(t, path+".1"); got != "first\nsecond\n" {
+		t.Errorf("backup = %q, want the records of before the failure", got)
+	}
+	if got := readFile(t, path); got != "third\n" {
+		t.Errorf("file = %q, want the record of after the rotation", got)
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// TimedLimiter is implemented by limiters storing their state out of process,
+// e.g. in Redis, to report how long their decisions waited for the backend,
+// so instrumentation can tell the backend round trips from the local work.
+// Wrappers implement it too, forwarding the times of the limiter they wrap.
+type TimedLimiter interface {
+	Limiter
+
+	// AllowNTimed is AllowN for a request carrying ctx, which cancels and
+	// traces the round trips to the backend. It also returns the time spent in
+	// those round trips, and whether the limiter has a backend at all: the
+	// wrappers of limiters deciding in process return false.
+	AllowNTimed(ctx context.Context, key string, requestTime time.Time, n int) (Decision, time.Duration, bool)
+}
+
+// AllowNTimed decides a request for key carrying ctx with limiter, returning
+// the time spent waiting for its backend if limiter is a TimedLimiter, and
+// false otherwise.
+func AllowNTimed(ctx context.Context, limiter Limiter, key string, requestTime time.Time, n int) (Decision, time.Duration, bool) {
+	if timed, ok := limiter.(TimedLimiter); ok {
+		return timed.AllowNTimed(ctx, key, requestTime, n)
+	}
+	return AllowNContext(ctx, limiter, key, requestTime, n), 0, false
+}
+
+// HealthChecker is implemented by limiters storing their state out of process
+// to report whether they can make decisions as configured. Wrappers implement
+// it too, reporting the health of the limiter they wrap.
+type HealthChecker interface {
+	// Healthy returns nil if the backend is reachable, and otherwise an error
+	// describing the failure and the fallback the limiter applies meanwhile.
+	Healthy() error
+}
+
+// ReadinessHandler returns a handler for readiness probes, such as those of
+// Kubernetes, answering 200 OK when every checker is healthy and 503 Service
+// Unavailable with their errors otherwise, so instances do not serve traffic
+// while their limits are not enforced as configured.
+func ReadinessHandler(checkers ...HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs []error
+		for _, checker := range checkers {
+			if err := checker.Healthy(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
@@ -0,0 +1,82 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// healthFunc is a HealthChecker calling a function.
+type healthFunc func() error
+
+func (f healthFunc) Healthy() error {
+	return f()
+}
+
+func TestReadinessHandler(t *testing.T) {
+	var redisErr error
+	redis := healthFunc(func() error { return redisErr })
+	memory := healthFunc(func() error { return nil })
+	handler := ReadinessHandler(redis, memory)
+
+	probe := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec
+	}
+	if rec := probe(); rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("healthy: status %d, body %q, want 200 ok", rec.Code, rec.Body)
+	}
+	redisErr = errors.New("redis unreachable, failing open")
+	if rec := probe(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "redis unreachable, failing open") {
+		t.Errorf("unhealthy: status %d, body %q, want 503 with the error", rec.Code, rec.Body)
+	}
+}
+
+// timedRecorder is a TimedLimiter denying every request after a backend round
+// trip of a second, recording the context value of its last decision.
+type timedRecorder struct {
+	contextRecorder
+}
+
+func (l *timedRecorder) AllowNTimed(ctx context.Context, key string, requestTime time.Time, n int) (Decision, time.Duration, bool) {
+	l.value = ctx.Value(contextKey{})
+	return Decision{Limit: 1}, time.Second, true
+}
+
+func TestWrappersForwardBackendTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		wrap        func(Limiter) Limiter
+		wantAllowed bool
+	}{
+		{"shadow", func(l Limiter) Limiter { return NewShadow(l, true, nil) }, true},
+		{"hooked", func(l Limiter) Limiter { return NewHooked(l, OnDeny(func(Event) {})) }, false},
+	}
+	for _, tt := range tests {
+		backend := new(timedRecorder)
+		limiter := tt.wrap(backend)
+		if hooked, ok := limiter.(*Hooked); ok {
+			defer hooked.Close()
+		}
+
+		ctx := context.WithValue(context.Background(), contextKey{}, tt.name)
+		decision, elapsed, timed := AllowNTimed(ctx, limiter, "client", time.Now(), 1)
+		if !timed || elapsed != time.Second || decision.Allowed != tt.wantAllowed || backend.value != tt.name {
+			t.Errorf("%s: allowed %t after %s in the backend (timed %t), context value %v, want the time and context of the wrapped limiter",
+				tt.name, decision.Allowed, elapsed, timed, backend.value)
+		}
+		// Limiters deciding in process are not timed, even wrapped.
+		local := tt.wrap(new(contextRecorder))
+		if hooked, ok := local.(*Hooked); ok {
+			defer hooked.Close()
+		}
+		if _, _, timed := AllowNTimed(ctx, local, "client", time.Now(), 1); timed {
+			t.Errorf("%s: wrapped in-process limiter timed", tt.name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bandwidth limits the number of bytes per second transferred by each key, with
+// a token bucket of one token per byte for every key.
+type Bandwidth struct {
+	mu             sync.Mutex              // Protects buckets.
+	bytesPerSecond int                     // The sustained rate of each key.
+	burst          int                     // The number of bytes a key may transfer at once.
+	buckets        map[string]*TokenBucket // Map to hold the token bucket of each key.
+}
+
+// NewBandwidth creates a new bandwidth limiter allowing bytesPerSecond to every
+// key, in bursts of up to burst bytes.
+func NewBandwidth(bytesPerSecond, burst int) *Bandwidth {
+	return &Bandwidth{
+		bytesPerSecond: bytesPerSecond,
+		burst:          max(burst, 1),
+		buckets:        make(map[string]*TokenBucket),
+	}
+}
+
+// Burst returns the number of bytes a key may transfer at once. Transfers
+// should be split in chunks of at most that size.
+func (b *Bandwidth) Burst() int {
+	return b.burst
+}
+
+// WaitN reserves n bytes for key and blocks until they may be transferred, or
+// until ctx is done. The reservation is kept even if the wait is cut short.
+func (b *Bandwidth) WaitN(ctx context.Context, key string, n int) error {
+	now := time.Now()
+	b.mu.Lock()
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(b.bytesPerSecond, time.Second, b.burst)
+		b.buckets[strings.Clone(key)] = bucket
+	}
+	delay := bucket.ReserveN(now, n)
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return sleep(ctx, now, delay)
+}
+
+// Prune forgets the keys whose bucket is full at now, as they would be
+// recreated in the same state, and returns how many were removed. Like Keyed,
+// Bandwidth never forgets keys on its own: call Prune periodically.
+func (b *Bandwidth) Prune(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pruned := 0
+	for key, bucket := range b.buckets {
+		if bucket.AllowN(now, 0).ResetAfter <= 0 {
+			delete(b.buckets, key)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// BandwidthMiddleware returns an HTTP middleware throttling the responses sent
+// to each client, keyed by keyFunc, to the rate of bandwidth. Request counts do
+// not protect egress bandwidth, a few clients downloading large files can
+// saturate it.
+func BandwidthMiddleware(bandwidth *Bandwidth, keyFunc KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&throttledResponseWriter{
+				ResponseWriter: w,
+				stream:         newStream(bandwidth, []StreamOption{WithStreamContext(r.Context()), WithStreamKey(keyFunc(r))}),
+			}, r)
+		})
+	}
+}
+
+// throttledResponseWriter is an http.ResponseWriter pacing the body it writes.
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	stream stream // The pacing of the body.
+}
+
+// Write writes p in chunks, waiting for the bandwidth of each one.
+func (w *throttledResponseWriter) Write(p []byte) (int, error) {
+	return w.stream.write(w.ResponseWriter, p)
+}
+
+// Unwrap returns the wrapped response writer, for http.ResponseController.
+func (w *throttledResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush flushes the wrapped response writer, if it supports it.
+func (w *throttledResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,50 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBandwidthPrune(t *testing.T) {
+	bandwidth := NewBandwidth(1000, 1000)
+	ctx := context.Background()
+
+	if err := bandwidth.WaitN(ctx, "idle", 10); err != nil {
+		t.Fatalf("WaitN: %v", err)
+	}
+	if err := bandwidth.WaitN(ctx, "busy", 1000); err != nil {
+		t.Fatalf("WaitN: %v", err)
+	}
+
+	// The bucket of idle refills its 10 bytes in 10ms, the one of busy takes a second.
+	if pruned := bandwidth.Prune(time.Now().Add(100 * time.Millisecond)); pruned != 1 {
+		t.Errorf("Prune = %d, want 1", pruned)
+	}
+	if _, ok := bandwidth.buckets["idle"]; ok {
+		t.Error("full bucket not pruned")
+	}
+	if _, ok := bandwidth.buckets["busy"]; !ok {
+		t.Error("bucket still refilling pruned")
+	}
+}
+
+func TestBandwidthMiddleware(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 300)
+	handler := BandwidthMiddleware(NewBandwidth(1000, 100), KeyByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !bytes.Equal(w.Body.Bytes(), body) {
+		t.Errorf("body of %d bytes, want 300", w.Body.Len())
+	}
+	// The first 100 bytes are sent at once, the next 200 at 1000 bytes per second.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("response sent in %s, want about 200ms", elapsed)
+	}
+}
@@ -0,0 +1,109 @@
+// Package benchmarks measures the algorithms of the ratelimiter package, so
+// performance claims and regressions can be checked. The benchmarks are
+// Benchmark functions of go test:
+//
+//	go test -bench 'ManyKeys/sliding-window' ./ratelimiter/benchmarks
+//
+// and plain functions run with testing.Benchmark by cmd/ratelimit:
+//
+//	go run ./cmd/ratelimit bench -bench 'sliding-window/.*'
+//
+// Every algorithm is measured deciding for a single key, for many keys, and
+// for many keys from every CPU at once. go test also measures a limiter
+// storing its counters in an in-process Redis server, to compare the round
+// trips of a backend with the in-memory algorithms.
+//
+// RunLoad drives any limiter, remote ones included, at a target rate of
+// decisions and reports their throughput, latency and allocations.
+package benchmarks
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Benchmark is a named benchmark.
+type Benchmark struct {
+	Name string           // Name of the benchmark, as algorithm/scenario.
+	F    func(*testing.B) // The benchmark function.
+}
+
+// manyKeys is the number of keys of the many keys scenarios.
+const manyKeys = 100_000
+
+// All returns the benchmarks of every algorithm and scenario.
+func All() []Benchmark {
+	var benchmarks []Benchmark
+	for _, name := range ratelimiter.Algorithms {
+		// A limit high enough for the decisions to be allowed, the common case.
+		newAlgorithm, err := ratelimiter.AlgorithmFactory(name, 1_000_000_000, time.Minute)
+		if err != nil {
+			panic(err)
+		}
+		benchmarks = append(benchmarks,
+			Benchmark{name + "/single-key", SingleKey(newAlgorithm)},
+			Benchmark{name + "/many-keys", ManyKeys(newAlgorithm, manyKeys)},
+			Benchmark{name + "/contended", Contended(newAlgorithm, manyKeys)},
+		)
+	}
+	return benchmarks
+}
+
+// SingleKey measures the decisions of a single algorithm instance.
+func SingleKey(newAlgorithm func() ratelimiter.Algorithm) func(*testing.B) {
+	return func(b *testing.B) {
+		algorithm := newAlgorithm()
+		now := time.Now()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			algorithm.Allow(now.Add(time.Duration(i) * time.Microsecond))
+		}
+	}
+}
+
+// ManyKeys measures the decisions of a Keyed limiter spread over keys keys.
+func ManyKeys(newAlgorithm func() ratelimiter.Algorithm, keys int) func(*testing.B) {
+	return func(b *testing.B) {
+		limiter := ratelimiter.NewKeyed(newAlgorithm)
+		names := keyNames(keys)
+		now := time.Now()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			limiter.Allow(names[i%keys], now.Add(time.Duration(i)*time.Microsecond))
+		}
+	}
+}
+
+// Contended measures the decisions of a Keyed limiter spread over keys keys,
+// made from GOMAXPROCS goroutines at once.
+func Contended(newAlgorithm func() ratelimiter.Algorithm, keys int) func(*testing.B) {
+	return func(b *testing.B) {
+		limiter := ratelimiter.NewKeyed(newAlgorithm)
+		names := keyNames(keys)
+		now := time.Now()
+		var next atomic.Int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				i := next.Add(1)
+				limiter.Allow(names[i%int64(keys)], now.Add(time.Duration(i)*time.Microsecond))
+			}
+		})
+	}
+}
+
+// keyNames returns n distinct keys looking like client addresses.
+func keyNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "10.0." + strconv.Itoa(i/256%256) + "." + strconv.Itoa(i%256) + "#" + strconv.Itoa(i/65536)
+	}
+	return names
+}
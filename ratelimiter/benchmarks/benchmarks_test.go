@@ -0,0 +1,111 @@
+package benchmarks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// forEachAlgorithm runs the benchmark of scenario for every algorithm, as a
+// sub-benchmark named after it.
+func forEachAlgorithm(b *testing.B, scenario func(newAlgorithm func() ratelimiter.Algorithm) func(*testing.B)) {
+	for _, name := range ratelimiter.Algorithms {
+		newAlgorithm, err := ratelimiter.AlgorithmFactory(name, 1_000_000_000, time.Minute)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, scenario(newAlgorithm))
+	}
+}
+
+func BenchmarkSingleKey(b *testing.B) {
+	forEachAlgorithm(b, SingleKey)
+}
+
+func BenchmarkManyKeys(b *testing.B) {
+	forEachAlgorithm(b, func(newAlgorithm func() ratelimiter.Algorithm) func(*testing.B) {
+		return ManyKeys(newAlgorithm, manyKeys)
+	})
+}
+
+func BenchmarkContended(b *testing.B) {
+	forEachAlgorithm(b, func(newAlgorithm func() ratelimiter.Algorithm) func(*testing.B) {
+		return Contended(newAlgorithm, manyKeys)
+	})
+}
+
+// fixedWindowScript counts n requests in the window of KEYS[1], lasting
+// ARGV[2] milliseconds, and returns the count of the window.
+var fixedWindowScript = redis.NewScript(`
+local count = redis.call('INCRBY', KEYS[1], ARGV[1])
+if count == tonumber(ARGV[1]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return count
+`)
+
+// redisLimiter is a minimal fixed window limiter storing its counters in Redis,
+// one round trip per decision. It only measures the cost of the round trip:
+// the tree ships no Redis backend, those are registered by the applications
+// with config.WithBackend.
+type redisLimiter struct {
+	client *redis.Client // The client of the Redis server.
+	rate   int           // Maximum number of requests allowed in the window.
+	window time.Duration // Duration of the window.
+}
+
+func (l *redisLimiter) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return l.AllowN(key, requestTime, 1)
+}
+
+func (l *redisLimiter) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	count, err := fixedWindowScript.Run(context.Background(), l.client, []string{key}, n, l.window.Milliseconds()).Int()
+	if err != nil {
+		return ratelimiter.Decision{Reason: ratelimiter.ReasonBackendFailure}
+	}
+	return ratelimiter.Decision{Allowed: count <= l.rate, Limit: l.rate, Remaining: max(l.rate-count, 0)}
+}
+
+// newRedisLimiter returns a redisLimiter backed by an in-process Redis server.
+func newRedisLimiter(b *testing.B) *redisLimiter {
+	b.Helper()
+	server := miniredis.RunT(b)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	b.Cleanup(func() { client.Close() })
+	return &redisLimiter{client: client, rate: 1_000_000_000, window: time.Minute}
+}
+
+// BenchmarkRedisBackend measures the decisions of a limiter storing its state
+// in Redis, dominated by the round trips to the server rather than by the
+// algorithm, to compare with the in-memory algorithms.
+func BenchmarkRedisBackend(b *testing.B) {
+	b.Run("many-keys", func(b *testing.B) {
+		limiter := newRedisLimiter(b)
+		names := keyNames(manyKeys)
+		now := time.Now()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if limiter.Allow(names[i%manyKeys], now).Reason == ratelimiter.ReasonBackendFailure {
+				b.Fatal("backend failure")
+			}
+		}
+	})
+	b.Run("contended", func(b *testing.B) {
+		limiter := newRedisLimiter(b)
+		names := keyNames(manyKeys)
+		now := time.Now()
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				limiter.Allow(names[i%manyKeys], now)
+			}
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package benchmarks
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Load describes the load RunLoad drives through a limiter.
+type Load struct {
+	Rate        float64       // Target number of decisions per second, as many as possible if zero.
+	Concurrency int           // Number of goroutines making the decisions, one if zero.
+	Duration    time.Duration // Duration of the load.
+	Keys        int           // Number of keys the decisions are spread over, one if zero.
+}
+
+// LoadResult is the outcome of RunLoad.
+type LoadResult struct {
+	Decisions  int           // Number of decisions made.
+	Allowed    int           // Number of decisions allowing the request.
+	Failures   int           // Number of decisions denied with ratelimiter.ReasonBackendFailure.
+	Elapsed    time.Duration // Time taken by the decisions.
+	Mallocs    uint64        // Number of heap allocations during the load, of every goroutine.
+	AllocBytes uint64        // Number of bytes allocated on the heap during the load.
+	latencies  histogram     // The latencies of the decisions.
+}
+
+// Throughput returns the number of decisions made per second.
+func (r *LoadResult) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Decisions) / r.Elapsed.Seconds()
+}
+
+// Latency returns the latency of the decisions at percentile p, between 0 and
+// 100, to within about 3%.
+func (r *LoadResult) Latency(p float64) time.Duration {
+	return r.latencies.percentile(p)
+}
+
+// AllocsPerDecision returns the mean number of heap allocations per decision.
+func (r *LoadResult) AllocsPerDecision() float64 {
+	if r.Decisions == 0 {
+		return 0
+	}
+	return float64(r.Mallocs) / float64(r.Decisions)
+}
+
+// BytesPerDecision returns the mean number of bytes allocated per decision.
+func (r *LoadResult) BytesPerDecision() float64 {
+	if r.Decisions == 0 {
+		return 0
+	}
+	return float64(r.AllocBytes) / float64(r.Decisions)
+}
+
+// RunLoad drives load through limiter, measuring the latency of every decision.
+// The decisions are paced at the target rate whatever their latency, so slow
+// decisions queue up as they would behind a real server, and a throughput
+// below the rate means the limiter cannot keep up with it. The latencies of
+// paced decisions are measured from the time they are scheduled at, so they
+// include the time spent queued rather than hide it.
+func RunLoad(limiter ratelimiter.Limiter, load Load) *LoadResult {
+	concurrency := max(load.Concurrency, 1)
+	names := keyNames(max(load.Keys, 1))
+
+	// The results of every goroutine, merged once the load is over so the
+	// goroutines do not contend on them.
+	results := make([]LoadResult, concurrency)
+	ticks := make(chan tick, concurrency)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := range results {
+		wg.Add(1)
+		go func(result *LoadResult) {
+			defer wg.Done()
+			for t := range ticks {
+				decision := limiter.Allow(names[t.index%len(names)], time.Now())
+				result.latencies.add(time.Since(t.scheduled))
+				result.Decisions++
+				if decision.Allowed {
+					result.Allowed++
+				} else if decision.Reason == ratelimiter.ReasonBackendFailure {
+					result.Failures++
+				}
+			}
+		}(&results[w])
+	}
+
+	for i := 0; ; i++ {
+		next := start
+		if load.Rate > 0 {
+			next = start.Add(time.Duration(float64(i) / load.Rate * float64(time.Second)))
+			time.Sleep(time.Until(next))
+		}
+		if next.Sub(start) >= load.Duration || time.Since(start) >= load.Duration {
+			break
+		}
+		if load.Rate <= 0 {
+			// Unpaced decisions are scheduled as soon as a goroutine is free.
+			next = time.Now()
+		}
+		ticks <- tick{index: i, scheduled: next}
+	}
+	close(ticks)
+	wg.Wait()
+
+	result := &LoadResult{Elapsed: time.Since(start)}
+	runtime.ReadMemStats(&after)
+	result.Mallocs = after.Mallocs - before.Mallocs
+	result.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	for i := range results {
+		result.Decisions += results[i].Decisions
+		result.Allowed += results[i].Allowed
+		result.Failures += results[i].Failures
+		result.latencies.merge(&results[i].latencies)
+	}
+	return result
+}
+
+// tick is a decision of a load.
+type tick struct {
+	index     int       // The index of the decision, from 0.
+	scheduled time.Time // The time the decision is scheduled at.
+}
+
+// histogramSubBuckets is the number of buckets a histogram splits every power
+// of two into.
+const histogramSubBuckets = 16
+
+// histogram counts durations in buckets of about 6% of their value, so
+// recording them allocates nothing whatever their number.
+type histogram struct {
+	counts [64 * histogramSubBuckets]uint64 // The number of durations of every bucket.
+	total  uint64                           // The number of durations.
+}
+
+// add records d.
+func (h *histogram) add(d time.Duration) {
+	h.counts[bucket(d)]++
+	h.total++
+}
+
+// merge adds the durations of other.
+func (h *histogram) merge(other *histogram) {
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.total += other.total
+}
+
+// percentile returns the middle of the bucket holding the duration at
+// percentile p.
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(h.total))
+	rank = min(max(rank, 1), h.total)
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			low, high := bucketBounds(i)
+			return low + (high-low)/2
+		}
+	}
+	return 0
+}
+
+// bucket returns the bucket of d: the power of two below it, and the next
+// bits under its leading one.
+func bucket(d time.Duration) int {
+	n := uint64(max(d, 0))
+	if n < histogramSubBuckets {
+		return int(n)
+	}
+	exp := bits.Len64(n) - 1
+	sub := int(n>>(exp-4)) & (histogramSubBuckets - 1)
+	return (exp-3)*histogramSubBuckets + sub
+}
+
+// bucketBounds returns the smallest duration of bucket i and that of the
+// next one.
+func bucketBounds(i int) (time.Duration, time.Duration) {
+	if i < histogramSubBuckets {
+		return time.Duration(i), time.Duration(i + 1)
+	}
+	exp := i/histogramSubBuckets + 3
+	sub := i % histogramSubBuckets
+	low := uint64(histogramSubBuckets+sub) << (exp - 4)
+	return time.Duration(low), time.Duration(low + 1<<(exp-4))
+}
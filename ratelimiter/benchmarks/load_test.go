@@ -0,0 +1,57 @@
+package benchmarks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// slowLimiter allows every request after a delay.
+type slowLimiter struct {
+	delay time.Duration // The time taken by every decision.
+}
+
+func (l slowLimiter) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return l.AllowN(key, requestTime, 1)
+}
+
+func (l slowLimiter) AllowN(string, time.Time, int) ratelimiter.Decision {
+	time.Sleep(l.delay)
+	return ratelimiter.Decision{Allowed: true}
+}
+
+func TestRunLoadMeasuresQueueing(t *testing.T) {
+	// The limiter makes 100 decisions per second, half the target rate, so the
+	// decisions queue up and wait longer and longer.
+	result := RunLoad(slowLimiter{delay: 10 * time.Millisecond}, Load{Rate: 200, Concurrency: 1, Duration: 200 * time.Millisecond})
+
+	if result.Decisions < 10 {
+		t.Fatalf("%d decisions, want at least 10", result.Decisions)
+	}
+	if result.Allowed != result.Decisions {
+		t.Errorf("allowed %d of %d decisions", result.Allowed, result.Decisions)
+	}
+	if latency := result.Latency(100); latency < 50*time.Millisecond {
+		t.Errorf("maximum latency = %s, want the queueing delay included", latency)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 100; i++ {
+		h.add(time.Duration(i) * time.Millisecond)
+	}
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+	} {
+		got := h.percentile(tt.p)
+		if diff := got - tt.want; diff < -tt.want/16 || diff > tt.want/16 {
+			t.Errorf("p%g = %s, want %s within 6%%", tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Period is a period of the calendar, such as a day, the windows of a
+// CalendarWindow are aligned on.
+type Period string
+
+const (
+	PeriodMinute Period = "minute" // Minutes of the clock.
+	PeriodHour   Period = "hour"   // Hours of the clock.
+	PeriodDay    Period = "day"    // Days, from midnight to midnight.
+	PeriodWeek   Period = "week"   // Weeks, from midnight on Monday.
+	PeriodMonth  Period = "month"  // Months, from midnight on their first day.
+)
+
+// Periods lists the periods accepted by NewCalendarWindow.
+var Periods = []Period{PeriodMinute, PeriodHour, PeriodDay, PeriodWeek, PeriodMonth}
+
+// PeriodOf returns the period usually lasting window: a minute, an hour, 24
+// hours or 168 hours, and false for other windows.
+func PeriodOf(window time.Duration) (Period, bool) {
+	switch window {
+	case time.Minute:
+		return PeriodMinute, true
+	case time.Hour:
+		return PeriodHour, true
+	case 24 * time.Hour:
+		return PeriodDay, true
+	case 7 * 24 * time.Hour:
+		return PeriodWeek, true
+	default:
+		return "", false
+	}
+}
+
+// Bounds returns the start and the end of the period holding t in the calendar
+// of location. The periods follow the daylight saving time transitions of the
+// location, so a day lasts 23 or 25 hours across them.
+func (p Period) Bounds(t time.Time, location *time.Location) (time.Time, time.Time) {
+	local := t.In(location)
+	year, month, day := local.Date()
+	switch p {
+	case PeriodMinute, PeriodHour:
+		// The clock of the location moves by whole hours, or half hours, so
+		// the minutes and hours are those of the absolute time once the
+		// fraction of the current one is removed.
+		elapsed := time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+		length := time.Minute
+		if p == PeriodHour {
+			elapsed += time.Duration(local.Minute()) * time.Minute
+			length = time.Hour
+		}
+		start := t.Add(-elapsed)
+		return start, start.Add(length)
+	case PeriodWeek:
+		day -= (int(local.Weekday()) + 6) % 7
+		return time.Date(year, month, day, 0, 0, 0, 0, location), time.Date(year, month, day+7, 0, 0, 0, 0, location)
+	case PeriodMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location), time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, location), time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	}
+}
+
+// CalendarWindow implements a fixed window aligned on the periods of the
+// calendar of a time zone, such as quotas per day reset at local midnight
+// rather than 24 hours after the first request. The whole budget is available
+// again at the start of every period.
+type CalendarWindow struct {
+	rate     int            // Maximum number of requests allowed in a period.
+	period   Period         // The period of the windows.
+	location *time.Location // The time zone of the calendar.
+	start    time.Time      // The start of the current window.
+	end      time.Time      // The end of the current window.
+	count    int            // The number of requests counted in the current window.
+	reserved []int          // The number of requests reserved in each of the windows after the current one.
+}
+
+// NewCalendarWindow creates a new calendar window rate limiter instance,
+// allowing rate requests per period of the calendar of location, UTC if nil.
+func NewCalendarWindow(rate int, period Period, location *time.Location) *CalendarWindow {
+	if location == nil {
+		location = time.UTC
+	}
+	return &CalendarWindow{rate: rate, period: period, location: location}
+}
+
+// CalendarWindowFactory returns a function creating calendar windows allowing
+// rate requests per period of the calendar of location, for use with NewKeyed.
+func CalendarWindowFactory(rate int, period Period, location *time.Location) (func() Algorithm, error) {
+	if !slices.Contains(Periods, period) {
+		return nil, fmt.Errorf("ratelimiter: unknown period %q", period)
+	}
+	return func() Algorithm { return NewCalendarWindow(rate, period, location) }, nil
+}
+
+// Allow determines whether a new request at requestTime should be allowed.
+func (cw *CalendarWindow) Allow(requestTime time.Time) Decision {
+	return cw.AllowN(requestTime, 1)
+}
+
+// AllowN determines whether a new request costing n units at requestTime
+// should be allowed. A request costing more than the rate is never allowed.
+func (cw *CalendarWindow) AllowN(requestTime time.Time, n int) Decision {
+	cw.advance(requestTime)
+
+	allowed := cw.count+n <= cw.rate
+	if allowed {
+		cw.count += n
+	}
+
+	decision := Decision{
+		Allowed:   allowed,
+		Limit:     cw.rate,
+		Remaining: max(cw.rate-cw.count, 0),
+		Window:    cw.end.Sub(cw.start),
+		Algorithm: CalendarWindowAlgorithm,
+	}
+	if len(cw.reserved) > 0 {
+		decision.ResetAfter = cw.reservedUntil().Sub(requestTime)
+	} else if cw.count > 0 {
+		decision.ResetAfter = cw.end.Sub(requestTime)
+	}
+	if !allowed {
+		decision.Reason = ReasonRateLimit
+		decision.RetryAfter = cw.end.Sub(requestTime)
+	}
+	return decision
+}
+
+// Reserve accounts for a new request at requestTime even if the window is full,
+// and returns how long the caller must wait before sending it.
+func (cw *CalendarWindow) Reserve(requestTime time.Time) time.Duration {
+	decision := cw.Allow(requestTime)
+	if decision.Allowed {
+		return 0
+	}
+
+	// Count the request in the first next window with budget left, so no
+	// window holds more than rate requests however many are reserved.
+	start := cw.end
+	for i, reserved := range cw.reserved {
+		if reserved < cw.rate {
+			cw.reserved[i]++
+			return start.Sub(requestTime)
+		}
+		_, start = cw.period.Bounds(start, cw.location)
+	}
+	cw.reserved = append(cw.reserved, 1)
+	return start.Sub(requestTime)
+}
+
+// Inspect returns the internal state of the calendar window.
+func (cw *CalendarWindow) Inspect() State {
+	return State{
+		Algorithm: CalendarWindowAlgorithm,
+		Limit:     cw.rate,
+		Window:    cw.end.Sub(cw.start),
+		Start:     cw.start,
+		Count:     cw.count,
+		Reserved:  slices.Clone(cw.reserved),
+	}
+}
+
+// advance moves the window to the period holding requestTime, if it is not in
+// the current one. The reservations of the windows moved through are counted
+// in them.
+func (cw *CalendarWindow) advance(requestTime time.Time) {
+	if cw.start.IsZero() || requestTime.Before(cw.start) {
+		cw.reset(requestTime)
+		return
+	}
+	for !requestTime.Before(cw.end) {
+		if len(cw.reserved) == 0 {
+			cw.reset(requestTime)
+			return
+		}
+		_, end := cw.period.Bounds(cw.end, cw.location)
+		cw.start, cw.end, cw.count = cw.end, end, cw.reserved[0]
+		cw.reserved = cw.reserved[1:]
+	}
+}
+
+// reset moves the window to the period holding requestTime, with its whole
+// budget and no reservations.
+func (cw *CalendarWindow) reset(requestTime time.Time) {
+	cw.start, cw.end = cw.period.Bounds(requestTime, cw.location)
+	cw.count, cw.reserved = 0, nil
+}
+
+// reservedUntil returns the end of the last window holding reservations.
+func (cw *CalendarWindow) reservedUntil() time.Time {
+	end := cw.end
+	for range cw.reserved {
+		_, end = cw.period.Bounds(end, cw.location)
+	}
+	return end
+}
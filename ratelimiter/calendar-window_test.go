@@ -0,0 +1,74 @@
+package ratelimiter
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCalendarWindowAllow(t *testing.T) {
+	location := time.FixedZone("UTC+7", 7*60*60)
+	cw := NewCalendarWindow(2, PeriodDay, location)
+	evening := time.Date(2026, 3, 10, 23, 0, 0, 0, location)
+
+	for i, want := range []bool{true, true, false} {
+		if decision := cw.Allow(evening); decision.Allowed != want {
+			t.Errorf("request %d: allowed = %t, want %t", i, decision.Allowed, want)
+		}
+	}
+	decision := cw.Allow(evening)
+	if decision.RetryAfter != time.Hour {
+		t.Errorf("RetryAfter = %s, want the hour left until local midnight", decision.RetryAfter)
+	}
+	if !cw.Allow(evening.Add(time.Hour)).Allowed {
+		t.Error("budget not replenished at local midnight")
+	}
+}
+
+func TestCalendarWindowReserveSpills(t *testing.T) {
+	cw := NewCalendarWindow(2, PeriodHour, time.UTC)
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+	var waits []time.Duration
+	for range 7 {
+		waits = append(waits, cw.Reserve(now))
+	}
+	want := []time.Duration{0, 0, 30 * time.Minute, 30 * time.Minute, 90 * time.Minute, 90 * time.Minute, 150 * time.Minute}
+	if !slices.Equal(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+
+	state := cw.Inspect()
+	if state.Count != 2 || !slices.Equal(state.Reserved, []int{2, 2, 1}) {
+		t.Errorf("Inspect = count %d, reserved %v, want count 2, reserved [2 2 1]", state.Count, state.Reserved)
+	}
+
+	// The reservations are counted in their windows as time goes by.
+	if cw.Allow(now.Add(time.Hour)).Allowed {
+		t.Error("request allowed in a window full of reservations")
+	}
+	if decision := cw.Allow(now.Add(3 * time.Hour)); !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("decision in the last reserved window = %+v, want allowed with nothing remaining", decision)
+	}
+}
+
+func TestKeyedPruneKeepsReservations(t *testing.T) {
+	newAlgorithm, err := CalendarWindowFactory(1, PeriodMinute, time.UTC)
+	if err != nil {
+		t.Fatalf("CalendarWindowFactory: %v", err)
+	}
+	keyed := NewKeyed(newAlgorithm)
+	now := time.Date(2026, 3, 10, 12, 0, 30, 0, time.UTC)
+
+	for range 3 {
+		keyed.Reserve("reserved", now)
+	}
+
+	// Past the first window, the key only holds reservations.
+	if pruned := keyed.Prune(now.Add(31 * time.Second)); pruned != 0 {
+		t.Errorf("Prune removed %d keys with pending reservations", pruned)
+	}
+	if pruned := keyed.Prune(now.Add(3 * time.Minute)); pruned != 1 {
+		t.Errorf("Prune = %d once the reservations are past, want 1", pruned)
+	}
+}
@@ -0,0 +1,185 @@
+// Package check exposes a limiter over HTTP as a small JSON API, so services
+// written in other languages can consult the same limits:
+//
+//	mux.Handle("POST /check", check.NewHandler(limiter))
+//
+// The requests name the key to check and the cost of the request, one if
+// omitted, zero reporting the state of the key without consuming anything:
+//
+//	POST /check {"key": "203.0.113.7", "cost": 1}
+//
+// and are answered 200 OK with the decision, whether allowed or not:
+//
+//	{"allowed": false, "limit": 100, "remaining": 0, "reset_after": 12.5, "retry_after": 0.6, "window": 60, "algorithm": "sliding-window", "reason": "rate_limit"}
+//
+// The durations are in seconds. Client is a ratelimiter.Limiter consulting
+// such an API.
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Request is the body of the requests checking a key.
+type Request struct {
+	Key  string `json:"key"`            // The key to check.
+	Cost *int   `json:"cost,omitempty"` // The cost of the request, one if omitted.
+}
+
+// Response is the body of the responses, the decision about the request.
+type Response struct {
+	Allowed    bool               `json:"allowed"`          // Whether the request is allowed.
+	Limit      int                `json:"limit"`            // Maximum number of requests allowed in the window.
+	Remaining  int                `json:"remaining"`        // Number of requests that can still be made in the current window.
+	ResetAfter float64            `json:"reset_after"`      // Number of seconds until the budget is fully replenished.
+	RetryAfter float64            `json:"retry_after"`      // Number of seconds until the next request would be allowed, zero if allowed.
+	Window     float64            `json:"window"`           // Number of seconds of the window the limit applies to.
+	Algorithm  string             `json:"algorithm"`        // Name of the algorithm that made the decision.
+	Reason     ratelimiter.Reason `json:"reason,omitempty"` // Why the request was denied, empty if allowed.
+}
+
+// NewResponse converts decision to its reported form.
+func NewResponse(decision ratelimiter.Decision) Response {
+	return Response{
+		Allowed:    decision.Allowed,
+		Limit:      decision.Limit,
+		Remaining:  decision.Remaining,
+		ResetAfter: decision.ResetAfter.Seconds(),
+		RetryAfter: decision.RetryAfter.Seconds(),
+		Window:     decision.Window.Seconds(),
+		Algorithm:  decision.Algorithm,
+		Reason:     decision.Reason,
+	}
+}
+
+// Decision converts the response back to a decision.
+func (r Response) Decision() ratelimiter.Decision {
+	return ratelimiter.Decision{
+		Allowed:    r.Allowed,
+		Limit:      r.Limit,
+		Remaining:  r.Remaining,
+		ResetAfter: seconds(r.ResetAfter),
+		RetryAfter: seconds(r.RetryAfter),
+		Window:     seconds(r.Window),
+		Algorithm:  r.Algorithm,
+		Reason:     r.Reason,
+	}
+}
+
+// seconds converts a number of seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// handler is the HTTP handler of the API.
+type handler struct {
+	limiter ratelimiter.Limiter // The limiter consulted.
+}
+
+// NewHandler returns the handler answering the requests checking keys with the
+// decisions of limiter.
+func NewHandler(limiter ratelimiter.Limiter) http.Handler {
+	return &handler{limiter: limiter}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cost := 1
+	if req.Cost != nil {
+		cost = *req.Cost
+	}
+	if cost < 0 {
+		writeError(w, http.StatusBadRequest, "cost must not be negative")
+		return
+	}
+	writeJSON(w, http.StatusOK, NewResponse(h.limiter.AllowN(req.Key, time.Now(), cost)))
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response with the given status.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// Client is a ratelimiter.Limiter consulting the API served by NewHandler.
+// The time of the requests is that of the server.
+type Client struct {
+	url    string       // The URL of the API, e.g. http://localhost:8080/check.
+	client *http.Client // The client sending the requests.
+}
+
+// NewClient creates a new client of the API at url, sending the requests with
+// client, http.DefaultClient if nil.
+func NewClient(url string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{url: url, client: client}
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (c *Client) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return c.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units should be
+// allowed, denying it with ratelimiter.ReasonBackendFailure if the API cannot
+// be consulted, see Check.
+func (c *Client) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	decision, err := c.Check(key, n)
+	if err != nil {
+		return ratelimiter.Decision{Reason: ratelimiter.ReasonBackendFailure}
+	}
+	return decision
+}
+
+// Check asks the API whether a new request for key costing n units should be
+// allowed.
+func (c *Client) Check(key string, n int) (ratelimiter.Decision, error) {
+	body, err := json.Marshal(Request{Key: key, Cost: &n})
+	if err != nil {
+		return ratelimiter.Decision{}, err
+	}
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return ratelimiter.Decision{}, fmt.Errorf("check: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return ratelimiter.Decision{}, fmt.Errorf("check: %s", apiErr.Error)
+	}
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return ratelimiter.Decision{}, fmt.Errorf("check: invalid response: %w", err)
+	}
+	return response.Decision(), nil
+}
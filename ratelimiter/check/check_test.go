@@ -0,0 +1,63 @@
+package check
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func newServer(t *testing.T, limit int) *httptest.Server {
+	t.Helper()
+	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(limit, time.Minute) })
+	server := httptest.NewServer(NewHandler(limiter))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := newServer(t, 3)
+	client := NewClient(server.URL, server.Client())
+
+	decision := client.AllowN("203.0.113.7", time.Now(), 2)
+	if !decision.Allowed || decision.Limit != 3 || decision.Remaining != 1 || decision.Window != time.Minute || decision.Algorithm != "sliding-window" {
+		t.Errorf("first decision %+v, want 2 of 3 consumed", decision)
+	}
+	// A cost of zero reports the state of the key without consuming anything.
+	if decision, err := client.Check("203.0.113.7", 0); err != nil || decision.Remaining != 1 {
+		t.Errorf("zero cost check: decision %+v, error %v, want 1 remaining", decision, err)
+	}
+	decision = client.AllowN("203.0.113.7", time.Now(), 2)
+	if decision.Allowed || decision.Reason != ratelimiter.ReasonRateLimit || decision.RetryAfter <= 0 {
+		t.Errorf("second decision %+v, want denied with a retry delay", decision)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := newServer(t, 3)
+	client := NewClient(server.URL, server.Client())
+
+	if _, err := client.Check("203.0.113.7", -1); err == nil || !strings.Contains(err.Error(), "cost must not be negative") {
+		t.Errorf("negative cost: error %v, want the API error", err)
+	}
+	// Decisions fail closed when the API cannot be consulted.
+	server.Close()
+	if decision := client.Allow("203.0.113.7", time.Now()); decision.Allowed || decision.Reason != ratelimiter.ReasonBackendFailure {
+		t.Errorf("decision %+v with the API down, want denied with %q", decision, ratelimiter.ReasonBackendFailure)
+	}
+}
+
+func TestHandlerMethod(t *testing.T) {
+	server := newServer(t, 3)
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("GET: status %d, Allow %q, want %d and POST", resp.StatusCode, resp.Header.Get("Allow"), http.StatusMethodNotAllowed)
+	}
+}
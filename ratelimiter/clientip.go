@@ -0,0 +1,225 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Names of the forwarding headers believed by KeyByClientIP.
+const (
+	HeaderForwarded     = "Forwarded"       // The standard header of RFC 7239.
+	HeaderXForwardedFor = "X-Forwarded-For" // The de facto standard header.
+	HeaderXRealIP       = "X-Real-IP"       // The header of the single client address set by nginx.
+)
+
+// ClientIPOption configures KeyByClientIP.
+type ClientIPOption func(*clientIP)
+
+// WithForwardingHeader sets the forwarding header the trusted proxies write the
+// client address to: HeaderForwarded, HeaderXForwardedFor or HeaderXRealIP.
+// Only that header is believed, the others being passed through by the proxies
+// as sent by the clients.
+func WithForwardingHeader(name string) ClientIPOption {
+	return func(c *clientIP) {
+		c.header = http.CanonicalHeaderKey(name)
+	}
+}
+
+// clientIP resolves the address of the client of requests behind trusted
+// proxies.
+type clientIP struct {
+	trusted []netip.Prefix // The trusted proxies.
+	header  string         // The forwarding header written by the trusted proxies, empty if unknown.
+}
+
+// KeyByClientIP returns a key function keying requests by the IP address of the
+// client behind the given trusted proxies, listed as CIDRs or single addresses.
+//
+// The forwarding headers are only believed when the request comes from a trusted
+// proxy, and are walked from the closest hop back, skipping trusted proxies: the
+// first untrusted address is the client. Clients therefore cannot escape their
+// limit by sending a forged X-Forwarded-For, since a proxy appends the real
+// address after it.
+//
+// Proxies only append to the header they write, and pass the others through,
+// so set it with WithForwardingHeader. Otherwise, when a request carries both
+// Forwarded and X-Forwarded-For, a header is only believed if every hop of the
+// other one is a trusted proxy, or if both name the same client: the request
+// is keyed by the address of the proxy when they disagree, so clients cannot
+// pick their key with the header their proxy ignores. X-Real-IP is used when
+// neither is present.
+func KeyByClientIP(trustedProxies []string, opts ...ClientIPOption) (KeyFunc, error) {
+	c, err := newClientIP(trustedProxies, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.resolve, nil
+}
+
+// newClientIP creates a new resolver of the client addresses behind
+// trustedProxies.
+func newClientIP(trustedProxies []string, opts []ClientIPOption) (*clientIP, error) {
+	c := &clientIP{trusted: make([]netip.Prefix, 0, len(trustedProxies))}
+	for _, proxy := range trustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimiter: invalid trusted proxy %q: %w", proxy, err)
+		}
+		c.trusted = append(c.trusted, prefix)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	switch c.header {
+	case "", HeaderForwarded, HeaderXForwardedFor, http.CanonicalHeaderKey(HeaderXRealIP):
+	default:
+		return nil, fmt.Errorf("ratelimiter: unknown forwarding header %q, want %s, %s or %s", c.header, HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP)
+	}
+	return c, nil
+}
+
+// isTrusted reports whether addr is a trusted proxy.
+func (c *clientIP) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the address of the client of r.
+func (c *clientIP) resolve(r *http.Request) string {
+	peer, ok := parseAddr(KeyByIP(r))
+	if !ok || !c.isTrusted(peer) {
+		return KeyByIP(r)
+	}
+
+	switch c.header {
+	case HeaderForwarded:
+		return c.walk(peer, forwarded(r.Header)).String()
+	case HeaderXForwardedFor:
+		return c.walk(peer, xForwardedFor(r.Header)).String()
+	case "":
+	default:
+		if realIP, ok := parseAddr(r.Header.Get(HeaderXRealIP)); ok {
+			return realIP.String()
+		}
+		return peer.String()
+	}
+
+	standard, xff := forwarded(r.Header), xForwardedFor(r.Header)
+	switch {
+	case len(standard) > 0 && len(xff) > 0:
+		client, other := c.walk(peer, standard), c.walk(peer, xff)
+		switch {
+		case c.allTrusted(xff) || client == other:
+			return client.String()
+		case c.allTrusted(standard):
+			return other.String()
+		default:
+			return peer.String()
+		}
+	case len(standard) > 0:
+		return c.walk(peer, standard).String()
+	case len(xff) > 0:
+		return c.walk(peer, xff).String()
+	}
+	if realIP, ok := parseAddr(r.Header.Get(HeaderXRealIP)); ok {
+		return realIP.String()
+	}
+	return peer.String()
+}
+
+// walk returns the client of a request from peer with the forwarding hops, from
+// the client to the last proxy: walking back from the proxy closest to us,
+// every hop before an untrusted one may have been forged by the client.
+func (c *clientIP) walk(peer netip.Addr, hops []string) netip.Addr {
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// allTrusted reports whether every hop is a trusted proxy, so the hops carry
+// no client address.
+func (c *clientIP) allTrusted(hops []string) bool {
+	for _, hop := range hops {
+		addr, ok := parseAddr(hop)
+		if !ok || !c.isTrusted(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// forwarded returns the addresses listed by the Forwarded header, from the
+// client to the last proxy.
+func forwarded(h http.Header) []string {
+	var hops []string
+	for _, value := range h.Values(HeaderForwarded) {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hops = append(hops, strings.Trim(node, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// xForwardedFor returns the addresses listed by the X-Forwarded-For header,
+// from the client to the last proxy.
+func xForwardedFor(h http.Header) []string {
+	var hops []string
+	for _, value := range h.Values(HeaderXForwardedFor) {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parseAddr parses an IP address, optionally bracketed or followed by a port as
+// in the Forwarded header.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// parsePrefix parses a CIDR or a single IP address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
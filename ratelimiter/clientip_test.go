@@ -0,0 +1,76 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyByClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ClientIPOption
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer", nil, "6.6.6.6:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "6.6.6.6"},
+		{"no header", nil, "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"x-forwarded-for", nil, "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.0.0.2"}, "1.2.3.4"},
+		{"forwarded", nil, "10.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db8::1]:80", for=10.0.0.2`}, "2001:db8::1"},
+		{"x-real-ip", nil, "10.0.0.1:1234", map[string]string{"X-Real-IP": "1.2.3.4"}, "1.2.3.4"},
+		{
+			"forged forwarded behind an x-forwarded-for proxy", nil, "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=6.6.6.6", "X-Forwarded-For": "1.2.3.4"}, "10.0.0.1",
+		},
+		{
+			"headers agreeing", nil, "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "1.2.3.4"}, "1.2.3.4",
+		},
+		{
+			"x-forwarded-for of trusted hops only", nil, "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "10.0.0.2"}, "1.2.3.4",
+		},
+		{
+			"forwarded of trusted hops only", nil, "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=10.0.0.2", "X-Forwarded-For": "1.2.3.4"}, "1.2.3.4",
+		},
+		{
+			"configured x-forwarded-for", []ClientIPOption{WithForwardingHeader(HeaderXForwardedFor)}, "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=6.6.6.6", "X-Forwarded-For": "1.2.3.4"}, "1.2.3.4",
+		},
+		{
+			"configured forwarded", []ClientIPOption{WithForwardingHeader(HeaderForwarded)}, "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "6.6.6.6"}, "1.2.3.4",
+		},
+		{
+			"configured x-real-ip", []ClientIPOption{WithForwardingHeader("x-real-ip")}, "10.0.0.1:1234",
+			map[string]string{"X-Real-IP": "1.2.3.4", "X-Forwarded-For": "6.6.6.6"}, "1.2.3.4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyFunc, err := KeyByClientIP([]string{"10.0.0.0/8"}, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.peer
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := keyFunc(r); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyByClientIPInvalid(t *testing.T) {
+	if _, err := KeyByClientIP([]string{"not-an-ip"}); err == nil {
+		t.Error("invalid trusted proxy accepted")
+	}
+	if _, err := KeyByClientIP(nil, WithForwardingHeader("X-Client-IP")); err == nil {
+		t.Error("unknown forwarding header accepted")
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyByIdempotencyKey identifies duplicate requests by their Idempotency-Key header.
+func KeyByIdempotencyKey(r *http.Request) string {
+	return r.Header.Get("Idempotency-Key")
+}
+
+// WithCoalesce makes duplicate requests, identified by the non-empty key
+// returned by idempotencyKey, share their denials: while a request is being
+// decided its duplicates wait for its decision, and a denial is reused by the
+// duplicates arriving until its Retry-After elapses. Retry storms then hit the
+// limiter, and the store behind it, once per denial instead of once per retry.
+// Duplicates of an allowed request are decided on their own.
+func WithCoalesce(idempotencyKey KeyFunc) Option {
+	return func(m *middleware) {
+		m.coalesce = &coalescer{
+			keyFunc: idempotencyKey,
+			calls:   make(map[string]*coalescedCall),
+		}
+	}
+}
+
+// coalescer shares the denials of duplicate requests.
+type coalescer struct {
+	keyFunc   KeyFunc                   // The function identifying duplicate requests.
+	mu        sync.Mutex                // Protects calls and lastPrune.
+	calls     map[string]*coalescedCall // Map to hold the last decision of each idempotency key.
+	lastPrune time.Time                 // The last time expired decisions were removed.
+}
+
+// coalescedCall is a decision shared by duplicate requests.
+type coalescedCall struct {
+	done      chan struct{} // Closed once the decision is made.
+	decision  Decision      // The decision, valid once done is closed.
+	decidedAt time.Time     // When the decision was made.
+}
+
+// decide returns the decision of the request identified by id, made with
+// decide unless a duplicate is being decided or was denied recently.
+func (c *coalescer) decide(id string, decide func() (Decision, time.Time)) (Decision, time.Time) {
+	for {
+		c.mu.Lock()
+		c.prune(time.Now())
+		call, ok := c.calls[id]
+		if !ok {
+			call = &coalescedCall{done: make(chan struct{})}
+			c.calls[id] = call
+			c.mu.Unlock()
+
+			call.decision, call.decidedAt = decide()
+			close(call.done)
+			if call.decision.Allowed {
+				c.forget(id, call)
+			}
+			return call.decision, call.decidedAt
+		}
+		c.mu.Unlock()
+
+		<-call.done
+		if call.decision.Allowed {
+			return decide()
+		}
+		now := time.Now()
+		if remaining := call.decision.RetryAfter - now.Sub(call.decidedAt); remaining > 0 {
+			decision := call.decision
+			decision.RetryAfter = remaining
+			return decision, now
+		}
+		// The denial expired, the next duplicate decides again.
+		c.forget(id, call)
+	}
+}
+
+// forget removes call from the decisions shared under id, unless it was
+// replaced already.
+func (c *coalescer) forget(id string, call *coalescedCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.calls[id] == call {
+		delete(c.calls, id)
+	}
+}
+
+// prune removes the expired denials, at most once a minute. It must be called
+// with mu held.
+func (c *coalescer) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < time.Minute {
+		return
+	}
+	c.lastPrune = now
+
+	for id, call := range c.calls {
+		select {
+		case <-call.done:
+			if now.Sub(call.decidedAt) >= call.decision.RetryAfter {
+				delete(c.calls, id)
+			}
+		default:
+		}
+	}
+}
+
+// idempotencyKey returns the key identifying the duplicates of r, empty if
+// duplicates are not coalesced.
+func (m *middleware) idempotencyKey(r *http.Request) string {
+	if m.coalesce == nil {
+		return ""
+	}
+	return m.coalesce.keyFunc(r)
+}
@@ -0,0 +1,56 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithCoalesce(t *testing.T) {
+	calls := 0
+	allowed := false
+	limiter := limiterFunc(func(key string, requestTime time.Time, n int) Decision {
+		calls++
+		if allowed {
+			return Decision{Allowed: true, Limit: 1, Remaining: 1}
+		}
+		return Decision{Limit: 1, RetryAfter: time.Minute, ResetAfter: time.Minute, Reason: ReasonRateLimit}
+	})
+	handler := Middleware(limiter, WithCoalesce(KeyByIdempotencyKey))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(idempotencyKey string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if idempotencyKey != "" {
+			r.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, test := range []struct {
+		idempotencyKey string
+		calls          int
+	}{
+		{"order-1", 1},
+		{"order-1", 1}, // The retry reuses the denial.
+		{"order-2", 2},
+		{"", 3},
+		{"", 4},
+	} {
+		if w := send(test.idempotencyKey); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %q: status %d, Retry-After %q, want denied", test.idempotencyKey, w.Code, w.Header().Get("Retry-After"))
+		}
+		if calls != test.calls {
+			t.Errorf("request %q: %d limiter calls, want %d", test.idempotencyKey, calls, test.calls)
+		}
+	}
+
+	// Allowed requests are decided on their own.
+	allowed = true
+	send("order-3")
+	send("order-3")
+	if calls != 6 {
+		t.Errorf("%d limiter calls after two allowed duplicates, want 6", calls)
+	}
+}
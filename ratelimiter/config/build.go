@@ -0,0 +1,442 @@
+package config
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// BackendFactory creates a limiter storing its state in the backend b, whose
+// keys are limited by algorithms created by newAlgorithm.
+type BackendFactory func(b Backend, newAlgorithm func() ratelimiter.Algorithm) (ratelimiter.Limiter, error)
+
+// KeyFuncFactory creates the function extracting the keys of requests for the
+// key "<name>:<arg>" of a rule, arg being empty for the key "<name>".
+type KeyFuncFactory func(arg string) (ratelimiter.KeyFunc, error)
+
+// Option configures the building of a configuration.
+type Option func(*builder)
+
+// WithBackend registers the factory of the backends of type typ.
+func WithBackend(typ string, factory BackendFactory) Option {
+	return func(b *builder) {
+		b.backends[typ] = factory
+	}
+}
+
+// WithKeyFunc registers the factory of the key "<name>" or "<name>:<arg>" of
+// the rules, e.g. "tenant" or "cookie:session".
+func WithKeyFunc(name string, factory KeyFuncFactory) Option {
+	return func(b *builder) {
+		b.keyFuncs[name] = factory
+	}
+}
+
+// WithMiddlewareOptions adds options to the middleware of every rule, e.g.
+// the headers or the response of the denied requests.
+func WithMiddlewareOptions(opts ...ratelimiter.Option) Option {
+	return func(b *builder) {
+		b.middlewareOpts = append(b.middlewareOpts, opts...)
+	}
+}
+
+// WithPruneInterval sets how often the graph forgets the keys of its
+// in-memory limiters whose budget is replenished, ratelimiter.DefaultPruneInterval
+// by default, zero to never prune them.
+func WithPruneInterval(interval time.Duration) Option {
+	return func(b *builder) {
+		b.pruneInterval = interval
+	}
+}
+
+// reuse reuses the limiters of previous whose definition is unchanged, so they
+// keep their state across reloads.
+func reuse(previous *Graph) Option {
+	return func(b *builder) {
+		b.previous = previous
+	}
+}
+
+type builder struct {
+	backends       map[string]BackendFactory // The factories of the backends, by type.
+	keyFuncs       map[string]KeyFuncFactory // The factories of the key functions, by name.
+	middlewareOpts []ratelimiter.Option      // The options of the middleware of every rule.
+	pruneInterval  time.Duration             // The interval of the pruning of the in-memory limiters, zero for none.
+	previous       *Graph                    // The graph whose unchanged limiters are reused, if any.
+}
+
+// Graph holds the limiters built from a configuration, and routes requests to
+// the middleware of their rule.
+type Graph struct {
+	limiters    map[string]ratelimiter.Limiter // Map to hold the limiters, by name.
+	definitions map[string]limiterDefinition   // Map to hold the definitions of the limiters, by name.
+	mode        Mode                           // How the matching rules apply.
+	rules       []builtRule                    // The rules tried in order.
+	fallback    *builtRule                     // The default rule, nil if none.
+	skip        []ratelimiter.Matcher          // The matchers of the requests never limited.
+	keyed       map[string]*ratelimiter.Keyed  // Map to hold the in-memory limiters pruned periodically, by name.
+	stopPruning context.CancelFunc             // The function stopping the pruning of the limiters.
+}
+
+// limiterDefinition is the definition a limiter was built from.
+type limiterDefinition struct {
+	limiter Limiter // The definition of the limiter.
+	backend Backend // The definition of its backend, if any.
+}
+
+// equal reports whether d and other define the same limiter.
+func (d limiterDefinition) equal(other limiterDefinition) bool {
+	return d.limiter == other.limiter &&
+		d.backend.Name == other.backend.Name &&
+		d.backend.Type == other.backend.Type &&
+		maps.Equal(d.backend.Options, other.backend.Options)
+}
+
+// builtRule is a rule with its limiter and key function.
+type builtRule struct {
+	Rule
+	limiter ratelimiter.Limiter  // The limiter applied to the matching requests.
+	keyFunc ratelimiter.KeyFunc  // The function extracting the keys of the requests.
+	cost    ratelimiter.CostFunc // The function computing the cost of the requests, nil for one unit.
+	match   ratelimiter.Matcher  // The matcher of the CEL expression of the rule, nil if none.
+	options []ratelimiter.Option // The options of the middleware of the rule.
+}
+
+// Build creates the limiters of the configuration and the middleware of its
+// rules.
+func (c *Config) Build(opts ...Option) (*Graph, error) {
+	b := &builder{
+		backends:      make(map[string]BackendFactory),
+		keyFuncs:      make(map[string]KeyFuncFactory),
+		pruneInterval: ratelimiter.DefaultPruneInterval,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	backends := make(map[string]Backend, len(c.Backends))
+	for _, backend := range c.Backends {
+		if _, ok := b.backends[backend.Type]; !ok {
+			return nil, fmt.Errorf("backend %s: unknown type %q", backend.Name, backend.Type)
+		}
+		backends[backend.Name] = backend
+	}
+
+	g := &Graph{
+		mode:        c.Mode,
+		limiters:    make(map[string]ratelimiter.Limiter, len(c.Limiters)),
+		definitions: make(map[string]limiterDefinition, len(c.Limiters)),
+		keyed:       make(map[string]*ratelimiter.Keyed),
+		stopPruning: func() {},
+	}
+	switch g.mode {
+	case "":
+		g.mode = FirstMatch
+	case FirstMatch, AllMatch:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", c.Mode)
+	}
+	for _, l := range c.Limiters {
+		if _, ok := g.limiters[l.Name]; ok {
+			return nil, fmt.Errorf("limiter %s: defined twice", l.Name)
+		}
+		l, ok := l.resolved()
+		if !ok {
+			return nil, fmt.Errorf("limiter %s: unknown preset %q", l.Name, l.Preset)
+		}
+		definition := limiterDefinition{limiter: l, backend: backends[l.Backend]}
+		if b.previous != nil {
+			if previous, ok := b.previous.definitions[l.Name]; ok && previous.equal(definition) {
+				g.limiters[l.Name] = b.previous.limiters[l.Name]
+				g.definitions[l.Name] = definition
+				if keyed, ok := b.previous.keyed[l.Name]; ok {
+					g.keyed[l.Name] = keyed
+				}
+				continue
+			}
+		}
+		limiter, keyed, err := b.limiter(l, backends)
+		if err != nil {
+			return nil, fmt.Errorf("limiter %s: %w", l.Name, err)
+		}
+		g.limiters[l.Name] = limiter
+		if keyed != nil {
+			g.keyed[l.Name] = keyed
+		}
+		g.definitions[l.Name] = definition
+	}
+
+	clientIP, err := b.keyFunc("ip", c)
+	if err != nil {
+		return nil, fmt.Errorf("skip: %w", err)
+	}
+	skip, err := c.Skip.matchers(clientIP)
+	if err != nil {
+		return nil, fmt.Errorf("skip: %w", err)
+	}
+	g.skip = skip
+	options := append([]ratelimiter.Option{ratelimiter.WithSkip(skip...)}, b.middlewareOpts...)
+
+	for i, rule := range c.Rules {
+		rule.Name = ruleName(rule, i)
+		built, err := b.rule(rule, g.limiters, c, options)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		g.rules = append(g.rules, built)
+	}
+	slices.SortStableFunc(g.rules, func(a, b builtRule) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+	if c.Default != nil {
+		rule := *c.Default
+		if rule.Name == "" {
+			rule.Name = "default"
+		}
+		built, err := b.rule(rule, g.limiters, c, options)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		g.fallback = &built
+	}
+
+	if b.pruneInterval > 0 && len(g.keyed) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		go ratelimiter.PruneEvery(ctx, b.pruneInterval, slices.Collect(maps.Values(g.keyed))...)
+		g.stopPruning = cancel
+	}
+	return g, nil
+}
+
+// Close stops the pruning of the in-memory limiters of the graph. The graph
+// keeps limiting requests, but the memory of its limiters grows with their
+// keys.
+func (g *Graph) Close() {
+	g.stopPruning()
+}
+
+// Limiter returns the limiter named name.
+func (g *Graph) Limiter(name string) (ratelimiter.Limiter, bool) {
+	limiter, ok := g.limiters[name]
+	return limiter, ok
+}
+
+// Limiters returns the limiters of the configuration, by name.
+func (g *Graph) Limiters() map[string]ratelimiter.Limiter {
+	return g.limiters
+}
+
+// HealthCheckers returns the health checkers of the limiters of the
+// configuration storing their state in a backend, by name, for
+// ratelimiter.ReadinessHandler. Their errors are prefixed with the name of the
+// limiter.
+func (g *Graph) HealthCheckers() []ratelimiter.HealthChecker {
+	var checkers []ratelimiter.HealthChecker
+	for _, name := range slices.Sorted(maps.Keys(g.limiters)) {
+		checker, ok := g.limiters[name].(ratelimiter.HealthChecker)
+		if ok && g.definitions[name].backend.Name != "" {
+			checkers = append(checkers, namedChecker{name: name, checker: checker})
+		}
+	}
+	return checkers
+}
+
+// namedChecker is the health checker of the limiter named name.
+type namedChecker struct {
+	name    string                    // The name of the limiter.
+	checker ratelimiter.HealthChecker // The health checker of the limiter.
+}
+
+func (c namedChecker) Healthy() error {
+	if err := c.checker.Healthy(); err != nil {
+		return fmt.Errorf("limiter %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// Skipped reports whether r is one of the requests the configuration never
+// limits, which the handlers of its rules let through.
+func (g *Graph) Skipped(r *http.Request) bool {
+	for _, skip := range g.skip {
+		if skip(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry returns a new registry of the limiters of the configuration, by
+// name, for the code applying them outside of the rules, e.g. to background
+// jobs.
+func (g *Graph) Registry() *ratelimiter.Registry {
+	return ratelimiter.NewRegistry(g.limiters)
+}
+
+// Handler returns next limited by the rules of the configuration: every request
+// is limited by the first rule matching it, or by every one in all-match mode,
+// or else by the default rule. Requests matching no rule are passed to next as
+// is.
+func (g *Graph) Handler(next http.Handler) http.Handler {
+	handlers := make([]http.Handler, len(g.rules))
+	// serve passes r to the handler of the first rule from the i-th matching
+	// it, or to next.
+	serve := func(w http.ResponseWriter, r *http.Request, i int) {
+		for ; i < len(g.rules); i++ {
+			if g.rules[i].matches(r) {
+				handlers[i].ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	for i := range g.rules {
+		if g.mode == AllMatch {
+			// The requests allowed by the rule go on to the next matching one.
+			handlers[i] = g.rules[i].handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				serve(w, r, i+1)
+			}))
+		} else {
+			handlers[i] = g.rules[i].handler(next)
+		}
+	}
+	fallback := next
+	if g.fallback != nil {
+		fallback = g.fallback.handler(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := range g.rules {
+			if g.rules[i].matches(r) {
+				handlers[i].ServeHTTP(w, r)
+				return
+			}
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// limiter creates the limiter l, storing its state in one of backends, and
+// returns the in-memory limiter to prune, if any.
+func (b *builder) limiter(l Limiter, backends map[string]Backend) (ratelimiter.Limiter, *ratelimiter.Keyed, error) {
+	newAlgorithm, err := algorithmFactory(l)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var limiter ratelimiter.Limiter
+	if l.Backend == "" {
+		limiter = ratelimiter.NewKeyed(newAlgorithm)
+	} else {
+		backend, ok := backends[l.Backend]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown backend %q", l.Backend)
+		}
+		if limiter, err = b.backends[backend.Type](backend, newAlgorithm); err != nil {
+			return nil, nil, fmt.Errorf("backend %s: %w", backend.Name, err)
+		}
+	}
+	keyed, _ := limiter.(*ratelimiter.Keyed)
+	if l.Shadow {
+		limiter = ratelimiter.NewShadow(limiter, true, nil)
+	}
+	return limiter, keyed, nil
+}
+
+// algorithmFactory returns the function creating the algorithm instances of l.
+func algorithmFactory(l Limiter) (func() ratelimiter.Algorithm, error) {
+	if l.Algorithm == ratelimiter.CalendarWindowAlgorithm {
+		period, location, err := l.calendar()
+		if err != nil {
+			return nil, err
+		}
+		return ratelimiter.CalendarWindowFactory(l.Rate, period, location)
+	}
+	if l.Algorithm == ratelimiter.TokenBucketAlgorithm && l.Burst > 0 {
+		rate, window, burst := l.Rate, time.Duration(l.Window), l.Burst
+		return func() ratelimiter.Algorithm { return ratelimiter.NewTokenBucket(rate, window, burst) }, nil
+	}
+	return ratelimiter.AlgorithmFactory(l.Algorithm, l.Rate, time.Duration(l.Window))
+}
+
+// rule resolves the limiter, key function and expressions of rule.
+func (b *builder) rule(rule Rule, limiters map[string]ratelimiter.Limiter, c *Config, options []ratelimiter.Option) (builtRule, error) {
+	limiter, ok := limiters[rule.Limiter]
+	if !ok {
+		return builtRule{}, fmt.Errorf("unknown limiter %q", rule.Limiter)
+	}
+	keyFunc, err := b.keyFunc(rule.Key, c)
+	if err != nil {
+		return builtRule{}, err
+	}
+	built := builtRule{Rule: rule, limiter: limiter, keyFunc: keyFunc, options: options}
+	if rule.Match != "" {
+		if built.match, err = celMatcher(rule.Match); err != nil {
+			return builtRule{}, fmt.Errorf("match: %w", err)
+		}
+	}
+	if rule.Cost != "" {
+		if built.cost, err = celCostFunc(rule.Cost); err != nil {
+			return builtRule{}, fmt.Errorf("cost: %w", err)
+		}
+		built.options = append(options[:len(options):len(options)], ratelimiter.WithCostFunc(built.cost))
+	}
+	return built, nil
+}
+
+// keyFunc returns the function extracting the keys of requests for key, the
+// client addresses being resolved behind the trusted proxies of c.
+func (b *builder) keyFunc(key string, c *Config) (ratelimiter.KeyFunc, error) {
+	name, arg, _ := strings.Cut(key, ":")
+	if factory, ok := b.keyFuncs[name]; ok {
+		return factory(arg)
+	}
+	switch {
+	case key == "" || key == "ip":
+		return ratelimiter.KeyByClientIP(c.TrustedProxies, ratelimiter.WithForwardingHeader(c.ForwardingHeader))
+	case key == "global":
+		return func(r *http.Request) string { return "" }, nil
+	case name == "header" && arg != "":
+		return ratelimiter.KeyByHeader(arg), nil
+	default:
+		return nil, fmt.Errorf("unknown key %q", key)
+	}
+}
+
+// matches reports whether the rule applies to r.
+func (rule *builtRule) matches(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, rule.Path) && (rule.Method == "" || rule.Method == r.Method) &&
+		(rule.match == nil || rule.match(r))
+}
+
+// handler returns next limited by the rule.
+func (rule *builtRule) handler(next http.Handler) http.Handler {
+	opts := append([]ratelimiter.Option{ratelimiter.WithKeyFunc(rule.keyFunc)}, rule.options...)
+	return ratelimiter.Middleware(rule.limiter, opts...)(next)
+}
+
+// matchers returns the matchers of the skipped requests, the clients of the
+// subnets being those clientIP returns as the ip key does.
+func (skip *Skip) matchers(clientIP ratelimiter.KeyFunc) ([]ratelimiter.Matcher, error) {
+	var matchers []ratelimiter.Matcher
+	if len(skip.Methods) > 0 {
+		matchers = append(matchers, ratelimiter.MatchMethods(skip.Methods...))
+	}
+	if len(skip.Paths) > 0 {
+		matchers = append(matchers, ratelimiter.MatchPaths(skip.Paths...))
+	}
+	if len(skip.Subnets) > 0 {
+		matcher, err := ratelimiter.MatchClientSubnets(clientIP, skip.Subnets...)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
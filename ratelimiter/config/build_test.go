@@ -0,0 +1,376 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// mustParse parses the configuration data, failing the test on errors.
+func mustParse(t *testing.T, data string) *Config {
+	t.Helper()
+	cfg, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// keyed returns the in-memory limiter named name of g.
+func keyed(t *testing.T, g *Graph, name string) *ratelimiter.Keyed {
+	t.Helper()
+	limiter, ok := g.Limiter(name)
+	if !ok {
+		t.Fatalf("no limiter %s", name)
+	}
+	k, ok := limiter.(*ratelimiter.Keyed)
+	if !ok {
+		t.Fatalf("limiter %s is a %T, want a *ratelimiter.Keyed", name, limiter)
+	}
+	return k
+}
+
+const pruneConfig = `
+limiters:
+  - name: api
+    algorithm: sliding-window
+    rate: 5
+    window: 20ms
+default:
+  limiter: api
+`
+
+func TestGraphPrunes(t *testing.T) {
+	g, err := mustParse(t, pruneConfig).Build(WithPruneInterval(10 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	limiter := keyed(t, g, "api")
+	for _, key := range []string{"a", "b", "c"} {
+		limiter.Allow(key, time.Now())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for limiter.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d keys left, want the keys pruned", limiter.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGraphCloseStopsPruning(t *testing.T) {
+	g, err := mustParse(t, pruneConfig).Build(WithPruneInterval(10 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+
+	limiter := keyed(t, g, "api")
+	limiter.Allow("a", time.Now())
+	time.Sleep(100 * time.Millisecond)
+	if limiter.Len() != 1 {
+		t.Errorf("%d keys left after Close, want the key kept", limiter.Len())
+	}
+}
+
+func TestGraphWithoutPruning(t *testing.T) {
+	g, err := mustParse(t, pruneConfig).Build(WithPruneInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	limiter := keyed(t, g, "api")
+	limiter.Allow("a", time.Now())
+	time.Sleep(50 * time.Millisecond)
+	if limiter.Len() != 1 {
+		t.Errorf("%d keys left, want the key kept without pruning", limiter.Len())
+	}
+}
+
+const skipConfig = `
+trustedProxies: [10.0.0.0/8]
+forwardingHeader: X-Forwarded-For
+limiters:
+  - name: strict
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+skip:
+  subnets: [192.168.0.0/16]
+default:
+  limiter: strict
+`
+
+func TestSkipSubnetsBehindProxies(t *testing.T) {
+	g, err := mustParse(t, skipConfig).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	handler := g.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		skipped    bool
+	}{
+		{"internal client behind the proxy", "10.0.0.1:1234", "192.168.1.1", true},
+		{"external client behind the proxy", "10.0.0.1:1234", "203.0.113.1", false},
+		{"internal address forged by an untrusted peer", "203.0.113.2:1234", "192.168.1.1", false},
+	}
+	for _, tt := range tests {
+		statuses := make([]int, 2)
+		for i := range statuses {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			statuses[i] = w.Code
+		}
+		if skipped := statuses[1] == http.StatusOK; skipped != tt.skipped {
+			t.Errorf("%s: statuses %v, want skipped %t", tt.name, statuses, tt.skipped)
+		}
+	}
+}
+
+// send sends a request for path with method and headers to handler, and returns
+// the status of the response.
+func send(handler http.Handler, method, path string, header http.Header) int {
+	r := httptest.NewRequest(method, path, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+// handler returns the handler of the graph built from the configuration data.
+func handler(t *testing.T, data string, opts ...Option) http.Handler {
+	t.Helper()
+	g, err := mustParse(t, data).Build(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(g.Close)
+	return g.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+}
+
+const rulesConfig = `
+limiters:
+  - name: login
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+  - name: api
+    algorithm: token-bucket
+    rate: 2
+    window: 1h
+    burst: 2
+  - name: trial
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+    shadow: true
+rules:
+  - path: /auth/login
+    method: POST
+    limiter: login
+  - path: /api/
+    limiter: api
+    key: header:X-API-Key
+  - path: /trial/
+    limiter: trial
+    key: global
+`
+
+func TestGraphRules(t *testing.T) {
+	h := handler(t, rulesConfig)
+	keyA, keyB := http.Header{"X-Api-Key": {"a"}}, http.Header{"X-Api-Key": {"b"}}
+	for i, test := range []struct {
+		method, path string
+		header       http.Header
+		status       int
+	}{
+		{http.MethodPost, "/auth/login", nil, http.StatusOK},
+		{http.MethodPost, "/auth/login", nil, http.StatusTooManyRequests},
+		{http.MethodGet, "/auth/login", nil, http.StatusOK}, // Matches no rule.
+		{http.MethodGet, "/api/orders", keyA, http.StatusOK},
+		{http.MethodGet, "/api/users", keyA, http.StatusOK},
+		{http.MethodGet, "/api/orders", keyA, http.StatusTooManyRequests},
+		{http.MethodGet, "/api/orders", keyB, http.StatusOK},
+		{http.MethodGet, "/trial/1", nil, http.StatusOK},
+		{http.MethodGet, "/trial/2", nil, http.StatusOK}, // Shadow limiters never deny.
+	} {
+		if status := send(h, test.method, test.path, test.header); status != test.status {
+			t.Errorf("request %d: %s %s: status %d, want %d", i, test.method, test.path, status, test.status)
+		}
+	}
+}
+
+func TestGraphMiddlewareOptions(t *testing.T) {
+	var decided []string
+	h := handler(t, rulesConfig, WithMiddlewareOptions(ratelimiter.WithOnDecision(func(r *http.Request, key string, decision ratelimiter.Decision) {
+		decided = append(decided, r.URL.Path)
+	})))
+	send(h, http.MethodPost, "/auth/login", nil)
+	send(h, http.MethodGet, "/api/orders", http.Header{"X-Api-Key": {"a"}})
+	send(h, http.MethodGet, "/auth/login", nil)
+
+	// The options apply to the middleware of every rule.
+	if len(decided) != 2 || decided[0] != "/auth/login" || decided[1] != "/api/orders" {
+		t.Errorf("decisions of %q, want those of the login and api rules", decided)
+	}
+}
+
+// healthLimiter is an in-memory limiter standing for one stored in a backend
+// failing with err.
+type healthLimiter struct {
+	*ratelimiter.Keyed
+	err error // The error of the backend, nil while healthy.
+}
+
+func (l *healthLimiter) Healthy() error { return l.err }
+
+const healthConfig = `
+backends:
+  - name: redis
+    type: fake
+limiters:
+  - name: remote
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+    backend: redis
+    shadow: true
+  - name: local
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+`
+
+func TestGraphHealthCheckers(t *testing.T) {
+	remote := &healthLimiter{}
+	fake := func(b Backend, newAlgorithm func() ratelimiter.Algorithm) (ratelimiter.Limiter, error) {
+		remote.Keyed = ratelimiter.NewKeyed(newAlgorithm)
+		return remote, nil
+	}
+	g, err := mustParse(t, healthConfig).Build(WithBackend("fake", fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	// Only the limiter stored in the backend is checked, through its shadow.
+	checkers := g.HealthCheckers()
+	if len(checkers) != 1 {
+		t.Fatalf("%d health checkers, want the one of the remote limiter", len(checkers))
+	}
+	probe := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ratelimiter.ReadinessHandler(checkers...).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w
+	}
+	if w := probe(); w.Code != http.StatusOK {
+		t.Errorf("healthy backend: status %d, want %d", w.Code, http.StatusOK)
+	}
+	remote.err = errors.New("connection refused")
+	if w := probe(); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "limiter remote: connection refused") {
+		t.Errorf("failing backend: status %d, body %q, want %d naming the limiter", w.Code, w.Body, http.StatusServiceUnavailable)
+	}
+}
+
+func TestGraphBackendsAndKeys(t *testing.T) {
+	var options map[string]string
+	backend := func(b Backend, newAlgorithm func() ratelimiter.Algorithm) (ratelimiter.Limiter, error) {
+		options = b.Options
+		return ratelimiter.NewKeyed(newAlgorithm), nil
+	}
+	tenant := func(arg string) (ratelimiter.KeyFunc, error) {
+		return func(r *http.Request) string { return r.URL.Query().Get(arg) }, nil
+	}
+	h := handler(t, `
+backends:
+  - name: shared
+    type: memory
+    options: {address: "localhost:6379"}
+limiters:
+  - name: api
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+    backend: shared
+default:
+  limiter: api
+  key: tenant:org
+`, WithBackend("memory", backend), WithKeyFunc("tenant", tenant))
+
+	if options["address"] != "localhost:6379" {
+		t.Errorf("backend options %v, want the address", options)
+	}
+	for i, test := range []struct {
+		path   string
+		status int
+	}{
+		{"/?org=acme", http.StatusOK},
+		{"/?org=acme", http.StatusTooManyRequests},
+		{"/?org=globex", http.StatusOK},
+	} {
+		if status := send(h, http.MethodGet, test.path, nil); status != test.status {
+			t.Errorf("request %d: %s: status %d, want %d", i, test.path, status, test.status)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown backend type": "backends: [{name: shared, type: redis}]",
+		"unknown backend":      "limiters: [{name: api, algorithm: sliding-window, rate: 1, window: 1m, backend: shared}]",
+		"unknown limiter":      "rules: [{path: /, limiter: api}]",
+		"unknown key":          "limiters: [{name: api, algorithm: sliding-window, rate: 1, window: 1m}]\nrules: [{path: /, limiter: api, key: cookie}]",
+		"duplicate limiter":    "limiters: [{name: api, algorithm: sliding-window, rate: 1, window: 1m}, {name: api, algorithm: sliding-window, rate: 2, window: 1m}]",
+		"unknown algorithm":    "limiters: [{name: api, algorithm: fixed-window, rate: 1, window: 1m}]",
+	} {
+		cfg, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if g, err := cfg.Build(); err == nil {
+			g.Close()
+			t.Errorf("%s: built, want an error", name)
+		}
+	}
+}
+
+func TestGraphRegistry(t *testing.T) {
+	g, err := mustParse(t, rulesConfig).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	registry := g.Registry()
+	for name, limiter := range g.Limiters() {
+		if got, ok := registry.Limiter(name); !ok || got != limiter {
+			t.Errorf("registry limiter %s: %v, want that of the graph", name, got)
+		}
+	}
+	// The registry holds a copy of the limiters.
+	registry.Register("exports", ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(1, time.Hour) }))
+	if _, ok := g.Limiter("exports"); ok {
+		t.Error("limiter registered in the graph")
+	}
+}
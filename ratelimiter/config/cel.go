@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// celEnv returns the environment of the CEL expressions of the rules, whose
+// only variable is the request:
+//
+//	request.method         string
+//	request.path           string
+//	request.host           string
+//	request.ip             string, the address of the remote peer
+//	request.contentLength  int, -1 if unknown
+//	request.headers        map(string, string), keyed by lowercase name
+//	request.query          map(string, string)
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)))
+})
+
+// celProgram compiles expr, whose result must be of type want, or only known
+// at evaluation, e.g. a field of the request.
+func celProgram(expr string, want *cel.Type) (cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if out := ast.OutputType(); !out.IsExactType(want) && !out.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression of type %s, want %s", ast.OutputType(), want)
+	}
+	return env.Program(ast)
+}
+
+// celMatcher compiles a boolean expression matching requests, e.g.
+// "request.path.startsWith('/admin') && request.method == 'POST'". Requests
+// failing the evaluation, e.g. missing a header, do not match.
+func celMatcher(expr string) (ratelimiter.Matcher, error) {
+	program, err := celProgram(expr, cel.BoolType)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *http.Request) bool {
+		out, _, err := program.Eval(celActivation(r))
+		if err != nil {
+			return false
+		}
+		matches, _ := out.Value().(bool)
+		return matches
+	}, nil
+}
+
+// celCostFunc compiles an integer expression computing the cost of requests,
+// e.g. "request.method == 'POST' ? 10 : 1". Requests failing the evaluation, or
+// not evaluating to an integer, cost one unit, and negative costs are clamped
+// to zero.
+func celCostFunc(expr string) (ratelimiter.CostFunc, error) {
+	program, err := celProgram(expr, cel.IntType)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *http.Request) int {
+		out, _, err := program.Eval(celActivation(r))
+		if err != nil {
+			return 1
+		}
+		cost, ok := out.Value().(int64)
+		if !ok {
+			return 1
+		}
+		return int(max(cost, 0))
+	}, nil
+}
+
+// celActivation returns the variables of the expressions evaluated for r.
+func celActivation(r *http.Request) map[string]any {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	query := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		query[name] = values[0]
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return map[string]any{
+		"request": map[string]any{
+			"method":        r.Method,
+			"path":          r.URL.Path,
+			"host":          r.Host,
+			"ip":            ip,
+			"contentLength": r.ContentLength,
+			"headers":       headers,
+			"query":         query,
+		},
+	}
+}
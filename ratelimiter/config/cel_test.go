@@ -0,0 +1,97 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// celRequest returns a POST request to /admin/users of a tenant, with a body
+// of 2 KiB.
+func celRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/admin/users?dry=true", strings.NewReader(strings.Repeat("x", 2048)))
+	r.Header.Set("X-Tenant", "acme")
+	return r
+}
+
+func TestCelMatcher(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		matches bool
+	}{
+		{"request.method == 'POST' && request.path.startsWith('/admin/')", true},
+		{"request.headers['x-tenant'] == 'acme'", true},
+		{"request.query['dry'] == 'true' && request.ip == '192.0.2.1'", true},
+		{"request.host == 'example.com' && request.contentLength > 1024", true},
+		{"request.method == 'GET'", false},
+		{"request.headers['authorization'] == 'secret'", false}, // Missing headers fail the evaluation.
+	} {
+		match, err := celMatcher(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if matches := match(celRequest()); matches != test.matches {
+			t.Errorf("%s: matches %t, want %t", test.expr, matches, test.matches)
+		}
+	}
+}
+
+func TestCelCostFunc(t *testing.T) {
+	for _, test := range []struct {
+		expr string
+		cost int
+	}{
+		{"request.contentLength > 1024 ? 10 : 1", 10},
+		{"request.contentLength / 1024", 2},
+		{"-5", 0},
+		{"request.headers['x-cost']", 1}, // Failed evaluations cost one unit.
+		{"request.path", 1},              // So do results of another type.
+	} {
+		cost, err := celCostFunc(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if got := cost(celRequest()); got != test.cost {
+			t.Errorf("%s: cost %d, want %d", test.expr, got, test.cost)
+		}
+	}
+}
+
+func TestCelCompileErrors(t *testing.T) {
+	for _, expr := range []string{"request.method ==", "'POST'", "unknown == 1"} {
+		if _, err := celMatcher(expr); err == nil {
+			t.Errorf("matcher %s compiled, want an error", expr)
+		}
+	}
+	if _, err := celCostFunc("true"); err == nil {
+		t.Error("boolean cost compiled, want an error")
+	}
+}
+
+func TestGraphCelRules(t *testing.T) {
+	h := handler(t, `
+limiters:
+  - name: admin
+    algorithm: sliding-window
+    rate: 10
+    window: 1h
+rules:
+  - path: /admin/
+    match: request.method == 'POST'
+    cost: "request.contentLength > 1024 ? 10 : 1"
+    limiter: admin
+`)
+	if status := send(h, http.MethodGet, "/admin/users", nil); status != http.StatusOK {
+		t.Errorf("GET: status %d, want %d", status, http.StatusOK)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, celRequest())
+		if w.Code != want {
+			t.Errorf("large POST %d: status %d, want %d", i, w.Code, want)
+		}
+	}
+}
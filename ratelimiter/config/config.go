@@ -0,0 +1,292 @@
+// Package config defines limiters, rules, key extractors and backends
+// declaratively in YAML or JSON, and builds the limiters and the middleware
+// enforcing them, so policy lives in configuration repositories rather than
+// code.
+//
+//	cfg, err := config.Load("ratelimit.yaml")
+//	graph, err := cfg.Build()
+//	handler = graph.Handler(handler)
+//
+// A configuration names its limiters, and its rules apply them to the
+// requests matching their path prefix and method, by decreasing priority and
+// first match wins unless the mode is all-match:
+//
+//	trustedProxies: [10.0.0.0/8]
+//	forwardingHeader: X-Forwarded-For
+//	limiters:
+//	  - name: login
+//	    algorithm: sliding-window
+//	    rate: 5
+//	    window: 1m
+//	  - name: api
+//	    algorithm: token-bucket
+//	    rate: 1000
+//	    window: 1h
+//	    burst: 50
+//	rules:
+//	  - name: login
+//	    path: /auth/login
+//	    method: POST
+//	    limiter: login
+//	    key: ip
+//	  - name: api
+//	    path: /api/
+//	    limiter: api
+//	    key: header:X-API-Key
+//
+// Rules may further match requests, and compute their cost, with CEL
+// expressions on the request variable, see https://cel.dev:
+//
+//	rules:
+//	  - name: admin-writes
+//	    path: /admin/
+//	    match: request.method == 'POST' && request.headers['x-tenant'] != 'internal'
+//	    cost: "request.contentLength > 1048576 ? 10 : 1"
+//	    limiter: admin
+//
+// The request has the fields method, path, host, ip, contentLength, and headers
+// and query, maps keyed by lowercase header name and by parameter name.
+//
+// Limiters may start from a preset of the ratelimiter package, such as
+// login-strict, overriding some of its fields:
+//
+//	limiters:
+//	  - name: login
+//	    preset: login-strict
+//	  - name: api
+//	    preset: api-default
+//	    rate: 1200
+//
+// Calendar windows count the requests per period of the calendar of their
+// time zone, following its daylight saving time transitions, such as quotas
+// reset at local midnight:
+//
+//	limiters:
+//	  - name: daily
+//	    algorithm: calendar-window
+//	    rate: 10000
+//	    period: day
+//	    timezone: America/New_York
+//
+// Limiters keep their state in memory unless they name a backend of a type
+// registered with WithBackend.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration read from a string such as "1m".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration from a JSON string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// UnmarshalYAML parses a duration from a YAML string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// String formats the duration like time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON formats the duration as a JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// MarshalYAML formats the duration as a YAML string.
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) parse(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config is the declarative definition of the limiters of a service.
+type Config struct {
+	TrustedProxies   []string  `json:"trustedProxies" yaml:"trustedProxies"`     // CIDRs of the proxies whose forwarding headers are believed by the "ip" key.
+	ForwardingHeader string    `json:"forwardingHeader" yaml:"forwardingHeader"` // Header the trusted proxies write the client address to, the only one believed, see ratelimiter.WithForwardingHeader.
+	Backends         []Backend `json:"backends" yaml:"backends"`                 // Backends storing the state of the limiters out of process.
+	Limiters         []Limiter `json:"limiters" yaml:"limiters"`                 // Limiters applied by the rules.
+	Skip             Skip      `json:"skip" yaml:"skip"`                         // Requests never limited by any rule.
+	Mode             Mode      `json:"mode" yaml:"mode"`                         // How the matching rules apply, first-match by default.
+	Rules            []Rule    `json:"rules" yaml:"rules"`                       // Rules tried by decreasing priority, then in order.
+	Default          *Rule     `json:"default" yaml:"default"`                   // Rule applied to the requests matching no other rule, if any.
+
+	source string     // The path of the file the configuration was loaded from, if any.
+	node   *yaml.Node // The document the configuration was parsed from, locating its fields.
+}
+
+// Backend is a store of limiter state, such as a Redis cluster.
+type Backend struct {
+	Name    string            `json:"name" yaml:"name"`       // Name of the backend in the limiters.
+	Type    string            `json:"type" yaml:"type"`       // Type of the backend, registered with WithBackend.
+	Options map[string]string `json:"options" yaml:"options"` // Options of the backend, such as its address.
+}
+
+// Limiter is a named limiter, possibly shared by several rules.
+type Limiter struct {
+	Name      string   `json:"name" yaml:"name"`           // Name of the limiter in the rules and metrics.
+	Preset    string   `json:"preset" yaml:"preset"`       // Name of the preset giving the fields left unset, see ratelimiter.Presets.
+	Algorithm string   `json:"algorithm" yaml:"algorithm"` // Name of the algorithm, see ratelimiter.Algorithms.
+	Rate      int      `json:"rate" yaml:"rate"`           // Maximum number of requests allowed in the window.
+	Window    Duration `json:"window" yaml:"window"`       // Duration of the window.
+	Burst     int      `json:"burst" yaml:"burst"`         // Size of the bursts of token buckets, defaulting to the rate.
+	Period    string   `json:"period" yaml:"period"`       // Calendar period of calendar windows, see ratelimiter.Periods, defaulting to that of the window.
+	Timezone  string   `json:"timezone" yaml:"timezone"`   // IANA time zone of the calendar of calendar windows, e.g. "Europe/Paris", defaulting to UTC.
+	Backend   string   `json:"backend" yaml:"backend"`     // Name of the backend storing the state, empty for memory.
+	Shadow    bool     `json:"shadow" yaml:"shadow"`       // Whether to only record denials without enforcing them.
+}
+
+// resolved returns the limiter with the fields left unset given by its preset,
+// and false if the preset is unknown.
+func (l Limiter) resolved() (Limiter, bool) {
+	if l.Preset == "" {
+		return l, true
+	}
+	preset, ok := ratelimiter.LookupPreset(l.Preset)
+	if !ok {
+		return l, false
+	}
+	if l.Algorithm == "" {
+		l.Algorithm = preset.Algorithm
+		if l.Burst == 0 && preset.Algorithm == ratelimiter.TokenBucketAlgorithm {
+			l.Burst = preset.Burst
+		}
+	}
+	if l.Rate == 0 {
+		l.Rate = preset.Rate
+	}
+	if l.Window == 0 {
+		l.Window = Duration(preset.Window)
+	}
+	return l, true
+}
+
+// calendar returns the period and the time zone of the calendar of a calendar
+// window.
+func (l Limiter) calendar() (ratelimiter.Period, *time.Location, error) {
+	period, err := l.period()
+	if err != nil {
+		return "", nil, err
+	}
+	location, err := l.location()
+	if err != nil {
+		return "", nil, err
+	}
+	return period, location, nil
+}
+
+// period returns the calendar period of a calendar window.
+func (l Limiter) period() (ratelimiter.Period, error) {
+	if l.Period == "" {
+		period, ok := ratelimiter.PeriodOf(time.Duration(l.Window))
+		if !ok {
+			return "", fmt.Errorf("no calendar period lasts %s, set the period", l.Window)
+		}
+		return period, nil
+	}
+	if !slices.Contains(ratelimiter.Periods, ratelimiter.Period(l.Period)) {
+		return "", fmt.Errorf("unknown period %q", l.Period)
+	}
+	return ratelimiter.Period(l.Period), nil
+}
+
+// location returns the time zone of the calendar of a calendar window.
+func (l Limiter) location() (*time.Location, error) {
+	location, err := time.LoadLocation(l.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", l.Timezone)
+	}
+	return location, nil
+}
+
+// Mode is how the rules matching a request apply.
+type Mode string
+
+const (
+	// FirstMatch applies the first rule matching a request.
+	FirstMatch Mode = "first-match"
+	// AllMatch applies every rule matching a request, which is denied as soon
+	// as one of them denies it.
+	AllMatch Mode = "all-match"
+)
+
+// Rule applies a limiter to the requests matching its path and method.
+type Rule struct {
+	Name     string `json:"name" yaml:"name"`         // Name of the rule.
+	Priority int    `json:"priority" yaml:"priority"` // Priority of the rule, the rules of higher priority are tried first.
+	Path     string `json:"path" yaml:"path"`         // Path prefix of the matching requests, empty matches every path.
+	Method   string `json:"method" yaml:"method"`     // Method of the matching requests, empty matches every method.
+	Limiter  string `json:"limiter" yaml:"limiter"`   // Name of the limiter applied to the matching requests.
+	Key      string `json:"key" yaml:"key"`           // Key of the requests: "ip", "global", "header:<name>" or registered with WithKeyFunc.
+	Match    string `json:"match" yaml:"match"`       // CEL expression the matching requests must also satisfy, empty for none.
+	Cost     string `json:"cost" yaml:"cost"`         // CEL expression computing the cost of the requests, empty for one unit.
+}
+
+// Skip lists the requests that are never limited.
+type Skip struct {
+	Methods []string `json:"methods" yaml:"methods"` // Methods of the skipped requests, e.g. "OPTIONS".
+	Paths   []string `json:"paths" yaml:"paths"`     // Paths of the skipped requests, those ending with a slash match every path below them.
+	Subnets []string `json:"subnets" yaml:"subnets"` // CIDRs of the clients whose requests are skipped, behind the trusted proxies.
+}
+
+// Load reads the configuration file at path, in YAML or JSON.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	cfg.source = path
+	return cfg, nil
+}
+
+// Parse parses a configuration in YAML or JSON, JSON being a subset of YAML.
+// Unknown fields are rejected, so typos do not go unnoticed.
+func Parse(data []byte) (*Config, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	// The document locates the fields in the errors of Validate.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err == nil {
+		cfg.node = &node
+	}
+	return &cfg, nil
+}
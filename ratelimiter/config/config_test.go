@@ -0,0 +1,212 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParse(t *testing.T) {
+	for name, data := range map[string]string{
+		"YAML": `
+limiters:
+  - name: login
+    algorithm: sliding-window
+    rate: 5
+    window: 1m
+rules:
+  - path: /auth/login
+    method: POST
+    limiter: login
+`,
+		"JSON": `{"limiters": [{"name": "login", "algorithm": "sliding-window", "rate": 5, "window": "1m"}],
+"rules": [{"path": "/auth/login", "method": "POST", "limiter": "login"}]}`,
+	} {
+		cfg, err := Parse([]byte(data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(cfg.Limiters) != 1 || cfg.Limiters[0].Window != Duration(time.Minute) || cfg.Limiters[0].Rate != 5 {
+			t.Errorf("%s: limiters %+v, want login", name, cfg.Limiters)
+		}
+		if len(cfg.Rules) != 1 || cfg.Rules[0].Method != "POST" {
+			t.Errorf("%s: rules %+v, want the login rule", name, cfg.Rules)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown field":    "limiters:\n  - name: login\n    rat: 5\n",
+		"invalid duration": "limiters:\n  - name: login\n    window: soon\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: parsed, want an error", name)
+		}
+	}
+	if cfg, err := Parse(nil); err != nil || len(cfg.Limiters) != 0 {
+		t.Errorf("empty config: %+v, error %v, want an empty config", cfg, err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	limiter := Limiter{Name: "api", Window: Duration(90 * time.Second)}
+	data, err := json.Marshal(limiter)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"window":"1m30s"`) {
+		t.Errorf("JSON %s, want the window as a string", data)
+	}
+	var fromJSON Limiter
+	if err := json.Unmarshal(data, &fromJSON); err != nil || fromJSON.Window != limiter.Window {
+		t.Errorf("JSON round trip: window %v, error %v, want %v", fromJSON.Window, err, limiter.Window)
+	}
+
+	data, err = yaml.Marshal(limiter)
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), "window: 1m30s") {
+		t.Errorf("YAML %s, want the window as a string", data)
+	}
+	var fromYAML Limiter
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil || fromYAML.Window != limiter.Window {
+		t.Errorf("YAML round trip: window %v, error %v, want %v", fromYAML.Window, err, limiter.Window)
+	}
+
+	var d Duration
+	if err := json.Unmarshal([]byte("60"), &d); err == nil {
+		t.Error("duration parsed from a JSON number")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.yaml")
+	if err := os.WriteFile(path, []byte("mode: sometimes\nlimiters: [{name: api, rate: x}]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("error %v, want the path of the file", err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("missing file: error %v, want not exist", err)
+	}
+}
+
+func TestLimiterPreset(t *testing.T) {
+	for _, test := range []struct {
+		limiter Limiter
+		want    Limiter
+	}{
+		{
+			Limiter{Name: "login", Preset: "login-strict"},
+			Limiter{Name: "login", Preset: "login-strict", Algorithm: "sliding-window", Rate: 5, Window: Duration(15 * time.Minute)},
+		},
+		{
+			Limiter{Name: "api", Preset: "api-default", Rate: 1200},
+			Limiter{Name: "api", Preset: "api-default", Algorithm: "token-bucket", Rate: 1200, Window: Duration(time.Minute), Burst: 100},
+		},
+		// The burst of the preset only applies to its own algorithm.
+		{
+			Limiter{Name: "api", Preset: "api-default", Algorithm: "leaky-bucket"},
+			Limiter{Name: "api", Preset: "api-default", Algorithm: "leaky-bucket", Rate: 600, Window: Duration(time.Minute)},
+		},
+	} {
+		if got, ok := test.limiter.resolved(); !ok || got != test.want {
+			t.Errorf("%+v resolved to %+v, want %+v", test.limiter, got, test.want)
+		}
+	}
+
+	// The graph applies the limits of the preset.
+	h := handler(t, "limiters: [{name: login, preset: login-strict}]\ndefault: {limiter: login}")
+	for i := 1; i <= 6; i++ {
+		want := http.StatusOK
+		if i == 6 {
+			want = http.StatusTooManyRequests
+		}
+		if status := send(h, http.MethodPost, "/login", nil); status != want {
+			t.Errorf("request %d: status %d, want %d", i, status, want)
+		}
+	}
+
+	cfg, err := Parse([]byte("limiters: [{name: api, preset: missing}]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `limiters[0].preset: unknown preset "missing"`) {
+		t.Errorf("unknown preset: validation error %v", err)
+	}
+	if _, err := cfg.Build(); err == nil || !strings.Contains(err.Error(), "unknown preset") {
+		t.Errorf("unknown preset: build error %v", err)
+	}
+}
+
+func TestLimiterCalendar(t *testing.T) {
+	period, location, err := Limiter{Window: Duration(24 * time.Hour), Timezone: "America/New_York"}.calendar()
+	if err != nil || period != ratelimiter.PeriodDay || location.String() != "America/New_York" {
+		t.Errorf("calendar of a day window: %s in %v, error %v, want day in America/New_York", period, location, err)
+	}
+	// The period wins over the window, and the time zone defaults to UTC.
+	period, location, err = Limiter{Window: Duration(time.Minute), Period: "month"}.calendar()
+	if err != nil || period != ratelimiter.PeriodMonth || location != time.UTC {
+		t.Errorf("calendar of a month period: %s in %v, error %v, want month in UTC", period, location, err)
+	}
+
+	cfg, err := Parse([]byte(`limiters:
+  - {name: a, algorithm: calendar-window, rate: 1, window: 90m}
+  - {name: b, algorithm: calendar-window, rate: 1, period: fortnight, timezone: Mars/Olympus}
+  - {name: c, algorithm: sliding-window, rate: 1, window: 1m, period: day, timezone: UTC}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verr ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("invalid calendars validated")
+	}
+	var got []string
+	for _, err := range verr {
+		got = append(got, err.Field+": "+err.Message)
+	}
+	want := []string{
+		"limiters[0].period: no calendar period lasts 1h30m0s, set the period",
+		`limiters[1].period: unknown period "fortnight"`,
+		`limiters[1].timezone: unknown timezone "Mars/Olympus"`,
+		"limiters[2].period: period only applies to the calendar-window algorithm",
+		"limiters[2].timezone: timezone only applies to the calendar-window algorithm",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("errors %q, want %q", got, want)
+	}
+}
+
+func TestGraphCalendar(t *testing.T) {
+	g, err := mustParse(t, "limiters: [{name: daily, algorithm: calendar-window, rate: 2, period: day, timezone: Asia/Tokyo}]").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	// Midnight in Tokyo is 15:00 UTC.
+	limiter := keyed(t, g, "daily")
+	before := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	for i, want := range []bool{true, true, false} {
+		if decision := limiter.Allow("a", before); decision.Allowed != want {
+			t.Errorf("request %d: allowed %v, want %v", i+1, decision.Allowed, want)
+		}
+	}
+	if decision := limiter.Allow("a", before.Add(time.Hour)); !decision.Allowed || decision.ResetAfter != 24*time.Hour {
+		t.Errorf("decision at midnight in Tokyo %+v, want allowed for the whole day", decision)
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// The environment variables configuring a single limiter applied to every
+// request, for deployments without configuration files.
+const (
+	EnvPreset           = "RATELIMIT_PRESET"            // Name of the preset giving the variables left unset, see ratelimiter.Presets.
+	EnvRate             = "RATELIMIT_RATE"              // Maximum number of requests allowed in the window, required without a preset.
+	EnvWindow           = "RATELIMIT_WINDOW"            // Duration of the window, e.g. "1m", defaulting to a minute.
+	EnvAlgorithm        = "RATELIMIT_ALGORITHM"         // Name of the algorithm, defaulting to "sliding-window".
+	EnvBurst            = "RATELIMIT_BURST"             // Size of the bursts of the token bucket, defaulting to the rate.
+	EnvKey              = "RATELIMIT_KEY"               // Key of the requests, see Rule.Key, defaulting to "ip".
+	EnvRedisURL         = "RATELIMIT_REDIS_URL"         // URL of the Redis server storing the state, empty for memory.
+	EnvTrustedProxies   = "RATELIMIT_TRUSTED_PROXIES"   // Comma-separated CIDRs of the trusted proxies.
+	EnvForwardingHeader = "RATELIMIT_FORWARDING_HEADER" // Header the trusted proxies write the client address to, e.g. "X-Forwarded-For".
+	EnvSkipPaths        = "RATELIMIT_SKIP_PATHS"        // Comma-separated paths of the skipped requests.
+	EnvShadow           = "RATELIMIT_SHADOW"            // Whether to only record denials without enforcing them.
+)
+
+// RedisBackend is the type of the backend configured by RATELIMIT_REDIS_URL,
+// whose "url" option is the URL. Its factory must be registered with
+// WithBackend.
+const RedisBackend = "redis"
+
+// LoadEnv reads the configuration from the environment variables, see
+// ParseEnv.
+func LoadEnv() (*Config, error) {
+	return ParseEnv(os.LookupEnv)
+}
+
+// ParseEnv reads the configuration of a single limiter applied to every
+// request from the variables returned by lookup, such as os.LookupEnv, named
+// by the Env constants:
+//
+//	RATELIMIT_RATE=100 RATELIMIT_WINDOW=1m RATELIMIT_KEY=header:X-API-Key
+//	RATELIMIT_PRESET=public-anonymous
+func ParseEnv(lookup func(key string) (string, bool)) (*Config, error) {
+	get := func(key string) string {
+		value, _ := lookup(key)
+		return strings.TrimSpace(value)
+	}
+
+	l := Limiter{Name: "default", Preset: get(EnvPreset)}
+	if l.Preset == "" {
+		l.Algorithm = ratelimiter.SlidingWindowAlgorithm
+		l.Window = Duration(time.Minute)
+	}
+	var err error
+	if v := get(EnvRate); v != "" {
+		if l.Rate, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvRate, err)
+		}
+	} else if l.Preset == "" {
+		return nil, fmt.Errorf("%s is not set", EnvRate)
+	}
+	if v := get(EnvWindow); v != "" {
+		if err := l.Window.parse(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvWindow, err)
+		}
+	}
+	if v := get(EnvAlgorithm); v != "" {
+		l.Algorithm = v
+	}
+	if v := get(EnvBurst); v != "" {
+		if l.Burst, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvBurst, err)
+		}
+	}
+	if v := get(EnvShadow); v != "" {
+		if l.Shadow, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvShadow, err)
+		}
+	}
+
+	cfg := &Config{
+		TrustedProxies:   splitList(get(EnvTrustedProxies)),
+		ForwardingHeader: get(EnvForwardingHeader),
+		Skip:             Skip{Paths: splitList(get(EnvSkipPaths))},
+	}
+	if v := get(EnvRedisURL); v != "" {
+		cfg.Backends = []Backend{{Name: RedisBackend, Type: RedisBackend, Options: map[string]string{"url": v}}}
+		l.Backend = RedisBackend
+	}
+	cfg.Limiters = []Limiter{l}
+	cfg.Default = &Rule{Name: "default", Limiter: l.Name, Key: get(EnvKey)}
+	return cfg, nil
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, element := range strings.Split(s, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// lookup returns a lookup function of the variables of env.
+func lookup(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	cfg, err := ParseEnv(lookup(map[string]string{
+		EnvRate:           "100",
+		EnvWindow:         "1h",
+		EnvAlgorithm:      "token-bucket",
+		EnvBurst:          "10",
+		EnvKey:            "header:X-API-Key",
+		EnvRedisURL:       "redis://localhost:6379",
+		EnvTrustedProxies: "10.0.0.0/8, ,192.168.0.0/16",
+		EnvSkipPaths:      "/healthz,/metrics",
+		EnvShadow:         "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Limiter{Name: "default", Algorithm: "token-bucket", Rate: 100, Window: Duration(time.Hour), Burst: 10, Backend: RedisBackend, Shadow: true}
+	if len(cfg.Limiters) != 1 || cfg.Limiters[0] != want {
+		t.Errorf("limiters %+v, want %+v", cfg.Limiters, want)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].Options["url"] != "redis://localhost:6379" {
+		t.Errorf("backends %+v, want the Redis URL", cfg.Backends)
+	}
+	if !slices.Equal(cfg.TrustedProxies, []string{"10.0.0.0/8", "192.168.0.0/16"}) || !slices.Equal(cfg.Skip.Paths, []string{"/healthz", "/metrics"}) {
+		t.Errorf("trusted proxies %q and skipped paths %q, want the lists", cfg.TrustedProxies, cfg.Skip.Paths)
+	}
+	if cfg.Default == nil || cfg.Default.Key != "header:X-API-Key" || cfg.Default.Limiter != "default" {
+		t.Errorf("default rule %+v, want the limiter by API key", cfg.Default)
+	}
+}
+
+func TestParseEnvDefaults(t *testing.T) {
+	cfg, err := ParseEnv(lookup(map[string]string{EnvRate: "2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	g, err := cfg.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	// A sliding window of 2 requests per minute by client IP.
+	h := g.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if status := send(h, http.MethodGet, "/", nil); status != want {
+			t.Errorf("request %d: status %d, want %d", i, status, want)
+		}
+	}
+
+	cfg, err = ParseEnv(lookup(map[string]string{EnvPreset: "login-strict"}))
+	if err != nil || cfg.Limiters[0].Preset != "login-strict" || cfg.Limiters[0].Rate != 0 {
+		t.Errorf("preset config %+v, error %v, want the fields left to the preset", cfg.Limiters, err)
+	}
+}
+
+func TestParseEnvErrors(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"missing rate":   {},
+		"invalid rate":   {EnvRate: "many"},
+		"invalid window": {EnvRate: "1", EnvWindow: "soon"},
+		"invalid burst":  {EnvRate: "1", EnvBurst: "x"},
+		"invalid shadow": {EnvRate: "1", EnvShadow: "maybe"},
+	} {
+		if _, err := ParseEnv(lookup(env)); err == nil {
+			t.Errorf("%s: parsed, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Explanation reports how the rules of a configuration apply to a request, to
+// debug layered policies.
+type Explanation struct {
+	Mode    Mode              `json:"mode"`    // How the matching rules apply.
+	Applied []string          `json:"applied"` // Names of the rules applied to the request, in order, empty if none.
+	Rules   []RuleExplanation `json:"rules"`   // Evaluation of every rule, in the order they are tried.
+}
+
+// RuleExplanation reports whether a rule matches a request, and why.
+type RuleExplanation struct {
+	Name     string `json:"name"`     // Name of the rule.
+	Priority int    `json:"priority"` // Priority of the rule.
+	Limiter  string `json:"limiter"`  // Name of the limiter of the rule.
+	Matched  bool   `json:"matched"`  // Whether the rule matches the request.
+	Applied  bool   `json:"applied"`  // Whether the rule applies to the request.
+	Reason   string `json:"reason"`   // Why the rule matches the request or not.
+}
+
+// String formats the explanation on one line per rule.
+func (e Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "mode %s, applied: %s\n", e.Mode, strings.Join(e.Applied, ", "))
+	for _, rule := range e.Rules {
+		mark := " "
+		if rule.Applied {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s (priority %d, limiter %s): %s\n", mark, rule.Name, rule.Priority, rule.Limiter, rule.Reason)
+	}
+	return b.String()
+}
+
+// Explain reports which rules apply to r and why, without limiting it.
+func (g *Graph) Explain(r *http.Request) Explanation {
+	e := Explanation{Mode: g.mode, Applied: []string{}}
+	for i := range g.rules {
+		rule := &g.rules[i]
+		matched, reason := rule.explain(r)
+		applied := matched && (g.mode == AllMatch || len(e.Applied) == 0)
+		if matched && !applied {
+			reason += ", but a rule of higher precedence applies"
+		}
+		if applied {
+			e.Applied = append(e.Applied, rule.Name)
+		}
+		e.Rules = append(e.Rules, RuleExplanation{
+			Name:     rule.Name,
+			Priority: rule.Priority,
+			Limiter:  rule.Limiter,
+			Matched:  matched,
+			Applied:  applied,
+			Reason:   reason,
+		})
+	}
+	if g.fallback != nil {
+		applied := len(e.Applied) == 0
+		reason := "no other rule matches"
+		if !applied {
+			reason = "another rule matches"
+		} else {
+			e.Applied = append(e.Applied, g.fallback.Name)
+		}
+		e.Rules = append(e.Rules, RuleExplanation{
+			Name:     g.fallback.Name,
+			Priority: g.fallback.Priority,
+			Limiter:  g.fallback.Limiter,
+			Matched:  applied,
+			Applied:  applied,
+			Reason:   "default rule, " + reason,
+		})
+	}
+	return e
+}
+
+// explain reports whether the rule matches r, and why, like matches.
+func (rule *builtRule) explain(r *http.Request) (bool, string) {
+	if !strings.HasPrefix(r.URL.Path, rule.Path) {
+		return false, fmt.Sprintf("path %s is not below %s", r.URL.Path, rule.Path)
+	}
+	if rule.Method != "" && rule.Method != r.Method {
+		return false, fmt.Sprintf("method %s is not %s", r.Method, rule.Method)
+	}
+	if rule.match != nil && !rule.match(r) {
+		return false, fmt.Sprintf("expression %q is not true", rule.Match)
+	}
+
+	var conditions []string
+	if rule.Path != "" {
+		conditions = append(conditions, "path below "+rule.Path)
+	}
+	if rule.Method != "" {
+		conditions = append(conditions, "method "+rule.Method)
+	}
+	if rule.match != nil {
+		conditions = append(conditions, fmt.Sprintf("expression %q", rule.Match))
+	}
+	if len(conditions) == 0 {
+		return true, "matches every request"
+	}
+	return true, "matches " + strings.Join(conditions, ", ")
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const layeredConfig = `
+mode: %s
+limiters:
+  - name: sustained
+    algorithm: sliding-window
+    rate: 3
+    window: 1h
+  - name: writes
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+rules:
+  - name: api
+    path: /api/
+    limiter: sustained
+  - name: api-writes
+    path: /api/
+    method: POST
+    priority: 10
+    limiter: writes
+default:
+  limiter: sustained
+`
+
+// layered returns the layered configuration in mode.
+func layered(mode Mode) string {
+	return fmt.Sprintf(layeredConfig, mode)
+}
+
+func TestGraphPriority(t *testing.T) {
+	h := handler(t, layered(FirstMatch))
+	// The writes rule of higher priority applies first, alone.
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if status := send(h, http.MethodPost, "/api/orders", nil); status != want {
+			t.Errorf("POST %d: status %d, want %d", i, status, want)
+		}
+	}
+	for i := range 3 {
+		if status := send(h, http.MethodGet, "/api/orders", nil); status != http.StatusOK {
+			t.Errorf("GET %d: status %d, want %d", i, status, http.StatusOK)
+		}
+	}
+}
+
+func TestGraphAllMatch(t *testing.T) {
+	h := handler(t, layered(AllMatch))
+	// Writes count against both limiters.
+	send(h, http.MethodPost, "/api/orders", nil)
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if status := send(h, http.MethodGet, "/api/orders", nil); status != want {
+			t.Errorf("GET %d: status %d, want %d", i, status, want)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	for _, test := range []struct {
+		mode    Mode
+		method  string
+		path    string
+		applied []string
+	}{
+		{FirstMatch, http.MethodPost, "/api/orders", []string{"api-writes"}},
+		{AllMatch, http.MethodPost, "/api/orders", []string{"api-writes", "api"}},
+		{FirstMatch, http.MethodGet, "/api/orders", []string{"api"}},
+		{FirstMatch, http.MethodGet, "/", []string{"default"}},
+	} {
+		g, err := mustParse(t, layered(test.mode)).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := g.Explain(httptest.NewRequest(test.method, test.path, nil))
+		g.Close()
+		if !slices.Equal(e.Applied, test.applied) {
+			t.Errorf("%s %s %s: applied %q, want %q", test.mode, test.method, test.path, e.Applied, test.applied)
+		}
+	}
+
+	g, err := mustParse(t, layered(FirstMatch)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	e := g.Explain(httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+	want := []string{
+		"mode first-match, applied: api",
+		"  api-writes (priority 10, limiter writes): method GET is not POST",
+		"* api (priority 0, limiter sustained): matches path below /api/",
+		"  default (priority 0, limiter sustained): default rule, another rule matches",
+	}
+	if got := strings.Split(strings.TrimSuffix(e.String(), "\n"), "\n"); !slices.Equal(got, want) {
+		t.Errorf("explanation\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import "github.com/minhpq331/ratelimiter-example/ratelimiter"
+
+// Policy is the effective policy of a rule: the requests it matches, and the
+// limiter it applies to them as built.
+type Policy struct {
+	Rule    Rule    // The rule, named.
+	Limiter Limiter // The limiter of the rule, with the fields left unset given by its preset.
+	Backend Backend // The backend storing the state of the limiter, if any.
+	Default bool    // Whether the rule is the default rule, applied to the requests matching no other rule.
+
+	// The function extracting the keys of the requests of the rule, as its
+	// handler does.
+	KeyFunc ratelimiter.KeyFunc
+
+	// The function computing the cost of the requests of the rule from its
+	// cost expression, as its handler does, nil for one unit.
+	CostFunc ratelimiter.CostFunc
+}
+
+// Policies returns the effective policies of the rules, in the order they are
+// tried, the default rule last.
+func (g *Graph) Policies() []Policy {
+	policies := make([]Policy, 0, len(g.rules)+1)
+	for _, rule := range g.rules {
+		policies = append(policies, g.policy(rule, false))
+	}
+	if g.fallback != nil {
+		policies = append(policies, g.policy(*g.fallback, true))
+	}
+	return policies
+}
+
+// policy returns the effective policy of rule.
+func (g *Graph) policy(rule builtRule, fallback bool) Policy {
+	definition := g.definitions[rule.Limiter]
+	return Policy{Rule: rule.Rule, Limiter: definition.limiter, Backend: definition.backend, Default: fallback, KeyFunc: rule.keyFunc, CostFunc: rule.cost}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestGraphPolicies(t *testing.T) {
+	memoryBackend := func(_ Backend, newAlgorithm func() ratelimiter.Algorithm) (ratelimiter.Limiter, error) {
+		return ratelimiter.NewKeyed(newAlgorithm), nil
+	}
+	g, err := mustParse(t, `
+backends:
+  - name: shared
+    type: memory
+limiters:
+  - name: login
+    preset: login-strict
+  - name: api
+    algorithm: sliding-window
+    rate: 100
+    window: 1m
+    backend: shared
+rules:
+  - path: /auth/login
+    limiter: login
+  - name: api
+    path: /api/
+    limiter: api
+    key: header:X-API-Key
+default:
+  limiter: login
+`).Build(WithBackend("memory", memoryBackend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	policies := g.Policies()
+	var names []string
+	for _, policy := range policies {
+		names = append(names, policy.Rule.Name)
+	}
+	if len(policies) != 3 || names[0] != "rule-0" || names[1] != "api" || !policies[2].Default || policies[0].Default {
+		t.Fatalf("policies of rules %q, want the two rules then the default one", names)
+	}
+	// The limiters are those built, with the fields of their preset.
+	if login := policies[0].Limiter; login.Algorithm != "sliding-window" || login.Rate != 5 || login.Window != Duration(15*time.Minute) {
+		t.Errorf("login limiter %+v, want that of the login-strict preset", login)
+	}
+	if backend := policies[1].Backend; backend.Name != "shared" || backend.Type != "memory" {
+		t.Errorf("api backend %+v, want shared", backend)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	r.Header.Set("X-API-Key", "acme")
+	if key := policies[1].KeyFunc(r); key != "acme" {
+		t.Errorf("api key %q, want the API key", key)
+	}
+	if key := policies[2].KeyFunc(r); key != "192.0.2.1" {
+		t.Errorf("default key %q, want the client address", key)
+	}
+}
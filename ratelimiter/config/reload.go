@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Reloader applies the changes of a configuration file to a running service,
+// without restarting it. A reload builds the whole new configuration aside
+// and swaps it in atomically, so requests see either the old rules or the new
+// ones, and limiters whose definition is unchanged keep their state.
+//
+//	reloader, err := config.NewReloader("ratelimit.yaml")
+//	go reloader.Watch(ctx, 10*time.Second)
+//	go reloader.WatchSignals(ctx)
+//	handler = reloader.Handler(handler)
+type Reloader struct {
+	path     string                // The path of the configuration file.
+	opts     []Option              // The options building the configuration.
+	onReload func(err error)       // The function notified of every reload attempt.
+	mu       sync.Mutex            // Serializes the reloads.
+	graph    atomic.Pointer[Graph] // The configuration in use.
+	registry *ratelimiter.Registry // The registry of the limiters of the configuration in use.
+	modTime  time.Time             // The modification time of the file last loaded.
+	size     int64                 // The size of the file last loaded.
+}
+
+// NewReloader creates a new reloader of the configuration file at path, built
+// with opts. It fails if the file cannot be loaded.
+func NewReloader(path string, opts ...Option) (*Reloader, error) {
+	r := &Reloader{path: path, opts: opts, onReload: func(error) {}, registry: ratelimiter.NewRegistry(nil)}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OnReload sets a function notified of every reload attempt triggered by Watch
+// or WatchSignals, with nil on success, e.g. to log it. Failed reloads keep the
+// configuration in use.
+func (r *Reloader) OnReload(onReload func(err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onReload = onReload
+}
+
+// Graph returns the configuration in use.
+func (r *Reloader) Graph() *Graph {
+	return r.graph.Load()
+}
+
+// Registry returns the registry of the limiters of the configuration in use,
+// by name. Reloads replace its limiters, so the limiters looked up in it on
+// every use follow the changes of the file.
+func (r *Reloader) Registry() *ratelimiter.Registry {
+	return r.registry
+}
+
+// ReadinessHandler returns a handler for readiness probes, see
+// ratelimiter.ReadinessHandler, checking the backends of the configuration in
+// use at every probe.
+func (r *Reloader) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ratelimiter.ReadinessHandler(r.Graph().HealthCheckers()...).ServeHTTP(w, req)
+	})
+}
+
+// Close stops the pruning of the limiters of the configuration in use, see
+// Graph.Close.
+func (r *Reloader) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.graph.Load().Close()
+}
+
+// Reload loads, validates and builds the configuration file, and swaps it in
+// if it is valid. It can be used as the reload function of the admin API.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reload()
+}
+
+// Watch reloads the configuration whenever its file changes, checking it every
+// interval, until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.changed() {
+				r.onReload(r.reload())
+			}
+			r.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WatchSignals reloads the configuration whenever the process receives one of
+// signals, SIGHUP by default, until ctx is done.
+func (r *Reloader) WatchSignals(ctx context.Context, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	for {
+		select {
+		case <-received:
+			r.mu.Lock()
+			r.onReload(r.reload())
+			r.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Handler returns next limited by the rules of the configuration in use, see
+// Graph.Handler.
+func (r *Reloader) Handler(next http.Handler) http.Handler {
+	return &reloadingHandler{reloader: r, next: next}
+}
+
+// reload loads, validates and builds the configuration file. It must be called with mu
+// held.
+func (r *Reloader) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	cfg, err := Load(r.path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	opts := r.opts
+	if previous := r.graph.Load(); previous != nil {
+		opts = append(opts[:len(opts):len(opts)], reuse(previous))
+	}
+	graph, err := cfg.Build(opts...)
+	if err != nil {
+		return err
+	}
+
+	if previous := r.graph.Swap(graph); previous != nil {
+		previous.Close()
+	}
+	r.registry.Replace(graph.Limiters())
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// changed reports whether the file changed since it was last loaded. It must
+// be called with mu held.
+func (r *Reloader) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		// Keep the configuration in use while the file is being replaced.
+		return false
+	}
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+}
+
+// reloadingHandler builds the handler of every configuration it serves with.
+type reloadingHandler struct {
+	reloader *Reloader                    // The reloader providing the configuration.
+	next     http.Handler                 // The handler of the requests.
+	current  atomic.Pointer[graphHandler] // The handler of the configuration last served with.
+	mu       sync.Mutex                   // Serializes the building of the handlers.
+}
+
+// graphHandler is the handler built for a configuration.
+type graphHandler struct {
+	graph   *Graph       // The configuration.
+	handler http.Handler // The handler limiting the requests with it.
+}
+
+func (h *reloadingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	graph := h.reloader.Graph()
+	current := h.current.Load()
+	if current == nil || current.graph != graph {
+		h.mu.Lock()
+		if current = h.current.Load(); current == nil || current.graph != graph {
+			current = &graphHandler{graph: graph, handler: graph.Handler(h.next)}
+			h.current.Store(current)
+		}
+		h.mu.Unlock()
+	}
+	current.handler.ServeHTTP(w, r)
+}
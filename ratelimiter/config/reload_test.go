@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// reloadConfig is a configuration whose api limiter allows apiRate requests.
+const reloadConfig = `
+limiters:
+  - name: login
+    algorithm: sliding-window
+    rate: 1
+    window: 1h
+  - name: api
+    algorithm: sliding-window
+    rate: %d
+    window: 1h
+rules:
+  - path: /login
+    limiter: login
+  - path: /api
+    limiter: api
+`
+
+// writeConfig writes data to the configuration file at path.
+func writeConfig(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.yaml")
+	writeConfig(t, path, fmt.Sprintf(reloadConfig, 1))
+	r, err := NewReloader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	h := r.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	send(h, http.MethodGet, "/login", nil)
+	send(h, http.MethodGet, "/api", nil)
+
+	writeConfig(t, path, fmt.Sprintf(reloadConfig, 5))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	// The unchanged login limiter keeps its state, the api one starts over.
+	if status := send(h, http.MethodGet, "/login", nil); status != http.StatusTooManyRequests {
+		t.Errorf("login: status %d after the reload, want %d", status, http.StatusTooManyRequests)
+	}
+	if status := send(h, http.MethodGet, "/api", nil); status != http.StatusOK {
+		t.Errorf("api: status %d after the reload, want %d", status, http.StatusOK)
+	}
+	if limiter, ok := r.Registry().Limiter("api"); !ok || limiter != r.Graph().Limiters()["api"] {
+		t.Error("registry not following the reload")
+	}
+
+	// Invalid configurations keep the one in use.
+	graph := r.Graph()
+	writeConfig(t, path, "limiters: [{name: api, algorithm: sliding-window, rate: 0, window: 1h}]")
+	if err := r.Reload(); err == nil || r.Graph() != graph {
+		t.Errorf("invalid reload: error %v, graph replaced %t, want an error", err, r.Graph() != graph)
+	}
+}
+
+func TestReloaderReadinessHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.yaml")
+	writeConfig(t, path, fmt.Sprintf(reloadConfig, 1))
+	backend := &healthLimiter{err: errors.New("connection refused")}
+	fake := func(b Backend, newAlgorithm func() ratelimiter.Algorithm) (ratelimiter.Limiter, error) {
+		backend.Keyed = ratelimiter.NewKeyed(newAlgorithm)
+		return backend, nil
+	}
+	r, err := NewReloader(path, WithBackend("fake", fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	handler := r.ReadinessHandler()
+	probe := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("in-memory limiters: status %d, want %d", code, http.StatusOK)
+	}
+
+	// The probes check the backends of the configuration reloaded since.
+	writeConfig(t, path, healthConfig)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("failing backend: status %d, want %d", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.yaml")
+	writeConfig(t, path, fmt.Sprintf(reloadConfig, 1))
+	r, err := NewReloader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	reloaded := make(chan error, 1)
+	r.OnReload(func(err error) { reloaded <- err })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 5*time.Millisecond)
+
+	graph := r.Graph()
+	writeConfig(t, path, fmt.Sprintf(reloadConfig, 10))
+	select {
+	case err := <-reloaded:
+		if err != nil || r.Graph() == graph {
+			t.Errorf("reload: error %v, graph replaced %t", err, r.Graph() != graph)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change of the file not reloaded")
+	}
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	if _, err := NewReloader(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("reloader created for a missing file")
+	}
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// FieldError is an invalid field of a configuration.
+type FieldError struct {
+	Source  string // Path of the configuration file, empty if unknown.
+	Line    int    // Line of the field in the file, zero if unknown.
+	Field   string // Path of the field, e.g. "limiters[1].burst".
+	Message string // What is wrong with the field.
+}
+
+// Error formats the error as "<source>:<line>: <field>: <message>", or
+// "line <line>: <field>: <message>" if the source is unknown.
+func (e *FieldError) Error() string {
+	switch {
+	case e.Source != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s: %s", e.Source, e.Line, e.Field, e.Message)
+	case e.Source != "":
+		return fmt.Sprintf("%s: %s: %s", e.Source, e.Field, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+	default:
+		return e.Field + ": " + e.Message
+	}
+}
+
+// ValidationError lists the invalid fields of a configuration.
+type ValidationError []*FieldError
+
+// Error formats the errors one per line.
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Validate reports the impossible policies of the configuration, such as
+// windows of zero, unknown algorithms or rules never applied, as a
+// ValidationError locating every invalid field in the file it was loaded from.
+// Backend types and keys registered with options are only checked by Build.
+func (c *Config) Validate() error {
+	v := &validator{config: c}
+
+	switch c.Mode {
+	case "", FirstMatch, AllMatch:
+	default:
+		v.errorf([]any{"mode"}, "unknown mode %q, want %s or %s", c.Mode, FirstMatch, AllMatch)
+	}
+	for i, cidr := range c.TrustedProxies {
+		if _, err := ratelimiter.MatchSubnets(cidr); err != nil {
+			v.errorf([]any{"trustedProxies", i}, "%v", err)
+		}
+	}
+	if c.ForwardingHeader != "" {
+		if _, err := ratelimiter.KeyByClientIP(nil, ratelimiter.WithForwardingHeader(c.ForwardingHeader)); err != nil {
+			v.errorf([]any{"forwardingHeader"}, "%v", err)
+		}
+	}
+	for i, cidr := range c.Skip.Subnets {
+		if _, err := ratelimiter.MatchSubnets(cidr); err != nil {
+			v.errorf([]any{"skip", "subnets", i}, "%v", err)
+		}
+	}
+
+	backends := make(map[string]bool, len(c.Backends))
+	for i, backend := range c.Backends {
+		field := []any{"backends", i}
+		switch {
+		case backend.Name == "":
+			v.errorf(append(field, "name"), "missing name")
+		case backends[backend.Name]:
+			v.errorf(append(field, "name"), "backend %s defined twice", backend.Name)
+		}
+		if backend.Type == "" {
+			v.errorf(append(field, "type"), "missing type")
+		}
+		backends[backend.Name] = true
+	}
+
+	limiters := make(map[string]bool, len(c.Limiters))
+	for i, l := range c.Limiters {
+		v.limiter([]any{"limiters", i}, l, limiters, backends)
+	}
+
+	for i, rule := range c.Rules {
+		v.rule([]any{"rules", i}, rule, limiters)
+	}
+	if c.Default != nil {
+		v.rule([]any{"default"}, *c.Default, limiters)
+	}
+	if c.Mode != AllMatch {
+		v.shadowedRules()
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// validator accumulates the errors of a configuration.
+type validator struct {
+	config *Config         // The configuration validated.
+	errors ValidationError // The errors found so far.
+}
+
+// errorf records an error of the field at path, a list of keys and indexes.
+func (v *validator) errorf(path []any, format string, args ...any) {
+	var field strings.Builder
+	for _, element := range path {
+		switch element := element.(type) {
+		case int:
+			fmt.Fprintf(&field, "[%d]", element)
+		default:
+			if field.Len() > 0 {
+				field.WriteString(".")
+			}
+			fmt.Fprint(&field, element)
+		}
+	}
+	v.errors = append(v.errors, &FieldError{
+		Source:  v.config.source,
+		Line:    line(v.config.node, path),
+		Field:   field.String(),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// limiter validates the limiter l at field, given the names of the limiters
+// before it and of the backends.
+func (v *validator) limiter(field []any, l Limiter, limiters, backends map[string]bool) {
+	switch {
+	case l.Name == "":
+		v.errorf(append(field, "name"), "missing name")
+	case limiters[l.Name]:
+		v.errorf(append(field, "name"), "limiter %s defined twice", l.Name)
+	}
+	limiters[l.Name] = true
+
+	l, ok := l.resolved()
+	if !ok {
+		v.errorf(append(field, "preset"), "unknown preset %q, want one of %s", l.Preset, strings.Join(presetNames(), ", "))
+		return
+	}
+	if !slices.Contains(ratelimiter.Algorithms, l.Algorithm) {
+		v.errorf(append(field, "algorithm"), "unknown algorithm %q, want one of %s", l.Algorithm, strings.Join(ratelimiter.Algorithms, ", "))
+	}
+	if l.Rate < 1 {
+		v.errorf(append(field, "rate"), "rate %d allows no request, want at least 1", l.Rate)
+	}
+	if l.Algorithm == ratelimiter.CalendarWindowAlgorithm {
+		if _, err := l.period(); err != nil {
+			v.errorf(append(field, "period"), "%v", err)
+		}
+		if _, err := l.location(); err != nil {
+			v.errorf(append(field, "timezone"), "%v", err)
+		}
+	} else {
+		if l.Window <= 0 {
+			v.errorf(append(field, "window"), "window %s is not positive", l.Window)
+		}
+		if l.Period != "" {
+			v.errorf(append(field, "period"), "period only applies to the %s algorithm", ratelimiter.CalendarWindowAlgorithm)
+		}
+		if l.Timezone != "" {
+			v.errorf(append(field, "timezone"), "timezone only applies to the %s algorithm", ratelimiter.CalendarWindowAlgorithm)
+		}
+	}
+	switch {
+	case l.Burst < 0:
+		v.errorf(append(field, "burst"), "burst %d allows no request, want at least 1", l.Burst)
+	case l.Burst > 0 && l.Algorithm != ratelimiter.TokenBucketAlgorithm:
+		v.errorf(append(field, "burst"), "burst only applies to the %s algorithm", ratelimiter.TokenBucketAlgorithm)
+	}
+	if l.Backend != "" && !backends[l.Backend] {
+		v.errorf(append(field, "backend"), "unknown backend %q", l.Backend)
+	}
+}
+
+// rule validates rule at field, given the names of the limiters.
+func (v *validator) rule(field []any, rule Rule, limiters map[string]bool) {
+	switch {
+	case rule.Limiter == "":
+		v.errorf(append(field, "limiter"), "missing limiter")
+	case !limiters[rule.Limiter]:
+		v.errorf(append(field, "limiter"), "unknown limiter %q", rule.Limiter)
+	}
+	if rule.Method != "" && rule.Method != strings.ToUpper(rule.Method) {
+		v.errorf(append(field, "method"), "method %q never matches, methods are upper case", rule.Method)
+	}
+	if rule.Path != "" && !strings.HasPrefix(rule.Path, "/") {
+		v.errorf(append(field, "path"), "path %q never matches, paths start with a slash", rule.Path)
+	}
+	if rule.Match != "" {
+		if _, err := celProgram(rule.Match, cel.BoolType); err != nil {
+			v.errorf(append(field, "match"), "%v", err)
+		}
+	}
+	if rule.Cost != "" {
+		if _, err := celProgram(rule.Cost, cel.IntType); err != nil {
+			v.errorf(append(field, "cost"), "%v", err)
+		}
+	}
+}
+
+// shadowedRules reports the rules never applied in first-match mode, because a
+// rule of higher precedence matches all their requests.
+func (v *validator) shadowedRules() {
+	order := make([]int, len(v.config.Rules))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(v.config.Rules[b].Priority, v.config.Rules[a].Priority)
+	})
+
+	for j, b := range order {
+		rule := v.config.Rules[b]
+		for _, a := range order[:j] {
+			earlier := v.config.Rules[a]
+			if earlier.Match == "" && strings.HasPrefix(rule.Path, earlier.Path) &&
+				(earlier.Method == "" || earlier.Method == rule.Method) {
+				v.errorf([]any{"rules", b}, "rule never applies, rule %s (rules[%d]) matches all its requests first", ruleName(earlier, a), a)
+				break
+			}
+		}
+	}
+}
+
+// ruleName returns the name of the i-th rule as Build does.
+func ruleName(rule Rule, i int) string {
+	if rule.Name == "" {
+		return fmt.Sprintf("rule-%d", i)
+	}
+	return rule.Name
+}
+
+// line returns the line of the field at path in the document node, or zero if
+// it is not found.
+func line(node *yaml.Node, path []any) int {
+	if node == nil {
+		return 0
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	found := node.Line
+	for _, element := range path {
+		var next *yaml.Node
+		switch element := element.(type) {
+		case int:
+			if node.Kind == yaml.SequenceNode && element < len(node.Content) {
+				next = node.Content[element]
+				found = next.Line
+			}
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == element {
+						found, next = node.Content[i].Line, node.Content[i+1]
+						break
+					}
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return found
+}
+
+// presetNames returns the names of the presets.
+func presetNames() []string {
+	names := make([]string, len(ratelimiter.Presets))
+	for i, preset := range ratelimiter.Presets {
+		names[i] = preset.Name
+	}
+	return names
+}
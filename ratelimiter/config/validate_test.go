@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const invalidConfig = `mode: sometimes
+trustedProxies: [10.0.0.0/33]
+limiters:
+  - name: api
+    algorithm: fixed-window
+    rate: 0
+    window: 1m
+  - name: api
+    algorithm: sliding-window
+    rate: 1
+    window: 0s
+    burst: 5
+    backend: redis
+rules:
+  - path: api/
+    method: get
+    limiter: missing
+  - path: /
+    limiter: api
+  - path: /admin/
+    limiter: api
+    match: request.method ==
+`
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.yaml")
+	if err := os.WriteFile(path, []byte(invalidConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verr ValidationError
+	if err := cfg.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("error %v, want a ValidationError", err)
+	}
+	var fields []string
+	for _, err := range verr {
+		if err.Source != path {
+			t.Errorf("%s: source %q, want %q", err.Field, err.Source, path)
+		}
+		fields = append(fields, err.Field)
+	}
+	want := []string{
+		"mode", "trustedProxies[0]",
+		"limiters[0].algorithm", "limiters[0].rate",
+		"limiters[1].name", "limiters[1].window", "limiters[1].burst", "limiters[1].backend",
+		"rules[0].limiter", "rules[0].method", "rules[0].path",
+		"rules[2].match",
+		"rules[2]", // Shadowed by the rule of every path.
+	}
+	if !slices.Equal(fields, want) {
+		t.Errorf("invalid fields %q, want %q", fields, want)
+	}
+	// The errors locate the fields in the file.
+	if err := verr[3]; err.Line != 6 || err.Error() != path+":6: limiters[0].rate: rate 0 allows no request, want at least 1" {
+		t.Errorf("rate error %q at line %d, want line 6", err, err.Line)
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	for name, data := range map[string]string{
+		"rules":     rulesConfig,
+		"all-match": layered(AllMatch),
+		"calendar":  "limiters: [{name: daily, algorithm: calendar-window, rate: 10, period: day, timezone: Europe/Paris}]",
+		"preset":    "limiters: [{name: login, preset: login-strict}]",
+	} {
+		cfg, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestFieldError(t *testing.T) {
+	for _, test := range []struct {
+		err  FieldError
+		want string
+	}{
+		{FieldError{Source: "a.yaml", Line: 3, Field: "mode", Message: "bad"}, "a.yaml:3: mode: bad"},
+		{FieldError{Source: "a.yaml", Field: "mode", Message: "bad"}, "a.yaml: mode: bad"},
+		{FieldError{Line: 3, Field: "mode", Message: "bad"}, "line 3: mode: bad"},
+		{FieldError{Field: "mode", Message: "bad"}, "mode: bad"},
+	} {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("%+v: %q, want %q", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ratelimiter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// KeyByConnection keys requests by the connection they arrived on, so that the
+// streams multiplexed over a single HTTP/2 connection share a budget whatever
+// the address behind it. Combined with WithMaxInFlight it limits the concurrent
+// streams of a connection, and with a rate limiter the streams it opens per
+// second:
+//
+//	limiter := ratelimiter.NewKeyed(func() ratelimiter.Algorithm {
+//		return ratelimiter.NewSlidingWindow(100, time.Second)
+//	})
+//	server := &http.Server{
+//		Handler: ratelimiter.Middleware(limiter,
+//			ratelimiter.WithKeyFunc(ratelimiter.KeyByConnection),
+//			ratelimiter.WithMaxInFlight(20),
+//		)(handler),
+//		ConnState: ratelimiter.ForgetClosedConnections(limiter),
+//	}
+//
+// The remote address and port identify a connection for as long as it is open.
+func KeyByConnection(r *http.Request) string {
+	return r.RemoteAddr
+}
+
+// ForgetClosedConnections returns an http.Server ConnState hook resetting the
+// keys of the connections that are closed or hijacked, so limiters keyed with
+// KeyByConnection do not keep their state after they are gone. The keys of the
+// connections on the routes of WithRoute, prefixed with their pattern, are
+// reset too.
+func ForgetClosedConnections(limiter *Keyed) func(net.Conn, http.ConnState) {
+	return func(c net.Conn, state http.ConnState) {
+		if state == http.StateClosed || state == http.StateHijacked {
+			addr := c.RemoteAddr().String()
+			limiter.resetMatching(func(key string) bool {
+				return key == addr || strings.HasSuffix(key, "|"+addr)
+			})
+		}
+	}
+}
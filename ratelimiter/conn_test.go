@@ -0,0 +1,44 @@
+package ratelimiter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// addrConn is a net.Conn reporting a remote address.
+type addrConn struct {
+	net.Conn
+	remote net.Addr // The remote address of the connection.
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestForgetClosedConnections(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(10, time.Minute) })
+	handler := Middleware(limiter, WithKeyFunc(KeyByConnection), WithRoute("POST /upload", limiter))(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, remoteAddr := range []string{"192.0.2.1:1234", "192.0.2.2:1234"} {
+		for _, r := range []*http.Request{httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRequest(http.MethodPost, "/upload", nil)} {
+			r.RemoteAddr = remoteAddr
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	}
+	if limiter.Len() != 4 {
+		t.Fatalf("%d keys tracked, want 4", limiter.Len())
+	}
+
+	closed := addrConn{remote: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}}
+	ForgetClosedConnections(limiter)(closed, http.StateClosed)
+	for _, key := range limiter.Keys() {
+		if key == "192.0.2.1:1234" || key == "POST /upload|192.0.2.1:1234" {
+			t.Errorf("key %q of the closed connection kept", key)
+		}
+	}
+	if limiter.Len() != 2 {
+		t.Errorf("%d keys tracked, want the 2 keys of the open connection", limiter.Len())
+	}
+}
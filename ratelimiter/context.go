@@ -0,0 +1,25 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// ContextLimiter is implemented by limiters using the context of the request
+// being decided, e.g. instrumentation linking its metrics to the trace of the
+// request. The middleware passes the context of the HTTP request to them.
+type ContextLimiter interface {
+	Limiter
+
+	// AllowNContext is AllowN for a request carrying ctx.
+	AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision
+}
+
+// AllowNContext decides a request for key carrying ctx with limiter, passing it
+// ctx if limiter is a ContextLimiter.
+func AllowNContext(ctx context.Context, limiter Limiter, key string, requestTime time.Time, n int) Decision {
+	if contextual, ok := limiter.(ContextLimiter); ok {
+		return contextual.AllowNContext(ctx, key, requestTime, n)
+	}
+	return limiter.AllowN(key, requestTime, n)
+}
@@ -0,0 +1,57 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// contextKey is the type of the context value passed through the wrappers.
+type contextKey struct{}
+
+// contextRecorder is a ContextLimiter recording the context value of its last
+// decision.
+type contextRecorder struct {
+	value any // The value of contextKey of the last decision.
+}
+
+func (c *contextRecorder) Allow(key string, requestTime time.Time) Decision {
+	return c.AllowN(key, requestTime, 1)
+}
+
+func (c *contextRecorder) AllowN(key string, requestTime time.Time, n int) Decision {
+	return c.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+func (c *contextRecorder) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision {
+	c.value = ctx.Value(contextKey{})
+	return Decision{Allowed: true}
+}
+
+func TestWrappersForwardContext(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap func(Limiter) Limiter
+	}{
+		{"hooked", func(l Limiter) Limiter { return NewHooked(l, OnAllow(func(Event) {})) }},
+		{"published", func(l Limiter) Limiter { return Publish("test-forward-context", l) }},
+		{"labeled", func(l Limiter) Limiter { return LabelDenials(l, ReasonGlobalLimit) }},
+		{"shadow", func(l Limiter) Limiter { return NewShadow(l, true, nil) }},
+		{"tightened", func(l Limiter) Limiter {
+			return NewAnomalyDetector(time.Minute, 3, nil).Tighten(l, l)
+		}},
+	}
+	for _, tt := range tests {
+		recorder := new(contextRecorder)
+		limiter := tt.wrap(recorder)
+		if hooked, ok := limiter.(*Hooked); ok {
+			defer hooked.Close()
+		}
+
+		ctx := context.WithValue(context.Background(), contextKey{}, tt.name)
+		AllowNContext(ctx, limiter, "client", time.Now(), 1)
+		if recorder.value != tt.name {
+			t.Errorf("%s: wrapped limiter got context value %v, want %q", tt.name, recorder.value, tt.name)
+		}
+	}
+}
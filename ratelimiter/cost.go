@@ -0,0 +1,33 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CostFunc computes how many units of budget a request consumes.
+type CostFunc func(r *http.Request) int
+
+// CostByContentLength charges one unit per started chunk of unit bytes of the
+// request body, and at least one unit, so a 50MB upload consumes more budget
+// than a ping. Requests of unknown length cost one unit. It panics if unit is
+// not positive.
+func CostByContentLength(unit int64) CostFunc {
+	if unit <= 0 {
+		panic(fmt.Sprintf("ratelimiter: CostByContentLength unit %d is not positive", unit))
+	}
+	return func(r *http.Request) int {
+		if r.ContentLength <= 0 {
+			return 1
+		}
+		return int((r.ContentLength + unit - 1) / unit)
+	}
+}
+
+// WithCostFunc sets the function computing the cost of a request. Every request
+// costs one unit by default.
+func WithCostFunc(costFunc CostFunc) Option {
+	return func(m *middleware) {
+		m.costFunc = costFunc
+	}
+}
@@ -0,0 +1,41 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCostByContentLength(t *testing.T) {
+	cost := CostByContentLength(1 << 20)
+	for _, tt := range []struct {
+		length int64
+		want   int
+	}{
+		{-1, 1},
+		{0, 1},
+		{1, 1},
+		{1 << 20, 1},
+		{1<<20 + 1, 2},
+		{50 << 20, 50},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.ContentLength = tt.length
+		if got := cost(r); got != tt.want {
+			t.Errorf("cost of %d bytes = %d, want %d", tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestCostByContentLengthInvalidUnit(t *testing.T) {
+	for _, unit := range []int64{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CostByContentLength(%d) did not panic", unit)
+				}
+			}()
+			CostByContentLength(unit)
+		}()
+	}
+}
@@ -0,0 +1,171 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFill is the use of the budget of a key.
+type KeyFill struct {
+	Key       string  `json:"key"`                 // The key.
+	Algorithm string  `json:"algorithm,omitempty"` // Name of the algorithm of the key.
+	Limit     int     `json:"limit"`               // Maximum number of requests allowed in the window.
+	Remaining int     `json:"remaining"`           // Number of requests left in the window.
+	Fill      float64 `json:"fill"`                // Share of the budget in use, from 0 to 1.
+}
+
+// Snapshot is a compact summary of the state of a limiter at a point in time.
+type Snapshot struct {
+	Time     time.Time  `json:"time"`               // Time of the snapshot.
+	Keys     int        `json:"keys"`               // Number of tracked keys.
+	Policies []string   `json:"policies,omitempty"` // Policies enforced, formatted as RateLimit-Policy members.
+	Fullest  []KeyFill  `json:"fullest"`            // Keys using the most of their budget, fullest first.
+	Top      *TopReport `json:"top,omitempty"`      // Most frequent keys of the last complete interval, see WithDumpTopKeys.
+}
+
+// DumpOption configures a Dumper.
+type DumpOption func(*Dumper)
+
+// WithDumpKeys sets the number of the fullest keys included in the snapshots.
+// It defaults to 20.
+func WithDumpKeys(n int) DumpOption {
+	return func(d *Dumper) {
+		d.keys = n
+	}
+}
+
+// WithDumpTopKeys includes the most frequent keys tracked by top in the
+// snapshots.
+func WithDumpTopKeys(top *TopKeys) DumpOption {
+	return func(d *Dumper) {
+		d.top = top
+	}
+}
+
+// WithDumpOnError sets a function notified of the errors writing snapshots.
+func WithDumpOnError(onError func(err error)) DumpOption {
+	return func(d *Dumper) {
+		d.onError = onError
+	}
+}
+
+// Dumper periodically writes snapshots of the state of a limiter, so
+// post-incident analysis can reconstruct what it was doing.
+type Dumper struct {
+	limiter  *Keyed               // The limiter whose state is dumped.
+	interval time.Duration        // How often the snapshots are written.
+	write    func(Snapshot) error // The function writing the snapshots.
+	keys     int                  // The number of the fullest keys included in the snapshots.
+	top      *TopKeys             // The most frequent keys, nil to leave them out.
+	onError  func(err error)      // The function notified of the errors writing snapshots.
+	stop     chan struct{}        // Closed to stop the dumping goroutine.
+	stopOnce sync.Once            // Closes stop once.
+	done     chan struct{}        // Closed once the last snapshot was written.
+}
+
+// NewDumper creates a new dumper passing a snapshot of limiter to write every
+// interval, on its own goroutine until Close. Use DumpJSON to write them to a
+// file.
+func NewDumper(limiter *Keyed, interval time.Duration, write func(Snapshot) error, opts ...DumpOption) *Dumper {
+	d := &Dumper{
+		limiter:  limiter,
+		interval: interval,
+		write:    write,
+		keys:     20,
+		onError:  func(error) {},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	go d.run()
+	return d
+}
+
+// DumpJSON returns a function writing snapshots to w as JSON lines, for use
+// with NewDumper, e.g. with an audit.RotatingFile.
+func DumpJSON(w io.Writer) func(Snapshot) error {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	return func(s Snapshot) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return encoder.Encode(s)
+	}
+}
+
+// Snapshot returns the state of the limiter at now.
+func (d *Dumper) Snapshot(now time.Time) Snapshot {
+	snapshot := Snapshot{Time: now, Keys: d.limiter.Len()}
+	for _, policy := range d.limiter.Policies() {
+		snapshot.Policies = append(snapshot.Policies, policy.String())
+	}
+
+	fills := make([]KeyFill, 0, snapshot.Keys)
+	for _, key := range d.limiter.Keys() {
+		decision, ok := d.limiter.Peek(key, now)
+		if !ok {
+			continue
+		}
+		fill := KeyFill{Key: key, Algorithm: decision.Algorithm, Limit: decision.Limit, Remaining: decision.Remaining}
+		if decision.Limit > 0 {
+			fill.Fill = min(max(1-float64(decision.Remaining)/float64(decision.Limit), 0), 1)
+		}
+		fills = append(fills, fill)
+	}
+	slices.SortFunc(fills, func(a, b KeyFill) int {
+		if a.Fill != b.Fill {
+			if a.Fill > b.Fill {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	snapshot.Fullest = fills[:min(len(fills), d.keys)]
+
+	if d.top != nil {
+		top := d.top.Previous(now)
+		snapshot.Top = &top
+	}
+	return snapshot
+}
+
+// Close writes a last snapshot and stops dumping.
+func (d *Dumper) Close() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+	<-d.done
+}
+
+// run writes a snapshot every interval until Close.
+func (d *Dumper) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			d.dump(now)
+		case <-d.stop:
+			d.dump(time.Now())
+			return
+		}
+	}
+}
+
+// dump writes the snapshot of the limiter at now.
+func (d *Dumper) dump(now time.Time) {
+	if err := d.write(d.Snapshot(now)); err != nil {
+		d.onError(err)
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDumperSnapshot(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(4, time.Minute) })
+	now := time.Now()
+	for key, n := range map[string]int{"a": 3, "b": 1, "c": 2} {
+		limiter.AllowN(key, now, n)
+	}
+	var out bytes.Buffer
+	d := NewDumper(limiter, time.Hour, DumpJSON(&out), WithDumpKeys(2))
+	d.Close()
+
+	// Close writes a last snapshot.
+	var snapshot Snapshot
+	if err := json.Unmarshal(out.Bytes(), &snapshot); err != nil {
+		t.Fatalf("snapshot %q: %v", out.String(), err)
+	}
+	if snapshot.Keys != 3 || len(snapshot.Policies) != 1 || snapshot.Top != nil {
+		t.Errorf("snapshot %+v, want 3 keys and 1 policy", snapshot)
+	}
+	want := []KeyFill{
+		{Key: "a", Algorithm: SlidingWindowAlgorithm, Limit: 4, Remaining: 1, Fill: 0.75},
+		{Key: "c", Algorithm: SlidingWindowAlgorithm, Limit: 4, Remaining: 2, Fill: 0.5},
+	}
+	if len(snapshot.Fullest) != 2 || snapshot.Fullest[0] != want[0] || snapshot.Fullest[1] != want[1] {
+		t.Errorf("fullest keys %+v, want %+v", snapshot.Fullest, want)
+	}
+}
+
+func TestDumperInterval(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(4, time.Minute) })
+	snapshots := make(chan Snapshot, 10)
+	errWrite := errors.New("disk full")
+	var errs []error
+	d := NewDumper(limiter, 10*time.Millisecond, func(s Snapshot) error {
+		snapshots <- s
+		return errWrite
+	}, WithDumpOnError(func(err error) { errs = append(errs, err) }))
+
+	select {
+	case <-snapshots:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no snapshot written")
+	}
+	d.Close()
+	if len(errs) < 2 || errs[0] != errWrite {
+		t.Errorf("errors %v, want the write errors of every snapshot", errs)
+	}
+}
+
+func TestDumperTopKeys(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(4, time.Minute) })
+	top := NewTopKeys(5, time.Minute)
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	top.Observe("heavy", start, Decision{})
+	d := NewDumper(limiter, time.Hour, func(Snapshot) error { return nil }, WithDumpTopKeys(top))
+	defer d.Close()
+
+	// The snapshots report the last complete interval.
+	snapshot := d.Snapshot(start.Add(time.Minute))
+	if snapshot.Top == nil || !snapshot.Top.Start.Equal(start) || len(snapshot.Top.Denied) != 1 || snapshot.Top.Denied[0].Key != "heavy" {
+		t.Errorf("top %+v, want heavy denied in the interval from %v", snapshot.Top, start)
+	}
+}
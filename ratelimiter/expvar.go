@@ -0,0 +1,121 @@
+package ratelimiter
+
+import (
+	"context"
+	"expvar"
+	"time"
+)
+
+// Publish publishes the state of limiter under name in expvar, so it can be
+// inspected on the /debug/vars endpoint served by expvar.Handler or the
+// default mux. The returned limiter must be used instead of limiter for its
+// decisions to be counted. The published map holds:
+//
+//	allowed   the number of allowed requests
+//	denied    the number of denied requests
+//	keys      the number of tracked keys, if limiter can count them as Keyed does
+//	memory    the estimated bytes held by the state of the keys, if limiter reports it as Keyed does
+//	evictions the number of idle keys evicted, if limiter reports it as Keyed does
+//	policies  the policies enforced, if limiter implements PolicyReporter
+//	shadow    whether shadow mode is on, if limiter is a Shadow
+//
+// Like expvar.Publish, it panics if name is already published.
+func Publish(name string, limiter Limiter) Limiter {
+	p := &published{limiter: limiter}
+
+	vars := expvar.NewMap(name)
+	vars.Set("allowed", &p.allowed)
+	vars.Set("denied", &p.denied)
+	if counter, ok := limiter.(interface{ Len() int }); ok {
+		vars.Set("keys", expvar.Func(func() any { return counter.Len() }))
+	}
+	if memory, ok := limiter.(interface{ MemoryUsage() int }); ok {
+		vars.Set("memory", expvar.Func(func() any { return memory.MemoryUsage() }))
+	}
+	if evictor, ok := limiter.(interface{ Evictions() uint64 }); ok {
+		vars.Set("evictions", expvar.Func(func() any { return evictor.Evictions() }))
+	}
+	if reporter, ok := limiter.(PolicyReporter); ok {
+		vars.Set("policies", expvar.Func(func() any { return publishedPolicies(reporter.Policies()) }))
+	}
+	if shadow, ok := limiter.(*Shadow); ok {
+		vars.Set("shadow", expvar.Func(func() any { return shadow.Enabled() }))
+	}
+	return p
+}
+
+// published is a Limiter counting the decisions of another in expvar.
+type published struct {
+	limiter Limiter    // The limiter making the decisions.
+	allowed expvar.Int // The number of allowed requests.
+	denied  expvar.Int // The number of denied requests.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (p *published) Allow(key string, requestTime time.Time) Decision {
+	return p.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed. Decisions of zero cost are not counted.
+func (p *published) AllowN(key string, requestTime time.Time, n int) Decision {
+	return p.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, passed to the wrapped
+// limiter.
+func (p *published) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision {
+	decision := AllowNContext(ctx, p.limiter, key, requestTime, n)
+	if n == 0 {
+		return decision
+	}
+	if decision.Allowed {
+		p.allowed.Add(1)
+	} else {
+		p.denied.Add(1)
+	}
+	return decision
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (p *published) Policies() []Policy {
+	if reporter, ok := p.limiter.(PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (p *published) Healthy() error {
+	if checker, ok := p.limiter.(HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (p *published) Peek(key string, requestTime time.Time) (Decision, bool) {
+	if peeker, ok := p.limiter.(Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return Decision{}, false
+}
+
+// publishedPolicy is the JSON form of a policy in expvar.
+type publishedPolicy struct {
+	Name   string `json:"name,omitempty"` // Name of the policy.
+	Limit  int    `json:"limit"`          // Maximum number of requests allowed in the window.
+	Window string `json:"window"`         // Duration of the window, e.g. "1m0s".
+}
+
+// publishedPolicies converts policies to their JSON form.
+func publishedPolicies(policies []Policy) []publishedPolicy {
+	out := make([]publishedPolicy, len(policies))
+	for i, p := range policies {
+		out[i] = publishedPolicy{Name: p.Name, Limit: p.Limit, Window: p.Window.String()}
+	}
+	return out
+}
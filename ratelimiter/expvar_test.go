@@ -0,0 +1,47 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestPublish(t *testing.T) {
+	keyed := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	limiter := Publish("ratelimiter-test-publish", NewShadow(keyed, false, nil))
+	now := time.Now()
+	limiter.Allow("a", now)
+	limiter.Allow("a", now)
+	limiter.AllowN("b", now, 0)
+
+	var vars struct {
+		Allowed  int
+		Denied   int
+		Keys     *int
+		Policies []publishedPolicy
+		Shadow   *bool
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("ratelimiter-test-publish").String()), &vars); err != nil {
+		t.Fatalf("published vars: %v", err)
+	}
+	if vars.Allowed != 1 || vars.Denied != 1 {
+		t.Errorf("%d allowed and %d denied, want 1 of each", vars.Allowed, vars.Denied)
+	}
+	// A Shadow cannot count its keys.
+	if vars.Keys != nil || vars.Shadow == nil || *vars.Shadow {
+		t.Errorf("keys %v, shadow %v, want shadow only", vars.Keys, vars.Shadow)
+	}
+	if len(vars.Policies) != 1 || vars.Policies[0] != (publishedPolicy{Limit: 1, Window: "1m0s"}) {
+		t.Errorf("policies %+v, want 1 per 1m0s", vars.Policies)
+	}
+
+	Publish("ratelimiter-test-publish-keyed", keyed)
+	var keyedVars map[string]any
+	json.Unmarshal([]byte(expvar.Get("ratelimiter-test-publish-keyed").String()), &keyedVars)
+	for _, name := range []string{"keys", "memory", "evictions"} {
+		if _, ok := keyedVars[name]; !ok {
+			t.Errorf("%s not published for a Keyed limiter", name)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package ratelimiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashKey returns a short stable digest of key, so telemetry can tell keys
+// apart without recording client addresses or user IDs. Small key spaces such
+// as IPv4 addresses can still be brute forced from their digest.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,228 @@
+// Package headers parses the rate limit headers sent by HTTP servers into a
+// normalized Status, so clients can respect the budget servers give them
+// whatever the convention they follow:
+//
+//   - Retry-After, in seconds or as an HTTP-date.
+//   - X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset, with the
+//     reset in seconds or as a Unix timestamp.
+//   - RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+//     RateLimit-Policy of the earlier IETF httpapi drafts.
+//   - RateLimit and RateLimit-Policy structured fields of the current IETF
+//     httpapi draft, e.g. RateLimit: "default";r=50;t=30.
+package headers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the state of the budget of a client as reported by a server.
+type Status struct {
+	Policy        string        // Name of the policy the status describes, empty if unnamed.
+	Limit         int           // Maximum number of requests in the window, zero if not reported.
+	Window        time.Duration // Duration of the window, zero if not reported.
+	Remaining     int           // Number of requests that can still be made in the window.
+	Reset         time.Duration // Time until the budget is replenished.
+	HasQuota      bool          // Whether Remaining and Reset were reported.
+	RetryAfter    time.Duration // Time the server asked the client to wait before retrying.
+	HasRetryAfter bool          // Whether RetryAfter was reported.
+}
+
+// Exhausted reports whether the server said the budget of the client is used up.
+func (s Status) Exhausted() bool {
+	return s.HasQuota && s.Remaining <= 0
+}
+
+// Parse parses the rate limit headers of h received at now. When several
+// conventions or policies are reported, the most restrictive policy wins.
+func Parse(h http.Header, now time.Time) Status {
+	var status Status
+	status.RetryAfter, status.HasRetryAfter = ParseRetryAfter(h.Get("Retry-After"), now)
+
+	var candidates []Status
+	candidates = append(candidates, parseStructured(h)...)
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if s, ok := parsePrefixed(h, prefix, now); ok {
+			candidates = append(candidates, s)
+		}
+	}
+	for _, c := range candidates {
+		if !status.HasQuota || restrictive(c, status) {
+			c.RetryAfter, c.HasRetryAfter = status.RetryAfter, status.HasRetryAfter
+			status = c
+		}
+	}
+	return status
+}
+
+// ParseRetryAfter parses a Retry-After value given in seconds or as an
+// HTTP-date, relative to now.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// ParseReset parses a reset value given either in seconds from now or, as some
+// APIs do for X-RateLimit-Reset, as a Unix timestamp.
+func ParseReset(value string, now time.Time) (time.Duration, bool) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// No window is anywhere close to 30 years, larger values are timestamps.
+	if seconds > 1e9 {
+		return time.Unix(seconds, 0).Sub(now), true
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// restrictive reports whether a leaves the client less room than b.
+func restrictive(a, b Status) bool {
+	if a.Remaining != b.Remaining {
+		return a.Remaining < b.Remaining
+	}
+	return a.Reset > b.Reset
+}
+
+// parsePrefixed parses the Limit, Remaining, Reset and Policy headers sharing
+// prefix. The first member of list values is used.
+func parsePrefixed(h http.Header, prefix string, now time.Time) (Status, bool) {
+	remaining, err := strconv.Atoi(firstMember(h.Get(prefix + "Remaining")))
+	if err != nil {
+		return Status{}, false
+	}
+	reset, ok := ParseReset(firstMember(h.Get(prefix+"Reset")), now)
+	if !ok {
+		return Status{}, false
+	}
+
+	status := Status{Remaining: remaining, Reset: reset, HasQuota: true}
+	status.Limit, _ = strconv.Atoi(firstMember(h.Get(prefix + "Limit")))
+
+	// Earlier IETF drafts describe the policies as 100;w=60.
+	if policy := h.Get(prefix + "Policy"); policy != "" && !strings.HasPrefix(strings.TrimSpace(policy), `"`) {
+		if items := parseList(policy); len(items) > 0 {
+			if status.Limit == 0 {
+				status.Limit, _ = strconv.Atoi(items[0].value)
+			}
+			if w, err := strconv.Atoi(items[0].params["w"]); err == nil {
+				status.Window = time.Duration(w) * time.Second
+			}
+		}
+	}
+	return status, true
+}
+
+// parseStructured parses the RateLimit and RateLimit-Policy structured fields,
+// returning a status per policy.
+func parseStructured(h http.Header) []Status {
+	value := strings.Join(h.Values("RateLimit"), ",")
+	if value == "" {
+		return nil
+	}
+
+	policies := make(map[string]item)
+	for _, p := range parseList(strings.Join(h.Values("RateLimit-Policy"), ",")) {
+		policies[p.value] = p
+	}
+
+	var statuses []Status
+	for _, it := range parseList(value) {
+		remaining, err := strconv.Atoi(it.params["r"])
+		if err != nil {
+			continue
+		}
+		status := Status{Policy: it.value, Remaining: remaining, HasQuota: true}
+		if t, err := strconv.Atoi(it.params["t"]); err == nil {
+			status.Reset = time.Duration(t) * time.Second
+		}
+		if p, ok := policies[it.value]; ok {
+			status.Limit, _ = strconv.Atoi(p.params["q"])
+			if w, err := strconv.Atoi(p.params["w"]); err == nil {
+				status.Window = time.Duration(w) * time.Second
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// item is a member of a structured field list: a bare or quoted value followed
+// by parameters.
+type item struct {
+	value  string
+	params map[string]string
+}
+
+// parseList parses a structured field list leniently, accepting quoted strings,
+// tokens and integers as values and parameter values.
+func parseList(value string) []item {
+	var items []item
+	for _, member := range splitOutsideQuotes(value, ',') {
+		parts := splitOutsideQuotes(member, ';')
+		it := item{value: unquote(parts[0]), params: make(map[string]string)}
+		for _, param := range parts[1:] {
+			k, v, _ := strings.Cut(param, "=")
+			it.params[strings.TrimSpace(k)] = unquote(v)
+		}
+		if it.value != "" || len(it.params) > 0 {
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+// splitOutsideQuotes splits s around the occurrences of sep that are not inside
+// a quoted string.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote trims s and removes the quotes and escapes of a quoted string.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// firstMember returns the first member of a list value, without parameters.
+func firstMember(value string) string {
+	value, _, _ = strings.Cut(value, ",")
+	value, _, _ = strings.Cut(value, ";")
+	return strings.TrimSpace(value)
+}
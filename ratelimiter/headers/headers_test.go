@@ -0,0 +1,98 @@
+package headers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   Status
+	}{
+		{
+			name:   "legacy",
+			header: http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"42"}, "X-Ratelimit-Reset": {"30"}},
+			want:   Status{Limit: 100, Remaining: 42, Reset: 30 * time.Second, HasQuota: true},
+		},
+		{
+			name:   "legacy with timestamp reset",
+			header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1714564860"}},
+			want:   Status{Remaining: 0, Reset: time.Minute, HasQuota: true},
+		},
+		{
+			name:   "earlier draft",
+			header: http.Header{"Ratelimit-Remaining": {"5"}, "Ratelimit-Reset": {"10"}, "Ratelimit-Policy": {"50;w=60"}},
+			want:   Status{Limit: 50, Window: time.Minute, Remaining: 5, Reset: 10 * time.Second, HasQuota: true},
+		},
+		{
+			name: "structured",
+			header: http.Header{
+				"Ratelimit":        {`"default";r=50;t=30`},
+				"Ratelimit-Policy": {`"default";q=100;w=60`},
+			},
+			want: Status{Policy: "default", Limit: 100, Window: time.Minute, Remaining: 50, Reset: 30 * time.Second, HasQuota: true},
+		},
+		{
+			name: "most restrictive policy",
+			header: http.Header{
+				"Ratelimit":        {`"minute";r=10;t=30, "day";r=3;t=3600`},
+				"Ratelimit-Policy": {`"minute";q=60;w=60, "day";q=1000;w=86400`},
+			},
+			want: Status{Policy: "day", Limit: 1000, Window: 24 * time.Hour, Remaining: 3, Reset: time.Hour, HasQuota: true},
+		},
+		{
+			name:   "retry after",
+			header: http.Header{"Retry-After": {"120"}, "X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"120"}},
+			want:   Status{Remaining: 0, Reset: 2 * time.Minute, HasQuota: true, RetryAfter: 2 * time.Minute, HasRetryAfter: true},
+		},
+		{
+			name:   "incomplete",
+			header: http.Header{"X-Ratelimit-Remaining": {"10"}},
+			want:   Status{},
+		},
+	}
+	for _, test := range tests {
+		if got := Parse(test.header, now); got != test.want {
+			t.Errorf("%s: status %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"Wed, 01 May 2024 12:01:30 GMT", 90 * time.Second, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		if got, ok := ParseRetryAfter(test.value, now); got != test.want || ok != test.ok {
+			t.Errorf("ParseRetryAfter(%q) = %s, %t, want %s, %t", test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	if (Status{}).Exhausted() {
+		t.Error("status without quota reported exhausted")
+	}
+	if !(Status{HasQuota: true}).Exhausted() {
+		t.Error("status without remaining requests not reported exhausted")
+	}
+}
+
+func TestParseList(t *testing.T) {
+	items := parseList(`"a,b";q=1;w="x;y", c`)
+	if len(items) != 2 || items[0].value != "a,b" || items[0].params["w"] != "x;y" || items[1].value != "c" {
+		t.Errorf("items %+v, want the separators in quotes ignored", items)
+	}
+}
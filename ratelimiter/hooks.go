@@ -0,0 +1,170 @@
+package ratelimiter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event describes a decision passed to the hooks of a Hooked limiter.
+type Event struct {
+	Key      string    // Key of the request.
+	Time     time.Time // Time of the request.
+	Cost     int       // Number of units the request cost.
+	Decision Decision  // Decision made for the request.
+}
+
+// HookOption configures a Hooked limiter.
+type HookOption func(*Hooked)
+
+// OnAllow adds a hook called with the allowed requests.
+func OnAllow(hook func(Event)) HookOption {
+	return func(h *Hooked) {
+		h.onAllow = append(h.onAllow, hook)
+	}
+}
+
+// OnDeny adds a hook called with the denied requests.
+func OnDeny(hook func(Event)) HookOption {
+	return func(h *Hooked) {
+		h.onDeny = append(h.onDeny, hook)
+	}
+}
+
+// WithHookBuffer sets the number of events held while the hooks are busy. It
+// defaults to 1024.
+func WithHookBuffer(size int) HookOption {
+	return func(h *Hooked) {
+		h.bufferSize = size
+	}
+}
+
+// Hooked is a Limiter passing the decisions of another limiter to hooks, e.g.
+// to feed alerting, billing or abuse detection pipelines. The hooks run one at
+// a time on their own goroutine, so a slow hook never delays a decision: when
+// they fall behind by more than the buffer, events are dropped and counted.
+type Hooked struct {
+	limiter    Limiter       // The limiter making the decisions.
+	onAllow    []func(Event) // The hooks called with the allowed requests.
+	onDeny     []func(Event) // The hooks called with the denied requests.
+	bufferSize int           // The number of events held while the hooks are busy.
+	events     chan Event    // The events waiting for the hooks.
+	dropped    atomic.Uint64 // The number of events dropped since the hooks fell behind.
+	closeOnce  sync.Once     // Closes events once.
+	done       chan struct{} // Closed once the hooks returned for every event.
+}
+
+// NewHooked creates a new limiter passing the decisions of limiter to the
+// given hooks. Close must be called to stop the goroutine running the hooks.
+func NewHooked(limiter Limiter, opts ...HookOption) *Hooked {
+	h := &Hooked{
+		limiter:    limiter,
+		bufferSize: 1024,
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.events = make(chan Event, h.bufferSize)
+	go h.run()
+	return h
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (h *Hooked) Allow(key string, requestTime time.Time) Decision {
+	return h.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed. Decisions of zero cost only report the state
+// of the key and are not passed to the hooks.
+func (h *Hooked) AllowN(key string, requestTime time.Time, n int) Decision {
+	return h.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, passed to the wrapped
+// limiter.
+func (h *Hooked) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision {
+	return h.observe(key, requestTime, n, AllowNContext(ctx, h.limiter, key, requestTime, n))
+}
+
+// AllowNTimed is AllowNContext, also returning the time the wrapped limiter
+// spent waiting for its backend, if it has one.
+func (h *Hooked) AllowNTimed(ctx context.Context, key string, requestTime time.Time, n int) (Decision, time.Duration, bool) {
+	decision, backend, timed := AllowNTimed(ctx, h.limiter, key, requestTime, n)
+	return h.observe(key, requestTime, n, decision), backend, timed
+}
+
+// observe queues the event of the decision for the hooks, and returns the
+// decision.
+func (h *Hooked) observe(key string, requestTime time.Time, n int, decision Decision) Decision {
+	if n == 0 || decision.Allowed && len(h.onAllow) == 0 || !decision.Allowed && len(h.onDeny) == 0 {
+		return decision
+	}
+
+	event := Event{Key: strings.Clone(key), Time: requestTime, Cost: n, Decision: decision}
+	select {
+	case h.events <- event:
+	default:
+		h.dropped.Add(1)
+	}
+	return decision
+}
+
+// Dropped returns the number of events dropped because the hooks fell behind.
+func (h *Hooked) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// Close waits for the hooks to handle the pending events and stops their
+// goroutine. The limiter must not be used afterwards.
+func (h *Hooked) Close() {
+	h.closeOnce.Do(func() {
+		close(h.events)
+	})
+	<-h.done
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (h *Hooked) Policies() []Policy {
+	if reporter, ok := h.limiter.(PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (h *Hooked) Healthy() error {
+	if checker, ok := h.limiter.(HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (h *Hooked) Peek(key string, requestTime time.Time) (Decision, bool) {
+	if peeker, ok := h.limiter.(Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return Decision{}, false
+}
+
+// run calls the hooks with the events until Close.
+func (h *Hooked) run() {
+	defer close(h.done)
+
+	for event := range h.events {
+		hooks := h.onDeny
+		if event.Decision.Allowed {
+			hooks = h.onAllow
+		}
+		for _, hook := range hooks {
+			hook(event)
+		}
+	}
+}
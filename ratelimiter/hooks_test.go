@@ -0,0 +1,51 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHooked(t *testing.T) {
+	var allowed, denied []Event
+	limiter := NewHooked(NewKeyed(func() Algorithm { return NewSlidingWindow(2, time.Minute) }),
+		OnAllow(func(e Event) { allowed = append(allowed, e) }),
+		OnDeny(func(e Event) { denied = append(denied, e) }),
+	)
+	now := time.Now()
+	limiter.Allow("a", now)
+	limiter.AllowN("a", now, 0)
+	limiter.Allow("a", now)
+	limiter.AllowN("a", now, 3)
+	limiter.Close()
+
+	// Decisions of zero cost are not passed to the hooks.
+	if len(allowed) != 2 || allowed[1].Decision.Remaining != 0 {
+		t.Errorf("allowed events %+v, want the two requests", allowed)
+	}
+	if len(denied) != 1 || denied[0].Key != "a" || denied[0].Cost != 3 || !denied[0].Time.Equal(now) || denied[0].Decision.Allowed {
+		t.Errorf("denied events %+v, want the request of cost 3", denied)
+	}
+}
+
+func TestHookedDropsEvents(t *testing.T) {
+	release := make(chan struct{})
+	limiter := NewHooked(NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) }),
+		OnAllow(func(e Event) { <-release }),
+		WithHookBuffer(2),
+	)
+	now := time.Now()
+	start := time.Now()
+	for range 10 {
+		limiter.Allow("a", now)
+	}
+	// A slow hook never delays a decision.
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("decisions took %s behind a blocked hook", elapsed)
+	}
+	close(release)
+	limiter.Close()
+	// One event is being handled and two are buffered when the others arrive.
+	if dropped := limiter.Dropped(); dropped < 7 || dropped > 8 {
+		t.Errorf("%d events dropped, want 7 or 8", dropped)
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimiter
+
+import (
+	"maps"
+	"time"
+)
+
+// State is the internal state of an algorithm instance, as reported to
+// debugging tools. Only the fields of the algorithm are set.
+type State struct {
+	Algorithm  string        // Name of the algorithm, see Algorithms.
+	Limit      int           // Maximum number of requests allowed in the window.
+	Window     time.Duration // Duration of the window.
+	Counts     map[int64]int // Sliding window: number of requests counted in each second, by Unix time.
+	Level      float64       // Leaky bucket: amount of requests in the bucket at LastUpdate.
+	Tokens     float64       // Token bucket: number of tokens in the bucket at LastUpdate.
+	Burst      int           // Token bucket: maximum number of tokens in the bucket.
+	LastUpdate time.Time     // Leaky and token buckets: last time the bucket was updated.
+	Start      time.Time     // Calendar window: start of the current window.
+	Count      int           // Calendar window: number of requests counted in the current window.
+	Reserved   []int         // Calendar window: number of requests reserved in each of the next windows.
+}
+
+// Inspector is implemented by the algorithms able to report their internal state.
+type Inspector interface {
+	// Inspect returns the internal state of the algorithm, without updating it.
+	Inspect() State
+}
+
+// Inspect returns the internal state of the sliding window.
+func (rl *SlidingWindow) Inspect() State {
+	return State{
+		Algorithm: SlidingWindowAlgorithm,
+		Limit:     rl.rate,
+		Window:    rl.windowDuration,
+		Counts:    maps.Clone(rl.requests),
+	}
+}
+
+// Inspect returns the internal state of the leaky bucket.
+func (lb *LeakyBucket) Inspect() State {
+	return State{
+		Algorithm:  LeakyBucketAlgorithm,
+		Limit:      int(lb.capacity),
+		Window:     lb.windowDuration,
+		Level:      lb.current,
+		LastUpdate: lb.lastUpdate,
+	}
+}
+
+// Inspect returns the internal state of the token bucket.
+func (tb *TokenBucket) Inspect() State {
+	return State{
+		Algorithm:  TokenBucketAlgorithm,
+		Limit:      int(tb.rate),
+		Window:     tb.windowDuration,
+		Tokens:     tb.tokens,
+		Burst:      int(tb.burst),
+		LastUpdate: tb.lastUpdate,
+	}
+}
+
+// Inspect returns the internal state of the algorithm instance of key, and
+// false if the key is not tracked or its algorithm cannot report its state.
+func (k *Keyed) Inspect(key string) (State, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	inspector, ok := k.algorithms[key].(Inspector)
+	if !ok {
+		return State{}, false
+	}
+	return inspector.Inspect(), true
+}
@@ -0,0 +1,47 @@
+package ratelimiter
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	sw := NewSlidingWindow(10, time.Minute)
+	sw.Allow(epoch)
+	sw.Allow(epoch)
+	sw.Allow(epoch.Add(time.Second))
+	if state := sw.Inspect(); state.Algorithm != SlidingWindowAlgorithm || state.Limit != 10 || state.Window != time.Minute ||
+		!maps.Equal(state.Counts, map[int64]int{epoch.Unix(): 2, epoch.Unix() + 1: 1}) {
+		t.Errorf("sliding window state %+v, want 2 then 1 requests", state)
+	}
+
+	lb := NewLeakyBucket(10, time.Minute)
+	lb.Allow(epoch)
+	if state := lb.Inspect(); state.Algorithm != LeakyBucketAlgorithm || state.Level != 1 || !state.LastUpdate.Equal(epoch) {
+		t.Errorf("leaky bucket state %+v, want one request at the epoch", state)
+	}
+
+	tb := NewTokenBucket(10, time.Minute, 5)
+	tb.Allow(epoch)
+	if state := tb.Inspect(); state.Algorithm != TokenBucketAlgorithm || state.Tokens != 4 || state.Burst != 5 || !state.LastUpdate.Equal(epoch) {
+		t.Errorf("token bucket state %+v, want 4 tokens of 5 left", state)
+	}
+}
+
+func TestKeyedInspect(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(10, time.Minute) })
+	limiter.Allow("a", epoch)
+	state, ok := limiter.Inspect("a")
+	if !ok || state.Counts[epoch.Unix()] != 1 {
+		t.Errorf("state of a %+v, %t, want one request", state, ok)
+	}
+	// The reported state is a copy.
+	state.Counts[epoch.Unix()] = 5
+	if state, _ := limiter.Inspect("a"); state.Counts[epoch.Unix()] != 1 {
+		t.Error("state of a changed through its copy")
+	}
+	if _, ok := limiter.Inspect("b"); ok {
+		t.Error("state reported for an untracked key")
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimiter
+
+import (
+	"math"
+	"time"
+)
+
+// LeakyBucket implements the leaky bucket algorithm.
+type LeakyBucket struct {
+	capacity       float64       // The maximum capacity of the bucket.
+	windowDuration time.Duration // The duration of the sliding window.
+	lastUpdate     time.Time     // The last time the bucket was updated.
+	current        float64       // The current amount of requests in the bucket.
+}
+
+// NewLeakyBucket creates a new leaky bucket rate limiter instance.
+func NewLeakyBucket(rate int, windowDuration time.Duration) *LeakyBucket {
+	return &LeakyBucket{
+		capacity:       float64(rate),
+		windowDuration: windowDuration,
+		current:        0,
+	}
+}
+
+// Allow determines whether a new request at requestTime should be allowed.
+func (lb *LeakyBucket) Allow(requestTime time.Time) Decision {
+	return lb.AllowN(requestTime, 1)
+}
+
+// AllowN determines whether a new request costing n units at requestTime should
+// be allowed. A request costing more than the capacity is never allowed.
+func (lb *LeakyBucket) AllowN(requestTime time.Time, n int) Decision {
+	lb.leak(requestTime)
+
+	// If the bucket has room for the request, allow it and update the bucket current amount.
+	allowed := math.Ceil(lb.current)+float64(n) <= lb.capacity
+	if allowed {
+		lb.current += float64(n)
+	}
+
+	decision := Decision{
+		Allowed:    allowed,
+		Limit:      int(lb.capacity),
+		Remaining:  max(int(lb.capacity-math.Ceil(lb.current)), 0),
+		ResetAfter: durationFromSeconds(lb.current / lb.leakRate()),
+		Window:     lb.windowDuration,
+		Algorithm:  LeakyBucketAlgorithm,
+	}
+	if !allowed {
+		decision.Reason = ReasonRateLimit
+		// The request would be allowed once the bucket has leaked enough to hold it.
+		decision.RetryAfter = durationFromSeconds((lb.current - (lb.capacity - float64(n))) / lb.leakRate())
+	}
+	return decision
+}
+
+// Reserve accounts for a new request at requestTime even if the bucket is full,
+// and returns how long the caller must wait before sending it.
+func (lb *LeakyBucket) Reserve(requestTime time.Time) time.Duration {
+	decision := lb.Allow(requestTime)
+	if decision.Allowed {
+		return 0
+	}
+
+	// Let the bucket overflow, the request will be sent once it has leaked out.
+	lb.current++
+	return decision.RetryAfter
+}
+
+// leak drains the bucket based on the time elapsed since the last update.
+func (lb *LeakyBucket) leak(requestTime time.Time) {
+	// Calculate time elapsed since the last request.
+	elapsed := requestTime.Sub(lb.lastUpdate).Seconds() / lb.windowDuration.Seconds()
+
+	// Leak the bucket based on the elapsed time.
+	lb.current -= elapsed * lb.capacity
+	if lb.current < 0 {
+		lb.current = 0
+	}
+	lb.lastUpdate = requestTime
+}
+
+// leakRate returns the amount of requests leaking out of the bucket per second.
+func (lb *LeakyBucket) leakRate() float64 {
+	return lb.capacity / lb.windowDuration.Seconds()
+}
@@ -0,0 +1,57 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeakyBucket(t *testing.T) {
+	lb := NewLeakyBucket(2, 10*time.Second)
+	tests := []struct {
+		offset    time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{0, true, 1},
+		{0, true, 0},
+		{time.Second, false, 0},
+		// One request leaks out every 5 seconds.
+		{5 * time.Second, true, 0},
+		{20 * time.Second, true, 1},
+	}
+	for _, test := range tests {
+		decision := lb.Allow(epoch.Add(test.offset))
+		if decision.Allowed != test.allowed || decision.Remaining != test.remaining {
+			t.Errorf("at +%s: allowed %t with %d remaining, want %t with %d", test.offset, decision.Allowed, decision.Remaining, test.allowed, test.remaining)
+		}
+	}
+}
+
+func TestLeakyBucketRetryAfter(t *testing.T) {
+	lb := NewLeakyBucket(2, 10*time.Second)
+	lb.AllowN(epoch, 2)
+
+	decision := lb.Allow(epoch.Add(time.Second))
+	if decision.Reason != ReasonRateLimit || decision.RetryAfter != 4*time.Second {
+		t.Errorf("decision %+v, want denied for 4s", decision)
+	}
+	if decision.ResetAfter != 9*time.Second {
+		t.Errorf("reset after %s, want 9s", decision.ResetAfter)
+	}
+	if decision := lb.AllowN(epoch.Add(time.Second), 3); decision.Allowed {
+		t.Error("request costing more than the capacity allowed")
+	}
+}
+
+func TestLeakyBucketReserve(t *testing.T) {
+	lb := NewLeakyBucket(1, time.Second)
+	if wait := lb.Reserve(epoch); wait != 0 {
+		t.Errorf("first reservation waits %s, want none", wait)
+	}
+	// Every reservation queues behind the previous one.
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		if wait := lb.Reserve(epoch); wait != want {
+			t.Errorf("reservation %d waits %s, want %s", i, wait, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package ratelimiter
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ListenerOption configures a Listener.
+type ListenerOption func(*Listener)
+
+// WithConnRate limits the rate of new connections of every remote IP address
+// with limiter.
+func WithConnRate(limiter Limiter) ListenerOption {
+	return func(l *Listener) {
+		l.limiter = limiter
+	}
+}
+
+// WithMaxConnsPerIP limits every remote IP address to n open connections.
+func WithMaxConnsPerIP(n int) ListenerOption {
+	return func(l *Listener) {
+		l.maxConns = n
+	}
+}
+
+// WithAcceptDelay delays the connections over the rate until the limiter allows
+// them, as long as they would be allowed within maxDelay, instead of closing
+// them right away. Delayed connections do not hold back the others.
+func WithAcceptDelay(maxDelay time.Duration) ListenerOption {
+	return func(l *Listener) {
+		l.maxDelay = maxDelay
+	}
+}
+
+// Listener is a net.Listener limiting the connections of every remote IP
+// address, so TCP-level floods are turned away before any HTTP parsing.
+// Rejected connections are closed as soon as they are accepted.
+type Listener struct {
+	net.Listener
+	limiter  Limiter       // The limiter of the connection rate, nil for no limit.
+	maxConns int           // The number of open connections of an address, zero for no limit.
+	maxDelay time.Duration // How long connections over the rate may be delayed, zero to close them.
+
+	startOnce sync.Once         // Starts the accept loop.
+	accepted  chan acceptResult // The connections ready to be returned by Accept.
+	done      chan struct{}     // Closed once the listener is closed.
+	closeOnce sync.Once         // Closes done.
+	failed    chan struct{}     // Closed once the wrapped listener failed.
+	err       error             // The error the wrapped listener failed with, valid once failed is closed.
+
+	mu    sync.Mutex     // Protects conns.
+	conns map[string]int // Map to hold the number of open connections of each address.
+}
+
+// acceptResult is the outcome of an accept of the wrapped listener.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// NewListener creates a new listener limiting the connections accepted by l.
+func NewListener(l net.Listener, opts ...ListenerOption) *Listener {
+	ln := &Listener{
+		Listener: l,
+		accepted: make(chan acceptResult),
+		done:     make(chan struct{}),
+		failed:   make(chan struct{}),
+		conns:    make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(ln)
+	}
+	return ln
+}
+
+// Accept waits for and returns the next connection allowed by the limits.
+func (l *Listener) Accept() (net.Conn, error) {
+	l.startOnce.Do(func() { go l.acceptLoop() })
+
+	select {
+	case result := <-l.accepted:
+		return result.conn, result.err
+	case <-l.failed:
+		return nil, l.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the wrapped listener.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// acceptLoop accepts the connections of the wrapped listener and hands the
+// allowed ones to Accept until the listener fails.
+func (l *Listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				l.deliver(acceptResult{err: err})
+				continue
+			}
+			l.err = err
+			close(l.failed)
+			return
+		}
+
+		ip := remoteIP(conn)
+		release, ok := l.acquire(ip)
+		if !ok {
+			conn.Close()
+			continue
+		}
+		conn = &limitedConn{Conn: conn, release: release}
+
+		if l.limiter == nil {
+			l.deliver(acceptResult{conn: conn})
+			continue
+		}
+		now := time.Now()
+		decision := l.limiter.Allow(ip, now)
+		switch {
+		case decision.Allowed:
+			l.deliver(acceptResult{conn: conn})
+		case l.maxDelay > 0 && decision.RetryAfter <= l.maxDelay:
+			go l.delay(conn, ip, decision.RetryAfter)
+		default:
+			conn.Close()
+		}
+	}
+}
+
+// delay hands conn to Accept once the limiter allows it, or closes it.
+func (l *Listener) delay(conn net.Conn, ip string, retryAfter time.Duration) {
+	deadline := time.Now().Add(l.maxDelay)
+	for {
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-timer.C:
+		case <-l.done:
+			timer.Stop()
+			conn.Close()
+			return
+		}
+
+		now := time.Now()
+		decision := l.limiter.Allow(ip, now)
+		if decision.Allowed {
+			l.deliver(acceptResult{conn: conn})
+			return
+		}
+		if now.Add(decision.RetryAfter).After(deadline) {
+			conn.Close()
+			return
+		}
+		retryAfter = decision.RetryAfter
+	}
+}
+
+// deliver hands result to Accept, or drops it once the listener is closed.
+func (l *Listener) deliver(result acceptResult) {
+	select {
+	case l.accepted <- result:
+	case <-l.done:
+		if result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
+
+// acquire takes a connection slot of ip, returning the function releasing it
+// and false if ip already uses all its slots.
+func (l *Listener) acquire(ip string) (func(), bool) {
+	if l.maxConns <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conns[ip] >= l.maxConns {
+		return nil, false
+	}
+	l.conns[ip]++
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.conns[ip]--; l.conns[ip] <= 0 {
+			delete(l.conns, ip)
+		}
+	}, true
+}
+
+// limitedConn is a connection releasing its slot when closed.
+type limitedConn struct {
+	net.Conn
+	release   func()    // Releases the slot of the connection.
+	closeOnce sync.Once // Releases the slot once.
+}
+
+func (c *limitedConn) Close() error {
+	c.closeOnce.Do(c.release)
+	return c.Conn.Close()
+}
+
+// remoteIP returns the IP address of the remote end of conn.
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
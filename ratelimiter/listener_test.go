@@ -0,0 +1,95 @@
+package ratelimiter
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// listen starts a listener with opts on a local port, and returns it with a
+// function dialing it.
+func listen(t *testing.T, opts ...ListenerOption) (*Listener, func() net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ln := NewListener(l, opts...)
+	t.Cleanup(func() { ln.Close() })
+
+	dial := func() net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	return ln, dial
+}
+
+// closed reports whether the server closed conn.
+func closed(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err := conn.Read(make([]byte, 1))
+	var ne net.Error
+	return err != nil && !(errors.As(err, &ne) && ne.Timeout())
+}
+
+// accept returns the next connection accepted by ln.
+func accept(t *testing.T, ln *Listener) net.Conn {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return conn
+}
+
+func TestListenerMaxConnsPerIP(t *testing.T) {
+	ln, dial := listen(t, WithMaxConnsPerIP(1))
+	first := dial()
+	server := accept(t, ln)
+	second := dial()
+	if !closed(second) {
+		t.Error("second connection of the address not closed")
+	}
+	if closed(first) {
+		t.Error("first connection of the address closed")
+	}
+
+	// The slot is freed when the connection is closed.
+	server.Close()
+	dial()
+	accept(t, ln).Close()
+}
+
+func TestListenerConnRate(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Hour) })
+	ln, dial := listen(t, WithConnRate(limiter))
+	dial()
+	accept(t, ln)
+	if !closed(dial()) {
+		t.Error("connection over the rate not closed")
+	}
+}
+
+func TestListenerAcceptDelay(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(1, 50*time.Millisecond) })
+	ln, dial := listen(t, WithConnRate(limiter), WithAcceptDelay(time.Second))
+	dial()
+	dial()
+	start := time.Now()
+	accept(t, ln)
+	accept(t, ln)
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("connections accepted within %s, want the second one delayed", elapsed)
+	}
+
+	ln.Close()
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept after Close: error %v, want %v", err, net.ErrClosed)
+	}
+}
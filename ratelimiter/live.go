@@ -0,0 +1,154 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LiveCounts are the numbers of decisions of a second.
+type LiveCounts struct {
+	Allowed int `json:"allowed"` // Number of allowed requests.
+	Denied  int `json:"denied"`  // Number of denied requests.
+}
+
+// LiveSample aggregates the decisions of a second.
+type LiveSample struct {
+	Time  time.Time             `json:"time"`  // Start of the second.
+	Total LiveCounts            `json:"total"` // Decisions of every rule.
+	Rules map[string]LiveCounts `json:"rules"` // Decisions of each rule.
+}
+
+// LiveStats aggregates decisions per second and streams the samples to
+// dashboards as server-sent events, so they can show the behavior of the
+// limiters in real time without scraping.
+type LiveStats struct {
+	mu          sync.Mutex
+	current     map[string]LiveCounts        // Map to hold the decisions of each rule in the current second.
+	subscribers map[chan LiveSample]struct{} // The channels of the connected clients.
+	stop        chan struct{}                // Closed to stop the sampling goroutine.
+	stopOnce    sync.Once                    // Closes stop once.
+}
+
+// NewLiveStats creates new live statistics, sampled every second on their own
+// goroutine until Close.
+func NewLiveStats() *LiveStats {
+	s := &LiveStats{
+		current:     make(map[string]LiveCounts),
+		subscribers: make(map[chan LiveSample]struct{}),
+		stop:        make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Observe counts a decision of the rule or limiter named rule.
+func (s *LiveStats) Observe(rule string, decision Decision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := s.current[rule]
+	if decision.Allowed {
+		counts.Allowed++
+	} else {
+		counts.Denied++
+	}
+	s.current[rule] = counts
+}
+
+// OnDecision returns a function counting the decisions of the middleware under
+// rule, for use with WithOnDecision.
+func (s *LiveStats) OnDecision(rule string) DecisionFunc {
+	return func(r *http.Request, key string, decision Decision) {
+		s.Observe(rule, decision)
+	}
+}
+
+// Close stops the sampling and ends the streams of the connected clients.
+func (s *LiveStats) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// ServeHTTP streams a sample every second as a server-sent event, until the
+// client goes away. Samples are skipped for clients too slow to read them.
+func (s *LiveStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	samples := make(chan LiveSample, 1)
+	s.mu.Lock()
+	s.subscribers[samples] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, samples)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case sample := <-samples:
+			data, err := json.Marshal(sample)
+			if err != nil {
+				return
+			}
+			if _, err := w.Write([]byte("data: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// run publishes a sample to the subscribers every second until Close.
+func (s *LiveStats) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	start := time.Now().Truncate(time.Second)
+	for {
+		select {
+		case now := <-ticker.C:
+			s.publish(start)
+			start = now.Truncate(time.Second)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// publish sends the decisions of the second started at start to the
+// subscribers, and starts counting the next second.
+func (s *LiveStats) publish(start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sample := LiveSample{Time: start, Rules: s.current}
+	for _, counts := range s.current {
+		sample.Total.Allowed += counts.Allowed
+		sample.Total.Denied += counts.Denied
+	}
+	s.current = make(map[string]LiveCounts, len(sample.Rules))
+
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- sample:
+		default:
+		}
+	}
+}
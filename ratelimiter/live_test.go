@@ -0,0 +1,59 @@
+package ratelimiter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveStats(t *testing.T) {
+	stats := NewLiveStats()
+	defer stats.Close()
+	server := httptest.NewServer(stats)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type %q, want text/event-stream", ct)
+	}
+	stats.Observe("login", Decision{Allowed: true})
+	stats.Observe("login", Decision{})
+	stats.Observe("api", Decision{Allowed: true})
+
+	// The decisions show up in the sample of the second they were made in.
+	events := bufio.NewScanner(resp.Body)
+	var sample LiveSample
+	for sample.Total.Allowed == 0 && events.Scan() {
+		data, ok := strings.CutPrefix(events.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal([]byte(data), &sample); err != nil {
+			t.Fatalf("event %q: %v", data, err)
+		}
+	}
+	if sample.Total != (LiveCounts{Allowed: 2, Denied: 1}) || sample.Rules["login"] != (LiveCounts{Allowed: 1, Denied: 1}) {
+		t.Errorf("sample %+v, want 2 allowed and 1 denied", sample)
+	}
+
+	// Close ends the streams.
+	stats.Close()
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, resp.Body)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("stream still open after Close")
+	}
+}
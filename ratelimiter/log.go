@@ -0,0 +1,207 @@
+package ratelimiter
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+)
+
+// LogOption configures a Logger.
+type LogOption func(*Logger)
+
+// WithLogLevel sets the level of the records of denials. It defaults to
+// slog.LevelInfo, since denials are expected under load.
+func WithLogLevel(level slog.Leveler) LogOption {
+	return func(l *Logger) {
+		l.level = level
+	}
+}
+
+// WithLogSampling logs only one denial out of every n, so a client hammering
+// the service does not flood the logs.
+func WithLogSampling(n int) LogOption {
+	return func(l *Logger) {
+		l.sampling = uint64(max(n, 1))
+	}
+}
+
+// WithDebugSampling logs the full details of one decision out of every n at
+// debug level, allowed or not, to diagnose production issues without drowning
+// in logs. Debug records are disabled by default.
+func WithDebugSampling(n int) LogOption {
+	return func(l *Logger) {
+		l.debugSampling = uint64(max(n, 1))
+	}
+}
+
+// WithKeyRedaction sets the function applied to keys before they are logged.
+// Keys often are client addresses or user IDs, so it defaults to HashKey. Pass
+// a function returning the key as is to log raw keys.
+func WithKeyRedaction(redact func(key string) string) LogOption {
+	return func(l *Logger) {
+		l.redact = redact
+	}
+}
+
+// WithLogLimiter adds the internal state of the key of every debug record, as
+// reported by limiter, a Keyed or an Inspector, so the record shows why the
+// decision was made.
+func WithLogLimiter(limiter Limiter) LogOption {
+	return func(l *Logger) {
+		l.limiter = limiter
+	}
+}
+
+// Logger logs rate limit denials and policy changes as structured records.
+type Logger struct {
+	logger        *slog.Logger            // The logger writing the records.
+	level         slog.Leveler            // The level of the records of denials.
+	sampling      uint64                  // One denial out of sampling is logged.
+	debugSampling uint64                  // One decision out of debugSampling is logged at debug level, zero for none.
+	redact        func(key string) string // The function applied to keys before they are logged.
+	limiter       Limiter                 // The limiter whose state is added to the debug records, if any.
+	denials       atomic.Uint64           // Counts the denials, for sampling.
+	decisions     atomic.Uint64           // Counts the decisions, for debug sampling.
+}
+
+// NewLogger creates a new logger writing its records with logger, or with
+// slog.Default if logger is nil.
+func NewLogger(logger *slog.Logger, opts ...LogOption) *Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	l := &Logger{
+		logger:   logger,
+		level:    slog.LevelInfo,
+		sampling: 1,
+		redact:   HashKey,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Denial logs the denial of a request for key, subject to sampling.
+func (l *Logger) Denial(ctx context.Context, key string, decision Decision, attrs ...slog.Attr) {
+	if (l.denials.Add(1)-1)%l.sampling != 0 || !l.logger.Enabled(ctx, l.level.Level()) {
+		return
+	}
+	attrs = append([]slog.Attr{
+		slog.String("key", l.redact(key)),
+		slog.String("reason", string(decision.Reason)),
+		slog.String("algorithm", decision.Algorithm),
+		slog.Int("limit", decision.Limit),
+		slog.Duration("window", decision.Window),
+		slog.Duration("retry_after", decision.RetryAfter),
+	}, attrs...)
+	l.logger.LogAttrs(ctx, l.level.Level(), "rate limit exceeded", attrs...)
+}
+
+// Debug logs every detail of the decision made for key at debug level, subject
+// to the debug sampling.
+func (l *Logger) Debug(ctx context.Context, key string, decision Decision, attrs ...slog.Attr) {
+	if l.debugSampling == 0 || (l.decisions.Add(1)-1)%l.debugSampling != 0 || !l.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs = append([]slog.Attr{
+		slog.String("key", l.redact(key)),
+		slog.Bool("allowed", decision.Allowed),
+		slog.String("reason", string(decision.Reason)),
+		slog.String("algorithm", decision.Algorithm),
+		slog.Int("limit", decision.Limit),
+		slog.Int("remaining", decision.Remaining),
+		slog.Duration("window", decision.Window),
+		slog.Duration("reset_after", decision.ResetAfter),
+		slog.Duration("retry_after", decision.RetryAfter),
+	}, attrs...)
+	if state, ok := l.inspect(key); ok {
+		attrs = append(attrs, stateAttr(state))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "rate limit decision", attrs...)
+}
+
+// inspect returns the internal state of key in the limiter of the logger, and
+// false if it cannot report it.
+func (l *Logger) inspect(key string) (State, bool) {
+	switch limiter := l.limiter.(type) {
+	case *Keyed:
+		return limiter.Inspect(key)
+	case Inspector:
+		return limiter.Inspect(), true
+	default:
+		return State{}, false
+	}
+}
+
+// stateAttr returns the fields of state set by its algorithm, as a group.
+func stateAttr(state State) slog.Attr {
+	attrs := []any{slog.String("algorithm", state.Algorithm), slog.Int("limit", state.Limit), slog.Duration("window", state.Window)}
+	switch state.Algorithm {
+	case SlidingWindowAlgorithm:
+		total := 0
+		for _, count := range state.Counts {
+			total += count
+		}
+		attrs = append(attrs, slog.Int("count", total), slog.Int("seconds", len(state.Counts)))
+	case LeakyBucketAlgorithm:
+		attrs = append(attrs, slog.Float64("level", state.Level), slog.Time("last_update", state.LastUpdate))
+	case TokenBucketAlgorithm:
+		attrs = append(attrs, slog.Float64("tokens", state.Tokens), slog.Int("burst", state.Burst), slog.Time("last_update", state.LastUpdate))
+	case CalendarWindowAlgorithm:
+		attrs = append(attrs, slog.Time("start", state.Start), slog.Int("count", state.Count), slog.Any("reserved", state.Reserved))
+	}
+	return slog.Group("state", attrs...)
+}
+
+// PolicyChange logs that key is now limited by policy, e.g. after its limit was
+// changed through the admin API. Policy changes are never sampled.
+func (l *Logger) PolicyChange(ctx context.Context, key string, policy Policy, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("key", l.redact(key)),
+		slog.Int("limit", policy.Limit),
+		slog.Duration("window", policy.Window),
+	}, attrs...)
+	if policy.Name != "" {
+		attrs = append(attrs, slog.String("policy", policy.Name))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelInfo, "rate limit policy changed", attrs...)
+}
+
+// RateChange logs a change of the rate enforced by a limiter. Rate changes are
+// never sampled.
+func (l *Logger) RateChange(ctx context.Context, change RateChange, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("trigger", change.Trigger),
+		slog.Int("old_limit", change.Old.Limit),
+		slog.Duration("old_window", change.Old.Window),
+		slog.Int("new_limit", change.New.Limit),
+		slog.Duration("new_window", change.New.Window),
+	}, attrs...)
+	if change.Key != "" {
+		attrs = append(attrs, slog.String("key", l.redact(change.Key)))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelInfo, "rate limit adjusted", attrs...)
+}
+
+// OnRateChange returns a function logging the rate changes of a limiter, for
+// use with RateChangeNotifier.
+func (l *Logger) OnRateChange() RateChangeFunc {
+	return func(change RateChange) {
+		l.RateChange(context.Background(), change)
+	}
+}
+
+// OnDecision returns a function logging the denials of the middleware, and
+// the sampled debug records of its decisions, along with the method and path
+// of the request, for use with WithOnDecision.
+func (l *Logger) OnDecision() DecisionFunc {
+	return func(r *http.Request, key string, decision Decision) {
+		method, path := slog.String("method", r.Method), slog.String("path", r.URL.Path)
+		if !decision.Allowed {
+			l.Denial(r.Context(), key, decision, method, path)
+		}
+		l.Debug(r.Context(), key, decision, method, path)
+	}
+}
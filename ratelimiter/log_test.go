@@ -0,0 +1,164 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoggerDebugState(t *testing.T) {
+	newAlgorithm, err := AlgorithmFactory(TokenBucketAlgorithm, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		limiter Limiter
+		state   bool
+	}{
+		{"keyed", NewKeyed(newAlgorithm), true},
+		{"without inspection", NewShadow(NewKeyed(newAlgorithm), true, nil), false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		logger := NewLogger(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			WithDebugSampling(1), WithLogLimiter(tt.limiter))
+
+		decision := tt.limiter.Allow("client", time.Now())
+		logger.Debug(context.Background(), "client", decision)
+
+		var record struct {
+			State *struct {
+				Algorithm string  `json:"algorithm"`
+				Tokens    float64 `json:"tokens"`
+				Burst     int     `json:"burst"`
+			} `json:"state"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+			t.Fatalf("%s: %v in %s", tt.name, err, out.String())
+		}
+		if (record.State != nil) != tt.state {
+			t.Fatalf("%s: state logged %t, want %t", tt.name, record.State != nil, tt.state)
+		}
+		if tt.state && (record.State.Algorithm != TokenBucketAlgorithm || record.State.Burst != 10 || record.State.Tokens < 8.9 || record.State.Tokens > 9.1) {
+			t.Errorf("%s: state = %+v, want the token bucket with 9 tokens left", tt.name, *record.State)
+		}
+	}
+}
+
+// records decodes the JSON records written to out.
+func records(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggerDenial(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(slog.New(slog.NewJSONHandler(&out, nil)), WithLogSampling(2), WithLogLevel(slog.LevelWarn))
+	decision := Decision{Limit: 10, Window: time.Minute, RetryAfter: time.Second, Reason: ReasonRateLimit, Algorithm: SlidingWindowAlgorithm}
+	for range 4 {
+		logger.Denial(context.Background(), "203.0.113.7", decision)
+	}
+
+	logged := records(t, &out)
+	if len(logged) != 2 {
+		t.Fatalf("%d denials logged, want one out of two", len(logged))
+	}
+	record := logged[0]
+	if record["level"] != "WARN" || record["msg"] != "rate limit exceeded" || record["reason"] != "rate_limit" || record["limit"] != 10.0 {
+		t.Errorf("record %v, want the denial at warn level", record)
+	}
+	// Keys are hashed by default.
+	if record["key"] != HashKey("203.0.113.7") {
+		t.Errorf("key %v, want its hash", record["key"])
+	}
+}
+
+func TestLoggerOnDecision(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(slog.New(slog.NewJSONHandler(&out, nil)), WithKeyRedaction(func(key string) string { return key }))
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	handler := Middleware(limiter, WithOnDecision(logger.OnDecision()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for range 2 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", nil))
+	}
+
+	// Only the denial is logged without debug sampling.
+	logged := records(t, &out)
+	if len(logged) != 1 || logged[0]["key"] != "192.0.2.1" || logged[0]["method"] != "POST" || logged[0]["path"] != "/orders" {
+		t.Errorf("records %v, want the denial with its request", logged)
+	}
+}
+
+func TestLoggerPolicyChange(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(slog.New(slog.NewJSONHandler(&out, nil)), WithLogSampling(100))
+	logger.PolicyChange(context.Background(), "acme", Policy{Name: "gold", Limit: 100, Window: time.Minute})
+	logger.PolicyChange(context.Background(), "acme", Policy{Limit: 10, Window: time.Minute})
+
+	// Policy changes are never sampled.
+	logged := records(t, &out)
+	if len(logged) != 2 || logged[0]["policy"] != "gold" || logged[0]["limit"] != 100.0 || logged[0]["window"] != float64(time.Minute) {
+		t.Fatalf("records %v, want both changes", logged)
+	}
+	if _, ok := logged[1]["policy"]; ok {
+		t.Errorf("record %v names an unnamed policy", logged[1])
+	}
+}
+
+func TestLoggerDebugSampling(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})), WithDebugSampling(3))
+	for i := range 6 {
+		logger.Debug(context.Background(), "client", Decision{Allowed: i%2 == 0, Remaining: i})
+	}
+
+	// One decision out of three is logged, allowed or not.
+	logged := records(t, &out)
+	if len(logged) != 2 || logged[0]["remaining"] != 0.0 || logged[1]["remaining"] != 3.0 || logged[1]["allowed"] != false {
+		t.Errorf("records %v, want the first and fourth decisions", logged)
+	}
+
+	// Debug records are dropped when the level is disabled.
+	out.Reset()
+	NewLogger(slog.New(slog.NewJSONHandler(&out, nil)), WithDebugSampling(1)).Debug(context.Background(), "client", Decision{})
+	if out.Len() != 0 {
+		t.Errorf("debug record %q logged at info level", out.String())
+	}
+}
+
+func TestLoggerRateChange(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(slog.New(slog.NewJSONHandler(&out, nil)), WithKeyRedaction(func(key string) string { return key }))
+	onRateChange := logger.OnRateChange()
+	onRateChange(RateChange{Trigger: "latency", Old: Policy{Limit: 100, Window: time.Second}, New: Policy{Limit: 50, Window: time.Second}})
+	onRateChange(RateChange{Key: "acme", Trigger: "errors", Old: Policy{Limit: 10, Window: time.Second}, New: Policy{Limit: 5, Window: time.Second}})
+
+	logged := records(t, &out)
+	if len(logged) != 2 {
+		t.Fatalf("%d records, want 2", len(logged))
+	}
+	if record := logged[0]; record["msg"] != "rate limit adjusted" || record["trigger"] != "latency" || record["old_limit"] != 100.0 || record["new_limit"] != 50.0 {
+		t.Errorf("record %v, want the global change", record)
+	}
+	if _, ok := logged[0]["key"]; ok {
+		t.Errorf("record %v of every key carries a key", logged[0])
+	}
+	if logged[1]["key"] != "acme" {
+		t.Errorf("record %v, want the change of acme", logged[1])
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithMaxInFlight limits every key to n requests being served at once, so the
+// clients of streaming or long-polling endpoints hold a slot for as long as
+// their requests last. Requests over the limit are denied, with a Retry-After of
+// one second since slots are freed whenever a request ends.
+func WithMaxInFlight(n int) Option {
+	return func(m *middleware) {
+		m.maxInFlight = n
+		m.inFlight = make(map[string]int)
+	}
+}
+
+// WithDurationCost charges a request one more unit for every interval it keeps
+// running after it was allowed, so clients holding long requests such as
+// server-sent events pay for the time they hold the server. The units are
+// charged even if the budget is exhausted when the limiter implements Reserver,
+// and only while budget is left otherwise.
+func WithDurationCost(interval time.Duration) Option {
+	return func(m *middleware) {
+		m.durationCost = interval
+	}
+}
+
+// acquire takes an in-flight slot for key, returning the function releasing it
+// and false if key already uses all its slots.
+func (m *middleware) acquire(key string) (func(), bool) {
+	if m.maxInFlight <= 0 {
+		return func() {}, true
+	}
+
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+
+	if m.inFlight[key] >= m.maxInFlight {
+		return nil, false
+	}
+	key = strings.Clone(key)
+	m.inFlight[key]++
+
+	return func() {
+		m.inFlightMu.Lock()
+		defer m.inFlightMu.Unlock()
+
+		if m.inFlight[key]--; m.inFlight[key] <= 0 {
+			delete(m.inFlight, key)
+		}
+	}, true
+}
+
+// inFlightDecision is the decision of a request denied because its key uses
+// all its in-flight slots.
+func (m *middleware) inFlightDecision() Decision {
+	return Decision{
+		Limit:      m.maxInFlight,
+		RetryAfter: time.Second,
+		ResetAfter: time.Second,
+		Reason:     ReasonConcurrency,
+	}
+}
+
+// serve serves r with next, charging limiter for the duration of the request
+// when WithDurationCost is set.
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request, limiter Limiter, key string) {
+	if m.durationCost <= 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(m.durationCost)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if reserver, ok := limiter.(Reserver); ok {
+					reserver.Reserve(key, now)
+				} else {
+					limiter.Allow(key, now)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	next.ServeHTTP(w, r)
+	close(done)
+	wg.Wait()
+}
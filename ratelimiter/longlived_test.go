@@ -0,0 +1,54 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithMaxInFlight(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })
+	started, finish := make(chan struct{}), make(chan struct{})
+	handler := Middleware(limiter, WithMaxInFlight(1))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			close(started)
+			<-finish
+		}
+	}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("request during the stream: status %d, Retry-After %q, want %d after 1s", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	// The slot is freed when the stream ends.
+	close(finish)
+	<-done
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("request after the stream: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestWithDurationCost(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })
+	handler := Middleware(limiter, WithDurationCost(10*time.Millisecond))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(55 * time.Millisecond)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// One unit for the request and one for every 10ms it ran.
+	decision, _ := limiter.Peek("192.0.2.1", time.Now())
+	if used := 100 - decision.Remaining; used < 4 || used > 7 {
+		t.Errorf("%d units used, want about 6", used)
+	}
+}
@@ -0,0 +1,119 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// Estimates of the memory held by the state of the limiters, on 64-bit
+// platforms, including the overhead of the maps holding it.
+const (
+	keyEntrySize       = 64 // A key in the map of a Keyed, excluding its bytes and algorithm.
+	algorithmSize      = 64 // An algorithm unable to report its size.
+	slidingWindowSize  = 96 // A SlidingWindow with an empty map of counters.
+	slidingWindowEntry = 32 // A counter of a SlidingWindow.
+	leakyBucketSize    = 48 // A LeakyBucket.
+	tokenBucketSize    = 64 // A TokenBucket.
+	calendarWindowSize = 96 // A CalendarWindow.
+)
+
+// Sizer is implemented by the algorithms able to estimate the memory they hold.
+type Sizer interface {
+	// Size returns the estimated number of bytes held by the algorithm.
+	Size() int
+}
+
+// Size returns the estimated number of bytes held by the sliding window.
+func (rl *SlidingWindow) Size() int {
+	return slidingWindowSize + len(rl.requests)*slidingWindowEntry
+}
+
+// Size returns the estimated number of bytes held by the leaky bucket.
+func (lb *LeakyBucket) Size() int {
+	return leakyBucketSize
+}
+
+// Size returns the estimated number of bytes held by the token bucket.
+func (tb *TokenBucket) Size() int {
+	return tokenBucketSize
+}
+
+// Size returns the estimated number of bytes held by the calendar window.
+func (cw *CalendarWindow) Size() int {
+	return calendarWindowSize
+}
+
+// MemoryUsage returns the estimated number of bytes held by the state of the
+// tracked keys, for capacity planning. It walks every key, so it should be
+// called at scrape time rather than on every request.
+func (k *Keyed) MemoryUsage() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	total := 0
+	for key, algorithm := range k.algorithms {
+		total += keyEntrySize + len(key)
+		if sizer, ok := algorithm.(Sizer); ok {
+			total += sizer.Size()
+		} else {
+			total += algorithmSize
+		}
+	}
+	return total
+}
+
+// Prune forgets the keys whose budget is fully replenished at now, as they
+// would be recreated in the same state, and returns how many were removed.
+// Keyed never forgets keys on its own: call Prune periodically to bound the
+// memory of high-cardinality workloads. Keys given their algorithm with
+// SetAlgorithm are kept.
+func (k *Keyed) Prune(now time.Time) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	pruned := 0
+	for key, algorithm := range k.algorithms {
+		if _, ok := k.overrides[key]; ok {
+			continue
+		}
+		if algorithm.AllowN(now, 0).ResetAfter <= 0 {
+			delete(k.algorithms, key)
+			pruned++
+		}
+	}
+	k.evictions += uint64(pruned)
+	return pruned
+}
+
+// DefaultPruneInterval is the interval at which the servers and adapters of
+// the module prune their in-memory limiters.
+const DefaultPruneInterval = time.Minute
+
+// PruneEvery prunes limiters every interval until ctx is done, so their memory
+// stays bounded by the keys still limited:
+//
+//	go ratelimiter.PruneEvery(ctx, ratelimiter.DefaultPruneInterval, limiter)
+func PruneEvery(ctx context.Context, interval time.Duration, limiters ...*Keyed) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, limiter := range limiters {
+				limiter.Prune(now)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Evictions returns the number of keys removed by Prune since the limiter was
+// created.
+func (k *Keyed) Evictions() uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.evictions
+}
@@ -0,0 +1,74 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(10, time.Minute) })
+	if usage := limiter.MemoryUsage(); usage != 0 {
+		t.Errorf("empty limiter holds %d bytes, want 0", usage)
+	}
+
+	now := time.Now()
+	limiter.Allow("a", now)
+	one := limiter.MemoryUsage()
+	if want := keyEntrySize + len("a") + slidingWindowSize + slidingWindowEntry; one != want {
+		t.Errorf("one key holds %d bytes, want %d", one, want)
+	}
+	// Requests counted in the same second share their counter.
+	limiter.Allow("a", now)
+	if usage := limiter.MemoryUsage(); usage != one {
+		t.Errorf("usage grew to %d bytes within a second, want %d", usage, one)
+	}
+	limiter.Allow("a", now.Add(time.Second))
+	if usage := limiter.MemoryUsage(); usage != one+slidingWindowEntry {
+		t.Errorf("usage %d bytes after a new second, want %d", usage, one+slidingWindowEntry)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(10, time.Minute) })
+	now := time.Now()
+	limiter.Allow("idle", now)
+	limiter.Allow("busy", now.Add(30*time.Second))
+	limiter.SetAlgorithm("override", NewSlidingWindow(100, time.Minute))
+
+	if pruned := limiter.Prune(now.Add(61 * time.Second)); pruned != 1 {
+		t.Errorf("%d keys pruned, want the idle one", pruned)
+	}
+	if limiter.Len() != 2 {
+		t.Errorf("keys %v left, want busy and override", limiter.Keys())
+	}
+	// Overrides are kept even once replenished.
+	limiter.Prune(now.Add(time.Hour))
+	if keys := limiter.Keys(); len(keys) != 1 || keys[0] != "override" {
+		t.Errorf("keys %v left, want override", keys)
+	}
+	if evictions := limiter.Evictions(); evictions != 2 {
+		t.Errorf("%d evictions, want 2", evictions)
+	}
+}
+
+func TestPruneEvery(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(10, time.Millisecond) })
+	limiter.Allow("a", time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		PruneEvery(ctx, 5*time.Millisecond, limiter)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for limiter.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if limiter.Len() != 0 {
+		t.Error("idle key not pruned")
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// OtherKey is the value of the key label of the decisions of the keys left
+// out by the KeyMode, or over the cap of WithKeyLabel.
+const OtherKey = "other"
+
+// KeyMode maps a key to the value of its label, and returns false for the keys
+// counted under OtherKey.
+type KeyMode func(key string) (string, bool)
+
+// KeyAllowlist labels the decisions of the listed keys with the keys
+// themselves, e.g. the API keys of the largest customers.
+func KeyAllowlist(keys ...string) KeyMode {
+	allowed := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		allowed[key] = struct{}{}
+	}
+	return func(key string) (string, bool) {
+		if _, ok := allowed[key]; !ok {
+			return "", false
+		}
+		return key, true
+	}
+}
+
+// KeyHash labels the decisions of every key with a digest of the key, see
+// ratelimiter.HashKey, so client addresses and user IDs stay out of the
+// metrics while keys can still be told apart.
+func KeyHash() KeyMode {
+	return func(key string) (string, bool) {
+		return ratelimiter.HashKey(key), true
+	}
+}
+
+// WithKeyLabel also counts the decisions of each key, in a key_decisions_total
+// metric with a key label named label whose values are given by mode. At most
+// maxValues values of the label are exported, the decisions of further keys
+// are counted under OtherKey, so per-key metrics cannot blow up the number of
+// series stored by Prometheus.
+func WithKeyLabel(label string, mode KeyMode, maxValues int) Option {
+	return func(c *config) {
+		c.keyLabel = label
+		c.keyMode = mode
+		c.maxKeyValues = maxValues
+	}
+}
+
+// keyLabeler counts the decisions of each key, under a bounded number of
+// label values.
+type keyLabeler struct {
+	decisions *prometheus.CounterVec // Counts the decisions by limiter, outcome and key.
+	mode      KeyMode                // The function mapping keys to label values.
+	maxValues int                    // The maximum number of label values, OtherKey aside.
+
+	mu     sync.RWMutex
+	values map[string]struct{} // Set of the label values exported.
+}
+
+// count counts a decision of the limiter named name for key.
+func (l *keyLabeler) count(name, key string, allowed bool) {
+	outcome := "allowed"
+	if !allowed {
+		outcome = "denied"
+	}
+	l.decisions.WithLabelValues(name, outcome, l.value(key)).Inc()
+}
+
+// value returns the label value of key.
+func (l *keyLabeler) value(key string) string {
+	value, ok := l.mode(key)
+	if !ok {
+		return OtherKey
+	}
+
+	l.mu.RLock()
+	_, seen := l.values[value]
+	l.mu.RUnlock()
+	if seen {
+		return value
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, seen := l.values[value]; seen {
+		return value
+	}
+	if len(l.values) >= l.maxValues {
+		return OtherKey
+	}
+	value = strings.Clone(value)
+	l.values[value] = struct{}{}
+	return value
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestKeyAllowlist(t *testing.T) {
+	m := New(WithKeyLabel("customer", KeyAllowlist("acme"), 10))
+	limiter := m.Instrument("api", newLimiter(1))
+	now := time.Now()
+	for _, key := range []string{"acme", "acme", "203.0.113.7", "198.51.100.1"} {
+		limiter.Allow(key, now)
+	}
+
+	decisions := m.perKey.decisions
+	for _, test := range []struct {
+		outcome, value string
+		want           float64
+	}{
+		{"allowed", "acme", 1},
+		{"denied", "acme", 1},
+		{"allowed", OtherKey, 2},
+	} {
+		if got := testutil.ToFloat64(decisions.WithLabelValues("api", test.outcome, test.value)); got != test.want {
+			t.Errorf("%s decisions of %s: %v, want %v", test.outcome, test.value, got, test.want)
+		}
+	}
+}
+
+func TestKeyLabelCap(t *testing.T) {
+	m := New(WithKeyLabel("key", KeyHash(), 2))
+	limiter := m.Instrument("api", newLimiter(10))
+	now := time.Now()
+	for _, key := range []string{"a", "b", "c", "d", "a"} {
+		limiter.Allow(key, now)
+	}
+
+	// Two hashed values and the other bucket.
+	if n := testutil.CollectAndCount(m.perKey.decisions); n != 3 {
+		t.Errorf("%d series, want 3", n)
+	}
+	if got := testutil.ToFloat64(m.perKey.decisions.WithLabelValues("api", "allowed", ratelimiter.HashKey("a"))); got != 2 {
+		t.Errorf("decisions of a: %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.perKey.decisions.WithLabelValues("api", "allowed", OtherKey)); got != 2 {
+		t.Errorf("decisions over the cap: %v, want 2", got)
+	}
+}
+
+func TestKeyLabelDisabled(t *testing.T) {
+	if New().perKey != nil {
+		t.Error("per-key decisions counted without WithKeyLabel")
+	}
+}
@@ -0,0 +1,390 @@
+// Package metrics exports Prometheus metrics of the decisions of limiters: the
+// allowed and denied requests, the latency of the decisions, the number of
+// tracked keys and the memory they hold, the evictions of idle keys and the
+// errors of the backends storing their state.
+//
+//	m := metrics.New(metrics.WithNamespace("myapp"))
+//	prometheus.MustRegister(m)
+//	limiter := m.Instrument("api", ratelimiter.NewKeyed(newAlgorithm))
+//
+// Every metric is labeled with the name given to the instrumented limiter. The
+// decision latency is also labeled with its phase: "local" for the time spent
+// in process, and "backend" for the round trips to the backend of the limiters
+// implementing ratelimiter.TimedLimiter.
+//
+// Keys are left out of the labels unless WithKeyLabel opts in, for a bounded
+// number of allowlisted or hashed keys.
+//
+// With WithExemplars, denials carry an exemplar, typically the ID of the trace
+// of the denied request, so a spike of denials can be drilled into from a
+// dashboard. Exemplars are only exported in the OpenMetrics format, e.g. with
+// promhttp.HandlerOpts{EnableOpenMetrics: true}.
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Option configures the metrics.
+type Option func(*config)
+
+// WithNamespace prefixes the names of the metrics with namespace.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets the subsystem of the names of the metrics. It defaults
+// to "ratelimiter".
+func WithSubsystem(subsystem string) Option {
+	return func(c *config) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric, e.g. the
+// region or the service the limiters run in.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithLimiterLabel sets the name of the label holding the name of the
+// instrumented limiter. It defaults to "limiter".
+func WithLimiterLabel(name string) Option {
+	return func(c *config) {
+		c.limiterLabel = name
+	}
+}
+
+// WithBuckets sets the buckets of the decision latency histogram, in seconds.
+// Decisions made in memory take microseconds, so it defaults to buckets from
+// 1µs to about 1s.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// ExemplarFunc returns the labels of the exemplar of a decision made for a
+// request carrying ctx, or nil for none.
+type ExemplarFunc func(ctx context.Context) prometheus.Labels
+
+// WithExemplars attaches the exemplar returned by exemplar to the denials and
+// their latency. With OpenTelemetry tracing, it would return the trace ID of
+// the request:
+//
+//	metrics.WithExemplars(func(ctx context.Context) prometheus.Labels {
+//		if span := trace.SpanContextFromContext(ctx); span.IsSampled() {
+//			return prometheus.Labels{"trace_id": span.TraceID().String()}
+//		}
+//		return nil
+//	})
+//
+// The context is the one of the HTTP request when the instrumented limiter is
+// used by ratelimiter.Middleware, see ratelimiter.ContextLimiter.
+func WithExemplars(exemplar ExemplarFunc) Option {
+	return func(c *config) {
+		c.exemplar = exemplar
+	}
+}
+
+type config struct {
+	namespace    string            // The namespace of the metric names.
+	subsystem    string            // The subsystem of the metric names.
+	constLabels  prometheus.Labels // The labels added to every metric.
+	limiterLabel string            // The name of the label holding the limiter name.
+	buckets      []float64         // The buckets of the latency histogram, in seconds.
+	exemplar     ExemplarFunc      // The function returning the exemplars of the denials.
+	keyLabel     string            // The name of the key label, empty to leave keys out.
+	keyMode      KeyMode           // The function mapping keys to label values.
+	maxKeyValues int               // The maximum number of values of the key label.
+}
+
+// KeyCounter is implemented by the limiters able to report the number of keys
+// they track, such as ratelimiter.Keyed.
+type KeyCounter interface {
+	Len() int
+}
+
+// MemoryReporter is implemented by the limiters able to estimate the memory held
+// by their state, such as ratelimiter.Keyed.
+type MemoryReporter interface {
+	MemoryUsage() int
+}
+
+// Evictor is implemented by the limiters forgetting idle keys, such as
+// ratelimiter.Keyed with Prune.
+type Evictor interface {
+	Evictions() uint64
+}
+
+// Metrics is a prometheus.Collector gathering the metrics of the limiters it
+// instruments.
+type Metrics struct {
+	decisions     *prometheus.CounterVec   // Counts the decisions by limiter and outcome.
+	latency       *prometheus.HistogramVec // Observes the duration of the decisions by limiter and phase.
+	backendErrors *prometheus.CounterVec   // Counts the backend errors by limiter.
+	keys          *prometheus.Desc         // Describes the number of tracked keys.
+	memory        *prometheus.Desc         // Describes the estimated memory of the tracked state.
+	evictions     *prometheus.Desc         // Describes the number of evicted keys.
+	exemplar      ExemplarFunc             // The function returning the exemplars of the denials.
+	perKey        *keyLabeler              // Counts the decisions of each key, nil unless enabled.
+
+	mu       sync.Mutex
+	counters map[string]KeyCounter     // Map to hold the limiters reporting their keys, by name.
+	memories map[string]MemoryReporter // Map to hold the limiters reporting their memory, by name.
+	evictors map[string]Evictor        // Map to hold the limiters reporting their evictions, by name.
+}
+
+// New creates a new set of metrics. It must be registered, e.g. with
+// prometheus.MustRegister or Register, for the metrics to be exported.
+func New(opts ...Option) *Metrics {
+	c := &config{
+		subsystem:    "ratelimiter",
+		limiterLabel: "limiter",
+		buckets:      prometheus.ExponentialBuckets(1e-6, 4, 11),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	m := &Metrics{
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        "decisions_total",
+			Help:        "Number of rate limit decisions, by outcome.",
+			ConstLabels: c.constLabels,
+		}, []string{c.limiterLabel, "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        "decision_duration_seconds",
+			Help:        "Duration of the rate limit decisions, by phase.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		}, []string{c.limiterLabel, "phase"}),
+		backendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   c.subsystem,
+			Name:        "backend_errors_total",
+			Help:        "Number of errors of the backends storing the rate limit state.",
+			ConstLabels: c.constLabels,
+		}, []string{c.limiterLabel}),
+		keys: prometheus.NewDesc(
+			prometheus.BuildFQName(c.namespace, c.subsystem, "tracked_keys"),
+			"Number of keys tracked by the limiter.",
+			[]string{c.limiterLabel}, c.constLabels,
+		),
+		memory: prometheus.NewDesc(
+			prometheus.BuildFQName(c.namespace, c.subsystem, "memory_bytes"),
+			"Estimated memory held by the state of the tracked keys.",
+			[]string{c.limiterLabel}, c.constLabels,
+		),
+		evictions: prometheus.NewDesc(
+			prometheus.BuildFQName(c.namespace, c.subsystem, "evictions_total"),
+			"Number of idle keys evicted by the limiter.",
+			[]string{c.limiterLabel}, c.constLabels,
+		),
+		exemplar: c.exemplar,
+		counters: make(map[string]KeyCounter),
+		memories: make(map[string]MemoryReporter),
+		evictors: make(map[string]Evictor),
+	}
+	if c.keyLabel != "" && c.keyMode != nil {
+		m.perKey = &keyLabeler{
+			decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace:   c.namespace,
+				Subsystem:   c.subsystem,
+				Name:        "key_decisions_total",
+				Help:        "Number of rate limit decisions, by outcome and key.",
+				ConstLabels: c.constLabels,
+			}, []string{c.limiterLabel, "outcome", c.keyLabel}),
+			mode:      c.keyMode,
+			maxValues: c.maxKeyValues,
+			values:    make(map[string]struct{}),
+		}
+	}
+	return m
+}
+
+// Register registers the metrics with reg, such as the registry of an
+// application already exporting its own metrics.
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	return reg.Register(m)
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.decisions.Describe(ch)
+	m.latency.Describe(ch)
+	m.backendErrors.Describe(ch)
+	if m.perKey != nil {
+		m.perKey.decisions.Describe(ch)
+	}
+	ch <- m.keys
+	ch <- m.memory
+	ch <- m.evictions
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.decisions.Collect(ch)
+	m.latency.Collect(ch)
+	m.backendErrors.Collect(ch)
+	if m.perKey != nil {
+		m.perKey.decisions.Collect(ch)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, counter := range m.counters {
+		ch <- prometheus.MustNewConstMetric(m.keys, prometheus.GaugeValue, float64(counter.Len()), name)
+	}
+	for name, memory := range m.memories {
+		ch <- prometheus.MustNewConstMetric(m.memory, prometheus.GaugeValue, float64(memory.MemoryUsage()), name)
+	}
+	for name, evictor := range m.evictors {
+		ch <- prometheus.MustNewConstMetric(m.evictions, prometheus.CounterValue, float64(evictor.Evictions()), name)
+	}
+}
+
+// Instrument returns limiter recording its decisions under name. The number of
+// tracked keys, their memory and evictions are reported if limiter implements
+// KeyCounter, MemoryReporter and Evictor.
+func (m *Metrics) Instrument(name string, limiter ratelimiter.Limiter) ratelimiter.Limiter {
+	m.mu.Lock()
+	if counter, ok := limiter.(KeyCounter); ok {
+		m.counters[name] = counter
+	}
+	if memory, ok := limiter.(MemoryReporter); ok {
+		m.memories[name] = memory
+	}
+	if evictor, ok := limiter.(Evictor); ok {
+		m.evictors[name] = evictor
+	}
+	m.mu.Unlock()
+
+	i := &instrumented{
+		limiter:  limiter,
+		name:     name,
+		exemplar: m.exemplar,
+		perKey:   m.perKey,
+		allowed:  m.decisions.WithLabelValues(name, "allowed"),
+		denied:   m.decisions.WithLabelValues(name, "denied"),
+		local:    m.latency.WithLabelValues(name, "local"),
+		// Limiters deciding in process have no backend phase to report, its
+		// series is only created by the first timed decision.
+		backend: sync.OnceValue(func() prometheus.Observer {
+			return m.latency.WithLabelValues(name, "backend")
+		}),
+	}
+	return i
+}
+
+// BackendError records an error of the backend of the limiter instrumented
+// under name, for limiters storing their state out of process.
+func (m *Metrics) BackendError(name string) {
+	m.backendErrors.WithLabelValues(name).Inc()
+}
+
+// instrumented is a Limiter recording the decisions of another.
+type instrumented struct {
+	limiter  ratelimiter.Limiter        // The limiter making the decisions.
+	allowed  prometheus.Counter         // Counts the allowed requests.
+	denied   prometheus.Counter         // Counts the denied requests.
+	local    prometheus.Observer        // Observes the time spent in process by the decisions.
+	backend  func() prometheus.Observer // Returns the observer of the time spent by the decisions waiting for the backend.
+	name     string                     // The name of the limiter in the metrics.
+	exemplar ExemplarFunc               // The function returning the exemplars of the denials.
+	perKey   *keyLabeler                // Counts the decisions of each key, nil unless enabled.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (i *instrumented) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return i.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed. Decisions of zero cost only report the state
+// of the key and are not recorded.
+func (i *instrumented) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	return i.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, from which the exemplars
+// of the denials are taken.
+func (i *instrumented) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) ratelimiter.Decision {
+	if n == 0 {
+		return ratelimiter.AllowNContext(ctx, i.limiter, key, requestTime, n)
+	}
+
+	start := time.Now()
+	decision, backend, timed := ratelimiter.AllowNTimed(ctx, i.limiter, key, requestTime, n)
+	local := time.Since(start) - backend
+
+	var exemplar prometheus.Labels
+	if !decision.Allowed && i.exemplar != nil {
+		exemplar = i.exemplar(ctx)
+	}
+	if timed {
+		observe(i.backend(), backend.Seconds(), exemplar)
+	}
+	observe(i.local, local.Seconds(), exemplar)
+	if decision.Allowed {
+		i.allowed.Inc()
+	} else if adder, ok := i.denied.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		adder.AddWithExemplar(1, exemplar)
+	} else {
+		i.denied.Inc()
+	}
+	if i.perKey != nil {
+		i.perKey.count(i.name, key, decision.Allowed)
+	}
+	return decision
+}
+
+// observe observes value with observer, attaching exemplar if not nil.
+func observe(observer prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		exemplarObserver.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	observer.Observe(value)
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (i *instrumented) Policies() []ratelimiter.Policy {
+	if reporter, ok := i.limiter.(ratelimiter.PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (i *instrumented) Healthy() error {
+	if checker, ok := i.limiter.(ratelimiter.HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (i *instrumented) Peek(key string, requestTime time.Time) (ratelimiter.Decision, bool) {
+	if peeker, ok := i.limiter.(ratelimiter.Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return ratelimiter.Decision{}, false
+}
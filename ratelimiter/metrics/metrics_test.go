@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func newLimiter(limit int) *ratelimiter.Keyed {
+	return ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(limit, time.Hour) })
+}
+
+// register registers m with a new registry, and returns the registry.
+func register(t *testing.T, m *Metrics) *prometheus.Registry {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	if err := m.Register(reg); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return reg
+}
+
+func TestDecisions(t *testing.T) {
+	m := New(WithNamespace("app"))
+	reg := register(t, m)
+	limiter := m.Instrument("api", newLimiter(2))
+
+	now := time.Now()
+	for _, key := range []string{"a", "a", "a", "b"} {
+		limiter.Allow(key, now)
+	}
+	// Decisions of zero cost only report the state of the key.
+	limiter.AllowN("a", now, 0)
+
+	expected := `
+# HELP app_ratelimiter_decisions_total Number of rate limit decisions, by outcome.
+# TYPE app_ratelimiter_decisions_total counter
+app_ratelimiter_decisions_total{limiter="api",outcome="allowed"} 3
+app_ratelimiter_decisions_total{limiter="api",outcome="denied"} 1
+# HELP app_ratelimiter_tracked_keys Number of keys tracked by the limiter.
+# TYPE app_ratelimiter_tracked_keys gauge
+app_ratelimiter_tracked_keys{limiter="api"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "app_ratelimiter_decisions_total", "app_ratelimiter_tracked_keys"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNameAndLabelOptions(t *testing.T) {
+	m := New(WithSubsystem("rl"), WithConstLabels(prometheus.Labels{"region": "eu"}), WithBuckets([]float64{0.5}))
+	reg := register(t, m)
+	m.Instrument("api", newLimiter(1)).Allow("a", time.Now())
+
+	expected := `
+# HELP rl_decisions_total Number of rate limit decisions, by outcome.
+# TYPE rl_decisions_total counter
+rl_decisions_total{limiter="api",outcome="allowed",region="eu"} 1
+rl_decisions_total{limiter="api",outcome="denied",region="eu"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "rl_decisions_total"); err != nil {
+		t.Error(err)
+	}
+	metric := &dto.Metric{}
+	m.latency.WithLabelValues("api", "local").(prometheus.Histogram).Write(metric)
+	if buckets := metric.GetHistogram().GetBucket(); len(buckets) != 1 || buckets[0].GetUpperBound() != 0.5 {
+		t.Errorf("latency buckets %v, want the configured 0.5s", buckets)
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	m := New(WithLimiterLabel("name"))
+	m.BackendError("redis")
+	m.BackendError("redis")
+	if value := testutil.ToFloat64(m.backendErrors.WithLabelValues("redis")); value != 2 {
+		t.Errorf("%v backend errors, want 2", value)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	limiter := New().Instrument("api", newLimiter(2))
+	policies := limiter.(ratelimiter.PolicyReporter).Policies()
+	if len(policies) != 1 || policies[0].Limit != 2 {
+		t.Errorf("policies %+v, want those of the wrapped limiter", policies)
+	}
+	limiter.Allow("a", time.Now())
+	if decision, ok := limiter.(ratelimiter.Peeker).Peek("a", time.Now()); !ok || decision.Remaining != 1 {
+		t.Errorf("peeked %+v, %t, want the state of the wrapped limiter", decision, ok)
+	}
+}
+
+// timedLimiter is a limiter spending backend time on every decision.
+type timedLimiter struct {
+	ratelimiter.Limiter
+	backend time.Duration // The time spent in the backend by each decision.
+	traces  []any         // The traceKey values of the contexts of the decisions.
+}
+
+func (l *timedLimiter) AllowNTimed(ctx context.Context, key string, requestTime time.Time, n int) (ratelimiter.Decision, time.Duration, bool) {
+	l.traces = append(l.traces, ctx.Value(traceKey{}))
+	time.Sleep(l.backend)
+	return l.Limiter.AllowN(key, requestTime, n), l.backend, true
+}
+
+func TestLatencyPhases(t *testing.T) {
+	m := New()
+	local := m.Instrument("local", ratelimiter.NewShadow(newLimiter(2), true, nil))
+	backend := &timedLimiter{Limiter: newLimiter(2), backend: 10 * time.Millisecond}
+	// The wrappers of the timed limiter forward its backend time.
+	timed := m.Instrument("timed", ratelimiter.NewShadow(backend, true, nil))
+	local.Allow("a", time.Now())
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f3577b34da6")
+	ratelimiter.AllowNContext(ctx, timed, "a", time.Now(), 1)
+
+	// Only the limiters with a backend report the backend phase.
+	if n := testutil.CollectAndCount(m.latency); n != 3 {
+		t.Errorf("%d latency series, want local for both and backend for timed", n)
+	}
+	if len(backend.traces) != 1 || backend.traces[0] != "4bf92f3577b34da6" {
+		t.Errorf("backend decided with traces %v, want the context of the request", backend.traces)
+	}
+	histogram := func(name, phase string) (uint64, float64) {
+		metric := &dto.Metric{}
+		m.latency.WithLabelValues(name, phase).(prometheus.Histogram).Write(metric)
+		return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+	}
+	if count, sum := histogram("timed", "backend"); count != 1 || sum < 0.01 {
+		t.Errorf("backend phase of timed: %d samples summing to %vs, want the 10ms spent in the backend", count, sum)
+	}
+	if count, sum := histogram("timed", "local"); count != 1 || sum >= 0.01 {
+		t.Errorf("local phase of timed: %d samples summing to %vs, want the backend time left out", count, sum)
+	}
+}
+
+type traceKey struct{}
+
+func TestExemplars(t *testing.T) {
+	m := New(WithExemplars(func(ctx context.Context) prometheus.Labels {
+		if traceID, ok := ctx.Value(traceKey{}).(string); ok {
+			return prometheus.Labels{"trace_id": traceID}
+		}
+		return nil
+	}))
+	limiter := m.Instrument("api", newLimiter(1))
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f3577b34da6")
+	now := time.Now()
+	ratelimiter.AllowNContext(ctx, limiter, "a", now, 1)
+	ratelimiter.AllowNContext(ctx, limiter, "a", now, 1)
+
+	// Only the denials carry the exemplar.
+	for outcome, want := range map[string]string{"allowed": "", "denied": "4bf92f3577b34da6"} {
+		metric := &dto.Metric{}
+		m.decisions.WithLabelValues("api", outcome).Write(metric)
+		got := ""
+		for _, label := range metric.GetCounter().GetExemplar().GetLabel() {
+			if label.GetName() == "trace_id" {
+				got = label.GetValue()
+			}
+		}
+		if got != want {
+			t.Errorf("%s: exemplar trace %q, want %q", outcome, got, want)
+		}
+	}
+}
+
+func TestMemoryAndEvictions(t *testing.T) {
+	m := New()
+	reg := register(t, m)
+	keyed := newLimiter(2)
+	limiter := m.Instrument("api", keyed)
+	now := time.Now()
+	limiter.Allow("a", now)
+	limiter.Allow("b", now)
+	keyed.Prune(now.Add(2 * time.Hour))
+	limiter.Allow("c", now.Add(2*time.Hour))
+
+	expected := fmt.Sprintf(`
+# HELP ratelimiter_evictions_total Number of idle keys evicted by the limiter.
+# TYPE ratelimiter_evictions_total counter
+ratelimiter_evictions_total{limiter="api"} 2
+# HELP ratelimiter_memory_bytes Estimated memory held by the state of the tracked keys.
+# TYPE ratelimiter_memory_bytes gauge
+ratelimiter_memory_bytes{limiter="api"} %d
+`, keyed.MemoryUsage())
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "ratelimiter_evictions_total", "ratelimiter_memory_bytes"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,458 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFunc extracts the key identifying the client of a request.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys requests by the IP address of the remote peer.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader keys requests by the value of the given header, e.g. an API key.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Option configures the HTTP middleware.
+type Option func(*middleware)
+
+// WithKeyFunc sets the function used to extract the key of a request. Requests
+// are keyed by IP address by default.
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(m *middleware) {
+		m.keyFunc = keyFunc
+	}
+}
+
+// WithHeaders sets whether the rate limit headers are sent on allowed and on
+// denied responses. They are sent on both by default. Denied responses always
+// carry Retry-After.
+func WithHeaders(onAllowed, onDenied bool) Option {
+	return func(m *middleware) {
+		m.headersOnAllowed = onAllowed
+		m.headersOnDenied = onDenied
+	}
+}
+
+// HeaderStyle selects the rate limit header fields sent by the middleware.
+// Styles can be combined to send both.
+type HeaderStyle int
+
+const (
+	// LegacyHeaders are the X-RateLimit-Limit, X-RateLimit-Remaining and
+	// X-RateLimit-Reset fields.
+	LegacyHeaders HeaderStyle = 1 << iota
+	// IETFHeaders are the RateLimit and RateLimit-Policy fields of the IETF
+	// httpapi rate limit headers draft.
+	IETFHeaders
+)
+
+// WithHeaderStyle sets the rate limit header fields sent by the middleware. It
+// defaults to LegacyHeaders.
+func WithHeaderStyle(style HeaderStyle) Option {
+	return func(m *middleware) {
+		m.headerStyle = style
+	}
+}
+
+// WithPolicyName sets the name of the quota policy in the IETF header fields.
+// It defaults to "default".
+func WithPolicyName(name string) Option {
+	return func(m *middleware) {
+		m.policyName = name
+	}
+}
+
+// WithPolicyAdvertisement sets RateLimit-Policy on allowed responses, whatever
+// the header style, so clients learn the quotas of the service before hitting
+// them. The policies are taken from the limiter when it implements
+// PolicyReporter, and from the decision otherwise.
+func WithPolicyAdvertisement() Option {
+	return func(m *middleware) {
+		m.advertisePolicy = true
+	}
+}
+
+// WithDelay holds requests over the limit until the limiter allows them instead
+// of denying them, as long as they would be allowed within maxDelay. Smoothing
+// bursts that way suits internal services better than failing them. Requests
+// that would wait longer, or whose client goes away, are denied as usual.
+func WithDelay(maxDelay time.Duration) Option {
+	return func(m *middleware) {
+		m.maxDelay = maxDelay
+	}
+}
+
+// WithExposeHeaders lists the rate limit headers in Access-Control-Expose-Headers
+// so browser clients of cross-origin requests can read them. Without names, the
+// rate limit headers set on each response are exposed. Headers already exposed,
+// e.g. by a CORS middleware running first, are kept.
+func WithExposeHeaders(names ...string) Option {
+	return func(m *middleware) {
+		m.exposeHeaders = true
+		m.exposedHeaders = names
+	}
+}
+
+// WithRetryAfterDate sends Retry-After as an HTTP-date rather than a number of
+// seconds, for clients whose clocks are synchronized with the server.
+func WithRetryAfterDate() Option {
+	return func(m *middleware) {
+		m.retryAfterDate = true
+	}
+}
+
+// LimitReachedFunc writes the response to a denied request. The rate limit
+// headers are already set on w when it is called.
+type LimitReachedFunc func(w http.ResponseWriter, r *http.Request, decision Decision)
+
+// WithOnLimitReached sets the function writing the response to denied requests,
+// e.g. to render an HTML page, redirect or log them. It defaults to
+// DefaultOnLimitReached.
+func WithOnLimitReached(onLimitReached LimitReachedFunc) Option {
+	return func(m *middleware) {
+		m.onLimitReached = onLimitReached
+	}
+}
+
+// DecisionFunc is notified of the decision made for the request r keyed by key.
+type DecisionFunc func(r *http.Request, key string, decision Decision)
+
+// WithOnDecision sets a function notified of every decision the middleware
+// makes, e.g. to trace or log them. It runs before the request is answered and
+// must not write to the response.
+func WithOnDecision(onDecision DecisionFunc) Option {
+	return func(m *middleware) {
+		m.onDecision = onDecision
+	}
+}
+
+// DefaultOnLimitReached answers denied requests with 429 Too Many Requests.
+func DefaultOnLimitReached(w http.ResponseWriter, r *http.Request, decision Decision) {
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+type middleware struct {
+	limiter          Limiter                                  // The limiter making the decisions.
+	keyFunc          KeyFunc                                  // The function extracting the key of a request.
+	headersOnAllowed bool                                     // Whether to send the rate limit headers on allowed responses.
+	headersOnDenied  bool                                     // Whether to send the rate limit headers on denied responses.
+	headerStyle      HeaderStyle                              // The rate limit header fields to send.
+	policyName       string                                   // The name of the quota policy in the IETF header fields.
+	advertisePolicy  bool                                     // Whether to send RateLimit-Policy on allowed responses.
+	retryAfterDate   bool                                     // Whether to send Retry-After as an HTTP-date.
+	exposeHeaders    bool                                     // Whether to list the headers in Access-Control-Expose-Headers.
+	exposedHeaders   []string                                 // The headers to expose, empty for the rate limit headers set.
+	onLimitReached   LimitReachedFunc                         // The function writing the response to denied requests.
+	maxDelay         time.Duration                            // How long requests over the limit may be held, zero to deny them right away.
+	routes           *http.ServeMux                           // The mux matching the route patterns, nil without routes.
+	routeLimiters    map[string]Limiter                       // Map to hold the limiter of each route pattern.
+	skip             []Matcher                                // The matchers of the requests excluded from limiting.
+	onSkipped        func(r *http.Request)                    // The function notified of the excluded requests.
+	costFunc         CostFunc                                 // The function computing the cost of a request, nil for one unit.
+	durationCost     time.Duration                            // The interval of the units charged while a request runs, zero to charge none.
+	maxInFlight      int                                      // The number of requests of a key served at once, zero for no limit.
+	inFlightMu       sync.Mutex                               // Protects inFlight.
+	inFlight         map[string]int                           // Map to hold the number of requests of each key being served.
+	softLimit        float64                                  // The share of the budget over which clients are warned, zero to never warn.
+	onSoftLimit      func(r *http.Request, decision Decision) // The function notified of the requests over the soft limit.
+	coalesce         *coalescer                               // The coalescer sharing the denials of duplicate requests, if any.
+	staleCache       StaleCache                               // The cache of the responses served to denied requests, if any.
+	onDecision       DecisionFunc                             // The function notified of every decision, if any.
+}
+
+type decisionKey struct{}
+
+// DecisionFromContext returns the decision the middleware made for the request
+// carrying ctx.
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	decision, ok := ctx.Value(decisionKey{}).(Decision)
+	return decision, ok
+}
+
+// Middleware returns an HTTP middleware limiting requests with limiter. Denied
+// requests are answered with 429 Too Many Requests unless WithOnLimitReached is
+// given. With WithRoute, limiter may be nil to leave the requests matching no
+// route unlimited.
+func Middleware(limiter Limiter, opts ...Option) func(http.Handler) http.Handler {
+	m := newMiddleware(limiter, opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.skipped(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limiter, key := m.route(r)
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			release, ok := m.acquire(key)
+			if !ok {
+				decision := m.inFlightDecision()
+				m.setHeaders(w.Header(), limiter, decision, time.Now())
+				r = r.WithContext(context.WithValue(r.Context(), decisionKey{}, decision))
+				m.notify(r, key, decision)
+				m.onLimitReached(w, r, decision)
+				return
+			}
+			defer release()
+
+			var decision Decision
+			var now time.Time
+			if id := m.idempotencyKey(r); id != "" {
+				decision, now = m.coalesce.decide(key+"|"+id, func() (Decision, time.Time) {
+					return m.allow(r, limiter, key)
+				})
+			} else {
+				decision, now = m.allow(r, limiter, key)
+			}
+			m.warn(w, r, decision)
+			m.setHeaders(w.Header(), limiter, decision, now)
+			r = r.WithContext(context.WithValue(r.Context(), decisionKey{}, decision))
+			m.notify(r, key, decision)
+
+			if !decision.Allowed {
+				if !m.serveStale(w, r, key) {
+					m.onLimitReached(w, r, decision)
+				}
+				return
+			}
+			m.serve(next, w, r, limiter, key)
+		})
+	}
+}
+
+// newMiddleware creates the configuration of a middleware limiting requests
+// with limiter.
+func newMiddleware(limiter Limiter, opts []Option) *middleware {
+	m := &middleware{
+		limiter:          limiter,
+		keyFunc:          KeyByIP,
+		headersOnAllowed: true,
+		headersOnDenied:  true,
+		headerStyle:      LegacyHeaders,
+		policyName:       "default",
+		onLimitReached:   DefaultOnLimitReached,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// allow decides whether limiter allows the request for key, holding it until the
+// limiter allows it when delaying is enabled. It returns the final decision and
+// when it was made.
+func (m *middleware) allow(r *http.Request, limiter Limiter, key string) (Decision, time.Time) {
+	cost := 1
+	if m.costFunc != nil {
+		cost = m.costFunc(r)
+	}
+
+	now := time.Now()
+	decision := AllowNContext(r.Context(), limiter, key, now, cost)
+	if !decision.Allowed && decision.Limit > 0 && cost > decision.Limit {
+		// Waiting would never allow the request.
+		decision.Reason = ReasonCostExceedsLimit
+		return decision, now
+	}
+	if decision.Allowed || m.maxDelay <= 0 {
+		return decision, now
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), m.maxDelay)
+	defer cancel()
+	for !decision.Allowed {
+		// Give up right away on requests that would not be allowed in time,
+		// or whose limiter cannot tell when they would be.
+		if decision.RetryAfter <= 0 {
+			return decision, now
+		}
+		if err := sleep(ctx, now, decision.RetryAfter); err != nil {
+			return decision, now
+		}
+		now = time.Now()
+		decision = AllowNContext(r.Context(), limiter, key, now, cost)
+	}
+	return decision, now
+}
+
+// notify passes the decision made for r to the decision function, if any.
+func (m *middleware) notify(r *http.Request, key string, decision Decision) {
+	if m.onDecision != nil {
+		m.onDecision(r, key, decision)
+	}
+}
+
+// setHeaders sets the configured rate limit headers describing the decision of
+// limiter on h.
+func (m *middleware) setHeaders(h http.Header, limiter Limiter, decision Decision, now time.Time) {
+	if decision.Allowed && m.headersOnAllowed || !decision.Allowed && m.headersOnDenied {
+		if m.headerStyle&LegacyHeaders != 0 {
+			writeLegacyHeaders(h.Set, decision)
+		}
+		if m.headerStyle&IETFHeaders != 0 {
+			WriteIETFHeaders(h.Set, m.policyName, decision)
+		}
+	}
+	if decision.Allowed && m.advertisePolicy {
+		m.setPolicy(h, limiter, decision)
+	}
+	if !decision.Allowed {
+		h.Set("Retry-After", FormatRetryAfter(decision, now, m.retryAfterDate))
+	}
+	if m.exposeHeaders {
+		m.expose(h)
+	}
+}
+
+// expose lists the rate limit headers set on h in Access-Control-Expose-Headers,
+// keeping the headers already exposed.
+func (m *middleware) expose(h http.Header) {
+	names := m.exposedHeaders
+	if len(names) == 0 {
+		for _, name := range []string{
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+			"RateLimit", "RateLimit-Policy", "Retry-After", "X-RateLimit-Warning",
+		} {
+			if h.Get(name) != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	exposed := h.Values("Access-Control-Expose-Headers")
+	var missing []string
+	for _, name := range names {
+		if !containsToken(exposed, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		h.Add("Access-Control-Expose-Headers", strings.Join(missing, ", "))
+	}
+}
+
+// containsToken reports whether the comma-separated values list token, or the
+// wildcard.
+func containsToken(values []string, token string) bool {
+	for _, value := range values {
+		for _, t := range strings.Split(value, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.EqualFold(t, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setPolicy sets RateLimit-Policy on h, describing the policies of limiter.
+func (m *middleware) setPolicy(h http.Header, limiter Limiter, decision Decision) {
+	var policies []Policy
+	if reporter, ok := limiter.(PolicyReporter); ok {
+		policies = slices.Clone(reporter.Policies())
+	}
+	if len(policies) == 0 && decision.Window > 0 {
+		policies = []Policy{{Limit: decision.Limit, Window: decision.Window}}
+	}
+	for i := range policies {
+		if policies[i].Name == "" {
+			policies[i].Name = m.policyName
+		}
+	}
+	if len(policies) > 0 {
+		h.Set("RateLimit-Policy", FormatPolicies(policies))
+	}
+}
+
+// SetHeaders sets the rate limit headers describing decision on h.
+func SetHeaders(h http.Header, decision Decision) {
+	WriteHeaders(h.Set, decision)
+}
+
+// WriteHeaders passes the rate limit headers describing decision to set, for
+// frameworks that do not expose an http.Header. X-RateLimit-Reset is the number
+// of seconds until the whole limit is available again.
+func WriteHeaders(set func(key, value string), decision Decision) {
+	writeLegacyHeaders(set, decision)
+	setRetryAfter(set, decision)
+}
+
+// WriteIETFHeaders passes the RateLimit and RateLimit-Policy header fields of
+// the IETF httpapi draft describing decision under the given policy name to set,
+// e.g. RateLimit: "default";r=50;t=30 and RateLimit-Policy: "default";q=100;w=60.
+// RateLimit-Policy is omitted when the window of decision is unknown.
+func WriteIETFHeaders(set func(key, value string), policy string, decision Decision) {
+	set("RateLimit", quoteString(policy)+";r="+strconv.Itoa(decision.Remaining)+";t="+strconv.Itoa(ceilSeconds(decision.ResetAfter)))
+	if decision.Window > 0 {
+		set("RateLimit-Policy", Policy{Name: policy, Limit: decision.Limit, Window: decision.Window}.String())
+	}
+}
+
+// writeLegacyHeaders passes the X-RateLimit-* headers describing decision to set.
+func writeLegacyHeaders(set func(key, value string), decision Decision) {
+	set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.ResetAfter)))
+}
+
+// setRetryAfter passes the Retry-After header of a denied decision to set.
+func setRetryAfter(set func(key, value string), decision Decision) {
+	if !decision.Allowed {
+		set("Retry-After", FormatRetryAfter(decision, time.Time{}, false))
+	}
+}
+
+// FormatRetryAfter formats the Retry-After value of decision made at now, as
+// the number of seconds to wait rounded up so clients never retry too early, or
+// as the HTTP-date at which the request would be allowed.
+func FormatRetryAfter(decision Decision, now time.Time, httpDate bool) string {
+	if httpDate {
+		// HTTP-dates have a precision of one second, round up for the same reason.
+		retryAt := now.Add(decision.RetryAfter + time.Second - 1).Truncate(time.Second)
+		return retryAt.UTC().Format(http.TimeFormat)
+	}
+	return strconv.Itoa(ceilSeconds(decision.RetryAfter))
+}
+
+// quoteString serializes s as a structured field string.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ceilSeconds rounds d up to the next whole second.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,229 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// limiterFunc is a Limiter deciding with a function.
+type limiterFunc func(key string, requestTime time.Time, n int) Decision
+
+func (f limiterFunc) Allow(key string, requestTime time.Time) Decision {
+	return f(key, requestTime, 1)
+}
+
+func (f limiterFunc) AllowN(key string, requestTime time.Time, n int) Decision {
+	return f(key, requestTime, n)
+}
+
+// serve passes a request through a middleware of limiter, and returns the
+// status code of the response, the last decision, and how long it took.
+func serve(t *testing.T, limiter Limiter, opts ...Option) (int, Decision, time.Duration) {
+	t.Helper()
+	var last Decision
+	opts = append(opts, WithOnDecision(func(r *http.Request, key string, decision Decision) { last = decision }))
+	handler := Middleware(limiter, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code, last, time.Since(start)
+}
+
+func TestMiddlewareLimits(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(2, time.Hour) })
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code, _, _ := serve(t, limiter); code != want {
+			t.Errorf("request %d: status %d, want %d", i, code, want)
+		}
+	}
+}
+
+func TestMiddlewareCostExceedsLimit(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(5, time.Minute) })
+	cost := WithCostFunc(func(r *http.Request) int { return 10 })
+	code, decision, elapsed := serve(t, limiter, cost, WithDelay(5*time.Second))
+	if code != http.StatusTooManyRequests {
+		t.Errorf("status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if decision.Reason != ReasonCostExceedsLimit {
+		t.Errorf("reason %q, want %q", decision.Reason, ReasonCostExceedsLimit)
+	}
+	if elapsed > time.Second {
+		t.Errorf("request held %s, want denied right away", elapsed)
+	}
+}
+
+func TestMiddlewareDelayWithoutRetryAfter(t *testing.T) {
+	calls := 0
+	limiter := limiterFunc(func(key string, requestTime time.Time, n int) Decision {
+		calls++
+		return Decision{Limit: 10, Reason: ReasonDenylist}
+	})
+	code, _, elapsed := serve(t, limiter, WithDelay(5*time.Second))
+	if code != http.StatusTooManyRequests {
+		t.Errorf("status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if calls != 1 || elapsed > time.Second {
+		t.Errorf("%d decisions in %s, want a single one right away", calls, elapsed)
+	}
+}
+
+func TestMiddlewareDelay(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(20, time.Second) })
+	for range 20 {
+		serve(t, limiter)
+	}
+	code, _, elapsed := serve(t, limiter, WithDelay(time.Second))
+	if code != http.StatusOK {
+		t.Errorf("status %d, want %d after a delay", code, http.StatusOK)
+	}
+	if elapsed > time.Second {
+		t.Errorf("request held %s, want at most the maximum delay", elapsed)
+	}
+}
+
+// record passes n requests through a middleware of limiter, and returns the
+// recorded responses.
+func record(t *testing.T, limiter Limiter, n int, opts ...Option) []*httptest.ResponseRecorder {
+	t.Helper()
+	handler := Middleware(limiter, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorders := make([]*httptest.ResponseRecorder, n)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		handler.ServeHTTP(recorders[i], httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	return recorders
+}
+
+// checkHeaders reports the headers of h differing from want, an empty value
+// meaning the header must be absent.
+func checkHeaders(t *testing.T, name string, h http.Header, want map[string]string) {
+	t.Helper()
+	for key, value := range want {
+		if got := h.Get(key); got != value {
+			t.Errorf("%s: %s %q, want %q", name, key, got, value)
+		}
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	responses := record(t, limiter, 2)
+	checkHeaders(t, "allowed", responses[0].Header(), map[string]string{
+		"X-RateLimit-Limit":     "1",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "61",
+		"Retry-After":           "",
+	})
+	checkHeaders(t, "denied", responses[1].Header(), map[string]string{
+		"X-RateLimit-Limit":     "1",
+		"X-RateLimit-Remaining": "0",
+		"Retry-After":           "61",
+	})
+
+	// Denied responses always carry Retry-After.
+	limiter.Reset("192.0.2.1")
+	responses = record(t, limiter, 2, WithHeaders(false, false))
+	checkHeaders(t, "allowed without headers", responses[0].Header(), map[string]string{"X-RateLimit-Limit": ""})
+	checkHeaders(t, "denied without headers", responses[1].Header(), map[string]string{"X-RateLimit-Limit": "", "Retry-After": "61"})
+}
+
+func TestMiddlewareIETFHeaders(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(2, time.Minute) })
+	responses := record(t, limiter, 1, WithHeaderStyle(IETFHeaders), WithPolicyName(`per "ip"`))
+	checkHeaders(t, "IETF", responses[0].Header(), map[string]string{
+		"RateLimit":         `"per \"ip\"";r=1;t=61`,
+		"RateLimit-Policy":  `"per \"ip\"";q=2;w=60`,
+		"X-RateLimit-Limit": "",
+	})
+
+	responses = record(t, limiter, 1, WithHeaderStyle(LegacyHeaders|IETFHeaders))
+	checkHeaders(t, "both", responses[0].Header(), map[string]string{
+		"RateLimit":         `"default";r=0;t=61`,
+		"X-RateLimit-Limit": "2",
+	})
+}
+
+func TestFormatRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 400*int(time.Millisecond), time.UTC)
+	decision := Decision{RetryAfter: 1500 * time.Millisecond}
+	// Clients must never retry before the request would be allowed.
+	if got := FormatRetryAfter(decision, now, false); got != "2" {
+		t.Errorf("seconds %q, want %q", got, "2")
+	}
+	if got, want := FormatRetryAfter(decision, now, true), "Wed, 01 May 2024 12:00:02 GMT"; got != want {
+		t.Errorf("HTTP-date %q, want %q", got, want)
+	}
+}
+
+func TestMiddlewareRetryAfter(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(1, 10*time.Second) })
+	var decision Decision
+	handler := Middleware(limiter, WithRetryAfterDate())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ = DecisionFromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !decision.Allowed {
+		t.Errorf("decision in context %+v, want the allowed one", decision)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	retryAt, err := http.ParseTime(w.Header().Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("Retry-After %q: %v", w.Header().Get("Retry-After"), err)
+	}
+	// The bucket leaks a request every 10 seconds.
+	if wait := time.Until(retryAt); wait < 8*time.Second || wait > 11*time.Second {
+		t.Errorf("Retry-After in %s, want about 10s", wait)
+	}
+}
+
+func TestMiddlewareOnLimitReached(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	var headers http.Header
+	onLimitReached := func(w http.ResponseWriter, r *http.Request, decision Decision) {
+		headers = w.Header().Clone()
+		http.Redirect(w, r, "/slow-down", http.StatusSeeOther)
+	}
+	responses := record(t, limiter, 2, WithOnLimitReached(onLimitReached))
+
+	if responses[0].Code != http.StatusOK {
+		t.Errorf("allowed request: status %d, want %d", responses[0].Code, http.StatusOK)
+	}
+	if responses[1].Code != http.StatusSeeOther || responses[1].Header().Get("Location") != "/slow-down" {
+		t.Errorf("denied request: status %d to %q, want the custom response", responses[1].Code, responses[1].Header().Get("Location"))
+	}
+	// The rate limit headers are set before the hook runs.
+	if headers.Get("Retry-After") == "" || headers.Get("X-RateLimit-Limit") != "1" {
+		t.Errorf("headers %v seen by the hook, want the rate limit headers", headers)
+	}
+}
+
+func TestMiddlewareExposeHeaders(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	responses := record(t, limiter, 2, WithExposeHeaders())
+	checkHeaders(t, "allowed", responses[0].Header(), map[string]string{
+		"Access-Control-Expose-Headers": "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset",
+	})
+	checkHeaders(t, "denied", responses[1].Header(), map[string]string{
+		"Access-Control-Expose-Headers": "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After",
+	})
+
+	// Headers exposed by a CORS middleware running first are kept.
+	cors := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id, retry-after")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := cors(Middleware(limiter, WithExposeHeaders("Retry-After", "RateLimit"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Header().Values("Access-Control-Expose-Headers"), []string{"X-Request-Id, retry-after", "RateLimit"}; !slices.Equal(got, want) {
+		t.Errorf("Access-Control-Expose-Headers %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pacer spreads jobs evenly over a window, for scheduled and batch jobs that
+// would otherwise hit their downstreams all at once. It hands out one slot
+// every window/n through reservations, so the jobs are spaced out rather than
+// sent in a burst at the start of the window.
+type Pacer struct {
+	mu        sync.Mutex // Protects algorithm.
+	algorithm Algorithm  // The algorithm handing out the slots.
+}
+
+// NewPacer creates a new pacer spreading n jobs over windowDuration.
+func NewPacer(n int, windowDuration time.Duration) *Pacer {
+	return &Pacer{
+		algorithm: NewLeakyBucket(1, windowDuration/time.Duration(max(n, 1))),
+	}
+}
+
+// Wait reserves the next slot and blocks until it is due, or until ctx is done.
+func (p *Pacer) Wait(ctx context.Context) error {
+	now := time.Now()
+	p.mu.Lock()
+	delay := p.algorithm.Reserve(now)
+	p.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return sleep(ctx, now, delay)
+}
+
+// Run runs job n times one after the other, starting each run in its own slot.
+// It stops at the first error returned by job or by ctx.
+func (p *Pacer) Run(ctx context.Context, n int, job func(ctx context.Context, i int) error) error {
+	for i := 0; i < n; i++ {
+		if err := p.Wait(ctx); err != nil {
+			return err
+		}
+		if err := job(ctx, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
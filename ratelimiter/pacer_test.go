@@ -0,0 +1,49 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPacerRun(t *testing.T) {
+	pacer := NewPacer(4, 200*time.Millisecond)
+	var starts []time.Duration
+	begin := time.Now()
+	err := pacer.Run(context.Background(), 4, func(ctx context.Context, i int) error {
+		starts = append(starts, time.Since(begin))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// The jobs start every 50ms instead of all at once.
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i] - starts[i-1]; gap < 40*time.Millisecond {
+			t.Errorf("job %d started %s after the previous one, want about 50ms", i, gap)
+		}
+	}
+}
+
+func TestPacerRunStops(t *testing.T) {
+	pacer := NewPacer(100, time.Second)
+	errJob := errors.New("job failed")
+	runs := 0
+	err := pacer.Run(context.Background(), 5, func(ctx context.Context, i int) error {
+		runs++
+		if i == 1 {
+			return errJob
+		}
+		return nil
+	})
+	if err != errJob || runs != 2 {
+		t.Errorf("Run: error %v after %d runs, want the job error after 2", err, runs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := NewPacer(1, time.Hour).Run(ctx, 2, func(ctx context.Context, i int) error { return nil }); !errors.Is(err, ErrWaitExceedsDeadline) {
+		t.Errorf("Run past the deadline: error %v, want %v", err, ErrWaitExceedsDeadline)
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimiter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a quota policy: at most Limit requests per Window.
+type Policy struct {
+	Name   string        // Name identifying the policy in the IETF header fields.
+	Limit  int           // Maximum number of requests allowed in the window.
+	Window time.Duration // Duration of the window.
+}
+
+// String formats p as a RateLimit-Policy member, e.g. "default";q=100;w=3600.
+func (p Policy) String() string {
+	return quoteString(p.Name) + ";q=" + strconv.Itoa(p.Limit) + ";w=" + strconv.Itoa(ceilSeconds(p.Window))
+}
+
+// PolicyReporter is implemented by limiters able to describe the policies they
+// enforce, so servers can advertise them to clients.
+type PolicyReporter interface {
+	// Policies returns the policies enforced by the limiter.
+	Policies() []Policy
+}
+
+// FormatPolicies formats policies as a RateLimit-Policy field value.
+func FormatPolicies(policies []Policy) string {
+	members := make([]string, len(policies))
+	for i, p := range policies {
+		members[i] = p.String()
+	}
+	return strings.Join(members, ", ")
+}
+
+// Policy returns the policy enforced by the sliding window.
+func (rl *SlidingWindow) Policy() Policy {
+	return Policy{Limit: rl.rate, Window: rl.windowDuration}
+}
+
+// Policy returns the policy enforced by the leaky bucket.
+func (lb *LeakyBucket) Policy() Policy {
+	return Policy{Limit: int(lb.capacity), Window: lb.windowDuration}
+}
+
+// Policy returns the policy enforced by the token bucket.
+func (tb *TokenBucket) Policy() Policy {
+	return Policy{Limit: int(tb.rate), Window: tb.windowDuration}
+}
+
+// Policy returns the policy enforced by the calendar window, over the current
+// period.
+func (cw *CalendarWindow) Policy() Policy {
+	start, end := cw.start, cw.end
+	if start.IsZero() {
+		start, end = cw.period.Bounds(time.Now(), cw.location)
+	}
+	return Policy{Limit: cw.rate, Window: end.Sub(start)}
+}
+
+// Policies returns the policy enforced for every key, if the algorithm of the
+// limiter can describe it. It is described once, when the limiter is created,
+// since the middleware asks for it on every response. The returned slice is
+// shared and must not be modified.
+func (k *Keyed) Policies() []Policy {
+	return k.policies
+}
+
+// policiesOf returns the policy enforced by algorithm, if it can describe it.
+func policiesOf(algorithm Algorithm) []Policy {
+	if p, ok := algorithm.(interface{ Policy() Policy }); ok {
+		return []Policy{p.Policy()}
+	}
+	return nil
+}
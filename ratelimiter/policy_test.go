@@ -0,0 +1,73 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedPoliciesDescribedOnce(t *testing.T) {
+	created := 0
+	limiter := NewKeyed(func() Algorithm {
+		created++
+		return NewSlidingWindow(100, time.Hour)
+	})
+
+	for range 10 {
+		policies := limiter.Policies()
+		if len(policies) != 1 || policies[0].Limit != 100 || policies[0].Window != time.Hour {
+			t.Fatalf("Policies = %v, want 100 per hour", policies)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d algorithms created to describe the policy, want 1", created)
+	}
+	if got, want := limiter.Policies()[0].String(), `"";q=100;w=3600`; got != want {
+		t.Errorf("policy = %s, want %s", got, want)
+	}
+}
+
+// reportingLimiter is a limiter reporting fixed policies.
+type reportingLimiter struct {
+	Limiter
+	policies []Policy
+}
+
+func (l reportingLimiter) Policies() []Policy { return l.policies }
+
+func TestMiddlewarePolicyAdvertisement(t *testing.T) {
+	keyed := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })
+	limiter := reportingLimiter{Limiter: keyed, policies: []Policy{
+		{Limit: 100, Window: time.Minute},
+		{Name: "daily", Limit: 5000, Window: 24 * time.Hour},
+	}}
+	responses := record(t, limiter, 1, WithPolicyAdvertisement(), WithPolicyName("burst"))
+	checkHeaders(t, "reporter", responses[0].Header(), map[string]string{
+		"RateLimit-Policy":  `"burst";q=100;w=60, "daily";q=5000;w=86400`,
+		"X-RateLimit-Limit": "100",
+	})
+	if limiter.policies[0].Name != "" {
+		t.Error("policies of the limiter renamed")
+	}
+
+	// Limiters unable to describe their policies are described by the decision.
+	decide := limiterFunc(func(key string, requestTime time.Time, n int) Decision {
+		return Decision{Allowed: true, Limit: 10, Remaining: 9, Window: time.Second}
+	})
+	responses = record(t, decide, 1, WithPolicyAdvertisement())
+	checkHeaders(t, "decision", responses[0].Header(), map[string]string{"RateLimit-Policy": `"default";q=10;w=1`})
+}
+
+func TestFormatPolicies(t *testing.T) {
+	policies := []Policy{
+		NewSlidingWindow(100, time.Minute).Policy(),
+		NewLeakyBucket(10, time.Second).Policy(),
+		NewTokenBucket(50, 1500*time.Millisecond, 80).Policy(),
+	}
+	policies[0].Name = "burst"
+	if got, want := FormatPolicies(policies), `"burst";q=100;w=60, "";q=10;w=1, "";q=50;w=2`; got != want {
+		t.Errorf("FormatPolicies = %s, want %s", got, want)
+	}
+	if got := FormatPolicies(nil); got != "" {
+		t.Errorf("FormatPolicies(nil) = %q, want empty", got)
+	}
+}
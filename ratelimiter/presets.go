@@ -0,0 +1,78 @@
+package ratelimiter
+
+import "time"
+
+// Preset is a named policy encoding a sensible combination of algorithm, rate,
+// window and burst for a common use, as a starting point to tune.
+type Preset struct {
+	Name        string        // Name of the preset.
+	Description string        // What the preset is meant for.
+	Algorithm   string        // Name of the algorithm, see Algorithms.
+	Rate        int           // Maximum number of requests allowed in the window.
+	Window      time.Duration // Duration of the window.
+	Burst       int           // Size of the bursts of token buckets, zero for the rate.
+}
+
+// Names of the presets returned by LookupPreset.
+const (
+	LoginStrictPreset     = "login-strict"
+	APIDefaultPreset      = "api-default"
+	WebhookSenderPreset   = "webhook-sender"
+	PublicAnonymousPreset = "public-anonymous"
+)
+
+// Presets lists the presets returned by LookupPreset.
+var Presets = []Preset{
+	{
+		Name:        LoginStrictPreset,
+		Description: "login and password reset attempts per client, slowing down credential stuffing",
+		Algorithm:   SlidingWindowAlgorithm,
+		Rate:        5,
+		Window:      15 * time.Minute,
+	},
+	{
+		Name:        APIDefaultPreset,
+		Description: "authenticated API calls per key, allowing short bursts",
+		Algorithm:   TokenBucketAlgorithm,
+		Rate:        600,
+		Window:      time.Minute,
+		Burst:       100,
+	},
+	{
+		Name:        WebhookSenderPreset,
+		Description: "outgoing webhook deliveries per destination, evenly paced",
+		Algorithm:   LeakyBucketAlgorithm,
+		Rate:        10,
+		Window:      time.Second,
+	},
+	{
+		Name:        PublicAnonymousPreset,
+		Description: "unauthenticated requests per client IP address",
+		Algorithm:   SlidingWindowAlgorithm,
+		Rate:        60,
+		Window:      time.Minute,
+	},
+}
+
+// LookupPreset returns the preset named name, if any.
+func LookupPreset(name string) (Preset, bool) {
+	for _, preset := range Presets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+// NewAlgorithm returns a function creating instances of the algorithm of the
+// preset, for use with NewKeyed.
+func (p Preset) NewAlgorithm() func() Algorithm {
+	if p.Algorithm == TokenBucketAlgorithm && p.Burst > 0 {
+		return func() Algorithm { return NewTokenBucket(p.Rate, p.Window, p.Burst) }
+	}
+	newAlgorithm, err := AlgorithmFactory(p.Algorithm, p.Rate, p.Window)
+	if err != nil {
+		panic(err)
+	}
+	return newAlgorithm
+}
@@ -0,0 +1,41 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresets(t *testing.T) {
+	for _, preset := range Presets {
+		found, ok := LookupPreset(preset.Name)
+		if !ok || found.Name != preset.Name {
+			t.Errorf("%s: not found", preset.Name)
+			continue
+		}
+		decision := preset.NewAlgorithm()().Allow(epoch)
+		if !decision.Allowed || decision.Algorithm != preset.Algorithm {
+			t.Errorf("%s: first decision %+v, want allowed by %s", preset.Name, decision, preset.Algorithm)
+		}
+	}
+	if _, ok := LookupPreset("unknown"); ok {
+		t.Error("unknown preset found")
+	}
+}
+
+func TestPresetBurst(t *testing.T) {
+	preset, _ := LookupPreset(APIDefaultPreset)
+	algorithm := preset.NewAlgorithm()()
+	allowed := 0
+	for algorithm.Allow(epoch).Allowed {
+		allowed++
+	}
+	if allowed != preset.Burst {
+		t.Errorf("%d requests allowed at once, want the burst of %d", allowed, preset.Burst)
+	}
+
+	// Presets without burst use the rate.
+	login, _ := LookupPreset(LoginStrictPreset)
+	if decision := login.NewAlgorithm()().Allow(epoch); decision.Limit != 5 || decision.Window != 15*time.Minute {
+		t.Errorf("login decision %+v, want 5 per 15m", decision)
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is the RFC 7807 problem details body of the denials rendered by
+// WithProblemJSON, extended with the state of the limit.
+type Problem struct {
+	Type       string         `json:"type"`             // URI identifying the problem type.
+	Title      string         `json:"title"`            // Short summary of the problem type.
+	Status     int            `json:"status"`           // HTTP status code of the response.
+	Detail     string         `json:"detail,omitempty"` // Explanation specific to this occurrence.
+	Limit      int            `json:"limit"`            // Maximum number of requests allowed in the window.
+	Remaining  int            `json:"remaining"`        // Number of requests that can still be made in the current window.
+	Reset      int            `json:"reset"`            // Number of seconds until the budget is fully replenished.
+	RetryAfter int            `json:"retryAfter"`       // Number of seconds until the next request would be allowed.
+	Reason     Reason         `json:"reason,omitempty"` // Why the request was denied.
+	Policy     *ProblemPolicy `json:"policy,omitempty"` // Policy that denied the request, if its window is known.
+}
+
+// ProblemPolicy describes the policy that denied a request in a Problem.
+type ProblemPolicy struct {
+	Name   string `json:"name"`   // Name of the policy.
+	Limit  int    `json:"limit"`  // Maximum number of requests allowed in the window.
+	Window int    `json:"window"` // Number of seconds of the window.
+}
+
+// WithProblemJSON renders denials as application/problem+json bodies with the
+// machine-readable state of the limit, see Problem. problemType is the URI of
+// the problem type, "about:blank" if empty.
+func WithProblemJSON(problemType string) Option {
+	return func(m *middleware) {
+		if problemType == "" {
+			problemType = "about:blank"
+		}
+		m.onLimitReached = func(w http.ResponseWriter, r *http.Request, decision Decision) {
+			problem := Problem{
+				Type:       problemType,
+				Title:      http.StatusText(http.StatusTooManyRequests),
+				Status:     http.StatusTooManyRequests,
+				Detail:     "The rate limit of the client is exceeded.",
+				Limit:      decision.Limit,
+				Remaining:  decision.Remaining,
+				Reset:      ceilSeconds(decision.ResetAfter),
+				RetryAfter: ceilSeconds(decision.RetryAfter),
+				Reason:     decision.Reason,
+			}
+			if decision.Window > 0 {
+				problem.Policy = &ProblemPolicy{
+					Name:   m.policyName,
+					Limit:  decision.Limit,
+					Window: ceilSeconds(decision.Window),
+				}
+			}
+
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(problem.Status)
+			json.NewEncoder(w).Encode(problem)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithProblemJSON(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	responses := record(t, limiter, 2, WithProblemJSON(""), WithPolicyName("per-ip"))
+	if responses[0].Body.Len() != 0 {
+		t.Errorf("allowed request: body %q, want the handler's", responses[0].Body)
+	}
+
+	w := responses[1]
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("denied request: status %d, Content-Type %q, want a problem", w.Code, w.Header().Get("Content-Type"))
+	}
+	var problem Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body %q: %v", w.Body, err)
+	}
+	want := Problem{
+		Type:       "about:blank",
+		Title:      "Too Many Requests",
+		Status:     http.StatusTooManyRequests,
+		Detail:     "The rate limit of the client is exceeded.",
+		Limit:      1,
+		Remaining:  0,
+		Reset:      61,
+		RetryAfter: 61,
+		Reason:     ReasonRateLimit,
+	}
+	policy := problem.Policy
+	problem.Policy = nil
+	if problem != want {
+		t.Errorf("problem %+v, want %+v", problem, want)
+	}
+	if policy == nil || *policy != (ProblemPolicy{Name: "per-ip", Limit: 1, Window: 60}) {
+		t.Errorf("policy %+v, want per-ip of 1 per 60s", policy)
+	}
+}
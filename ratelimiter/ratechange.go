@@ -0,0 +1,26 @@
+package ratelimiter
+
+import "time"
+
+// RateChange reports that a limiter changed the rate it enforces on its own,
+// as adaptive limiters do, so operators can chart and audit their controller.
+type RateChange struct {
+	Key     string    // Key whose rate changed, empty for every key.
+	Time    time.Time // Time of the change.
+	Old     Policy    // Policy enforced before the change.
+	New     Policy    // Policy enforced after the change.
+	Trigger string    // What caused the change, e.g. "latency" or "errors".
+}
+
+// RateChangeFunc is notified of the rate changes of a limiter.
+type RateChangeFunc func(change RateChange)
+
+// RateChangeNotifier is implemented by the limiters adjusting their rate on
+// their own, such as adaptive or AIMD limiters, and those of
+// AnomalyDetector.Tighten.
+type RateChangeNotifier interface {
+	// NotifyRateChanges adds a function notified of every change of the
+	// effective rate. It is called from the goroutine making the change, so it
+	// should not block.
+	NotifyRateChanges(f RateChangeFunc)
+}
@@ -0,0 +1,175 @@
+// Package ratelimiter provides the rate limiting algorithms described in the
+// repository README as a library, together with helpers to plug them into
+// HTTP servers.
+package ratelimiter
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decision is the result of a single rate limit check.
+type Decision struct {
+	Allowed    bool          // Whether the request is allowed.
+	Limit      int           // Maximum number of requests allowed in the window.
+	Remaining  int           // Number of requests that can still be made in the current window.
+	ResetAfter time.Duration // Time until the budget is fully replenished.
+	RetryAfter time.Duration // Time until the next request would be allowed, zero if allowed.
+	Window     time.Duration // Duration of the window the limit applies to.
+	Algorithm  string        // Name of the algorithm that made the decision, see Algorithms.
+	Reason     Reason        // Why the request was denied, empty if allowed.
+}
+
+// Algorithm is a rate limiting algorithm tracking the requests of a single client.
+type Algorithm interface {
+	// Allow determines whether a new request at requestTime should be allowed.
+	Allow(requestTime time.Time) Decision
+
+	// AllowN determines whether a new request costing n units at requestTime
+	// should be allowed.
+	AllowN(requestTime time.Time, n int) Decision
+
+	// Reserve accounts for a new request at requestTime even if the window is
+	// full, and returns how long the caller must wait before sending it.
+	Reserve(requestTime time.Time) time.Duration
+}
+
+// Limiter decides whether requests identified by a key should be allowed. The
+// key may reference a reused buffer, implementations must copy it before
+// retaining it.
+type Limiter interface {
+	// Allow determines whether a new request for key at requestTime should be allowed.
+	Allow(key string, requestTime time.Time) Decision
+
+	// AllowN determines whether a new request for key costing n units at
+	// requestTime should be allowed. A cost of zero reports the state of the
+	// key without consuming anything.
+	AllowN(key string, requestTime time.Time, n int) Decision
+}
+
+// Keyed is a Limiter that tracks a separate Algorithm instance for every key.
+type Keyed struct {
+	mu           sync.Mutex
+	newAlgorithm func() Algorithm     // Factory creating the algorithm for a newly seen key.
+	algorithms   map[string]Algorithm // Map to hold the algorithm instance of each key.
+	overrides    map[string]struct{}  // Set of the keys given their algorithm with SetAlgorithm.
+	evictions    uint64               // Number of keys removed by Prune.
+	policies     []Policy             // The policies enforced for every key, described once by policiesOf.
+}
+
+// NewKeyed creates a new keyed rate limiter using newAlgorithm to create the
+// algorithm instance of each key.
+func NewKeyed(newAlgorithm func() Algorithm) *Keyed {
+	return &Keyed{
+		newAlgorithm: newAlgorithm,
+		algorithms:   make(map[string]Algorithm),
+		overrides:    make(map[string]struct{}),
+		policies:     policiesOf(newAlgorithm()),
+	}
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (k *Keyed) Allow(key string, requestTime time.Time) Decision {
+	return k.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at requestTime
+// should be allowed.
+func (k *Keyed) AllowN(key string, requestTime time.Time, n int) Decision {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.algorithm(key).AllowN(requestTime, n)
+}
+
+// Reserve accounts for a new request for key at requestTime even if the window
+// is full, and returns how long the caller must wait before sending it.
+func (k *Keyed) Reserve(key string, requestTime time.Time) time.Duration {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.algorithm(key).Reserve(requestTime)
+}
+
+// Peek reports the state of key at requestTime without consuming anything, and
+// false if the key is not tracked.
+func (k *Keyed) Peek(key string, requestTime time.Time) (Decision, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	algorithm, ok := k.algorithms[key]
+	if !ok {
+		return Decision{}, false
+	}
+	return algorithm.AllowN(requestTime, 0), true
+}
+
+// Keys returns the tracked keys, in no particular order.
+func (k *Keyed) Keys() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys := make([]string, 0, len(k.algorithms))
+	for key := range k.algorithms {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Len returns the number of tracked keys.
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return len(k.algorithms)
+}
+
+// Reset forgets the requests of key, giving it its full budget back.
+func (k *Keyed) Reset(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.algorithms, key)
+	delete(k.overrides, key)
+}
+
+// resetMatching forgets the requests of the keys matching match, as Reset.
+func (k *Keyed) resetMatching(match func(key string) bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for key := range k.algorithms {
+		if match(key) {
+			delete(k.algorithms, key)
+			delete(k.overrides, key)
+		}
+	}
+}
+
+// SetAlgorithm replaces the algorithm instance of key, e.g. to give it another
+// limit. The key starts over with the state of algorithm, until it is reset,
+// and is never pruned.
+func (k *Keyed) SetAlgorithm(key string, algorithm Algorithm) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key = strings.Clone(key)
+	k.algorithms[key] = algorithm
+	k.overrides[key] = struct{}{}
+}
+
+// algorithm returns the algorithm instance of key, creating it if needed.
+func (k *Keyed) algorithm(key string) Algorithm {
+	algorithm, ok := k.algorithms[key]
+	if !ok {
+		algorithm = k.newAlgorithm()
+		k.algorithms[strings.Clone(key)] = algorithm
+	}
+	return algorithm
+}
+
+// durationFromSeconds converts a floating point number of seconds to a time.Duration.
+func durationFromSeconds(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,69 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedSeparatesKeys(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	for i, test := range []struct {
+		key     string
+		allowed bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"a", false},
+	} {
+		if decision := limiter.Allow(test.key, epoch); decision.Allowed != test.allowed {
+			t.Errorf("request %d for %s: allowed %t, want %t", i, test.key, decision.Allowed, test.allowed)
+		}
+	}
+
+	limiter.Reset("a")
+	if !limiter.Allow("a", epoch).Allowed {
+		t.Error("request denied after a reset")
+	}
+}
+
+func TestKeyedCopiesKeys(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	buf := []byte("a")
+	limiter.Allow(string(buf), epoch)
+	buf[0] = 'b'
+	if _, ok := limiter.Peek("a", epoch); !ok {
+		t.Error("key changed with the buffer it was read from")
+	}
+}
+
+func TestKeyedPeek(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(2, time.Minute) })
+	if _, ok := limiter.Peek("a", epoch); ok {
+		t.Error("untracked key peeked")
+	}
+	limiter.Allow("a", epoch)
+	for range 2 {
+		if decision, ok := limiter.Peek("a", epoch); !ok || decision.Remaining != 1 {
+			t.Errorf("peek %+v, %t, want 1 remaining", decision, ok)
+		}
+	}
+	if limiter.Len() != 1 {
+		t.Errorf("%d keys tracked, want 1", limiter.Len())
+	}
+}
+
+func TestKeyedSetAlgorithm(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	limiter.SetAlgorithm("vip", NewSlidingWindow(3, time.Minute))
+	for range 3 {
+		if !limiter.Allow("vip", epoch).Allowed {
+			t.Fatal("request within the overridden limit denied")
+		}
+	}
+	// A reset gives the key the default algorithm back.
+	limiter.Reset("vip")
+	limiter.Allow("vip", epoch)
+	if limiter.Allow("vip", epoch).Allowed {
+		t.Error("override kept after a reset")
+	}
+}
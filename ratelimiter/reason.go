@@ -0,0 +1,92 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// Reason is a machine-readable code telling why a request was denied, so
+// callers and logs can tell the denials apart.
+type Reason string
+
+const (
+	// ReasonRateLimit is set by the algorithms: the key used up its budget.
+	ReasonRateLimit Reason = "rate_limit"
+	// ReasonGlobalLimit is set by limiters shared by every client, see LabelDenials.
+	ReasonGlobalLimit Reason = "global_limit"
+	// ReasonQuotaExhausted is set by limiters enforcing long-term quotas, such
+	// as monthly plans, once the quota is used up.
+	ReasonQuotaExhausted Reason = "quota_exhausted"
+	// ReasonDenylist is set by limiters denying the keys of a denylist.
+	ReasonDenylist Reason = "denylist"
+	// ReasonBackendFailure is set by limiters failing closed because their
+	// backend is unreachable.
+	ReasonBackendFailure Reason = "backend_failure"
+	// ReasonConcurrency is set by the middleware when the key has too many
+	// requests in flight, see WithMaxInFlight.
+	ReasonConcurrency Reason = "concurrency"
+	// ReasonCostExceedsLimit is set by the middleware when the cost of the
+	// request exceeds the limit of the key, so it can never be allowed,
+	// however long it waits, see WithCostFunc.
+	ReasonCostExceedsLimit Reason = "cost_exceeds_limit"
+)
+
+// LabelDenials returns limiter reporting reason for its denials, e.g.
+// ReasonGlobalLimit for a limiter shared by every client.
+func LabelDenials(limiter Limiter, reason Reason) Limiter {
+	return &labeled{limiter: limiter, reason: reason}
+}
+
+// labeled is a Limiter overriding the reason of the denials of another.
+type labeled struct {
+	limiter Limiter // The limiter making the decisions.
+	reason  Reason  // The reason reported for the denials.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (l *labeled) Allow(key string, requestTime time.Time) Decision {
+	return l.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed.
+func (l *labeled) AllowN(key string, requestTime time.Time, n int) Decision {
+	return l.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, passed to the wrapped
+// limiter.
+func (l *labeled) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision {
+	decision := AllowNContext(ctx, l.limiter, key, requestTime, n)
+	if !decision.Allowed {
+		decision.Reason = l.reason
+	}
+	return decision
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (l *labeled) Policies() []Policy {
+	if reporter, ok := l.limiter.(PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (l *labeled) Healthy() error {
+	if checker, ok := l.limiter.(HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (l *labeled) Peek(key string, requestTime time.Time) (Decision, bool) {
+	if peeker, ok := l.limiter.(Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return Decision{}, false
+}
@@ -0,0 +1,35 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlgorithmsReportReason(t *testing.T) {
+	for name, algorithm := range map[string]Algorithm{
+		"sliding window":  NewSlidingWindow(1, time.Minute),
+		"leaky bucket":    NewLeakyBucket(1, time.Minute),
+		"token bucket":    NewTokenBucket(1, time.Minute, 1),
+		"calendar window": NewCalendarWindow(1, PeriodDay, time.UTC),
+	} {
+		if decision := algorithm.Allow(epoch); decision.Reason != "" {
+			t.Errorf("%s: allowed request has reason %q", name, decision.Reason)
+		}
+		if decision := algorithm.Allow(epoch); decision.Reason != ReasonRateLimit {
+			t.Errorf("%s: denied request has reason %q, want %q", name, decision.Reason, ReasonRateLimit)
+		}
+	}
+}
+
+func TestLabelDenials(t *testing.T) {
+	limiter := LabelDenials(NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) }), ReasonGlobalLimit)
+	if decision := limiter.Allow("a", epoch); !decision.Allowed || decision.Reason != "" {
+		t.Errorf("allowed decision %+v, want no reason", decision)
+	}
+	if decision := limiter.Allow("a", epoch); decision.Allowed || decision.Reason != ReasonGlobalLimit {
+		t.Errorf("denied decision %+v, want %q", decision, ReasonGlobalLimit)
+	}
+	if policies := limiter.(PolicyReporter).Policies(); len(policies) != 1 {
+		t.Errorf("policies %+v, want those of the wrapped limiter", policies)
+	}
+}
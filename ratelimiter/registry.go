@@ -0,0 +1,88 @@
+package ratelimiter
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Registry holds independent limiters by name, such as "login", "api" and
+// "exports", so one process hosts many policies and looks them up where they
+// apply rather than wiring every limiter through. It is safe for concurrent
+// use.
+//
+//	registry := ratelimiter.NewRegistry(nil)
+//	registry.Register("exports", ratelimiter.NewKeyed(newAlgorithm))
+//	...
+//	decision := registry.MustLimiter("exports").Allow(tenant, time.Now())
+type Registry struct {
+	mu       sync.RWMutex       // Guards limiters.
+	limiters map[string]Limiter // The limiters, by name.
+}
+
+// NewRegistry creates a new registry holding limiters, by name, nil for none
+// yet.
+func NewRegistry(limiters map[string]Limiter) *Registry {
+	r := &Registry{limiters: make(map[string]Limiter, len(limiters))}
+	maps.Copy(r.limiters, limiters)
+	return r
+}
+
+// Register adds limiter under name. It fails if the name is empty or already
+// registered.
+func (r *Registry) Register(name string, limiter Limiter) error {
+	if name == "" {
+		return errors.New("ratelimiter: registering a limiter without name")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.limiters[name]; ok {
+		return fmt.Errorf("ratelimiter: limiter %s registered twice", name)
+	}
+	r.limiters[name] = limiter
+	return nil
+}
+
+// Replace swaps all the limiters of the registry for limiters at once, so
+// lookups see either the old limiters or the new ones, e.g. on a reload of
+// their configuration.
+func (r *Registry) Replace(limiters map[string]Limiter) {
+	replaced := maps.Clone(limiters)
+	if replaced == nil {
+		replaced = make(map[string]Limiter)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.limiters = replaced
+}
+
+// Limiter returns the limiter named name, if any.
+func (r *Registry) Limiter(name string) (Limiter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	limiter, ok := r.limiters[name]
+	return limiter, ok
+}
+
+// MustLimiter returns the limiter named name, and panics if there is none, for
+// the limiters a program cannot run without.
+func (r *Registry) MustLimiter(name string) Limiter {
+	limiter, ok := r.Limiter(name)
+	if !ok {
+		panic(fmt.Sprintf("ratelimiter: no limiter named %q", name))
+	}
+	return limiter
+}
+
+// Names returns the names of the limiters, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(r.limiters))
+}
@@ -0,0 +1,49 @@
+package ratelimiter
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	login := NewKeyed(func() Algorithm { return NewSlidingWindow(5, time.Minute) })
+	registry := NewRegistry(map[string]Limiter{"login": login})
+	if err := registry.Register("api", NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	for _, name := range []string{"login", ""} {
+		if err := registry.Register(name, login); err == nil {
+			t.Errorf("Register(%q) succeeded, want an error", name)
+		}
+	}
+	if names := registry.Names(); !slices.Equal(names, []string{"api", "login"}) {
+		t.Errorf("names %q, want api and login", names)
+	}
+	if limiter, ok := registry.Limiter("login"); !ok || limiter != login {
+		t.Errorf("login limiter %v, want the registered one", limiter)
+	}
+
+	// Replace swaps every limiter at once.
+	registry.Replace(map[string]Limiter{"exports": login})
+	if _, ok := registry.Limiter("api"); ok {
+		t.Error("api limiter kept after Replace")
+	}
+	if names := registry.Names(); !slices.Equal(names, []string{"exports"}) {
+		t.Errorf("names %q after Replace, want exports", names)
+	}
+	registry.Replace(nil)
+	if err := registry.Register("api", login); err != nil {
+		t.Errorf("Register after Replace(nil): %v", err)
+	}
+}
+
+func TestRegistryMustLimiter(t *testing.T) {
+	registry := NewRegistry(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("MustLimiter of an unknown name did not panic")
+		}
+	}()
+	registry.MustLimiter("login")
+}
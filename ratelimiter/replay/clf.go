@@ -0,0 +1,44 @@
+package replay
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// clfTimeFormat is the layout of the timestamps of the common log format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// clfLine matches a line of the common log format, and of the combined log
+// format with its referer and user agent.
+var clfLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "([^"]*)" (\S+) (\S+)(?: "([^"]*)" "([^"]*)")?`)
+
+// clfFields are the names of the fields of a line of the common log format, by
+// submatch index.
+var clfFields = []string{"", "host", "ident", "user", "time", "request", "status", "bytes", "referer", "agent"}
+
+// parseCLF parses the event of a line of the common or combined log format.
+func (r *Reader) parseCLF(text string) (Event, error) {
+	match := clfLine.FindStringSubmatch(text)
+	if match == nil {
+		return Event{}, errors.New("not in the common log format")
+	}
+	fields := make(map[string]string, len(clfFields)+3)
+	for i, name := range clfFields {
+		if name != "" && match[i] != "-" {
+			fields[name] = match[i]
+		}
+	}
+	request := strings.Fields(fields["request"])
+	for i, name := range []string{"method", "path", "protocol"} {
+		if i < len(request) {
+			fields[name] = request[i]
+		}
+	}
+	event, err := r.newEvent(fields["time"], fields[r.fields[1]], fields[r.fields[2]])
+	if err != nil {
+		return Event{}, err
+	}
+	event.Method, event.Path = fields["method"], fields["path"]
+	return event, nil
+}
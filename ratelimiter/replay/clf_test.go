@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+const clfInput = `203.0.113.7 - alice [20/Jan/2022:01:13:05 +0100] "GET /api/orders HTTP/1.1" 200 512
+198.51.100.1 - - [20/Jan/2022:00:13:06 +0000] "POST /login HTTP/1.1" 401 - "https://example.com/" "curl/8.0"
+garbage
+`
+
+func TestReaderCLF(t *testing.T) {
+	events, invalid := readAll(t, clfInput, CLF)
+	keys := make([]string, len(events))
+	for i, event := range events {
+		keys[i] = event.Key
+	}
+	if len(events) != 2 || !events[0].Time.Equal(epoch) || !events[1].Time.Equal(epoch.Add(time.Second)) || !slices.Equal(invalid, []int{3}) {
+		t.Fatalf("events %v, invalid lines %v, want two and line 3", events, invalid)
+	}
+	if !slices.Equal(keys, []string{"203.0.113.7", "198.51.100.1"}) {
+		t.Errorf("keys %q, want the client hosts", keys)
+	}
+
+	// The key and the cost may be other fields.
+	events, _ = readAll(t, clfInput, CLF, WithFields("", "agent", "status"))
+	if len(events) != 2 || events[0].Key != "" || events[0].Cost != 200 || events[1].Key != "curl/8.0" || events[1].Cost != 401 {
+		t.Errorf("events %v keyed by agent and costing their status, want the combined fields", events)
+	}
+}
+
+func TestReaderFields(t *testing.T) {
+	events, _ := readAll(t, `{"time": "2022-01-20T00:13:05Z", "request": {"client_ip": "203.0.113.7"}, "units": 4}`, JSONL, WithFields("time", "request.client_ip", "units"))
+	if want := (Event{Time: epoch, Key: "203.0.113.7", Cost: 4}); len(events) != 1 || events[0] != want {
+		t.Errorf("JSON events %v, want %v", events, want)
+	}
+	events, _ = readAll(t, "when,who\n2022-01-20T00:13:05Z,alice\n", CSV, WithFields("when", "who", ""))
+	if want := (Event{Time: epoch, Key: "alice", Cost: 1}); len(events) != 1 || events[0] != want {
+		t.Errorf("CSV events %v, want %v", events, want)
+	}
+}
+
+func TestReaderRequests(t *testing.T) {
+	events, _ := readAll(t, clfInput, CLF)
+	if len(events) != 2 || events[0].Method != "GET" || events[0].Path != "/api/orders" || events[1].Method != "POST" || events[1].Path != "/login" {
+		t.Errorf("access log events %v, want their requests", events)
+	}
+	events, _ = readAll(t, `{"ts": "2022-01-20T00:13:05Z", "method": "DELETE", "path": "/api/orders/1"}`, JSONL)
+	if len(events) != 1 || events[0].Method != "DELETE" || events[0].Path != "/api/orders/1" {
+		t.Errorf("JSON events %v, want their request", events)
+	}
+}
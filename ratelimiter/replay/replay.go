@@ -0,0 +1,424 @@
+// Package replay reads recorded traffic, such as exported access logs, as
+// events to evaluate against a limiter offline.
+//
+//	reader, err := replay.NewReader(os.Stdin, replay.CSV)
+//	for {
+//		event, err := reader.Read()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//		decision := limiter.AllowN(event.Key, event.Time, event.Cost)
+//	}
+//
+// Inputs are either lines of timestamps, CSV rows whose columns are the
+// timestamp, and optionally the key and the cost of the request, or JSON lines
+// such as {"ts": "2022-01-20T00:13:05Z", "key": "alice", "cost": 2}, possibly
+// with the method and path of the request. The
+// timestamps are RFC 3339 unless set otherwise with WithTimeFormat, e.g. epoch
+// seconds for most access logs. A Writer prints the decisions in the format of
+// the input, one per event.
+//
+// Readers stream their input through a buffer of fixed size, so their memory
+// does not grow with the input, and skip the lines longer than the maximum
+// line size as invalid, see WithBufferSize and WithMaxLineSize.
+package replay
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the format of the events of an input.
+type Format string
+
+const (
+	// Lines is one timestamp per line, every request costing one unit
+	// for the same key.
+	Lines Format = "lines"
+	// CSV is one request per row with the columns timestamp, key and cost, the
+	// last two being optional. A header row naming the columns may place them
+	// in any order.
+	CSV Format = "csv"
+	// JSONL is one JSON object per line with the fields ts, key and cost, the
+	// last two being optional, and the optional fields method and path, such as
+	// JSON access logs. The decisions are JSON objects too.
+	JSONL Format = "jsonl"
+	// CLF is the common or combined log format of access logs, the key being
+	// the host of the client.
+	CLF Format = "clf"
+)
+
+// Special time formats of the timestamps, besides the layouts of time.Parse.
+const (
+	UnixSeconds = "unix"   // Seconds since the Unix epoch, possibly fractional.
+	UnixMillis  = "unixms" // Milliseconds since the Unix epoch.
+)
+
+// Option configures a Reader.
+type Option func(*Reader)
+
+// WithTimeFormat sets the format of the timestamps: a layout of time.Parse,
+// UnixSeconds or UnixMillis. It defaults to time.RFC3339, and to the layout of
+// the common log format in the CLF format.
+func WithTimeFormat(format string) Option {
+	return func(r *Reader) {
+		r.timeFormat = format
+	}
+}
+
+// WithFields sets the names of the fields of the timestamp, key and cost of the
+// requests, empty to keep the default of the format: the names of the columns
+// of a CSV header row, the paths of the fields of JSON objects, dot-separated
+// for nested objects such as "request.client_ip", or the fields of the CLF
+// format: host, ident, user, method, path, protocol, status, bytes, referer
+// and agent. The timestamp of the CLF format is always its own.
+func WithFields(time, key, cost string) Option {
+	return func(r *Reader) {
+		for i, name := range []string{time, key, cost} {
+			if name != "" {
+				r.fields[i] = name
+			}
+		}
+	}
+}
+
+// Default sizes of the buffers of a Reader.
+const (
+	DefaultBufferSize  = 64 << 10 // Size of the buffer reading the input.
+	DefaultMaxLineSize = 1 << 20  // Size of the longest line of the input.
+)
+
+// WithBufferSize sets the size of the buffer reading the input,
+// DefaultBufferSize by default. Larger buffers make fewer reads of slow or
+// remote inputs.
+func WithBufferSize(size int) Option {
+	return func(r *Reader) {
+		r.bufferSize = size
+	}
+}
+
+// WithMaxLineSize sets the size of the longest line of the input, line ending
+// included, DefaultMaxLineSize by default. Longer lines are skipped as invalid
+// without being kept in memory. It does not apply to the CSV format, whose
+// quoted fields may span lines.
+func WithMaxLineSize(size int) Option {
+	return func(r *Reader) {
+		r.maxLineSize = size
+	}
+}
+
+// ErrLineTooLong is the error of the lines longer than the maximum line size,
+// see WithMaxLineSize.
+var ErrLineTooLong = errors.New("line too long")
+
+// Formats lists the formats accepted by NewReader.
+var Formats = []Format{Lines, CSV, JSONL, CLF}
+
+// Event is a recorded request.
+type Event struct {
+	Time   time.Time // Time of the request.
+	Key    string    // Key of the request, empty for inputs without keys.
+	Cost   int       // Number of units of budget the request consumes.
+	Method string    // HTTP method of the request, empty for inputs without methods.
+	Path   string    // URL path of the request, empty for inputs without paths.
+}
+
+// LineError is an invalid event of an input. Reading may go on after it.
+type LineError struct {
+	Line int   // Number of the line of the event, from 1.
+	Err  error // What is wrong with the event.
+}
+
+// Error formats the error with its line number.
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the error of the event.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the events of an input.
+type Reader struct {
+	format      Format         // The format of the input.
+	timeFormat  string         // The format of the timestamps.
+	input       *countingInput // The input, counting the bytes read.
+	bufferSize  int            // The size of the buffer reading the input.
+	maxLineSize int            // The size of the longest line of the input.
+	lines       *bufio.Reader  // The lines of the input in the Lines, JSONL and CLF formats.
+	buf         []byte         // The last line read, reused from line to line.
+	csv         *csv.Reader    // The rows of the input in the CSV format.
+	line        int            // The number of the last line read.
+	fields      [3]string      // The names of the fields of the timestamp, key and cost.
+	columns     []int          // The indexes of the timestamp, key and cost columns, -1 if absent.
+}
+
+// countingInput is an input counting the bytes read from it.
+type countingInput struct {
+	r io.Reader // The input.
+	n int64     // The number of bytes read.
+}
+
+func (c *countingInput) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewReader creates a new reader of the events of r in format.
+func NewReader(r io.Reader, format Format, opts ...Option) (*Reader, error) {
+	reader := &Reader{
+		format:      format,
+		timeFormat:  time.RFC3339,
+		input:       &countingInput{r: r},
+		bufferSize:  DefaultBufferSize,
+		maxLineSize: DefaultMaxLineSize,
+		fields:      [3]string{"ts", "key", "cost"},
+	}
+	switch format {
+	case CSV:
+		reader.fields = [3]string{"timestamp", "key", "cost"}
+	case CLF:
+		reader.timeFormat = clfTimeFormat
+		reader.fields = [3]string{"", "host", ""}
+	}
+	for _, opt := range opts {
+		opt(reader)
+	}
+	if reader.bufferSize < 16 || reader.maxLineSize < 1 {
+		return nil, fmt.Errorf("replay: invalid buffer size %d or maximum line size %d", reader.bufferSize, reader.maxLineSize)
+	}
+	switch format {
+	case Lines, JSONL, CLF:
+		reader.lines = bufio.NewReaderSize(reader.input, reader.bufferSize)
+	case CSV:
+		reader.csv = csv.NewReader(bufio.NewReaderSize(reader.input, reader.bufferSize))
+		reader.csv.FieldsPerRecord = -1
+		reader.csv.TrimLeadingSpace = true
+		reader.csv.ReuseRecord = true
+		reader.columns = []int{0, 1, 2}
+	default:
+		return nil, fmt.Errorf("replay: unknown format %q", format)
+	}
+	return reader, nil
+}
+
+// Read returns the next event of the input, a *LineError if it is invalid, or
+// io.EOF at the end of the input. Empty lines are skipped.
+func (r *Reader) Read() (Event, error) {
+	if r.format == CSV {
+		return r.readCSV()
+	}
+
+	for {
+		line, err := r.readLine()
+		if err == io.EOF {
+			return Event{}, io.EOF
+		}
+		if err != nil && err != ErrLineTooLong {
+			return Event{}, err
+		}
+		r.line++
+		if err != nil {
+			return Event{}, &LineError{Line: r.line, Err: err}
+		}
+		text := strings.TrimSpace(string(line))
+		if text == "" {
+			continue
+		}
+		event, err := r.parseLine(text)
+		if err != nil {
+			return Event{}, &LineError{Line: r.line, Err: err}
+		}
+		return event, nil
+	}
+}
+
+// Offset returns the number of bytes read from the input so far, ahead of the
+// events returned by up to the size of the buffer, to report the progress of
+// the reading.
+func (r *Reader) Offset() int64 {
+	return r.input.n
+}
+
+// readLine returns the next line of the input, or ErrLineTooLong if it is
+// longer than the maximum line size, the line being skipped. The line is only
+// valid until the next call.
+func (r *Reader) readLine() ([]byte, error) {
+	r.buf = r.buf[:0]
+	tooLong := false
+	for {
+		chunk, err := r.lines.ReadSlice('\n')
+		if !tooLong && len(r.buf)+len(chunk) > r.maxLineSize {
+			tooLong, r.buf = true, r.buf[:0]
+		}
+		if !tooLong {
+			r.buf = append(r.buf, chunk...)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && (len(r.buf) > 0 || tooLong):
+			// The last line has no line ending.
+		case err != nil:
+			return nil, err
+		}
+		if tooLong {
+			return nil, ErrLineTooLong
+		}
+		return r.buf, nil
+	}
+}
+
+// parseLine parses the event of a line in the Lines, JSONL or CLF format.
+func (r *Reader) parseLine(text string) (Event, error) {
+	switch r.format {
+	case JSONL:
+		return r.parseJSON(text)
+	case CLF:
+		return r.parseCLF(text)
+	}
+	t, err := r.parseTime(text)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Time: t, Cost: 1}, nil
+}
+
+// parseJSON parses the event of a JSON object.
+func (r *Reader) parseJSON(text string) (Event, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	// Epoch timestamps keep their precision.
+	decoder.UseNumber()
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return Event{}, err
+	}
+	field := func(path string) string {
+		var value any = object
+		for name := range strings.SplitSeq(path, ".") {
+			nested, ok := value.(map[string]any)
+			if !ok {
+				return ""
+			}
+			value = nested[name]
+		}
+		if value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+	event, err := r.newEvent(field(r.fields[0]), field(r.fields[1]), field(r.fields[2]))
+	if err != nil {
+		return Event{}, err
+	}
+	event.Method, event.Path = field("method"), field("path")
+	return event, nil
+}
+
+// newEvent returns the event of the fields of a request, an empty cost being
+// one unit.
+func (r *Reader) newEvent(timestamp, key, cost string) (Event, error) {
+	t, err := r.parseTime(timestamp)
+	if err != nil {
+		return Event{}, err
+	}
+	event := Event{Time: t, Key: key, Cost: 1}
+	if cost != "" {
+		if event.Cost, err = strconv.Atoi(cost); err != nil {
+			return Event{}, fmt.Errorf("invalid cost %q", cost)
+		}
+		if event.Cost < 0 {
+			return Event{}, fmt.Errorf("negative cost %d", event.Cost)
+		}
+	}
+	return event, nil
+}
+
+// readCSV returns the event of the next row of the input.
+func (r *Reader) readCSV() (Event, error) {
+	for {
+		row, err := r.csv.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return Event{}, &LineError{Line: parseErr.Line, Err: parseErr.Err}
+			}
+			return Event{}, err
+		}
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		line, _ := r.csv.FieldPos(0)
+		first := r.line == 0
+		r.line = line
+		if first && slices.Contains(row, r.fields[0]) {
+			r.header(row)
+			continue
+		}
+
+		event, err := r.event(row)
+		if err != nil {
+			return Event{}, &LineError{Line: line, Err: err}
+		}
+		return event, nil
+	}
+}
+
+// header locates the columns named by the header row.
+func (r *Reader) header(row []string) {
+	for i, name := range r.fields {
+		r.columns[i] = slices.Index(row, name)
+	}
+}
+
+// event parses the event of row.
+func (r *Reader) event(row []string) (Event, error) {
+	column := func(i int) string {
+		if r.columns[i] < 0 || r.columns[i] >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[r.columns[i]])
+	}
+
+	return r.newEvent(column(0), column(1), column(2))
+}
+
+// parseTime parses a timestamp in the time format of the reader.
+func (r *Reader) parseTime(s string) (time.Time, error) {
+	switch r.timeFormat {
+	case UnixSeconds:
+		// The fraction is parsed apart, as float64 seconds lose the
+		// nanoseconds of current times.
+		whole, fraction, _ := strings.Cut(s, ".")
+		if seconds, err := strconv.ParseInt(whole, 10, 64); err == nil && len(fraction) <= 9 {
+			fraction += strings.Repeat("0", 9-len(fraction))
+			if nanos, err := strconv.ParseUint(fraction, 10, 32); err == nil {
+				return time.Unix(seconds, int64(nanos)).UTC(), nil
+			}
+		}
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch seconds %q", s)
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), nil
+	case UnixMillis:
+		millis, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch milliseconds %q", s)
+		}
+		return time.UnixMilli(millis).UTC(), nil
+	default:
+		return time.Parse(r.timeFormat, s)
+	}
+}
@@ -0,0 +1,150 @@
+package replay
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAll reads the events of input in format, and returns them with the line
+// numbers of the invalid ones.
+func readAll(t *testing.T, input string, format Format, opts ...Option) ([]Event, []int) {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(input), format, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	var invalid []int
+	for {
+		event, err := reader.Read()
+		if err == io.EOF {
+			return events, invalid
+		}
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) {
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			events = append(events, event)
+			continue
+		}
+		invalid = append(invalid, lineErr.Line)
+	}
+}
+
+var epoch = time.Date(2022, 1, 20, 0, 13, 5, 0, time.UTC)
+
+func TestReaderLines(t *testing.T) {
+	events, invalid := readAll(t, "2022-01-20T00:13:05Z\n\nyesterday\n2022-01-20T00:13:06Z", Lines)
+	want := []Event{{Time: epoch, Cost: 1}, {Time: epoch.Add(time.Second), Cost: 1}}
+	if !slices.Equal(events, want) || !slices.Equal(invalid, []int{3}) {
+		t.Errorf("events %v, invalid lines %v, want %v and line 3", events, invalid, want)
+	}
+}
+
+func TestReaderCSV(t *testing.T) {
+	for name, test := range map[string]struct {
+		input   string
+		want    []Event
+		invalid []int
+	}{
+		"columns": {
+			input:   "2022-01-20T00:13:05Z,alice,2\n2022-01-20T00:13:05Z, bob\n2022-01-20T00:13:06Z,alice,many\n2022-01-20T00:13:06Z,alice,-1\n",
+			want:    []Event{{Time: epoch, Key: "alice", Cost: 2}, {Time: epoch, Key: "bob", Cost: 1}},
+			invalid: []int{3, 4},
+		},
+		"header": {
+			input: "cost,key,timestamp\n3,alice,2022-01-20T00:13:05Z\n\n,bob,2022-01-20T00:13:06Z\n",
+			want:  []Event{{Time: epoch, Key: "alice", Cost: 3}, {Time: epoch.Add(time.Second), Key: "bob", Cost: 1}},
+		},
+		"timestamps only": {
+			input: "2022-01-20T00:13:05Z\n",
+			want:  []Event{{Time: epoch, Cost: 1}},
+		},
+	} {
+		events, invalid := readAll(t, test.input, CSV)
+		if !slices.Equal(events, test.want) || !slices.Equal(invalid, test.invalid) {
+			t.Errorf("%s: events %v, invalid lines %v, want %v and %v", name, events, invalid, test.want, test.invalid)
+		}
+	}
+	if _, err := NewReader(strings.NewReader(""), "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestReaderJSONL(t *testing.T) {
+	input := `{"ts": "2022-01-20T00:13:05Z", "key": "alice", "cost": 2}
+{"ts": "2022-01-20T00:13:06Z"}
+{"ts": "2022-01-20T00:13:06Z", "cost": "two"}
+not json
+`
+	events, invalid := readAll(t, input, JSONL)
+	want := []Event{{Time: epoch, Key: "alice", Cost: 2}, {Time: epoch.Add(time.Second), Cost: 1}}
+	if !slices.Equal(events, want) || !slices.Equal(invalid, []int{3, 4}) {
+		t.Errorf("events %v, invalid lines %v, want %v and lines 3 and 4", events, invalid, want)
+	}
+}
+
+func TestReaderTimeFormats(t *testing.T) {
+	for _, test := range []struct {
+		format, input string
+		want          time.Time
+	}{
+		{UnixSeconds, "1642637585", epoch},
+		{UnixSeconds, "1642637585.25", epoch.Add(250 * time.Millisecond)},
+		{UnixSeconds, "1.6426375855e9", epoch.Add(500 * time.Millisecond)},
+		{UnixMillis, "1642637585250", epoch.Add(250 * time.Millisecond)},
+		{"2006-01-02 15:04:05", "2022-01-20 00:13:05", epoch},
+	} {
+		events, invalid := readAll(t, test.input+"\nnot a time\n", Lines, WithTimeFormat(test.format))
+		if len(events) != 1 || !events[0].Time.Equal(test.want) || !slices.Equal(invalid, []int{2}) {
+			t.Errorf("%s: events %v, invalid lines %v, want %v and line 2", test.format, events, invalid, test.want)
+		}
+	}
+	// Epoch timestamps of JSON objects may be numbers.
+	events, _ := readAll(t, `{"ts": 1642637585.5}`, JSONL, WithTimeFormat(UnixSeconds))
+	if len(events) != 1 || !events[0].Time.Equal(epoch.Add(500*time.Millisecond)) {
+		t.Errorf("JSON epoch events %v, want one at %v", events, epoch.Add(500*time.Millisecond))
+	}
+}
+
+func TestReaderLongLines(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	input := "2022-01-20T00:13:05Z\n" + long + "\n2022-01-20T00:13:06Z\n" + long
+	reader, err := NewReader(strings.NewReader(input), Lines, WithBufferSize(16), WithMaxLineSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	var invalid []int
+	for {
+		event, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !errors.Is(err, ErrLineTooLong) {
+				t.Fatalf("Read: %v, want events or too long lines", err)
+			}
+			invalid = append(invalid, err.(*LineError).Line)
+			continue
+		}
+		events = append(events, event)
+	}
+	// The lines longer than the buffer are read whole.
+	want := []Event{{Time: epoch, Cost: 1}, {Time: epoch.Add(time.Second), Cost: 1}}
+	if !slices.Equal(events, want) || !slices.Equal(invalid, []int{2, 4}) {
+		t.Errorf("events %v, invalid lines %v, want %v and lines 2 and 4", events, invalid, want)
+	}
+	if offset := reader.Offset(); offset != int64(len(input)) {
+		t.Errorf("offset %d at the end, want %d", offset, len(input))
+	}
+
+	if _, err := NewReader(strings.NewReader(""), Lines, WithBufferSize(8)); err == nil {
+		t.Error("buffer of 8 bytes accepted")
+	}
+}
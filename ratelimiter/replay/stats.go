@@ -0,0 +1,63 @@
+package replay
+
+import (
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Stats summarizes the decisions of a stream of events.
+type Stats struct {
+	Total        int `json:"total"`         // Number of requests.
+	Allowed      int `json:"allowed"`       // Number of allowed requests.
+	Denied       int `json:"denied"`        // Number of denied requests.
+	DenialBursts int `json:"denial_bursts"` // Number of runs of consecutive denied requests.
+	MaxBurst     int `json:"max_burst"`     // Largest number of requests allowed within a second.
+
+	denying bool        // Whether the last request was denied.
+	burst   []time.Time // The times of the requests allowed within the last second, oldest first.
+}
+
+// Observe counts the decision of event.
+func (s *Stats) Observe(event Event, decision ratelimiter.Decision) {
+	s.Total++
+	if !decision.Allowed {
+		s.Denied++
+		if !s.denying {
+			s.DenialBursts++
+		}
+		s.denying = true
+		return
+	}
+	s.Allowed++
+	s.denying = false
+
+	// The times are in order, so the burst is a window sliding over them.
+	start := 0
+	for start < len(s.burst) && event.Time.Sub(s.burst[start]) >= time.Second {
+		start++
+	}
+	s.burst = append(s.burst[start:], event.Time)
+	s.MaxBurst = max(s.MaxBurst, len(s.burst))
+}
+
+// DenialRatio returns the share of the requests that were denied, zero if
+// there were none.
+func (s *Stats) DenialRatio() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Denied) / float64(s.Total)
+}
+
+// Summary is the machine-readable summary of the decisions of a limiter.
+type Summary struct {
+	Limiter string `json:"limiter"` // The limiter making the decisions.
+	Stats
+	DenialRatio float64 `json:"denial_ratio"` // Share of the requests that were denied.
+}
+
+// Summarize returns the summary of the statistics of the decisions of limiter.
+func (s *Stats) Summarize(limiter string) Summary {
+	return Summary{Limiter: limiter, Stats: *s, DenialRatio: s.DenialRatio()}
+}
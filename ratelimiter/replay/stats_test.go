@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestStats(t *testing.T) {
+	var s Stats
+	if s.DenialRatio() != 0 {
+		t.Errorf("denial ratio %v without requests, want 0", s.DenialRatio())
+	}
+	for _, allowed := range []bool{true, true, false, true} {
+		s.Observe(Event{Time: epoch, Cost: 1}, ratelimiter.Decision{Allowed: allowed})
+	}
+	if s.Total != 4 || s.Allowed != 3 || s.Denied != 1 || s.DenialRatio() != 0.25 {
+		t.Errorf("stats %+v with a denial ratio of %v, want 3 of 4 allowed", s, s.DenialRatio())
+	}
+}
+
+func TestStatsBursts(t *testing.T) {
+	var s Stats
+	for _, request := range []struct {
+		at      time.Duration
+		allowed bool
+	}{
+		{0, true}, {100 * time.Millisecond, true}, {200 * time.Millisecond, false}, {300 * time.Millisecond, false},
+		{900 * time.Millisecond, true}, {1100 * time.Millisecond, true}, {1200 * time.Millisecond, false},
+		{3 * time.Second, true},
+	} {
+		s.Observe(Event{Time: epoch.Add(request.at), Cost: 1}, ratelimiter.Decision{Allowed: request.allowed})
+	}
+	// Three allowed within a second, from 0 to 900ms.
+	if s.DenialBursts != 2 || s.MaxBurst != 3 {
+		t.Errorf("%d denial bursts, max burst %d, want 2 and 3", s.DenialBursts, s.MaxBurst)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var s Stats
+	s.Observe(Event{Time: epoch, Cost: 1}, ratelimiter.Decision{Allowed: true})
+	s.Observe(Event{Time: epoch, Cost: 1}, ratelimiter.Decision{})
+	data, err := json.Marshal(s.Summarize("api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"limiter":"api","total":2,"allowed":1,"denied":1,"denial_bursts":1,"max_burst":1,"denial_ratio":0.5}`
+	if string(data) != want {
+		t.Errorf("summary %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,135 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// Source is a stream of events, such as a Reader or a Generator.
+type Source interface {
+	// Read returns the next event, or io.EOF at the end of the stream.
+	Read() (Event, error)
+}
+
+// Pattern is a pattern of synthetic arrivals.
+type Pattern string
+
+const (
+	// Constant spaces the requests evenly.
+	Constant Pattern = "constant"
+	// Poisson spaces the requests randomly, as independent arrivals.
+	Poisson Pattern = "poisson"
+	// Bursty sends the requests in bursts at once, the bursts arriving as
+	// independent arrivals.
+	Bursty Pattern = "bursty"
+)
+
+// Patterns lists the patterns accepted by NewGenerator.
+var Patterns = []Pattern{Constant, Poisson, Bursty}
+
+// Traffic describes synthetic traffic.
+type Traffic struct {
+	Pattern   Pattern       // Pattern of the arrivals.
+	Rate      float64       // Mean number of requests per second.
+	Duration  time.Duration // Duration of the traffic.
+	Start     time.Time     // Time of the start of the traffic.
+	Keys      int           // Number of keys the requests are spread over at random, one if zero.
+	BurstSize int           // Number of requests of the bursts of the Bursty pattern, ten if zero.
+	Jitter    time.Duration // Maximum random change of the intervals between requests, or bursts, e.g. to make constant traffic irregular.
+	Seed      uint64        // Seed of the source of randomness if Rand is nil, making the traffic reproducible, a random seed if zero.
+	Rand      *rand.Rand    // Source of randomness, nil for one seeded with Seed.
+}
+
+// Generator generates the events of synthetic traffic.
+type Generator struct {
+	traffic Traffic    // The traffic generated.
+	rand    *rand.Rand // The source of randomness.
+	next    time.Time  // The time of the next request, or burst of requests.
+	burst   int        // The number of requests left in the current burst.
+}
+
+// NewGenerator creates a new generator of traffic.
+func NewGenerator(traffic Traffic) (*Generator, error) {
+	switch traffic.Pattern {
+	case Constant, Poisson, Bursty:
+	default:
+		return nil, fmt.Errorf("replay: unknown pattern %q", traffic.Pattern)
+	}
+	if traffic.Rate <= 0 {
+		return nil, fmt.Errorf("replay: rate %g is not positive", traffic.Rate)
+	}
+	if traffic.Keys < 1 {
+		traffic.Keys = 1
+	}
+	if traffic.BurstSize < 1 {
+		traffic.BurstSize = 10
+	}
+	if traffic.Jitter < 0 {
+		return nil, fmt.Errorf("replay: jitter %s is negative", traffic.Jitter)
+	}
+	g := &Generator{traffic: traffic, rand: traffic.Rand}
+	if g.rand == nil {
+		g.rand = NewRand(traffic.Seed)
+	}
+	g.next = traffic.Start.Add(g.interval())
+	return g, nil
+}
+
+// Read returns the next request of the traffic, or io.EOF once its duration is
+// over.
+func (g *Generator) Read() (Event, error) {
+	if g.traffic.Pattern == Bursty && g.burst == 0 {
+		g.burst = g.traffic.BurstSize
+	}
+
+	t := g.next
+	if t.Sub(g.traffic.Start) >= g.traffic.Duration {
+		return Event{}, io.EOF
+	}
+	if g.traffic.Pattern == Bursty {
+		g.burst--
+		if g.burst == 0 {
+			g.next = g.next.Add(g.interval())
+		}
+	} else {
+		g.next = g.next.Add(g.interval())
+	}
+
+	event := Event{Time: t, Cost: 1}
+	if g.traffic.Keys > 1 {
+		event.Key = strconv.Itoa(g.rand.IntN(g.traffic.Keys))
+	}
+	return event, nil
+}
+
+// interval returns the time until the next request, or burst of requests.
+func (g *Generator) interval() time.Duration {
+	mean := float64(time.Second) / g.traffic.Rate
+	var interval time.Duration
+	switch g.traffic.Pattern {
+	case Poisson:
+		interval = time.Duration(g.rand.ExpFloat64() * mean)
+	case Bursty:
+		interval = time.Duration(g.rand.ExpFloat64() * mean * float64(g.traffic.BurstSize))
+	default:
+		interval = time.Duration(mean)
+	}
+	if g.traffic.Jitter > 0 {
+		// A uniform change between -Jitter and +Jitter, the requests staying in
+		// order.
+		interval += time.Duration(g.rand.Int64N(2*int64(g.traffic.Jitter)+1)) - g.traffic.Jitter
+	}
+	return max(interval, 0)
+}
+
+// NewRand returns a source of randomness seeded with seed, the same seed
+// giving the same values, or a random seed if zero.
+func NewRand(seed uint64) *rand.Rand {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return rand.New(rand.NewPCG(seed, 0))
+}
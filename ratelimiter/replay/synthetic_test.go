@@ -0,0 +1,102 @@
+package replay
+
+import (
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// generate returns the events of traffic.
+func generate(t *testing.T, traffic Traffic) []Event {
+	t.Helper()
+	g, err := NewGenerator(traffic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	for {
+		event, err := g.Read()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	for _, pattern := range Patterns {
+		events := generate(t, Traffic{Pattern: pattern, Rate: 100, Duration: time.Minute, Start: epoch, Keys: 3, Seed: 1})
+		// Random arrivals average the rate over a minute within a few percent.
+		if n := len(events); math.Abs(float64(n)-6000) > 600 {
+			t.Errorf("%s: %d requests in a minute, want about 6000", pattern, n)
+		}
+		keys := map[string]bool{}
+		for i, event := range events {
+			if i > 0 && event.Time.Before(events[i-1].Time) || event.Time.Sub(epoch) >= time.Minute {
+				t.Fatalf("%s: request %d at %v, want in order within the minute", pattern, i, event.Time)
+			}
+			keys[event.Key] = true
+		}
+		if len(keys) != 3 {
+			t.Errorf("%s: keys %v, want 3", pattern, keys)
+		}
+	}
+
+	events := generate(t, Traffic{Pattern: Constant, Rate: 4, Duration: time.Second, Start: epoch})
+	for i, event := range events {
+		if want := epoch.Add(time.Duration(i+1) * 250 * time.Millisecond); !event.Time.Equal(want) || event.Key != "" {
+			t.Errorf("constant request %d at %v with key %q, want %v without key", i, event.Time, event.Key, want)
+		}
+	}
+	// Bursts arrive at once.
+	events = generate(t, Traffic{Pattern: Bursty, Rate: 50, Duration: 10 * time.Second, Start: epoch, BurstSize: 5, Seed: 1})
+	for i := 0; i+5 <= len(events); i += 5 {
+		if !events[i].Time.Equal(events[i+4].Time) {
+			t.Fatalf("burst at request %d spans %v to %v, want one time", i, events[i].Time, events[i+4].Time)
+		}
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	for name, traffic := range map[string]Traffic{
+		"unknown pattern": {Pattern: "tidal", Rate: 1},
+		"zero rate":       {Pattern: Constant},
+		"negative jitter": {Pattern: Constant, Rate: 1, Jitter: -time.Second},
+	} {
+		if _, err := NewGenerator(traffic); err == nil {
+			t.Errorf("%s: created, want an error", name)
+		}
+	}
+}
+
+func TestGeneratorSeed(t *testing.T) {
+	traffic := Traffic{Pattern: Poisson, Rate: 10, Duration: 10 * time.Second, Start: epoch, Keys: 5, Seed: 42}
+	first, second := generate(t, traffic), generate(t, traffic)
+	if !slices.Equal(first, second) {
+		t.Error("traffic of the same seed differs")
+	}
+	traffic.Seed = 43
+	if slices.Equal(first, generate(t, traffic)) {
+		t.Error("traffic of another seed is the same")
+	}
+}
+
+func TestGeneratorJitter(t *testing.T) {
+	events := generate(t, Traffic{Pattern: Constant, Rate: 10, Duration: 10 * time.Second, Start: epoch, Jitter: 20 * time.Millisecond, Seed: 1})
+	irregular := false
+	for i := 1; i < len(events); i++ {
+		interval := events[i].Time.Sub(events[i-1].Time)
+		if interval < 80*time.Millisecond || interval > 120*time.Millisecond {
+			t.Fatalf("interval %v before request %d, want 100ms ± 20ms", interval, i)
+		}
+		irregular = irregular || interval != 100*time.Millisecond
+	}
+	if !irregular {
+		t.Error("constant traffic with jitter is regular")
+	}
+}
@@ -0,0 +1,175 @@
+package replay
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Timeline records the decisions of a stream of events to chart them. The
+// decisions are counted in timelineSlots slots of time, twice as long every
+// time the stream outgrows them, so the memory of a timeline and the size of
+// its chart are bounded whatever the number of events.
+type Timeline struct {
+	Title   string         // Title of the chart.
+	start   time.Time      // The time of the first request.
+	end     time.Time      // The time of the last request.
+	slot    time.Duration  // The duration of the slots.
+	slots   []timelineSlot // The decisions of each slot, from start.
+	allowed int            // The number of allowed requests.
+	denied  int            // The number of denied requests.
+}
+
+// timelineSlots is the number of slots of a Timeline, about one per pixel of
+// the plots.
+const timelineSlots = chartPlotEnd - chartMargin
+
+// timelineSlot counts the decisions of a slot of a Timeline.
+type timelineSlot struct {
+	allowed int     // The number of allowed requests.
+	denied  int     // The number of denied requests.
+	level   float64 // The largest share of the budget of the key of a request used after it, between 0 and 1.
+}
+
+// NewTimeline creates a new timeline titled title.
+func NewTimeline(title string) *Timeline {
+	return &Timeline{Title: title}
+}
+
+// Observe records the decision of event. Events before the first one are
+// counted in the first slot.
+func (t *Timeline) Observe(event Event, decision ratelimiter.Decision) {
+	level := 0.0
+	if decision.Limit > 0 {
+		level = 1 - float64(decision.Remaining)/float64(decision.Limit)
+	}
+	if t.slot == 0 {
+		t.start, t.end, t.slot = event.Time, event.Time, time.Millisecond
+	}
+	t.end = later(t.end, event.Time)
+	for event.Time.Sub(t.start) >= t.slot*timelineSlots {
+		t.widen()
+	}
+
+	i := max(int(event.Time.Sub(t.start)/t.slot), 0)
+	if i >= len(t.slots) {
+		t.slots = append(t.slots, make([]timelineSlot, i+1-len(t.slots))...)
+	}
+	slot := &t.slots[i]
+	if decision.Allowed {
+		slot.allowed++
+		t.allowed++
+	} else {
+		slot.denied++
+		t.denied++
+	}
+	slot.level = max(slot.level, min(max(level, 0), 1))
+}
+
+// widen doubles the duration of the slots, merging them in pairs.
+func (t *Timeline) widen() {
+	merged := t.slots[:(len(t.slots)+1)/2]
+	for i := range merged {
+		slot := t.slots[2*i]
+		if 2*i+1 < len(t.slots) {
+			next := t.slots[2*i+1]
+			slot = timelineSlot{allowed: slot.allowed + next.allowed, denied: slot.denied + next.denied, level: max(slot.level, next.level)}
+		}
+		merged[i] = slot
+	}
+	clear(t.slots[len(merged):])
+	t.slots = merged
+	t.slot *= 2
+}
+
+// later returns the later of a and b.
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// Dimensions of the charts, in pixels.
+const (
+	chartWidth   = 1000                             // The width of the charts.
+	chartHeight  = 260                              // The height of the charts.
+	chartMargin  = 40                               // The margin around the plots.
+	requestsRow  = 30                               // The height of the rows of the allowed and denied requests.
+	levelTop     = chartMargin + 2*requestsRow + 20 // The top of the plot of the level.
+	levelBottom  = chartHeight - chartMargin        // The bottom of the plot of the level.
+	chartPlotEnd = chartWidth - chartMargin         // The right edge of the plots.
+)
+
+// WriteHTML writes an HTML page charting timelines, one SVG chart each: the
+// requests over time colored by decision, above the level of the limiter, the
+// share of the budget of the key of every request used after it.
+func WriteHTML(w io.Writer, timelines ...*Timeline) error {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Rate limiter decisions</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+svg { display: block; margin-bottom: 2em; }
+.allowed { stroke: #2e7d32; }
+.denied { stroke: #c62828; }
+.level { fill: none; stroke: #1565c0; stroke-width: 1.5; }
+.axis { stroke: #999; }
+text { font-size: 12px; fill: #333; }
+</style></head><body>
+<h1>Rate limiter decisions</h1>
+`)
+	for _, t := range timelines {
+		t.writeSVG(&b)
+	}
+	b.WriteString("</body></html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSVG writes the chart of the timeline, one mark per slot with requests
+// of each decision, and the largest level of every slot.
+func (t *Timeline) writeSVG(b *strings.Builder) {
+	fmt.Fprintf(b, "<h2>%s</h2>\n<p>%d requests, %d allowed, %d denied</p>\n", html.EscapeString(t.Title), t.allowed+t.denied, t.allowed, t.denied)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+"\n", chartWidth, chartHeight)
+	if len(t.slots) == 0 {
+		b.WriteString("</svg>\n")
+		return
+	}
+
+	span := max(t.end.Sub(t.start), time.Second)
+	x := func(i int) float64 {
+		at := time.Duration(i)*t.slot + t.slot/2
+		return chartMargin + min(float64(at)/float64(span), 1)*(chartPlotEnd-chartMargin)
+	}
+
+	fmt.Fprintf(b, `<text x="0" y="%d">allowed</text><text x="0" y="%d">denied</text>`+"\n", chartMargin+requestsRow/2+4, chartMargin+3*requestsRow/2+4)
+	for i, slot := range t.slots {
+		if slot.allowed > 0 {
+			fmt.Fprintf(b, `<line class="allowed" x1="%.1f" x2="%.1f" y1="%d" y2="%d"/>`, x(i), x(i), chartMargin+4, chartMargin+requestsRow-4)
+		}
+		if slot.denied > 0 {
+			fmt.Fprintf(b, `<line class="denied" x1="%.1f" x2="%.1f" y1="%d" y2="%d"/>`, x(i), x(i), chartMargin+requestsRow+4, chartMargin+2*requestsRow-4)
+		}
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(b, `<text x="0" y="%d">level</text>`, levelTop+4)
+	fmt.Fprintf(b, `<line class="axis" x1="%d" x2="%d" y1="%d" y2="%d"/>`, chartMargin, chartPlotEnd, levelBottom, levelBottom)
+	fmt.Fprintf(b, `<line class="axis" x1="%d" x2="%d" y1="%d" y2="%d"/>`+"\n", chartMargin, chartMargin, levelTop, levelBottom)
+	b.WriteString(`<polyline class="level" points="`)
+	for i, slot := range t.slots {
+		if slot.allowed+slot.denied > 0 {
+			fmt.Fprintf(b, "%.1f,%.1f ", x(i), levelBottom-slot.level*(levelBottom-levelTop))
+		}
+	}
+	b.WriteString(`"/>` + "\n")
+	fmt.Fprintf(b, `<text x="%d" y="%d">%s</text><text x="%d" y="%d" text-anchor="end">%s</text>`+"\n",
+		chartMargin, chartHeight-chartMargin/2, t.start.Format(time.RFC3339),
+		chartPlotEnd, chartHeight-chartMargin/2, t.end.Format(time.RFC3339))
+	b.WriteString("</svg>\n")
+}
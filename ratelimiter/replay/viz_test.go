@@ -0,0 +1,41 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestTimelineBounded(t *testing.T) {
+	timeline := NewTimeline("a day of traffic")
+	start := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	const events = 100000
+	for i := range events {
+		event := Event{Time: start.Add(time.Duration(i) * 24 * time.Hour / events), Cost: 1}
+		timeline.Observe(event, ratelimiter.Decision{Allowed: i%10 != 0, Limit: 10, Remaining: i % 10})
+	}
+
+	if len(timeline.slots) > timelineSlots {
+		t.Errorf("%d slots, want at most %d", len(timeline.slots), timelineSlots)
+	}
+	total := 0
+	for _, slot := range timeline.slots {
+		total += slot.allowed + slot.denied
+	}
+	if total != events || timeline.denied != events/10 {
+		t.Errorf("%d requests counted with %d denied, want %d with %d denied", total, timeline.denied, events, events/10)
+	}
+
+	var b strings.Builder
+	if err := WriteHTML(&b, timeline); err != nil {
+		t.Fatal(err)
+	}
+	if marks := strings.Count(b.String(), `<line class="allowed"`); marks > timelineSlots {
+		t.Errorf("%d marks charted, want at most one per slot", marks)
+	}
+	if !strings.Contains(b.String(), "100000 requests, 90000 allowed, 10000 denied") {
+		t.Error("chart does not report the decisions")
+	}
+}
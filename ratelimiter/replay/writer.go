@@ -0,0 +1,72 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// Result is a decision printed in the JSONL format.
+type Result struct {
+	Time       time.Time `json:"ts"`               // Time of the request.
+	Key        string    `json:"key,omitempty"`    // Key of the request.
+	Cost       int       `json:"cost"`             // Number of units of budget the request consumes.
+	Allowed    bool      `json:"allowed"`          // Whether the request is allowed.
+	Remaining  int       `json:"remaining"`        // Number of requests left in the window.
+	RetryAfter float64   `json:"retry_after"`      // Seconds until the request would be allowed, zero if allowed.
+	Reason     string    `json:"reason,omitempty"` // Why the request was denied, see ratelimiter.Reason.
+}
+
+// Writer prints the decisions of the events of an input.
+type Writer struct {
+	w      io.Writer     // The output.
+	format Format        // The format of the output.
+	json   *json.Encoder // The encoder of the output in the JSONL format.
+}
+
+// NewWriter creates a new writer of decisions to w in format: "true" or
+// "false" per line in the Lines and CSV formats, a Result per line in the JSONL
+// format.
+func NewWriter(w io.Writer, format Format) *Writer {
+	return &Writer{w: w, format: format, json: json.NewEncoder(w)}
+}
+
+// Write prints the decision of event.
+func (w *Writer) Write(event Event, decision ratelimiter.Decision) error {
+	if w.format != JSONL {
+		_, err := fmt.Fprintln(w.w, decision.Allowed)
+		return err
+	}
+	return w.json.Encode(Result{
+		Time:       event.Time,
+		Key:        event.Key,
+		Cost:       event.Cost,
+		Allowed:    decision.Allowed,
+		Remaining:  decision.Remaining,
+		RetryAfter: decision.RetryAfter.Seconds(),
+		Reason:     string(decision.Reason),
+	})
+}
+
+// WriteError prints an invalid event in place of its decision, as
+// {"line": ..., "error": ...} in the JSONL format.
+func (w *Writer) WriteError(err error) error {
+	if w.format != JSONL {
+		_, err := fmt.Fprintf(w.w, "Error parsing %v\n", err)
+		return err
+	}
+	var line struct {
+		Line  int    `json:"line,omitempty"`
+		Error string `json:"error"`
+	}
+	line.Error = err.Error()
+	var lineErr *LineError
+	if errors.As(err, &lineErr) {
+		line.Line, line.Error = lineErr.Line, lineErr.Err.Error()
+	}
+	return w.json.Encode(line)
+}
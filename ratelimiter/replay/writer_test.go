@@ -0,0 +1,34 @@
+package replay
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+func TestWriter(t *testing.T) {
+	event := Event{Time: epoch, Key: "alice", Cost: 2}
+	denied := ratelimiter.Decision{Remaining: 0, RetryAfter: 1500 * time.Millisecond, Reason: ratelimiter.ReasonRateLimit}
+	for _, test := range []struct {
+		format Format
+		want   string
+	}{
+		{Lines, "true\nfalse\nError parsing line 3: invalid cost \"x\"\n"},
+		{JSONL, `{"ts":"2022-01-20T00:13:05Z","key":"alice","cost":2,"allowed":true,"remaining":3,"retry_after":0}
+{"ts":"2022-01-20T00:13:05Z","key":"alice","cost":2,"allowed":false,"remaining":0,"retry_after":1.5,"reason":"rate_limit"}
+{"line":3,"error":"invalid cost \"x\""}
+`},
+	} {
+		var b strings.Builder
+		w := NewWriter(&b, test.format)
+		w.Write(event, ratelimiter.Decision{Allowed: true, Remaining: 3})
+		w.Write(event, denied)
+		w.WriteError(&LineError{Line: 3, Err: errors.New(`invalid cost "x"`)})
+		if b.String() != test.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", test.format, b.String(), test.want)
+		}
+	}
+}
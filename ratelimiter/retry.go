@@ -0,0 +1,78 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudget bounds retries to a share of the recent successful requests, like
+// the retry budgets of Envoy and Finagle: however many clients retry, retries
+// add at most ratio to the load of a struggling server, so retry storms are
+// bounded by construction. A minimum number of retries per second lets
+// low-traffic clients retry at all.
+type RetryBudget struct {
+	mu                  sync.Mutex
+	ratio               float64       // The number of retries allowed per successful request.
+	minRetriesPerSecond int           // The number of retries always allowed per second.
+	windowDuration      time.Duration // The duration over which requests and retries are counted.
+	successes           map[int64]int // Map to hold the successful requests counted each second within the window.
+	retries             map[int64]int // Map to hold the retries counted each second within the window.
+}
+
+// NewRetryBudget creates a new retry budget allowing ratio retries per
+// successful request over windowDuration, e.g. 0.2 for 20%, plus
+// minRetriesPerSecond.
+func NewRetryBudget(ratio float64, minRetriesPerSecond int, windowDuration time.Duration) *RetryBudget {
+	return &RetryBudget{
+		ratio:               ratio,
+		minRetriesPerSecond: minRetriesPerSecond,
+		windowDuration:      windowDuration,
+		successes:           make(map[int64]int),
+		retries:             make(map[int64]int),
+	}
+}
+
+// Deposit records a successful request at requestTime, earning ratio retries.
+// The counters outside the window are cleaned up once a second, so clients
+// that never retry keep a bounded budget too.
+func (b *RetryBudget) Deposit(requestTime time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	second := requestTime.Truncate(time.Second).Unix()
+	if _, ok := b.successes[second]; !ok {
+		b.count(b.successes, requestTime)
+	}
+	b.successes[second]++
+}
+
+// Withdraw determines whether a retry at requestTime fits in the budget, and
+// records it if so.
+func (b *RetryBudget) Withdraw(requestTime time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	successes := b.count(b.successes, requestTime)
+	retries := b.count(b.retries, requestTime)
+	allowed := float64(successes)*b.ratio + float64(b.minRetriesPerSecond)*b.windowDuration.Seconds()
+	if float64(retries+1) > allowed {
+		return false
+	}
+	b.retries[requestTime.Truncate(time.Second).Unix()]++
+	return true
+}
+
+// count cleans up the counters of counts outside the window and returns the
+// sum of the others.
+func (b *RetryBudget) count(counts map[int64]int, requestTime time.Time) int {
+	windowStart := requestTime.Add(-b.windowDuration).Unix()
+	total := 0
+	for second, count := range counts {
+		if second <= windowStart {
+			delete(counts, second)
+			continue
+		}
+		total += count
+	}
+	return total
+}
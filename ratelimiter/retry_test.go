@@ -0,0 +1,37 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBudgetWithdraw(t *testing.T) {
+	budget := NewRetryBudget(0.5, 0, 10*time.Second)
+	now := time.Now()
+
+	for range 4 {
+		budget.Deposit(now)
+	}
+	for i, want := range []bool{true, true, false} {
+		if got := budget.Withdraw(now); got != want {
+			t.Errorf("retry %d: Withdraw = %t, want %t", i, got, want)
+		}
+	}
+
+	// Once the successes leave the window, so does their budget.
+	if budget.Withdraw(now.Add(11 * time.Second)) {
+		t.Error("retry allowed past the window of the successes")
+	}
+}
+
+func TestRetryBudgetDepositBounded(t *testing.T) {
+	budget := NewRetryBudget(0.2, 1, 10*time.Second)
+	start := time.Now()
+
+	for i := range time.Duration(3600) {
+		budget.Deposit(start.Add(i * time.Second))
+	}
+	if len(budget.successes) > 11 {
+		t.Errorf("%d counters kept for a 10s window", len(budget.successes))
+	}
+}
@@ -0,0 +1,36 @@
+package ratelimiter
+
+import "net/http"
+
+// WithRoute limits the requests matching pattern with limiter instead of the
+// limiter of the middleware, so one middleware can enforce a policy per route.
+// Patterns follow the syntax of http.ServeMux, e.g. "POST /users/{id}", and the
+// most specific matching pattern wins. Every route has its own budget even when
+// routes share a limiter, as keys are prefixed with the pattern. Middleware
+// panics on invalid or conflicting patterns, like http.Handle.
+func WithRoute(pattern string, limiter Limiter) Option {
+	return func(m *middleware) {
+		if m.routes == nil {
+			m.routes = http.NewServeMux()
+			m.routeLimiters = make(map[string]Limiter)
+		}
+		// The mux is only used to match patterns, its handlers are never called.
+		m.routes.Handle(pattern, http.NotFoundHandler())
+		m.routeLimiters[pattern] = limiter
+	}
+}
+
+// route returns the limiter applying to r and the key of r for that limiter.
+// The limiter is nil when r matches no route and the middleware has no limiter.
+func (m *middleware) route(r *http.Request) (Limiter, string) {
+	key := m.keyFunc(r)
+	if m.routes == nil {
+		return m.limiter, key
+	}
+	if _, pattern := m.routes.Handler(r); pattern != "" {
+		if limiter, ok := m.routeLimiters[pattern]; ok {
+			return limiter, pattern + "|" + key
+		}
+	}
+	return m.limiter, key
+}
@@ -0,0 +1,44 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithRoute(t *testing.T) {
+	strict := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Hour) })
+	lenient := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Hour) })
+	handler := Middleware(nil,
+		WithRoute("POST /login", strict),
+		WithRoute("/users/{id}", strict),
+		WithRoute("/users/{id}/avatar", lenient),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, test := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/login", http.StatusOK},
+		{http.MethodPost, "/login", http.StatusTooManyRequests},
+		// Routes sharing a limiter still have their own budget.
+		{http.MethodGet, "/users/1", http.StatusOK},
+		{http.MethodGet, "/users/2", http.StatusTooManyRequests},
+		// The most specific pattern wins.
+		{http.MethodGet, "/users/1/avatar", http.StatusOK},
+		{http.MethodGet, "/users/1/avatar", http.StatusOK},
+		// Requests matching no route are not limited without a default limiter.
+		{http.MethodGet, "/login", http.StatusOK},
+		{http.MethodGet, "/login", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.want {
+			t.Errorf("request %d, %s %s: status %d, want %d", i, test.method, test.path, w.Code, test.want)
+		}
+	}
+	if _, ok := strict.Peek("/users/{id}|192.0.2.1", time.Now()); !ok {
+		t.Errorf("keys %v, want them prefixed with the pattern", strict.Keys())
+	}
+}
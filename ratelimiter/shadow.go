@@ -0,0 +1,101 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Shadow is a Limiter that can run another limiter in shadow mode: its
+// decisions are still made and recorded, but denied requests are let through.
+// It is used to try out new limits on live traffic before enforcing them.
+type Shadow struct {
+	limiter  Limiter                             // The limiter making the decisions.
+	enabled  atomic.Bool                         // Whether shadow mode is on.
+	onDenied func(key string, decision Decision) // The function notified of the requests let through.
+}
+
+// NewShadow creates a new shadow limiter wrapping limiter, with shadow mode on
+// if enabled. onDenied, if not nil, is called for every request that would have
+// been denied and was let through.
+func NewShadow(limiter Limiter, enabled bool, onDenied func(key string, decision Decision)) *Shadow {
+	s := &Shadow{limiter: limiter, onDenied: onDenied}
+	s.enabled.Store(enabled)
+	return s
+}
+
+// Enabled reports whether shadow mode is on.
+func (s *Shadow) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// SetEnabled turns shadow mode on or off.
+func (s *Shadow) SetEnabled(enabled bool) {
+	s.enabled.Store(enabled)
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (s *Shadow) Allow(key string, requestTime time.Time) Decision {
+	return s.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed, allowing it anyway in shadow mode.
+func (s *Shadow) AllowN(key string, requestTime time.Time, n int) Decision {
+	return s.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, passed to the wrapped
+// limiter.
+func (s *Shadow) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) Decision {
+	return s.shadow(key, AllowNContext(ctx, s.limiter, key, requestTime, n))
+}
+
+// AllowNTimed is AllowNContext, also returning the time the wrapped limiter
+// spent waiting for its backend, if it has one.
+func (s *Shadow) AllowNTimed(ctx context.Context, key string, requestTime time.Time, n int) (Decision, time.Duration, bool) {
+	decision, backend, timed := AllowNTimed(ctx, s.limiter, key, requestTime, n)
+	return s.shadow(key, decision), backend, timed
+}
+
+// shadow returns the decision for key, allowed anyway in shadow mode.
+func (s *Shadow) shadow(key string, decision Decision) Decision {
+	if decision.Allowed || !s.enabled.Load() {
+		return decision
+	}
+
+	if s.onDenied != nil {
+		s.onDenied(key, decision)
+	}
+	decision.Allowed = true
+	decision.RetryAfter = 0
+	decision.Reason = ""
+	return decision
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (s *Shadow) Policies() []Policy {
+	if reporter, ok := s.limiter.(PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (s *Shadow) Healthy() error {
+	if checker, ok := s.limiter.(HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (s *Shadow) Peek(key string, requestTime time.Time) (Decision, bool) {
+	if peeker, ok := s.limiter.(Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return Decision{}, false
+}
@@ -0,0 +1,38 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShadow(t *testing.T) {
+	var denied []string
+	limiter := NewShadow(NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) }), true, func(key string, decision Decision) {
+		denied = append(denied, key)
+	})
+	now := time.Now()
+	limiter.Allow("a", now)
+
+	// Denied requests are let through and reported in shadow mode.
+	decision := limiter.Allow("a", now)
+	if !decision.Allowed || decision.RetryAfter != 0 || decision.Reason != "" || decision.Remaining != 0 {
+		t.Errorf("shadow decision %+v, want allowed with the state of the limit", decision)
+	}
+	if len(denied) != 1 || denied[0] != "a" {
+		t.Errorf("denied keys %q, want a", denied)
+	}
+
+	limiter.SetEnabled(false)
+	if limiter.Enabled() {
+		t.Error("shadow mode still on")
+	}
+	if decision := limiter.Allow("a", now); decision.Allowed || decision.Reason != ReasonRateLimit {
+		t.Errorf("enforced decision %+v, want denied", decision)
+	}
+	if len(denied) != 1 {
+		t.Errorf("%d denials reported, want the shadow one only", len(denied))
+	}
+	if policies := limiter.Policies(); len(policies) != 1 || policies[0].Limit != 1 {
+		t.Errorf("policies %+v, want those of the wrapped limiter", policies)
+	}
+}
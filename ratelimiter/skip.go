@@ -0,0 +1,96 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Matcher reports whether a request matches a condition.
+type Matcher func(r *http.Request) bool
+
+// MatchMethods matches the requests using one of methods, e.g. http.MethodOptions
+// for CORS preflight requests.
+func MatchMethods(methods ...string) Matcher {
+	return func(r *http.Request) bool {
+		return slices.Contains(methods, r.Method)
+	}
+}
+
+// MatchPaths matches the requests for one of paths. Paths ending with a slash
+// match every path below them, e.g. "/internal/".
+func MatchPaths(paths ...string) Matcher {
+	return func(r *http.Request) bool {
+		for _, path := range paths {
+			if r.URL.Path == path || strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchSubnets matches the requests whose remote peer is in one of subnets,
+// listed as CIDRs or single addresses.
+func MatchSubnets(subnets ...string) (Matcher, error) {
+	return MatchClientSubnets(KeyByIP, subnets...)
+}
+
+// MatchClientSubnets matches the requests whose client address, as returned by
+// clientIP, is in one of subnets. Behind proxies, clientIP is the KeyByClientIP
+// of the trusted proxies, so the subnets match the clients rather than the
+// proxies.
+func MatchClientSubnets(clientIP KeyFunc, subnets ...string) (Matcher, error) {
+	prefixes := make([]netip.Prefix, 0, len(subnets))
+	for _, subnet := range subnets {
+		prefix, err := parsePrefix(subnet)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimiter: invalid subnet %q: %w", subnet, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return func(r *http.Request) bool {
+		addr, ok := parseAddr(clientIP(r))
+		if !ok {
+			return false
+		}
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// WithSkip lets the requests matching any of matchers through without limiting
+// them or consuming any budget, e.g. health checks or internal traffic.
+func WithSkip(matchers ...Matcher) Option {
+	return func(m *middleware) {
+		m.skip = append(m.skip, matchers...)
+	}
+}
+
+// WithOnSkipped sets a function called for every request let through by
+// WithSkip, so skipped requests can be counted apart from the decisions.
+func WithOnSkipped(onSkipped func(r *http.Request)) Option {
+	return func(m *middleware) {
+		m.onSkipped = onSkipped
+	}
+}
+
+// skipped reports whether r is excluded from limiting, notifying onSkipped if so.
+func (m *middleware) skipped(r *http.Request) bool {
+	for _, matcher := range m.skip {
+		if matcher(r) {
+			if m.onSkipped != nil {
+				m.onSkipped(r)
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchers(t *testing.T) {
+	subnets, err := MatchSubnets("10.0.0.0/8", "192.0.2.7")
+	if err != nil {
+		t.Fatalf("MatchSubnets: %v", err)
+	}
+	tests := []struct {
+		name    string
+		matcher Matcher
+		method  string
+		path    string
+		remote  string
+		want    bool
+	}{
+		{"method", MatchMethods(http.MethodOptions), http.MethodOptions, "/", "", true},
+		{"other method", MatchMethods(http.MethodOptions), http.MethodGet, "/", "", false},
+		{"exact path", MatchPaths("/healthz", "/internal/"), http.MethodGet, "/healthz", "", true},
+		{"path below", MatchPaths("/healthz", "/internal/"), http.MethodGet, "/internal/metrics", "", true},
+		{"path prefix", MatchPaths("/healthz", "/internal/"), http.MethodGet, "/healthz/deep", "", false},
+		{"subnet", subnets, http.MethodGet, "/", "10.1.2.3:1234", true},
+		{"address", subnets, http.MethodGet, "/", "192.0.2.7:1234", true},
+		{"outside", subnets, http.MethodGet, "/", "192.0.2.8:1234", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.remote != "" {
+			r.RemoteAddr = test.remote
+		}
+		if got := test.matcher(r); got != test.want {
+			t.Errorf("%s: matched %t, want %t", test.name, got, test.want)
+		}
+	}
+
+	if _, err := MatchSubnets("10.0.0.0/33"); err == nil {
+		t.Error("invalid subnet accepted")
+	}
+}
+
+func TestMatchClientSubnets(t *testing.T) {
+	clientIP, err := KeyByClientIP([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("KeyByClientIP: %v", err)
+	}
+	matcher, err := MatchClientSubnets(clientIP, "192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("MatchClientSubnets: %v", err)
+	}
+	for _, test := range []struct {
+		name      string
+		remote    string
+		forwarded string
+		want      bool
+	}{
+		{"client behind the proxy", "10.0.0.1:1234", "192.0.2.7", true},
+		{"other client behind the proxy", "10.0.0.1:1234", "198.51.100.1", false},
+		{"proxy itself", "10.0.0.1:1234", "", false},
+		{"forged by an untrusted peer", "198.51.100.1:1234", "192.0.2.7", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := matcher(r); got != test.want {
+			t.Errorf("%s: matched %t, want %t", test.name, got, test.want)
+		}
+	}
+
+	if _, err := MatchClientSubnets(clientIP, "192.0.2.0/33"); err == nil {
+		t.Error("invalid subnet accepted")
+	}
+}
+
+func TestWithSkip(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Hour) })
+	skipped := 0
+	handler := Middleware(limiter,
+		WithSkip(MatchPaths("/healthz")),
+		WithOnSkipped(func(r *http.Request) { skipped++ }),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, test := range []struct {
+		path string
+		want int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/healthz", http.StatusOK},
+		{"/", http.StatusOK},
+		{"/", http.StatusTooManyRequests},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.want {
+			t.Errorf("request %d to %s: status %d, want %d", i, test.path, w.Code, test.want)
+		}
+		if test.path == "/healthz" && w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("request %d to %s: rate limit headers on a skipped request", i, test.path)
+		}
+	}
+	if skipped != 2 {
+		t.Errorf("%d requests skipped, want 2", skipped)
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimiter
+
+import (
+	"slices"
+	"time"
+)
+
+// SlidingWindow implements the sliding window algorithm with a counter per second.
+type SlidingWindow struct {
+	rate           int           // Maximum number of requests allowed in the windowDuration.
+	windowDuration time.Duration // Duration of the sliding window.
+	requests       map[int64]int // Map to hold request counts for each second within the window.
+}
+
+// NewSlidingWindow creates a new sliding window rate limiter instance.
+func NewSlidingWindow(rate int, windowDuration time.Duration) *SlidingWindow {
+	return &SlidingWindow{
+		rate:           rate,
+		windowDuration: windowDuration,
+		requests:       make(map[int64]int),
+	}
+}
+
+// Allow determines whether a new request at requestTime should be allowed.
+func (rl *SlidingWindow) Allow(requestTime time.Time) Decision {
+	return rl.AllowN(requestTime, 1)
+}
+
+// AllowN determines whether a new request costing n units at requestTime should
+// be allowed. A request costing more than the rate is never allowed.
+func (rl *SlidingWindow) AllowN(requestTime time.Time, n int) Decision {
+	// Round the request time down to the nearest second to group requests by second.
+	requestTimeSecond := requestTime.Truncate(time.Second).Unix()
+
+	// Clean up old requests that are outside the current window and count the number of requests in the current window.
+	startOfWindow := requestTime.Add(-rl.windowDuration).Unix()
+	currentCount := 0
+
+	for timestamp, count := range rl.requests {
+		if timestamp < startOfWindow {
+			delete(rl.requests, timestamp)
+		} else {
+			currentCount += count
+		}
+	}
+
+	// If the current window has room for the request, allow it and update the counter.
+	allowed := currentCount+n <= rl.rate
+	if allowed && n > 0 {
+		rl.requests[requestTimeSecond] += n
+		currentCount += n
+	}
+
+	decision := Decision{
+		Allowed:    allowed,
+		Limit:      rl.rate,
+		Remaining:  max(rl.rate-currentCount, 0),
+		ResetAfter: rl.resetAfter(requestTime),
+		Window:     rl.windowDuration,
+		Algorithm:  SlidingWindowAlgorithm,
+	}
+	if !allowed {
+		decision.Reason = ReasonRateLimit
+		decision.RetryAfter = rl.retryAfter(requestTime, currentCount, n)
+	}
+	return decision
+}
+
+// Reserve accounts for a new request at requestTime even if the window is full,
+// and returns how long the caller must wait before sending it.
+func (rl *SlidingWindow) Reserve(requestTime time.Time) time.Duration {
+	decision := rl.Allow(requestTime)
+	if decision.Allowed {
+		return 0
+	}
+
+	// Count the request in the second where the window has room for it again.
+	slot := requestTime.Add(decision.RetryAfter)
+	rl.requests[slot.Truncate(time.Second).Unix()]++
+	return decision.RetryAfter
+}
+
+// expiresAfter returns the time until the counter of the given second leaves the window.
+func (rl *SlidingWindow) expiresAfter(requestTime time.Time, timestamp int64) time.Duration {
+	return time.Unix(timestamp+1, 0).Add(rl.windowDuration).Sub(requestTime)
+}
+
+// resetAfter returns the time until every counter in the window has expired.
+func (rl *SlidingWindow) resetAfter(requestTime time.Time) time.Duration {
+	var latest int64
+	for timestamp := range rl.requests {
+		latest = max(latest, timestamp)
+	}
+	if len(rl.requests) == 0 {
+		return 0
+	}
+	return rl.expiresAfter(requestTime, latest)
+}
+
+// retryAfter returns the time until enough counters have expired for a new
+// request costing n units to be allowed.
+func (rl *SlidingWindow) retryAfter(requestTime time.Time, currentCount, n int) time.Duration {
+	timestamps := make([]int64, 0, len(rl.requests))
+	for timestamp := range rl.requests {
+		timestamps = append(timestamps, timestamp)
+	}
+	slices.Sort(timestamps)
+
+	// Expire the oldest counters until the window has room for the request.
+	for _, timestamp := range timestamps {
+		currentCount -= rl.requests[timestamp]
+		if currentCount+n <= rl.rate {
+			return rl.expiresAfter(requestTime, timestamp)
+		}
+	}
+	return rl.resetAfter(requestTime)
+}
@@ -0,0 +1,83 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSlidingWindow(t *testing.T) {
+	rl := NewSlidingWindow(3, 10*time.Second)
+	tests := []struct {
+		offset    time.Duration
+		allowed   bool
+		remaining int
+	}{
+		{0, true, 2},
+		{2 * time.Second, true, 1},
+		{4 * time.Second, true, 0},
+		{5 * time.Second, false, 0},
+		// The request of the first second leaves the window.
+		{11 * time.Second, true, 0},
+		{12 * time.Second, false, 0},
+	}
+	for _, test := range tests {
+		decision := rl.Allow(epoch.Add(test.offset))
+		if decision.Allowed != test.allowed || decision.Remaining != test.remaining {
+			t.Errorf("at +%s: allowed %t with %d remaining, want %t with %d", test.offset, decision.Allowed, decision.Remaining, test.allowed, test.remaining)
+		}
+	}
+}
+
+func TestSlidingWindowRetryAfter(t *testing.T) {
+	rl := NewSlidingWindow(2, 10*time.Second)
+	rl.Allow(epoch)
+	rl.Allow(epoch.Add(3 * time.Second))
+
+	decision := rl.Allow(epoch.Add(5 * time.Second))
+	if decision.Reason != ReasonRateLimit {
+		t.Errorf("reason %q, want %q", decision.Reason, ReasonRateLimit)
+	}
+	// The counter of the first second expires 11s after it started.
+	if want := 6 * time.Second; decision.RetryAfter != want {
+		t.Errorf("retry after %s, want %s", decision.RetryAfter, want)
+	}
+	if want := 9 * time.Second; decision.ResetAfter != want {
+		t.Errorf("reset after %s, want %s", decision.ResetAfter, want)
+	}
+	if !rl.Allow(epoch.Add(5 * time.Second).Add(decision.RetryAfter)).Allowed {
+		t.Error("request denied after the retry delay")
+	}
+}
+
+func TestSlidingWindowAllowN(t *testing.T) {
+	rl := NewSlidingWindow(5, time.Minute)
+	if decision := rl.AllowN(epoch, 6); decision.Allowed {
+		t.Error("request costing more than the rate allowed")
+	}
+	if decision := rl.AllowN(epoch, 4); !decision.Allowed || decision.Remaining != 1 {
+		t.Errorf("decision %+v, want allowed with 1 remaining", decision)
+	}
+	// A cost of zero reports the state without consuming anything.
+	if decision := rl.AllowN(epoch, 0); !decision.Allowed || decision.Remaining != 1 {
+		t.Errorf("peek %+v, want 1 remaining", decision)
+	}
+	if decision := rl.AllowN(epoch, 2); decision.Allowed {
+		t.Error("request over the remaining budget allowed")
+	}
+}
+
+func TestSlidingWindowReserve(t *testing.T) {
+	rl := NewSlidingWindow(1, 10*time.Second)
+	if wait := rl.Reserve(epoch); wait != 0 {
+		t.Errorf("first reservation waits %s, want none", wait)
+	}
+	if wait := rl.Reserve(epoch); wait != 11*time.Second {
+		t.Errorf("second reservation waits %s, want 11s", wait)
+	}
+	// The reserved slot is taken.
+	if rl.Allow(epoch.Add(11 * time.Second)).Allowed {
+		t.Error("request allowed in the reserved slot")
+	}
+}
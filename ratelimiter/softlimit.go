@@ -0,0 +1,33 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// WithSoftLimit warns the clients that used more than threshold of their budget,
+// e.g. 0.8 for 80%, on the responses that are still allowed, so well-behaved
+// clients can slow down before being denied. Those responses carry an
+// X-RateLimit-Warning header, and onSoftLimit, if not nil, is called for them.
+func WithSoftLimit(threshold float64, onSoftLimit func(r *http.Request, decision Decision)) Option {
+	return func(m *middleware) {
+		m.softLimit = threshold
+		m.onSoftLimit = onSoftLimit
+	}
+}
+
+// warn flags the allowed requests over the soft limit.
+func (m *middleware) warn(w http.ResponseWriter, r *http.Request, decision Decision) {
+	if m.softLimit <= 0 || !decision.Allowed || decision.Limit <= 0 {
+		return
+	}
+	used := float64(decision.Limit-decision.Remaining) / float64(decision.Limit)
+	if used < m.softLimit {
+		return
+	}
+
+	w.Header().Set("X-RateLimit-Warning", strconv.Itoa(int(used*100))+"% of the rate limit used")
+	if m.onSoftLimit != nil {
+		m.onSoftLimit(r, decision)
+	}
+}
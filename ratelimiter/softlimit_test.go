@@ -0,0 +1,26 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithSoftLimit(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(5, time.Minute) })
+	var warned []int
+	onSoftLimit := func(r *http.Request, decision Decision) {
+		warned = append(warned, decision.Remaining)
+	}
+	responses := record(t, limiter, 6, WithSoftLimit(0.8, onSoftLimit))
+
+	for i, want := range []string{"", "", "", "80% of the rate limit used", "100% of the rate limit used", ""} {
+		if got := responses[i].Header().Get("X-RateLimit-Warning"); got != want {
+			t.Errorf("request %d: X-RateLimit-Warning %q, want %q", i, got, want)
+		}
+	}
+	// Denied requests are not warned.
+	if len(warned) != 2 || warned[0] != 1 || warned[1] != 0 {
+		t.Errorf("hook called with remaining %v, want [1 0]", warned)
+	}
+}
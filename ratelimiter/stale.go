@@ -0,0 +1,52 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// CachedResponse is a response stored by a StaleCache.
+type CachedResponse struct {
+	StatusCode int         // Status code of the response.
+	Header     http.Header // Header of the response.
+	Body       []byte      // Body of the response.
+}
+
+// StaleCache provides the cached responses served to denied requests by
+// WithServeStale. Implementations decide what they store and for how long.
+type StaleCache interface {
+	// Get returns the response cached for r, whose client has the given key.
+	Get(key string, r *http.Request) (*CachedResponse, bool)
+}
+
+// WithServeStale degrades denials gracefully: denied requests for which cache
+// has a response are answered with it, flagged with a Warning: 110 header,
+// instead of 429. Requests without cached response are denied as usual.
+func WithServeStale(cache StaleCache) Option {
+	return func(m *middleware) {
+		m.staleCache = cache
+	}
+}
+
+// serveStale answers r with the response cached for it, if any.
+func (m *middleware) serveStale(w http.ResponseWriter, r *http.Request, key string) bool {
+	if m.staleCache == nil {
+		return false
+	}
+	cached, ok := m.staleCache.Get(key, r)
+	if !ok {
+		return false
+	}
+
+	h := w.Header()
+	for name, values := range cached.Header {
+		h[name] = slices.Clone(values)
+	}
+	h.Del("Retry-After")
+	h.Set("Warning", `110 - "Response is Stale"`)
+	h.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+	w.WriteHeader(cached.StatusCode)
+	w.Write(cached.Body)
+	return true
+}
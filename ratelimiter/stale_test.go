@@ -0,0 +1,45 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// staleCache is a StaleCache holding responses by path.
+type staleCache map[string]*CachedResponse
+
+func (c staleCache) Get(key string, r *http.Request) (*CachedResponse, bool) {
+	cached, ok := c[r.URL.Path]
+	return cached, ok
+}
+
+func TestWithServeStale(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Minute) })
+	cache := staleCache{"/cached": {StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"items":[]}`)}}
+	handler := Middleware(limiter, WithServeStale(cache))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	send("/")
+
+	w := send("/cached")
+	if w.Code != http.StatusOK || w.Body.String() != `{"items":[]}` {
+		t.Errorf("denied cached request: status %d, body %q, want the cached response", w.Code, w.Body)
+	}
+	checkHeaders(t, "stale", w.Header(), map[string]string{
+		"Content-Type":      "application/json",
+		"Content-Length":    "12",
+		"Warning":           `110 - "Response is Stale"`,
+		"Retry-After":       "",
+		"X-RateLimit-Limit": "1",
+	})
+
+	// Requests without cached response are denied as usual.
+	if w := send("/other"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("denied uncached request: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
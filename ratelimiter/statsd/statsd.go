@@ -0,0 +1,302 @@
+// Package statsd sends the metrics of the decisions of limiters to a StatsD or
+// DogStatsD agent, for teams on Datadog or Graphite rather than Prometheus.
+//
+//	client, err := statsd.New("127.0.0.1:8125", statsd.WithDogStatsD(), statsd.WithTags("env:prod"))
+//	defer client.Close()
+//	limiter = client.Instrument("api", limiter)
+//
+// Decisions are counted in memory and flushed periodically, so deciding never
+// waits for the network. The following metrics are sent:
+//
+//	<prefix>decisions      counter of the decisions, tagged with limiter, outcome and reason
+//	<prefix>tracked_keys   gauge of the number of keys tracked by a limiter, tagged with limiter
+//
+// Plain StatsD has no tags, so the limiter, outcome and reason are part of the
+// metric names instead, e.g. ratelimiter.api.decisions.denied.rate_limit.
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// maxPacketSize is the size of the UDP packets sent, safe for any network MTU.
+const maxPacketSize = 1432
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithPrefix sets the prefix of the metric names. It defaults to "ratelimiter.".
+func WithPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.prefix = prefix
+	}
+}
+
+// WithDogStatsD sends tags in the DogStatsD format, e.g. |#limiter:api.
+func WithDogStatsD() Option {
+	return func(c *Client) {
+		c.dogStatsD = true
+	}
+}
+
+// WithTags adds tags to every metric, e.g. "env:prod". They are only sent with
+// WithDogStatsD.
+func WithTags(tags ...string) Option {
+	return func(c *Client) {
+		c.tags = append(c.tags, tags...)
+	}
+}
+
+// WithFlushInterval sets how often the metrics are sent. It defaults to 10
+// seconds, the usual flush interval of the agents.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.flushInterval = interval
+	}
+}
+
+// counter identifies a decision counter.
+type counter struct {
+	limiter string             // The name of the instrumented limiter.
+	allowed bool               // Whether the counted decisions are allowed.
+	reason  ratelimiter.Reason // The reason of the counted denials.
+}
+
+// Client aggregates the decisions of the limiters it instruments and sends
+// them to a StatsD agent.
+type Client struct {
+	conn          net.Conn      // The connection to the agent.
+	prefix        string        // The prefix of the metric names.
+	dogStatsD     bool          // Whether to send tags in the DogStatsD format.
+	tags          []string      // The tags added to every metric.
+	flushInterval time.Duration // How often the metrics are sent.
+
+	mu       sync.Mutex
+	counts   map[counter]int64                 // Map to hold the decisions counted since the last flush.
+	counters map[string]interface{ Len() int } // Map to hold the limiters reporting their keys, by name.
+
+	stop     chan struct{} // Closed to stop the flushing goroutine.
+	stopOnce sync.Once     // Closes stop once.
+	done     chan struct{} // Closed once the flushing goroutine returned.
+}
+
+// New creates a new client sending the metrics to the agent listening on the
+// UDP address addr, e.g. "127.0.0.1:8125". Close must be called to send the
+// last metrics and stop the goroutine flushing them.
+func New(addr string, opts ...Option) (*Client, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:          conn,
+		prefix:        "ratelimiter.",
+		flushInterval: 10 * time.Second,
+		counts:        make(map[counter]int64),
+		counters:      make(map[string]interface{ Len() int }),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go c.run()
+	return c, nil
+}
+
+// Instrument returns limiter counting its decisions under name. The number of
+// tracked keys is reported if limiter can count them, as ratelimiter.Keyed does.
+func (c *Client) Instrument(name string, limiter ratelimiter.Limiter) ratelimiter.Limiter {
+	if counter, ok := limiter.(interface{ Len() int }); ok {
+		c.mu.Lock()
+		c.counters[name] = counter
+		c.mu.Unlock()
+	}
+	return &instrumented{limiter: limiter, client: c, name: name}
+}
+
+// Flush sends the metrics aggregated since the last flush.
+func (c *Client) Flush() error {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = make(map[counter]int64, len(counts))
+	var lines []string
+	for k, n := range counts {
+		lines = append(lines, c.counterLine(k, n))
+	}
+	for name, keys := range c.counters {
+		lines = append(lines, c.line(name, "tracked_keys", nil, strconv.Itoa(keys.Len())+"|g"))
+	}
+	c.mu.Unlock()
+
+	sort.Strings(lines)
+	return c.send(lines)
+}
+
+// Close sends the last metrics and closes the connection to the agent.
+func (c *Client) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+	err := c.Flush()
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// count counts a decision of the limiter named name.
+func (c *Client) count(name string, decision ratelimiter.Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[counter{limiter: name, allowed: decision.Allowed, reason: decision.Reason}]++
+}
+
+// run flushes the metrics periodically until Close.
+func (c *Client) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// counterLine formats the decision counter k.
+func (c *Client) counterLine(k counter, n int64) string {
+	outcome := "allowed"
+	if !k.allowed {
+		outcome = "denied"
+	}
+	tags := [][2]string{{"outcome", outcome}}
+	if k.reason != "" {
+		tags = append(tags, [2]string{"reason", string(k.reason)})
+	}
+	return c.line(k.limiter, "decisions", tags, strconv.FormatInt(n, 10)+"|c")
+}
+
+// line formats the metric of the limiter named limiter with its tags and
+// value, e.g. "5|c".
+func (c *Client) line(limiter, metric string, tags [][2]string, value string) string {
+	if !c.dogStatsD {
+		name := c.prefix + sanitize(limiter) + "." + metric
+		for _, tag := range tags {
+			name += "." + sanitize(tag[1])
+		}
+		return name + ":" + value
+	}
+
+	all := []string{"limiter:" + sanitize(limiter)}
+	for _, tag := range tags {
+		all = append(all, tag[0]+":"+sanitize(tag[1]))
+	}
+	all = append(all, c.tags...)
+	return c.prefix + metric + ":" + value + "|#" + strings.Join(all, ",")
+}
+
+// send sends lines to the agent, batched in packets.
+func (c *Client) send(lines []string) error {
+	var packet bytes.Buffer
+	var err error
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacketSize {
+			if _, writeErr := c.conn.Write(packet.Bytes()); writeErr != nil && err == nil {
+				err = writeErr
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if _, writeErr := c.conn.Write(packet.Bytes()); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+// sanitize replaces the characters reserved by the StatsD protocol in s.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// instrumented is a Limiter counting the decisions of another.
+type instrumented struct {
+	limiter ratelimiter.Limiter // The limiter making the decisions.
+	client  *Client             // The client counting the decisions.
+	name    string              // The name of the limiter in the metrics.
+}
+
+// Allow determines whether a new request for key at requestTime should be allowed.
+func (i *instrumented) Allow(key string, requestTime time.Time) ratelimiter.Decision {
+	return i.AllowN(key, requestTime, 1)
+}
+
+// AllowN determines whether a new request for key costing n units at
+// requestTime should be allowed. Decisions of zero cost are not counted.
+func (i *instrumented) AllowN(key string, requestTime time.Time, n int) ratelimiter.Decision {
+	return i.AllowNContext(context.Background(), key, requestTime, n)
+}
+
+// AllowNContext is AllowN for a request carrying ctx, passed to the wrapped
+// limiter.
+func (i *instrumented) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) ratelimiter.Decision {
+	decision := ratelimiter.AllowNContext(ctx, i.limiter, key, requestTime, n)
+	if n != 0 {
+		i.client.count(i.name, decision)
+	}
+	return decision
+}
+
+// Policies returns the policies enforced by the wrapped limiter, if it can
+// describe them.
+func (i *instrumented) Policies() []ratelimiter.Policy {
+	if reporter, ok := i.limiter.(ratelimiter.PolicyReporter); ok {
+		return reporter.Policies()
+	}
+	return nil
+}
+
+// Healthy reports the health of the backend of the wrapped limiter, if it has
+// one.
+func (i *instrumented) Healthy() error {
+	if checker, ok := i.limiter.(ratelimiter.HealthChecker); ok {
+		return checker.Healthy()
+	}
+	return nil
+}
+
+// Peek reports the state of key in the wrapped limiter at requestTime, if it
+// can peek at it, without consuming anything.
+func (i *instrumented) Peek(key string, requestTime time.Time) (ratelimiter.Decision, bool) {
+	if peeker, ok := i.limiter.(ratelimiter.Peeker); ok {
+		return peeker.Peek(key, requestTime)
+	}
+	return ratelimiter.Decision{}, false
+}
@@ -0,0 +1,130 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+// newAgent listens for metrics on a local UDP port, and returns a client
+// sending them to it and a function reading the lines of the next packet.
+func newAgent(t *testing.T, opts ...Option) (*Client, func() []string) {
+	t.Helper()
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	t.Cleanup(func() { agent.Close() })
+
+	client, err := New(agent.LocalAddr().String(), append([]Option{WithFlushInterval(time.Hour)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	read := func() []string {
+		t.Helper()
+		buf := make([]byte, maxPacketSize)
+		agent.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := agent.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom: %v", err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+	return client, read
+}
+
+// decide makes the decisions of three requests against a limit of two.
+func decide(limiter ratelimiter.Limiter) {
+	now := time.Now()
+	for range 3 {
+		limiter.Allow("client", now)
+	}
+	limiter.AllowN("client", now, 0)
+}
+
+func newLimiter() *ratelimiter.Keyed {
+	return ratelimiter.NewKeyed(func() ratelimiter.Algorithm { return ratelimiter.NewSlidingWindow(2, time.Hour) })
+}
+
+func TestStatsD(t *testing.T) {
+	client, read := newAgent(t)
+	decide(client.Instrument("api", newLimiter()))
+
+	if err := client.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	want := []string{
+		"ratelimiter.api.decisions.allowed:2|c",
+		"ratelimiter.api.decisions.denied.rate_limit:1|c",
+		"ratelimiter.api.tracked_keys:1|g",
+	}
+	if lines := read(); !slices.Equal(lines, want) {
+		t.Errorf("lines %q, want %q", lines, want)
+	}
+}
+
+func TestDogStatsD(t *testing.T) {
+	client, read := newAgent(t, WithDogStatsD(), WithPrefix("rl."), WithTags("env:prod"))
+	decide(client.Instrument("my api", newLimiter()))
+
+	client.Flush()
+	want := []string{
+		"rl.decisions:1|c|#limiter:my_api,outcome:denied,reason:rate_limit,env:prod",
+		"rl.decisions:2|c|#limiter:my_api,outcome:allowed,env:prod",
+		"rl.tracked_keys:1|g|#limiter:my_api,env:prod",
+	}
+	if lines := read(); !slices.Equal(lines, want) {
+		t.Errorf("lines %q, want %q", lines, want)
+	}
+
+	// The counters restart from zero after every flush.
+	client.Flush()
+	if lines := read(); !slices.Equal(lines, want[2:]) {
+		t.Errorf("lines after a second flush %q, want %q", lines, want[2:])
+	}
+}
+
+func TestSendBatchesPackets(t *testing.T) {
+	client, read := newAgent(t)
+	line := strings.Repeat("x", 600)
+	client.send([]string{line, line, line})
+
+	if lines := read(); len(lines) != 2 {
+		t.Errorf("first packet holds %d lines, want 2", len(lines))
+	}
+	if lines := read(); len(lines) != 1 {
+		t.Errorf("second packet holds %d lines, want 1", len(lines))
+	}
+}
+
+type contextKey struct{}
+
+// contextRecorder is a limiter recording the context value of its last decision.
+type contextRecorder struct {
+	ratelimiter.Limiter
+	value any // The value of contextKey of the last decision.
+}
+
+func (c *contextRecorder) AllowNContext(ctx context.Context, key string, requestTime time.Time, n int) ratelimiter.Decision {
+	c.value = ctx.Value(contextKey{})
+	return c.Limiter.AllowN(key, requestTime, n)
+}
+
+func TestInstrumentForwardsContext(t *testing.T) {
+	client, _ := newAgent(t)
+	recorder := &contextRecorder{Limiter: newLimiter()}
+	limiter := client.Instrument("api", recorder)
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "trace")
+	ratelimiter.AllowNContext(ctx, limiter, "client", time.Now(), 1)
+	if recorder.value != "trace" {
+		t.Errorf("wrapped limiter got context value %v, want the one of the request", recorder.value)
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Peeker is implemented by limiters reporting the state of a key without
+// deciding any request, such as Keyed. Wrappers implement it too, peeking at
+// the limiter they wrap.
+type Peeker interface {
+	// Peek reports the state of key at requestTime without consuming anything,
+	// and false if the key is not tracked.
+	Peek(key string, requestTime time.Time) (Decision, bool)
+}
+
+// Status is the body of the responses of StatusHandler.
+type Status struct {
+	Limit     int  `json:"limit"`     // Maximum number of requests allowed in the window.
+	Remaining int  `json:"remaining"` // Number of requests that can still be made in the current window.
+	Reset     int  `json:"reset"`     // Number of seconds until the budget is fully replenished.
+	Window    int  `json:"window"`    // Number of seconds of the window, zero if unknown.
+	Tracked   bool `json:"tracked"`   // Whether the limiter tracks the key, its budget being whole otherwise.
+}
+
+// StatusHandler returns a handler reporting the budget left to the caller,
+// keyed like the middleware given the same options, without consuming any of
+// it. API consumers can check it before sending a burst:
+//
+//	mux.Handle("GET /ratelimit/status", ratelimiter.StatusHandler(limiter))
+//
+// The response carries the same rate limit headers as the middleware, and a
+// JSON Status body. The state of the key is peeked at when the limiter is a
+// Peeker; other limiters, and keys a Peeker does not track, are reported
+// untracked with the whole budget of the policy of the limiter, if it reports
+// one, since deciding even a request of zero cost may count or store it.
+func StatusHandler(limiter Limiter, opts ...Option) http.Handler {
+	m := newMiddleware(limiter, opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter, key := m.route(r)
+		if limiter == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		now := time.Now()
+		decision, tracked := peek(limiter, key, now)
+		m.setHeaders(w.Header(), limiter, decision, now)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Status{
+			Limit:     decision.Limit,
+			Remaining: decision.Remaining,
+			Reset:     ceilSeconds(decision.ResetAfter),
+			Window:    ceilSeconds(decision.Window),
+			Tracked:   tracked,
+		})
+	})
+}
+
+// peek returns the state of key in limiter at now without deciding any
+// request, and false if limiter does not track key.
+func peek(limiter Limiter, key string, now time.Time) (Decision, bool) {
+	if peeker, ok := limiter.(Peeker); ok {
+		if decision, ok := peeker.Peek(key, now); ok {
+			return decision, true
+		}
+	}
+	decision := Decision{Allowed: true}
+	if reporter, ok := limiter.(PolicyReporter); ok {
+		if policies := reporter.Policies(); len(policies) > 0 {
+			decision.Limit, decision.Remaining, decision.Window = policies[0].Limit, policies[0].Limit, policies[0].Window
+		}
+	}
+	return decision, false
+}
@@ -0,0 +1,58 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(5, time.Minute) })
+	status := StatusHandler(limiter)
+	get := func() Status {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/ratelimit/status", nil)
+		w := httptest.NewRecorder()
+		status.ServeHTTP(w, r)
+		var s Status
+		if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if s := get(); s.Tracked || s.Limit != 5 || s.Remaining != 5 || s.Window != 60 {
+		t.Errorf("status of an untracked key = %+v, want the whole budget of the policy", s)
+	}
+	if limiter.Len() != 0 {
+		t.Errorf("status tracked %d keys, want none", limiter.Len())
+	}
+
+	limiter.Allow(KeyByIP(httptest.NewRequest(http.MethodGet, "/", nil)), time.Now())
+	if s := get(); !s.Tracked || s.Remaining != 4 {
+		t.Errorf("status of a tracked key = %+v, want 4 requests remaining", s)
+	}
+}
+
+func TestStatusHandlerWrapped(t *testing.T) {
+	keyed := NewKeyed(func() Algorithm { return NewSlidingWindow(5, time.Minute) })
+	hooked := NewHooked(keyed)
+	defer hooked.Close()
+	limiter := NewShadow(LabelDenials(hooked, ReasonRateLimit), false, nil)
+	status := StatusHandler(limiter)
+
+	key := KeyByIP(httptest.NewRequest(http.MethodGet, "/", nil))
+	limiter.AllowN(key, time.Now(), 2)
+	w := httptest.NewRecorder()
+	status.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ratelimit/status", nil))
+	var s Status
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	// The wrappers peek at the Keyed they wrap.
+	if !s.Tracked || s.Remaining != 3 {
+		t.Errorf("status through wrappers = %+v, want 3 requests remaining", s)
+	}
+}
@@ -0,0 +1,107 @@
+package ratelimiter
+
+import (
+	"context"
+	"io"
+)
+
+// StreamOption configures a rate-limited stream.
+type StreamOption func(*stream)
+
+// WithStreamContext sets the context cutting the waits of the stream short.
+// Operations fail with the error of ctx once it is done. It defaults to
+// context.Background.
+func WithStreamContext(ctx context.Context) StreamOption {
+	return func(s *stream) {
+		s.ctx = ctx
+	}
+}
+
+// WithStreamKey sets the key under which the stream consumes bandwidth, so
+// streams sharing a key share their rate. It defaults to the empty key.
+func WithStreamKey(key string) StreamOption {
+	return func(s *stream) {
+		s.key = key
+	}
+}
+
+// stream holds what a rate-limited stream needs to pace itself.
+type stream struct {
+	ctx       context.Context // The context cutting the waits short.
+	bandwidth *Bandwidth      // The bandwidth limiter pacing the stream.
+	key       string          // The key under which the stream consumes bandwidth.
+}
+
+// newStream creates the state of a stream paced by bandwidth.
+func newStream(bandwidth *Bandwidth, opts []StreamOption) stream {
+	s := stream{ctx: context.Background(), bandwidth: bandwidth}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// write writes p to w in chunks of at most a burst, waiting for the bandwidth
+// of each one.
+func (s *stream) write(w io.Writer, p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), s.bandwidth.Burst())]
+		if err := s.bandwidth.WaitN(s.ctx, s.key, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Reader is an io.Reader pacing the reads of another reader to the rate of a
+// bandwidth limiter, e.g. to throttle uploads or file ingestion.
+type Reader struct {
+	r      io.Reader // The reader being paced.
+	stream stream    // The pacing of the reads.
+}
+
+// NewReader creates a new reader reading from r at the rate of bandwidth.
+func NewReader(r io.Reader, bandwidth *Bandwidth, opts ...StreamOption) *Reader {
+	return &Reader{r: r, stream: newStream(bandwidth, opts)}
+}
+
+// Read reads at most a burst of bytes from the underlying reader, then blocks
+// until the bytes read fit in the rate.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) > r.stream.bandwidth.Burst() {
+		p = p[:r.stream.bandwidth.Burst()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.stream.bandwidth.WaitN(r.stream.ctx, r.stream.key, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Writer is an io.Writer pacing the writes to another writer to the rate of a
+// bandwidth limiter, e.g. to shape log shipping, replication or backup uploads.
+type Writer struct {
+	w      io.Writer // The writer being paced.
+	stream stream    // The pacing of the writes.
+}
+
+// NewWriter creates a new writer writing to w at the rate of bandwidth. Writes
+// of up to the burst of bandwidth go through at once when the stream was idle.
+func NewWriter(w io.Writer, bandwidth *Bandwidth, opts ...StreamOption) *Writer {
+	return &Writer{w: w, stream: newStream(bandwidth, opts)}
+}
+
+// Write writes p to the underlying writer in chunks of at most a burst, waiting
+// for the bandwidth of each one.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.stream.write(w.w, p)
+}
@@ -0,0 +1,80 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 300)
+	start := time.Now()
+	got, err := io.ReadAll(NewReader(bytes.NewReader(data), NewBandwidth(1000, 100)))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll: %d bytes, error %v, want the 300 bytes", len(got), err)
+	}
+	// The first 100 bytes are read at once, the next 200 at 1000 bytes per second.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("read in %s, want about 200ms", elapsed)
+	}
+}
+
+func TestReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := NewReader(bytes.NewReader(make([]byte, 300)), NewBandwidth(10, 100), WithStreamContext(ctx))
+	if n, err := r.Read(make([]byte, 300)); n != 100 || err != nil {
+		t.Errorf("first read: %d bytes, error %v, want a burst", n, err)
+	}
+	if _, err := r.Read(make([]byte, 300)); !errors.Is(err, context.Canceled) {
+		t.Errorf("second read: error %v, want %v", err, context.Canceled)
+	}
+}
+
+// chunkWriter records the sizes of the writes it receives.
+type chunkWriter struct {
+	bytes.Buffer
+	chunks []int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestWriter(t *testing.T) {
+	var dst chunkWriter
+	start := time.Now()
+	if n, err := NewWriter(&dst, NewBandwidth(1000, 100)).Write(make([]byte, 250)); n != 250 || err != nil {
+		t.Fatalf("Write: %d bytes, error %v, want 250", n, err)
+	}
+	if len(dst.chunks) != 3 || dst.chunks[0] != 100 || dst.chunks[2] != 50 {
+		t.Errorf("chunks %v, want [100 100 50]", dst.chunks)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("written in %s, want about 150ms", elapsed)
+	}
+}
+
+func TestWriterSharedKey(t *testing.T) {
+	bandwidth := NewBandwidth(10, 100)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	first := NewWriter(io.Discard, bandwidth, WithStreamKey("tenant"), WithStreamContext(ctx))
+	second := NewWriter(io.Discard, bandwidth, WithStreamKey("tenant"), WithStreamContext(ctx))
+	other := NewWriter(io.Discard, bandwidth, WithStreamKey("other"), WithStreamContext(ctx))
+
+	if _, err := first.Write(make([]byte, 100)); err != nil {
+		t.Fatalf("first Write: %v", err)
+	}
+	// The second stream of the tenant would wait 10s for the burst of the first.
+	if n, err := second.Write(make([]byte, 100)); n != 0 || !errors.Is(err, ErrWaitExceedsDeadline) {
+		t.Errorf("second Write: %d bytes, error %v, want %v", n, err, ErrWaitExceedsDeadline)
+	}
+	if _, err := other.Write(make([]byte, 100)); err != nil {
+		t.Errorf("Write of another key: %v", err)
+	}
+}
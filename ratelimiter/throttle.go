@@ -0,0 +1,47 @@
+package ratelimiter
+
+import (
+	"context"
+)
+
+// Throttle runs jobs no faster than a limiter allows and with a bounded number
+// of jobs in flight, for worker pools whose jobs call a downstream API with a
+// quota shared across the pool.
+type Throttle struct {
+	limiter Limiter       // The limiter pacing the jobs.
+	key     string        // The key under which the jobs are paced.
+	slots   chan struct{} // Semaphore bounding the number of jobs in flight.
+}
+
+// NewThrottle creates a new throttle pacing jobs with limiter under key and
+// running at most maxConcurrency of them at a time. A maxConcurrency below one
+// does not bound concurrency.
+func NewThrottle(limiter Limiter, key string, maxConcurrency int) *Throttle {
+	t := &Throttle{
+		limiter: limiter,
+		key:     key,
+	}
+	if maxConcurrency > 0 {
+		t.slots = make(chan struct{}, maxConcurrency)
+	}
+	return t
+}
+
+// Do waits for a free slot and for the limiter to allow another job, then runs
+// job. It returns the error of job, or the error of ctx if it is done before
+// job could start.
+func (t *Throttle) Do(ctx context.Context, job func(ctx context.Context) error) error {
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+			defer func() { <-t.slots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err := Wait(ctx, t.limiter, t.key); err != nil {
+		return err
+	}
+	return job(ctx)
+}
@@ -0,0 +1,59 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThrottleConcurrency(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })
+	throttle := NewThrottle(limiter, "api", 2)
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Go(func() {
+			throttle.Do(context.Background(), func(ctx context.Context) error {
+				n := running.Add(1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		})
+	}
+	wg.Wait()
+	if peak.Load() != 2 {
+		t.Errorf("%d jobs in flight at most, want 2", peak.Load())
+	}
+}
+
+func TestThrottlePacing(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(1, 50*time.Millisecond) })
+	throttle := NewThrottle(limiter, "api", 0)
+	start := time.Now()
+	for i := range 3 {
+		if err := throttle.Do(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+			t.Fatalf("job %d: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("3 jobs ran in %s, want one every 50ms", elapsed)
+	}
+
+	errJob := errors.New("job failed")
+	if err := throttle.Do(context.Background(), func(ctx context.Context) error { return errJob }); err != errJob {
+		t.Errorf("error %v, want the one of the job", err)
+	}
+	// Jobs are not started once ctx is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	if err := throttle.Do(ctx, func(ctx context.Context) error { ran = true; return nil }); err == nil || ran {
+		t.Errorf("job ran %t with a canceled context, error %v", ran, err)
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimiter
+
+import (
+	"math"
+	"time"
+)
+
+// TokenBucket implements the token bucket algorithm: the bucket refills at rate
+// tokens per windowDuration up to its burst size, and every request takes
+// tokens out of it. Unlike the leaky bucket, a full bucket lets a burst through
+// at once.
+type TokenBucket struct {
+	rate           float64       // The number of tokens added to the bucket per windowDuration.
+	windowDuration time.Duration // The duration over which rate tokens are added.
+	burst          float64       // The maximum number of tokens in the bucket.
+	lastUpdate     time.Time     // The last time the bucket was refilled.
+	tokens         float64       // The current number of tokens in the bucket.
+}
+
+// NewTokenBucket creates a new token bucket rate limiter instance, starting
+// full with burst tokens.
+func NewTokenBucket(rate int, windowDuration time.Duration, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:           float64(rate),
+		windowDuration: windowDuration,
+		burst:          float64(burst),
+		tokens:         float64(burst),
+	}
+}
+
+// Allow determines whether a new request at requestTime should be allowed.
+func (tb *TokenBucket) Allow(requestTime time.Time) Decision {
+	return tb.AllowN(requestTime, 1)
+}
+
+// AllowN determines whether a new request costing n tokens at requestTime
+// should be allowed. A request costing more than the burst is never allowed.
+func (tb *TokenBucket) AllowN(requestTime time.Time, n int) Decision {
+	tb.refill(requestTime)
+
+	allowed := tb.tokens >= float64(n)
+	if allowed {
+		tb.tokens -= float64(n)
+	}
+
+	decision := Decision{
+		Allowed:    allowed,
+		Limit:      int(tb.burst),
+		Remaining:  max(int(math.Floor(tb.tokens)), 0),
+		ResetAfter: durationFromSeconds((tb.burst - tb.tokens) / tb.refillRate()),
+		Window:     tb.windowDuration,
+		Algorithm:  TokenBucketAlgorithm,
+	}
+	if !allowed {
+		decision.Reason = ReasonRateLimit
+		decision.RetryAfter = durationFromSeconds((float64(n) - tb.tokens) / tb.refillRate())
+	}
+	return decision
+}
+
+// Reserve takes a token for a new request at requestTime even if the bucket is
+// empty, and returns how long the caller must wait before sending it.
+func (tb *TokenBucket) Reserve(requestTime time.Time) time.Duration {
+	return tb.ReserveN(requestTime, 1)
+}
+
+// ReserveN takes n tokens at requestTime even if the bucket does not hold them,
+// and returns how long the caller must wait until they are refilled.
+func (tb *TokenBucket) ReserveN(requestTime time.Time, n int) time.Duration {
+	tb.refill(requestTime)
+
+	// Let the bucket go into debt, the request is due once it is paid back.
+	tb.tokens -= float64(n)
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return durationFromSeconds(-tb.tokens / tb.refillRate())
+}
+
+// refill adds the tokens earned since the last update, up to the burst size.
+func (tb *TokenBucket) refill(requestTime time.Time) {
+	if !tb.lastUpdate.IsZero() {
+		elapsed := requestTime.Sub(tb.lastUpdate).Seconds()
+		tb.tokens = math.Min(tb.tokens+elapsed*tb.refillRate(), tb.burst)
+	}
+	tb.lastUpdate = requestTime
+}
+
+// refillRate returns the number of tokens added to the bucket per second.
+func (tb *TokenBucket) refillRate() float64 {
+	return tb.rate / tb.windowDuration.Seconds()
+}
@@ -0,0 +1,47 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurst(t *testing.T) {
+	tb := NewTokenBucket(1, time.Second, 3)
+	// A full bucket lets the whole burst through at once.
+	for i := range 3 {
+		if decision := tb.Allow(epoch); !decision.Allowed || decision.Remaining != 2-i {
+			t.Errorf("request %d: decision %+v, want allowed with %d remaining", i, decision, 2-i)
+		}
+	}
+	decision := tb.Allow(epoch)
+	if decision.Allowed || decision.RetryAfter != time.Second || decision.ResetAfter != 3*time.Second {
+		t.Errorf("decision %+v, want denied for 1s and reset in 3s", decision)
+	}
+	if !tb.Allow(epoch.Add(time.Second)).Allowed {
+		t.Error("request denied once a token was refilled")
+	}
+	// The bucket never holds more than the burst.
+	if decision := tb.AllowN(epoch.Add(time.Hour), 0); decision.Remaining != 3 {
+		t.Errorf("%d tokens after an hour, want 3", decision.Remaining)
+	}
+	if tb.AllowN(epoch.Add(time.Hour), 4).Allowed {
+		t.Error("request costing more than the burst allowed")
+	}
+}
+
+func TestTokenBucketReserveN(t *testing.T) {
+	tb := NewTokenBucket(10, time.Second, 10)
+	if wait := tb.ReserveN(epoch, 10); wait != 0 {
+		t.Errorf("first reservation waits %s, want none", wait)
+	}
+	// The bucket goes into debt, paid back at 10 tokens per second.
+	if wait := tb.ReserveN(epoch, 5); wait != 500*time.Millisecond {
+		t.Errorf("second reservation waits %s, want 500ms", wait)
+	}
+	if wait := tb.Reserve(epoch); wait != 600*time.Millisecond {
+		t.Errorf("third reservation waits %s, want 600ms", wait)
+	}
+	if tb.Allow(epoch.Add(500 * time.Millisecond)).Allowed {
+		t.Error("request allowed while the bucket is in debt")
+	}
+}
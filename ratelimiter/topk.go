@@ -0,0 +1,203 @@
+package ratelimiter
+
+import (
+	"container/heap"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeavyHitter is a key among the most frequent ones of an interval.
+type HeavyHitter struct {
+	Key   string `json:"key"`   // The key.
+	Count int    `json:"count"` // Estimated number of requests of the key, never below the actual number.
+	Error int    `json:"error"` // Maximum overestimation of Count.
+}
+
+// TopReport lists the keys with the most allowed and denied requests of an
+// interval, most frequent first.
+type TopReport struct {
+	Start   time.Time     `json:"start"`   // Start of the interval.
+	Allowed []HeavyHitter `json:"allowed"` // Keys with the most allowed requests.
+	Denied  []HeavyHitter `json:"denied"`  // Keys with the most denied requests.
+}
+
+// TopKeys tracks the keys with the most allowed and denied requests per
+// interval, in memory bounded by k whatever the number of keys, so operators
+// can see at once who is being throttled. It uses the Space-Saving algorithm:
+// every key more frequent than 1/k of the requests is reported, with counts
+// overestimated by at most their reported error.
+type TopKeys struct {
+	mu       sync.Mutex
+	k        int           // The number of keys tracked per outcome.
+	interval time.Duration // The duration of the intervals.
+	start    time.Time     // The start of the current interval.
+	allowed  *spaceSaving  // The counters of the allowed requests of the current interval.
+	denied   *spaceSaving  // The counters of the denied requests of the current interval.
+	previous TopReport     // The report of the previous interval.
+}
+
+// NewTopKeys creates a new tracker of the k most frequent keys per interval.
+// An interval of zero never ends, e.g. to summarize a whole access log.
+func NewTopKeys(k int, interval time.Duration) *TopKeys {
+	return &TopKeys{
+		k:        k,
+		interval: interval,
+		allowed:  newSpaceSaving(k),
+		denied:   newSpaceSaving(k),
+	}
+}
+
+// Observe counts the request for key decided at requestTime.
+func (t *TopKeys) Observe(key string, requestTime time.Time, decision Decision) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(requestTime)
+	if decision.Allowed {
+		t.allowed.add(key)
+	} else {
+		t.denied.add(key)
+	}
+}
+
+// OnDecision returns a function counting the decisions of the middleware, for
+// use with WithOnDecision.
+func (t *TopKeys) OnDecision() DecisionFunc {
+	return func(r *http.Request, key string, decision Decision) {
+		t.Observe(key, time.Now(), decision)
+	}
+}
+
+// Current reports the keys of the interval in progress at now.
+func (t *TopKeys) Current(now time.Time) TopReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(now)
+	return t.report()
+}
+
+// Previous reports the keys of the last complete interval at now.
+func (t *TopKeys) Previous(now time.Time) TopReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(now)
+	return t.previous
+}
+
+// ServeHTTP serves the reports of the current and previous intervals as JSON,
+// e.g. {"current": {...}, "previous": {...}}.
+func (t *TopKeys) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	body := struct {
+		Current  TopReport `json:"current"`
+		Previous TopReport `json:"previous"`
+	}{t.Current(now), t.Previous(now)}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// advance starts a new interval if the current one is over at now. It must be
+// called with mu held.
+func (t *TopKeys) advance(now time.Time) {
+	if t.start.IsZero() {
+		t.start = now.Truncate(t.interval)
+		return
+	}
+	if t.interval <= 0 || now.Sub(t.start) < t.interval {
+		return
+	}
+
+	t.previous = t.report()
+	if now.Sub(t.start) >= 2*t.interval {
+		// No request was seen during the previous interval.
+		t.previous = TopReport{Start: now.Truncate(t.interval).Add(-t.interval)}
+	}
+	t.start = now.Truncate(t.interval)
+	t.allowed = newSpaceSaving(t.k)
+	t.denied = newSpaceSaving(t.k)
+}
+
+// report reports the keys of the current interval. It must be called with mu
+// held.
+func (t *TopKeys) report() TopReport {
+	return TopReport{Start: t.start, Allowed: t.allowed.top(), Denied: t.denied.top()}
+}
+
+// spaceSaving holds the counters of the Space-Saving algorithm, in a min-heap
+// of their counts so the least frequent key is replaced in O(log k).
+type spaceSaving struct {
+	k        int            // The number of counters.
+	counters []HeavyHitter  // The counters, a min-heap of their counts.
+	index    map[string]int // Map to hold the index in counters of each tracked key.
+}
+
+func newSpaceSaving(k int) *spaceSaving {
+	return &spaceSaving{k: k, counters: make([]HeavyHitter, 0, k), index: make(map[string]int, k)}
+}
+
+// add counts a request of key, replacing the least frequent tracked key by key
+// if every counter is in use.
+func (s *spaceSaving) add(key string) {
+	if i, ok := s.index[key]; ok {
+		s.counters[i].Count++
+		heap.Fix(s, i)
+		return
+	}
+	if len(s.counters) < s.k {
+		heap.Push(s, HeavyHitter{Key: strings.Clone(key), Count: 1})
+		return
+	}
+	if len(s.counters) == 0 {
+		return
+	}
+
+	least := &s.counters[0]
+	delete(s.index, least.Key)
+	least.Key = strings.Clone(key)
+	least.Error = least.Count
+	least.Count++
+	s.index[least.Key] = 0
+	heap.Fix(s, 0)
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface.
+func (s *spaceSaving) Len() int           { return len(s.counters) }
+func (s *spaceSaving) Less(i, j int) bool { return s.counters[i].Count < s.counters[j].Count }
+
+func (s *spaceSaving) Swap(i, j int) {
+	s.counters[i], s.counters[j] = s.counters[j], s.counters[i]
+	s.index[s.counters[i].Key] = i
+	s.index[s.counters[j].Key] = j
+}
+
+func (s *spaceSaving) Push(x any) {
+	c := x.(HeavyHitter)
+	s.index[c.Key] = len(s.counters)
+	s.counters = append(s.counters, c)
+}
+
+func (s *spaceSaving) Pop() any {
+	c := s.counters[len(s.counters)-1]
+	s.counters = s.counters[:len(s.counters)-1]
+	delete(s.index, c.Key)
+	return c
+}
+
+// top returns the tracked keys, most frequent first.
+func (s *spaceSaving) top() []HeavyHitter {
+	top := slices.Clone(s.counters)
+	slices.SortFunc(top, func(a, b HeavyHitter) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return top
+}
@@ -0,0 +1,118 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTopKeysWithoutInterval(t *testing.T) {
+	top := NewTopKeys(2, 0)
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// A day of denials of one key, among many keys denied once.
+	for i := range 100 {
+		requestTime := start.Add(time.Duration(i) * 15 * time.Minute)
+		top.Observe("heavy", requestTime, Decision{})
+		top.Observe(fmt.Sprint("light-", i), requestTime, Decision{})
+	}
+
+	denied := top.Current(start.Add(48 * time.Hour)).Denied
+	if len(denied) != 2 || denied[0].Key != "heavy" || denied[0].Count-denied[0].Error > 100 || denied[0].Count < 100 {
+		t.Errorf("Denied = %+v, want heavy first with 100 denials", denied)
+	}
+}
+
+func TestTopKeysIntervals(t *testing.T) {
+	top := NewTopKeys(5, time.Minute)
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	top.Observe("a", start.Add(10*time.Second), Decision{Allowed: true})
+	top.Observe("a", start.Add(20*time.Second), Decision{Allowed: true})
+	top.Observe("b", start.Add(30*time.Second), Decision{})
+	top.Observe("c", start.Add(70*time.Second), Decision{Allowed: true})
+
+	previous := top.Previous(start.Add(90 * time.Second))
+	if !previous.Start.Equal(start) || len(previous.Allowed) != 1 || previous.Allowed[0] != (HeavyHitter{Key: "a", Count: 2}) ||
+		len(previous.Denied) != 1 || previous.Denied[0].Key != "b" {
+		t.Errorf("previous interval %+v, want a allowed twice and b denied from %v", previous, start)
+	}
+	current := top.Current(start.Add(90 * time.Second))
+	if !current.Start.Equal(start.Add(time.Minute)) || len(current.Allowed) != 1 || current.Allowed[0].Key != "c" || len(current.Denied) != 0 {
+		t.Errorf("current interval %+v, want c allowed once", current)
+	}
+
+	// An interval without requests is reported empty.
+	previous = top.Previous(start.Add(5 * time.Minute))
+	if !previous.Start.Equal(start.Add(4*time.Minute)) || len(previous.Allowed)+len(previous.Denied) != 0 {
+		t.Errorf("previous interval after an idle one %+v, want an empty report from %v", previous, start.Add(4*time.Minute))
+	}
+}
+
+func TestTopKeysServeHTTP(t *testing.T) {
+	top := NewTopKeys(5, 0)
+	top.Observe("a", time.Now(), Decision{})
+
+	w := httptest.NewRecorder()
+	top.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/top", nil))
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", contentType)
+	}
+	var body struct {
+		Current, Previous TopReport
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	if len(body.Current.Denied) != 1 || body.Current.Denied[0].Key != "a" || len(body.Previous.Denied) != 0 {
+		t.Errorf("report %s, want a denied in the current interval", w.Body)
+	}
+}
+
+func TestSpaceSavingBounds(t *testing.T) {
+	s := newSpaceSaving(10)
+	actual := make(map[string]int)
+	for i := range 10000 {
+		// A few heavy keys among a long tail.
+		key := fmt.Sprint("tail-", i)
+		if i%3 == 0 {
+			key = fmt.Sprint("heavy-", i%4)
+		}
+		s.add(key)
+		actual[key]++
+	}
+
+	top := s.top()
+	if len(top) != 10 {
+		t.Fatalf("%d keys tracked, want 10", len(top))
+	}
+	total := 0
+	for _, c := range top {
+		total += c.Count
+		if c.Count < actual[c.Key] || c.Count-c.Error > actual[c.Key] {
+			t.Errorf("%s: count %d with error %d, actual %d", c.Key, c.Count, c.Error, actual[c.Key])
+		}
+	}
+	if total != 10000 {
+		t.Errorf("counts sum to %d, want the 10000 requests", total)
+	}
+	for i, c := range top[:4] {
+		if c.Key[:6] != "heavy-" {
+			t.Errorf("top[%d] = %s, want the heavy keys first", i, c.Key)
+		}
+	}
+}
+
+func BenchmarkSpaceSavingAdd(b *testing.B) {
+	s := newSpaceSaving(1000)
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key-", i)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		s.add(keys[i%len(keys)])
+	}
+}
@@ -0,0 +1,212 @@
+package ratelimiter
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter/headers"
+)
+
+// KeyByHost keys outgoing requests by the host they are sent to.
+func KeyByHost(r *http.Request) string {
+	return r.URL.Host
+}
+
+// TransportOption configures a Transport.
+type TransportOption func(*Transport)
+
+// WithTransportKeyFunc sets the function used to extract the key under which a
+// request is paced. Requests are keyed by host by default.
+func WithTransportKeyFunc(keyFunc KeyFunc) TransportOption {
+	return func(t *Transport) {
+		t.keyFunc = keyFunc
+	}
+}
+
+// WithTransportReserve paces requests by reserving their slot up front instead
+// of waiting for the limiter to allow them. It is ignored unless the limiter
+// implements Reserver.
+func WithTransportReserve() TransportOption {
+	return func(t *Transport) {
+		t.reserve = true
+	}
+}
+
+// WithRetry retries the idempotent requests failing with a transport error or
+// with 429, 502, 503 and 504 responses, up to maxRetries times, as long as
+// budget allows it. Every other response is a success depositing into budget.
+// Retries honor the Retry-After of the server like any other request.
+func WithRetry(budget *RetryBudget, maxRetries int) TransportOption {
+	return func(t *Transport) {
+		t.retryBudget = budget
+		t.maxRetries = maxRetries
+	}
+}
+
+// Transport is an http.RoundTripper pacing outgoing requests with a limiter so
+// that clients stay under the quotas of the APIs they call. When a server reports
+// that the budget of the client is exhausted, through Retry-After on 429 and 503
+// responses or through any of the rate limit headers understood by the headers
+// package, the following requests for the same key are held back until the
+// server said it would reset.
+type Transport struct {
+	base        http.RoundTripper    // The round tripper sending the requests.
+	limiter     Limiter              // The limiter pacing the requests.
+	keyFunc     KeyFunc              // The function extracting the key of a request.
+	reserve     bool                 // Whether to pace requests with Reserve instead of Wait.
+	retryBudget *RetryBudget         // The budget of the retries, nil to never retry.
+	maxRetries  int                  // The number of retries of a request.
+	mu          sync.Mutex           // Protects pushback.
+	pushback    map[string]time.Time // Time until which the server asked each key to back off.
+}
+
+// NewTransport creates a new transport sending requests with base once limiter
+// allows them. A nil base uses http.DefaultTransport.
+func NewTransport(base http.RoundTripper, limiter Limiter, opts ...TransportOption) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{
+		base:     base,
+		limiter:  limiter,
+		keyFunc:  KeyByHost,
+		pushback: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip waits until the request may be sent, then sends it with the base
+// round tripper. It fails without sending the request if the context of the
+// request is done first. With WithRetry, failed idempotent requests are retried
+// as long as the retry budget allows it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.keyFunc(req)
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req, key); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			t.observe(key, resp)
+		}
+		if t.retryBudget == nil {
+			return resp, err
+		}
+		if !shouldRetry(resp, err) {
+			t.retryBudget.Deposit(time.Now())
+			return resp, err
+		}
+		if attempt >= t.maxRetries || !retryable(req) || req.Context().Err() != nil || !t.retryBudget.Withdraw(time.Now()) {
+			return resp, err
+		}
+
+		retry, rewindErr := rewind(req)
+		if rewindErr != nil {
+			return resp, err
+		}
+		req = retry
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+	}
+}
+
+// wait blocks until a request for key may be sent, honoring the server pushback first.
+func (t *Transport) wait(req *http.Request, key string) error {
+	now := time.Now()
+	t.mu.Lock()
+	until, ok := t.pushback[key]
+	if ok && !now.Before(until) {
+		delete(t.pushback, key)
+		ok = false
+	}
+	t.mu.Unlock()
+
+	if ok {
+		if err := sleep(req.Context(), now, until.Sub(now)); err != nil {
+			return err
+		}
+	}
+
+	if reserver, ok := t.limiter.(Reserver); ok && t.reserve {
+		return WaitReservation(req.Context(), reserver, key)
+	}
+	return Wait(req.Context(), t.limiter, key)
+}
+
+// observe records how long the server asked the client to back off, if at all.
+func (t *Transport) observe(key string, resp *http.Response) {
+	now := time.Now()
+	delay, ok := backoff(resp, now)
+	if !ok || delay <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until := now.Add(delay)
+	if until.After(t.pushback[key]) {
+		t.pushback[key] = until
+	}
+}
+
+// backoff returns how long the response asks the client to wait before sending
+// another request.
+func backoff(resp *http.Response, now time.Time) (time.Duration, bool) {
+	status := headers.Parse(resp.Header, now)
+	if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && status.HasRetryAfter {
+		return status.RetryAfter, true
+	}
+
+	// An exhausted budget also means waiting for the reset, whatever the status.
+	if status.Exhausted() {
+		return status.Reset, true
+	}
+	return 0, false
+}
+
+// shouldRetry reports whether the outcome of a request calls for a retry.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether req may safely be sent again: its method is
+// idempotent or it carries an idempotency key, and its body can be replayed.
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// rewind returns a copy of req ready to be sent again, with a fresh body.
+func rewind(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
@@ -0,0 +1,151 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripperFunc is an http.RoundTripper answering with a function.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// respond returns a response of the given status and headers to req.
+func respond(req *http.Request, status int, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader("")), Request: req}
+}
+
+func TestTransport(t *testing.T) {
+	var hosts []string
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		return respond(req, http.StatusOK, nil), nil
+	})
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(1, 50*time.Millisecond) })
+	client := &http.Client{Transport: NewTransport(base, limiter)}
+
+	start := time.Now()
+	for _, url := range []string{"http://a.example/1", "http://b.example/1", "http://a.example/2"} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		resp.Body.Close()
+	}
+	// The second request to a.example waits for the first one to leak.
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("requests sent in %s, want a.example paced to one per 50ms", elapsed)
+	}
+	if len(hosts) != 3 {
+		t.Errorf("%d requests sent, want 3", len(hosts))
+	}
+
+	// Requests are not sent once their context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "http://a.example/3", nil)
+	if _, err := NewTransport(base, limiter, WithTransportReserve()).RoundTrip(req); err == nil || len(hosts) != 3 {
+		t.Errorf("request with a canceled context: error %v, %d requests sent, want it dropped", err, len(hosts))
+	}
+}
+
+func TestTransportKeyFunc(t *testing.T) {
+	var keys []string
+	limiter := limiterFunc(func(key string, requestTime time.Time, n int) Decision {
+		keys = append(keys, key)
+		return Decision{Allowed: true}
+	})
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) { return respond(req, http.StatusOK, nil), nil })
+	transport := NewTransport(base, limiter, WithTransportKeyFunc(KeyByHeader("X-Tenant")))
+	req := httptest.NewRequest(http.MethodGet, "http://a.example/", nil)
+	req.Header.Set("X-Tenant", "acme")
+	transport.RoundTrip(req)
+	if len(keys) != 1 || keys[0] != "acme" {
+		t.Errorf("keys %q, want acme", keys)
+	}
+}
+
+func TestTransportPushback(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status int
+		header http.Header
+	}{
+		{"Retry-After", http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}},
+		{"exhausted budget", http.StatusOK, http.Header{"X-Ratelimit-Limit": {"10"}, "X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"30"}}},
+	} {
+		sent := 0
+		base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent++
+			return respond(req, test.status, test.header), nil
+		})
+		limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })
+		transport := NewTransport(base, limiter)
+		transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://a.example/", nil))
+
+		// The next request would wait 30s for the server to reset.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := transport.RoundTrip(httptest.NewRequestWithContext(ctx, http.MethodGet, "http://a.example/", nil))
+		cancel()
+		if !errors.Is(err, ErrWaitExceedsDeadline) || sent != 1 {
+			t.Errorf("%s: error %v, %d requests sent, want the second one held back", test.name, err, sent)
+		}
+		if _, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://b.example/", nil)); err != nil {
+			t.Errorf("%s: request to another host: %v", test.name, err)
+		}
+	}
+}
+
+func TestTransportRetry(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewSlidingWindow(100, time.Minute) })
+	for _, test := range []struct {
+		name     string
+		method   string
+		header   http.Header
+		budget   *RetryBudget
+		attempts int
+		status   int
+	}{
+		{"GET", http.MethodGet, nil, NewRetryBudget(0, 10, time.Second), 3, http.StatusOK},
+		{"POST", http.MethodPost, nil, NewRetryBudget(0, 10, time.Second), 1, http.StatusServiceUnavailable},
+		{"POST with Idempotency-Key", http.MethodPost, http.Header{"Idempotency-Key": {"order-1"}}, NewRetryBudget(0, 10, time.Second), 3, http.StatusOK},
+		{"exhausted budget", http.MethodGet, nil, NewRetryBudget(0, 0, time.Second), 1, http.StatusServiceUnavailable},
+	} {
+		attempts := 0
+		base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts < 3 {
+				return respond(req, http.StatusServiceUnavailable, nil), nil
+			}
+			return respond(req, http.StatusOK, nil), nil
+		})
+		transport := NewTransport(base, limiter, WithRetry(test.budget, 2))
+		req := httptest.NewRequest(test.method, "http://a.example/", strings.NewReader("body"))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("body")), nil }
+		for name, values := range test.header {
+			req.Header[name] = values
+		}
+
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if attempts != test.attempts {
+			t.Errorf("%s: %d attempts, want %d", test.name, attempts, test.attempts)
+		}
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+	}
+}
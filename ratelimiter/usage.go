@@ -0,0 +1,210 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageCounts are the numbers of decisions of an interval.
+type UsageCounts struct {
+	Allowed int `json:"allowed"` // Number of allowed requests.
+	Denied  int `json:"denied"`  // Number of denied requests.
+}
+
+// UsageInterval aggregates the decisions of an interval.
+type UsageInterval struct {
+	Start       time.Time `json:"start"` // Start of the interval.
+	UsageCounts           // Decisions of the interval.
+}
+
+// UsageOption configures a UsageHistory.
+type UsageOption func(*UsageHistory)
+
+// WithUsageKeys also tracks the decisions of each key, for at most maxKeys keys
+// per slot so memory stays bounded. The decisions of the keys over the cap are
+// only counted in the totals.
+func WithUsageKeys(maxKeys int) UsageOption {
+	return func(u *UsageHistory) {
+		u.maxKeys = maxKeys
+	}
+}
+
+// UsageHistory keeps the number of allowed and denied requests per slot of
+// time, in a bounded ring of slots, to render traffic heatmaps.
+type UsageHistory struct {
+	mu      sync.Mutex
+	slot    time.Duration // The duration of the slots.
+	slots   []usageSlot   // The ring of slots, indexed by their start.
+	maxKeys int           // The maximum number of keys tracked per slot, zero to track none.
+}
+
+// usageSlot holds the decisions of a slot of time.
+type usageSlot struct {
+	start time.Time               // The start of the slot, zero if unused.
+	total UsageCounts             // The decisions of every key.
+	keys  map[string]*UsageCounts // Map to hold the decisions of each tracked key.
+}
+
+// NewUsageHistory creates a new usage history aggregating decisions per slot,
+// e.g. a minute, and keeping the last slots of them, e.g. 1440 for a day.
+func NewUsageHistory(slot time.Duration, slots int, opts ...UsageOption) *UsageHistory {
+	u := &UsageHistory{
+		slot:  slot,
+		slots: make([]usageSlot, max(slots, 1)),
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Observe counts the request for key decided at requestTime.
+func (u *UsageHistory) Observe(key string, requestTime time.Time, decision Decision) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	start := requestTime.Truncate(u.slot)
+	s := &u.slots[u.index(start)]
+	if !s.start.Equal(start) {
+		if start.Before(s.start) {
+			// The slot of the request already left the ring.
+			return
+		}
+		*s = usageSlot{start: start}
+	}
+	s.total.count(decision)
+
+	if u.maxKeys <= 0 {
+		return
+	}
+	counts, ok := s.keys[key]
+	if !ok {
+		if len(s.keys) >= u.maxKeys {
+			return
+		}
+		if s.keys == nil {
+			s.keys = make(map[string]*UsageCounts)
+		}
+		counts = &UsageCounts{}
+		s.keys[strings.Clone(key)] = counts
+	}
+	counts.count(decision)
+}
+
+// OnDecision returns a function counting the decisions of the middleware, for
+// use with WithOnDecision.
+func (u *UsageHistory) OnDecision() DecisionFunc {
+	return func(r *http.Request, key string, decision Decision) {
+		u.Observe(key, time.Now(), decision)
+	}
+}
+
+// Usage returns the decisions of every key per interval of resolution from
+// from to to, in chronological order. Intervals without requests, or older
+// than the ring, are zero. The resolution is rounded up to a multiple of the
+// slot duration.
+func (u *UsageHistory) Usage(from, to time.Time, resolution time.Duration) []UsageInterval {
+	return u.usage(from, to, resolution, func(s *usageSlot) UsageCounts {
+		return s.total
+	})
+}
+
+// KeyUsage returns the decisions of key like Usage, if the keys are tracked,
+// see WithUsageKeys.
+func (u *UsageHistory) KeyUsage(key string, from, to time.Time, resolution time.Duration) []UsageInterval {
+	return u.usage(from, to, resolution, func(s *usageSlot) UsageCounts {
+		if counts, ok := s.keys[key]; ok {
+			return *counts
+		}
+		return UsageCounts{}
+	})
+}
+
+// ServeHTTP serves the usage as JSON. The from and to query parameters are RFC
+// 3339 times, defaulting to the retention of the ring until now, resolution is
+// a duration such as "5m" defaulting to the slot duration, and key selects the
+// usage of a key.
+func (u *UsageHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	to, from := time.Now(), time.Time{}
+	resolution := u.slot
+	var err error
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("resolution"); v != "" {
+		if resolution, err = time.ParseDuration(v); err != nil {
+			http.Error(w, "invalid resolution: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var intervals []UsageInterval
+	if key := query.Get("key"); key != "" {
+		intervals = u.KeyUsage(key, from, to, resolution)
+	} else {
+		intervals = u.Usage(from, to, resolution)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(intervals)
+}
+
+// usage aggregates the counts returned by counts for the slots of each interval.
+func (u *UsageHistory) usage(from, to time.Time, resolution time.Duration, counts func(s *usageSlot) UsageCounts) []UsageInterval {
+	resolution = max((resolution+u.slot-1)/u.slot*u.slot, u.slot)
+	// Intervals older than the ring are not worth allocating.
+	oldest := to.Add(-time.Duration(len(u.slots)) * u.slot)
+	if from.Before(oldest) {
+		from = oldest
+	}
+	start := from.Truncate(resolution)
+	if !start.Before(to) {
+		return []UsageInterval{}
+	}
+
+	intervals := make([]UsageInterval, (to.Sub(start)+resolution-1)/resolution)
+	for i := range intervals {
+		intervals[i].Start = start.Add(time.Duration(i) * resolution)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for i := range u.slots {
+		s := &u.slots[i]
+		if s.start.IsZero() || s.start.Before(start) || !s.start.Before(to) {
+			continue
+		}
+		c := counts(s)
+		interval := &intervals[s.start.Sub(start)/resolution]
+		interval.Allowed += c.Allowed
+		interval.Denied += c.Denied
+	}
+	return intervals
+}
+
+// index returns the index in the ring of the slot starting at start.
+func (u *UsageHistory) index(start time.Time) int {
+	n := int64(len(u.slots))
+	return int(((start.UnixNano()/int64(u.slot))%n + n) % n)
+}
+
+// count counts a decision.
+func (c *UsageCounts) count(decision Decision) {
+	if decision.Allowed {
+		c.Allowed++
+	} else {
+		c.Denied++
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newUsage returns a usage history of ten one-minute slots tracking one key,
+// with a few decisions over the first minutes after the epoch.
+func newUsage() *UsageHistory {
+	u := NewUsageHistory(time.Minute, 10, WithUsageKeys(1))
+	u.Observe("a", epoch, Decision{Allowed: true})
+	u.Observe("b", epoch.Add(10*time.Second), Decision{})
+	u.Observe("a", epoch.Add(time.Minute), Decision{})
+	u.Observe("a", epoch.Add(3*time.Minute), Decision{Allowed: true})
+	return u
+}
+
+func TestUsage(t *testing.T) {
+	u := newUsage()
+	want := []UsageInterval{
+		{Start: epoch, UsageCounts: UsageCounts{Allowed: 1, Denied: 2}},
+		{Start: epoch.Add(2 * time.Minute), UsageCounts: UsageCounts{Allowed: 1}},
+	}
+	if got := u.Usage(epoch, epoch.Add(4*time.Minute), 2*time.Minute); !slices.Equal(got, want) {
+		t.Errorf("usage %+v, want %+v", got, want)
+	}
+	// The keys over the cap are only counted in the totals.
+	want = []UsageInterval{
+		{Start: epoch, UsageCounts: UsageCounts{Allowed: 1, Denied: 1}},
+		{Start: epoch.Add(2 * time.Minute), UsageCounts: UsageCounts{Allowed: 1}},
+	}
+	if got := u.KeyUsage("a", epoch, epoch.Add(4*time.Minute), 90*time.Second); !slices.Equal(got, want) {
+		t.Errorf("usage of a %+v, want %+v", got, want)
+	}
+	if got := u.KeyUsage("b", epoch, epoch.Add(time.Minute), time.Minute); got[0].UsageCounts != (UsageCounts{}) {
+		t.Errorf("usage of b %+v, want none", got)
+	}
+}
+
+func TestUsageRing(t *testing.T) {
+	u := newUsage()
+	u.Observe("a", epoch.Add(10*time.Minute), Decision{Allowed: true})
+	// The slot of the epoch was reused, so its late requests are dropped.
+	u.Observe("a", epoch, Decision{Allowed: true})
+
+	got := u.Usage(epoch, epoch.Add(11*time.Minute), time.Minute)
+	if len(got) != 10 || !got[0].Start.Equal(epoch.Add(time.Minute)) {
+		t.Fatalf("%d intervals from %s, want the 10 of the ring", len(got), got[0].Start)
+	}
+	if got[0].UsageCounts != (UsageCounts{Denied: 1}) || got[9].UsageCounts != (UsageCounts{Allowed: 1}) {
+		t.Errorf("usage %+v, want the requests of minutes 1 and 10", got)
+	}
+}
+
+func TestUsageServeHTTP(t *testing.T) {
+	u := newUsage()
+	w := httptest.NewRecorder()
+	u.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?key=a&resolution=2m&from=2024-05-01T12:00:00Z&to=2024-05-01T12:04:00Z", nil))
+	var got []UsageInterval
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body %q: %v", w.Body, err)
+	}
+	if len(got) != 2 || got[0].UsageCounts != (UsageCounts{Allowed: 1, Denied: 1}) {
+		t.Errorf("usage of a %+v, want 2 intervals", got)
+	}
+
+	w = httptest.NewRecorder()
+	u.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?resolution=soon", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid resolution: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWaitExceedsDeadline is returned by Wait when the request would not be
+// allowed before the deadline of the context.
+var ErrWaitExceedsDeadline = errors.New("ratelimiter: wait would exceed context deadline")
+
+// Reserver is implemented by limiters able to account for a request ahead of
+// time, letting callers pace themselves instead of retrying.
+type Reserver interface {
+	// Reserve accounts for a new request for key at requestTime even if the
+	// window is full, and returns how long the caller must wait before sending it.
+	Reserve(key string, requestTime time.Time) time.Duration
+}
+
+// Wait blocks until limiter allows a request for key, or until ctx is done.
+// It fails early with ErrWaitExceedsDeadline when the request would not be
+// allowed before the deadline of ctx.
+func Wait(ctx context.Context, limiter Limiter, key string) error {
+	for {
+		now := time.Now()
+		decision := limiter.Allow(key, now)
+		if decision.Allowed {
+			return nil
+		}
+		if err := sleep(ctx, now, decision.RetryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// WaitReservation reserves a request for key with reserver and blocks until it
+// is due, or until ctx is done. The reservation is kept even if the wait is
+// cut short.
+func WaitReservation(ctx context.Context, reserver Reserver, key string) error {
+	now := time.Now()
+	delay := reserver.Reserve(key, now)
+	if delay <= 0 {
+		return nil
+	}
+	return sleep(ctx, now, delay)
+}
+
+// sleep waits for delay counted from now, or until ctx is done.
+func sleep(ctx context.Context, now time.Time, delay time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		return ErrWaitExceedsDeadline
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
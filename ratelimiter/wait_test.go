@@ -0,0 +1,55 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(1, 50*time.Millisecond) })
+	ctx := context.Background()
+	start := time.Now()
+	for i := range 2 {
+		if err := Wait(ctx, limiter, "a"); err != nil {
+			t.Fatalf("wait %d: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second request allowed after %s, want about 50ms", elapsed)
+	}
+
+	// Waits past the deadline fail early.
+	limiter = NewKeyed(func() Algorithm { return NewSlidingWindow(1, time.Hour) })
+	limiter.Allow("a", time.Now())
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	start = time.Now()
+	if err := Wait(ctx, limiter, "a"); !errors.Is(err, ErrWaitExceedsDeadline) || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("wait of an hour: error %v after %s, want %v at once", err, time.Since(start), ErrWaitExceedsDeadline)
+	}
+}
+
+func TestWaitReservation(t *testing.T) {
+	limiter := NewKeyed(func() Algorithm { return NewLeakyBucket(1, 50*time.Millisecond) })
+	start := time.Now()
+	for i := range 2 {
+		if err := WaitReservation(context.Background(), limiter, "a"); err != nil {
+			t.Fatalf("reservation %d: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second reservation due after %s, want about 50ms", elapsed)
+	}
+
+	// The reservation is kept when the wait is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WaitReservation(ctx, limiter, "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled reservation: error %v, want %v", err, context.Canceled)
+	}
+	if delay := limiter.Reserve("a", time.Now()); delay < 60*time.Millisecond {
+		t.Errorf("next reservation due in %s, want after the canceled one", delay)
+	}
+}
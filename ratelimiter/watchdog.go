@@ -0,0 +1,181 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alert reports a sustained denial rate.
+type Alert struct {
+	Key       string    // Key whose requests are denied, empty for the requests of every key.
+	Time      time.Time // End of the last interval over the threshold.
+	Intervals int       // Number of consecutive intervals over the threshold.
+	Allowed   int       // Number of allowed requests of the last interval.
+	Denied    int       // Number of denied requests of the last interval.
+	Ratio     float64   // Share of denied requests of the last interval.
+}
+
+// WatchdogOption configures a Watchdog.
+type WatchdogOption func(*Watchdog)
+
+// WithWatchdogKeys also watches the denial rate of every key, not only the
+// rate of all the requests.
+func WithWatchdogKeys() WatchdogOption {
+	return func(w *Watchdog) {
+		w.perKey = true
+	}
+}
+
+// WithMinRequests ignores the intervals with fewer than n requests, so a
+// handful of denials of a quiet key or service does not page anybody. It
+// defaults to 10.
+func WithMinRequests(n int) WatchdogOption {
+	return func(w *Watchdog) {
+		w.minRequests = n
+	}
+}
+
+// Watchdog calls a function when the share of denied requests stays over a
+// threshold for a number of consecutive intervals, globally or for a key, so
+// abuse or a misconfigured client can trigger paging. It fires once when the
+// streak reaches the number of intervals, and again only after the rate went
+// back under the threshold.
+type Watchdog struct {
+	mu          sync.Mutex
+	threshold   float64                    // The share of denied requests over which an interval counts.
+	intervals   int                        // The number of consecutive intervals over the threshold firing an alert.
+	interval    time.Duration              // The duration of the intervals.
+	onAlert     func(Alert)                // The function notified of the alerts.
+	perKey      bool                       // Whether to watch every key.
+	minRequests int                        // The number of requests under which intervals are ignored.
+	start       time.Time                  // The start of the current interval.
+	global      watchdogCounts             // The counts of all the requests.
+	keys        map[string]*watchdogCounts // Map to hold the counts of each key.
+}
+
+// watchdogCounts are the decisions of the current interval, and the streak of
+// intervals over the threshold.
+type watchdogCounts struct {
+	allowed int // Number of allowed requests in the current interval.
+	denied  int // Number of denied requests in the current interval.
+	streak  int // Number of consecutive intervals over the threshold.
+}
+
+// NewWatchdog creates a new watchdog calling onAlert when the share of denied
+// requests exceeds threshold, e.g. 0.5, for intervals consecutive intervals.
+// onAlert is called from the goroutine deciding the first request of the next
+// interval, so it should not block.
+func NewWatchdog(threshold float64, intervals int, interval time.Duration, onAlert func(Alert), opts ...WatchdogOption) *Watchdog {
+	w := &Watchdog{
+		threshold:   threshold,
+		intervals:   max(intervals, 1),
+		interval:    interval,
+		onAlert:     onAlert,
+		minRequests: 10,
+		keys:        make(map[string]*watchdogCounts),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Observe counts the request for key decided at requestTime.
+func (w *Watchdog) Observe(key string, requestTime time.Time, decision Decision) {
+	alerts := w.observe(key, requestTime, decision)
+	for _, alert := range alerts {
+		w.onAlert(alert)
+	}
+}
+
+// OnDecision returns a function counting the decisions of the middleware, for
+// use with WithOnDecision.
+func (w *Watchdog) OnDecision() DecisionFunc {
+	return func(r *http.Request, key string, decision Decision) {
+		w.Observe(key, time.Now(), decision)
+	}
+}
+
+// observe counts the request, and returns the alerts of the intervals it ends.
+func (w *Watchdog) observe(key string, requestTime time.Time, decision Decision) []Alert {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	alerts := w.advance(requestTime)
+	w.global.count(decision)
+	if w.perKey {
+		counts, ok := w.keys[key]
+		if !ok {
+			counts = &watchdogCounts{}
+			w.keys[strings.Clone(key)] = counts
+		}
+		counts.count(decision)
+	}
+	return alerts
+}
+
+// advance evaluates the current interval if it is over at now, and starts a
+// new one. It must be called with mu held.
+func (w *Watchdog) advance(now time.Time) []Alert {
+	if w.start.IsZero() {
+		w.start = now.Truncate(w.interval)
+		return nil
+	}
+	if now.Sub(w.start) < w.interval {
+		return nil
+	}
+
+	end := w.start.Add(w.interval)
+	// Streaks do not survive an interval without requests.
+	gap := now.Sub(w.start) >= 2*w.interval
+	w.start = now.Truncate(w.interval)
+
+	var alerts []Alert
+	if alert, ok := w.evaluate("", &w.global, end); ok {
+		alerts = append(alerts, alert)
+	}
+	if gap {
+		w.global.streak = 0
+	}
+	for key, counts := range w.keys {
+		if alert, ok := w.evaluate(key, counts, end); ok {
+			alerts = append(alerts, alert)
+		}
+		if gap || counts.streak == 0 {
+			delete(w.keys, key)
+		}
+	}
+	return alerts
+}
+
+// evaluate updates the streak of counts with the interval ending at end, and
+// resets the counts. It returns an alert if the streak reached the number of
+// intervals.
+func (w *Watchdog) evaluate(key string, counts *watchdogCounts, end time.Time) (Alert, bool) {
+	total := counts.allowed + counts.denied
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(counts.denied) / float64(total)
+	}
+	alert := Alert{Key: key, Time: end, Allowed: counts.allowed, Denied: counts.denied, Ratio: ratio}
+	counts.allowed, counts.denied = 0, 0
+
+	if total < w.minRequests || ratio <= w.threshold {
+		counts.streak = 0
+		return Alert{}, false
+	}
+	counts.streak++
+	alert.Intervals = counts.streak
+	return alert, counts.streak == w.intervals
+}
+
+// count counts a decision.
+func (c *watchdogCounts) count(decision Decision) {
+	if decision.Allowed {
+		c.allowed++
+	} else {
+		c.denied++
+	}
+}
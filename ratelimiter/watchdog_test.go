@@ -0,0 +1,64 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+// observeInterval makes w observe allowed then denied requests of key in the
+// i-th minute after the epoch.
+func observeInterval(w *Watchdog, i int, key string, allowed, denied int) {
+	at := epoch.Add(time.Duration(i) * time.Minute)
+	for range allowed {
+		w.Observe(key, at, Decision{Allowed: true})
+	}
+	for range denied {
+		w.Observe(key, at, Decision{})
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	var alerts []Alert
+	w := NewWatchdog(0.5, 2, time.Minute, func(alert Alert) { alerts = append(alerts, alert) }, WithMinRequests(2))
+	for i, counts := range [][2]int{{1, 3}, {0, 3}, {0, 3}, {3, 0}, {0, 3}, {0, 3}, {1, 0}} {
+		observeInterval(w, i, "a", counts[0], counts[1])
+	}
+
+	// The alert fires when the streak reaches two intervals, and again once
+	// the rate went back under the threshold.
+	if len(alerts) != 2 {
+		t.Fatalf("%d alerts %+v, want 2", len(alerts), alerts)
+	}
+	want := Alert{Time: epoch.Add(2 * time.Minute), Intervals: 2, Denied: 3, Ratio: 1}
+	if alerts[0] != want {
+		t.Errorf("first alert %+v, want %+v", alerts[0], want)
+	}
+	if !alerts[1].Time.Equal(epoch.Add(6 * time.Minute)) {
+		t.Errorf("second alert at %s, want the end of the sixth interval", alerts[1].Time)
+	}
+}
+
+func TestWatchdogGap(t *testing.T) {
+	var alerts []Alert
+	w := NewWatchdog(0.5, 2, time.Minute, func(alert Alert) { alerts = append(alerts, alert) }, WithMinRequests(2))
+	observeInterval(w, 0, "a", 0, 3)
+	observeInterval(w, 2, "a", 0, 3)
+	observeInterval(w, 3, "a", 1, 0)
+	if len(alerts) != 0 {
+		t.Errorf("alerts %+v across an interval without requests, want none", alerts)
+	}
+}
+
+func TestWatchdogKeys(t *testing.T) {
+	var alerts []Alert
+	w := NewWatchdog(0.5, 1, time.Minute, func(alert Alert) { alerts = append(alerts, alert) }, WithWatchdogKeys(), WithMinRequests(2))
+	observeInterval(w, 0, "bad", 0, 3)
+	observeInterval(w, 0, "good", 5, 0)
+	observeInterval(w, 0, "quiet", 0, 1)
+	observeInterval(w, 1, "good", 1, 0)
+
+	// Only the key over the threshold with enough requests alerts.
+	if len(alerts) != 1 || alerts[0].Key != "bad" || alerts[0].Denied != 3 {
+		t.Errorf("alerts %+v, want bad only", alerts)
+	}
+}
@@ -0,0 +1,302 @@
+// Package webhook queues outbound webhooks and delivers them to each
+// destination endpoint under its own rate, so a burst of events never floods
+// the servers of a subscriber.
+//
+//	d := webhook.NewDispatcher(deliver, webhook.WithRate(10, time.Second))
+//	defer d.Close()
+//	d.Enqueue(webhook.Webhook{Endpoint: url, Payload: body})
+package webhook
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/minhpq331/ratelimiter-example/ratelimiter"
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue, and passed to the dead-letter
+	// callback, for webhooks dropped because their endpoint queue is full.
+	ErrQueueFull = errors.New("webhook: endpoint queue is full")
+
+	// ErrClosed is returned by Enqueue after Close, and passed to the
+	// dead-letter callback for the webhooks still queued when it was called.
+	ErrClosed = errors.New("webhook: dispatcher is closed")
+)
+
+// Webhook is an outbound webhook.
+type Webhook struct {
+	Endpoint    string // Destination URL of the webhook, each endpoint has its own queue and rate.
+	CoalesceKey string // Key of the queued webhook this one supersedes, empty to never coalesce.
+	Payload     []byte // Body of the webhook.
+}
+
+// DeliverFunc sends a webhook to its endpoint.
+type DeliverFunc func(ctx context.Context, w Webhook) error
+
+// Overflow is the policy applied when a webhook is enqueued to a full queue.
+type Overflow int
+
+const (
+	// DropNewest rejects the webhook being enqueued.
+	DropNewest Overflow = iota
+	// DropOldest drops the oldest queued webhook to make room for the new one.
+	DropOldest
+)
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithRate delivers at most n webhooks per window to each endpoint, spaced
+// evenly. It defaults to 1 webhook per second.
+func WithRate(n int, window time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.rate = rate{n, window}
+	}
+}
+
+// WithEndpointRate delivers at most n webhooks per window to endpoint,
+// overriding the rate set by WithRate.
+func WithEndpointRate(endpoint string, n int, window time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.endpointRates[endpoint] = rate{n, window}
+	}
+}
+
+// WithQueueSize sets the number of webhooks each endpoint queue holds. It
+// defaults to 100.
+func WithQueueSize(size int) Option {
+	return func(d *Dispatcher) {
+		d.queueSize = size
+	}
+}
+
+// WithOverflow sets the policy applied to full queues. It defaults to DropNewest.
+func WithOverflow(overflow Overflow) Option {
+	return func(d *Dispatcher) {
+		d.overflow = overflow
+	}
+}
+
+// WithDeadLetter sets a callback receiving the webhooks that will never be
+// delivered: those dropped on overflow or on Close, and those whose delivery
+// failed, along with the reason. It must not call the dispatcher.
+func WithDeadLetter(deadLetter func(w Webhook, err error)) Option {
+	return func(d *Dispatcher) {
+		d.deadLetter = deadLetter
+	}
+}
+
+// WithIdleTimeout stops the worker of an endpoint, and forgets its queue, once
+// nothing was queued for it for d, or for the window of its rate if longer, so
+// the budget of its pacer is whole again by then. It defaults to one minute.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.idleTimeout = d
+	}
+}
+
+// rate is a number of deliveries per window.
+type rate struct {
+	n      int
+	window time.Duration
+}
+
+// Dispatcher queues webhooks and delivers them per endpoint under its rate.
+type Dispatcher struct {
+	deliver       DeliverFunc                // The function sending webhooks.
+	rate          rate                       // The default rate of an endpoint.
+	endpointRates map[string]rate            // Map to hold the rate of specific endpoints.
+	queueSize     int                        // The number of webhooks each queue holds.
+	overflow      Overflow                   // The policy applied to full queues.
+	deadLetter    func(w Webhook, err error) // The callback receiving undeliverable webhooks.
+	idleTimeout   time.Duration              // How long the queue of an endpoint stays empty before its worker stops.
+
+	ctx    context.Context    // Context of the deliveries, done once the dispatcher is closed.
+	cancel context.CancelFunc // Cancels ctx.
+	wg     sync.WaitGroup     // Tracks the endpoint workers.
+
+	mu     sync.Mutex           // Protects queues and closed.
+	queues map[string]*endpoint // Map to hold the queue of each endpoint.
+	closed bool                 // Whether Close was called.
+}
+
+// endpoint is the queue of an endpoint, drained by its own worker.
+type endpoint struct {
+	pending []Webhook          // Queued webhooks, oldest first.
+	wake    chan struct{}      // Signals the worker that webhooks were queued.
+	pacer   *ratelimiter.Pacer // Paces the deliveries to the endpoint.
+	idle    time.Duration      // How long the queue stays empty before the worker stops.
+}
+
+// NewDispatcher creates a new dispatcher sending webhooks with deliver.
+func NewDispatcher(deliver DeliverFunc, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		deliver:       deliver,
+		rate:          rate{1, time.Second},
+		endpointRates: make(map[string]rate),
+		queueSize:     100,
+		deadLetter:    func(Webhook, error) {},
+		idleTimeout:   time.Minute,
+		queues:        make(map[string]*endpoint),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	return d
+}
+
+// Enqueue queues w for delivery to its endpoint. A queued webhook with the same
+// coalesce key is replaced in place, keeping its position in the queue. When the
+// queue is full, the overflow policy decides which webhook is dropped.
+func (d *Dispatcher) Enqueue(w Webhook) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+	e := d.endpoint(w.Endpoint)
+
+	if w.CoalesceKey != "" {
+		for i := range e.pending {
+			if e.pending[i].CoalesceKey == w.CoalesceKey {
+				e.pending[i] = w
+				return nil
+			}
+		}
+	}
+
+	if len(e.pending) >= d.queueSize {
+		if d.overflow != DropOldest || len(e.pending) == 0 {
+			d.deadLetter(w, ErrQueueFull)
+			return ErrQueueFull
+		}
+		d.deadLetter(e.pending[0], ErrQueueFull)
+		e.pending = e.pending[1:]
+	}
+	e.pending = append(e.pending, w)
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the number of webhooks queued for endpoint.
+func (d *Dispatcher) Pending(endpoint string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.queues[endpoint]; ok {
+		return len(e.pending)
+	}
+	return 0
+}
+
+// Close stops the deliveries and waits for the ongoing ones to return. The
+// webhooks still queued are passed to the dead-letter callback.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	d.cancel()
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.queues {
+		for _, w := range e.pending {
+			d.deadLetter(w, ErrClosed)
+		}
+		e.pending = nil
+	}
+}
+
+// endpoint returns the queue of url, starting its worker on first use or after
+// it stopped idle. It must be called with mu held.
+func (d *Dispatcher) endpoint(url string) *endpoint {
+	if e, ok := d.queues[url]; ok {
+		return e
+	}
+
+	r, ok := d.endpointRates[url]
+	if !ok {
+		r = d.rate
+	}
+	e := &endpoint{
+		wake:  make(chan struct{}, 1),
+		pacer: ratelimiter.NewPacer(r.n, r.window),
+		idle:  max(d.idleTimeout, r.window),
+	}
+	d.queues[url] = e
+
+	d.wg.Add(1)
+	go d.work(url, e)
+	return e
+}
+
+// work delivers the webhooks queued in e until the dispatcher is closed, or e
+// stays empty for its idle time.
+func (d *Dispatcher) work(url string, e *endpoint) {
+	defer d.wg.Done()
+
+	idle := time.NewTimer(e.idle)
+	defer idle.Stop()
+	for {
+		select {
+		case <-e.wake:
+		case <-idle.C:
+			if d.stopIdle(url, e) {
+				return
+			}
+			idle.Reset(e.idle)
+			continue
+		case <-d.ctx.Done():
+			return
+		}
+
+		for d.pending(e) > 0 {
+			if err := e.pacer.Wait(d.ctx); err != nil {
+				return
+			}
+
+			// Pop after the wait so webhooks coalesced meanwhile are sent in
+			// their latest version.
+			d.mu.Lock()
+			w := e.pending[0]
+			e.pending = e.pending[1:]
+			d.mu.Unlock()
+
+			if err := d.deliver(d.ctx, w); err != nil {
+				d.deadLetter(w, err)
+			}
+		}
+		idle.Reset(e.idle)
+	}
+}
+
+// stopIdle removes e from the queues if it is still empty, the next webhook of
+// url starting a new worker, and reports whether it did.
+func (d *Dispatcher) stopIdle(url string, e *endpoint) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(e.pending) > 0 {
+		return false
+	}
+	delete(d.queues, url)
+	return true
+}
+
+// pending returns the number of webhooks queued in e.
+func (d *Dispatcher) pending(e *endpoint) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(e.pending)
+}
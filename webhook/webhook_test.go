@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the webhooks delivered and dead-lettered by a dispatcher.
+type recorder struct {
+	mu         sync.Mutex
+	delivered  []string        // Payloads of the delivered webhooks.
+	dead       []string        // Payloads of the dead-lettered webhooks.
+	deadErrs   []error         // Reasons the webhooks were dead-lettered.
+	deliveries chan struct{}   // Receives a value on every delivery.
+	fail       map[string]bool // Payloads whose delivery fails.
+}
+
+func newRecorder() *recorder {
+	return &recorder{deliveries: make(chan struct{}, 100), fail: make(map[string]bool)}
+}
+
+func (r *recorder) deliver(ctx context.Context, w Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delivered = append(r.delivered, string(w.Payload))
+	r.deliveries <- struct{}{}
+	if r.fail[string(w.Payload)] {
+		return errors.New("endpoint unavailable")
+	}
+	return nil
+}
+
+func (r *recorder) deadLetter(w Webhook, err error) {
+	r.dead = append(r.dead, string(w.Payload))
+	r.deadErrs = append(r.deadErrs, err)
+}
+
+// waitDeliveries waits for n deliveries.
+func (r *recorder) waitDeliveries(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		select {
+		case <-r.deliveries:
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook not delivered")
+		}
+	}
+}
+
+func enqueue(t *testing.T, d *Dispatcher, endpoint string, payloads ...string) {
+	t.Helper()
+	for _, payload := range payloads {
+		if err := d.Enqueue(Webhook{Endpoint: endpoint, Payload: []byte(payload)}); err != nil {
+			t.Fatalf("Enqueue %s: %v", payload, err)
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	tests := []struct {
+		overflow Overflow
+		dropped  string
+		closed   []string
+	}{
+		{DropNewest, "d", []string{"b", "c"}},
+		{DropOldest, "b", []string{"c", "d"}},
+	}
+	for _, test := range tests {
+		r := newRecorder()
+		d := NewDispatcher(r.deliver, WithRate(1, time.Hour), WithQueueSize(2), WithOverflow(test.overflow), WithDeadLetter(r.deadLetter))
+		// The first webhook is sent right away, the next ones wait for an hour.
+		enqueue(t, d, "https://example.com", "a")
+		r.waitDeliveries(t, 1)
+		enqueue(t, d, "https://example.com", "b", "c")
+		err := d.Enqueue(Webhook{Endpoint: "https://example.com", Payload: []byte("d")})
+		if test.overflow == DropNewest && !errors.Is(err, ErrQueueFull) {
+			t.Errorf("overflow %d: error %v, want %v", test.overflow, err, ErrQueueFull)
+		}
+
+		d.Close()
+		if !slices.Equal(r.dead, append([]string{test.dropped}, test.closed...)) {
+			t.Errorf("overflow %d: dead letters %v, want %s dropped then %v closed", test.overflow, r.dead, test.dropped, test.closed)
+		}
+		if !errors.Is(r.deadErrs[0], ErrQueueFull) || !errors.Is(r.deadErrs[1], ErrClosed) {
+			t.Errorf("overflow %d: dead letter reasons %v", test.overflow, r.deadErrs)
+		}
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	r := newRecorder()
+	d := NewDispatcher(r.deliver, WithRate(1, time.Hour), WithDeadLetter(r.deadLetter))
+	enqueue(t, d, "https://example.com", "first")
+	r.waitDeliveries(t, 1)
+
+	for _, payload := range []string{"v1", "other", "v2"} {
+		key := "order-1"
+		if payload == "other" {
+			key = ""
+		}
+		d.Enqueue(Webhook{Endpoint: "https://example.com", CoalesceKey: key, Payload: []byte(payload)})
+	}
+	if pending := d.Pending("https://example.com"); pending != 2 {
+		t.Errorf("%d webhooks pending, want 2", pending)
+	}
+	d.Close()
+	// The latest version keeps the position of the one it replaced.
+	if !slices.Equal(r.dead, []string{"v2", "other"}) {
+		t.Errorf("queued webhooks %v, want [v2 other]", r.dead)
+	}
+	if err := d.Enqueue(Webhook{Endpoint: "https://example.com"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Enqueue after Close: error %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestEndpointRates(t *testing.T) {
+	r := newRecorder()
+	r.fail["failing"] = true
+	d := NewDispatcher(r.deliver, WithRate(100, time.Second), WithEndpointRate("https://slow.example.com", 1, time.Hour), WithDeadLetter(r.deadLetter))
+	defer d.Close()
+
+	enqueue(t, d, "https://slow.example.com", "slow-1", "slow-2")
+	enqueue(t, d, "https://fast.example.com", "fast-1", "failing", "fast-2")
+	r.waitDeliveries(t, 4)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.Contains(r.delivered, "slow-2") {
+		t.Errorf("delivered %v, want the slow endpoint held to its rate", r.delivered)
+	}
+	if !slices.Equal(r.dead, []string{"failing"}) {
+		t.Errorf("dead letters %v, want the failed delivery", r.dead)
+	}
+}
+
+func TestIdleEndpointsStopped(t *testing.T) {
+	r := newRecorder()
+	d := NewDispatcher(r.deliver, WithRate(100, 10*time.Millisecond), WithIdleTimeout(10*time.Millisecond))
+	defer d.Close()
+
+	enqueue(t, d, "https://example.com", "first")
+	r.waitDeliveries(t, 1)
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		d.mu.Lock()
+		endpoints := len(d.queues)
+		d.mu.Unlock()
+		if endpoints == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d endpoints still queued after their idle timeout", endpoints)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The next webhook of the endpoint starts a new worker.
+	enqueue(t, d, "https://example.com", "second")
+	r.waitDeliveries(t, 1)
+}